@@ -0,0 +1,208 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ModelFileExtensions are the extensions of the files that LoadModel accepts, and that are found in directories.
+var ModelFileExtensions = []string{".smithy", ".json", ".yaml", ".yml", ".sadl", ".zip", ".jar"}
+
+// LoadModel loads a model file of any of the supported kinds, determined by its extension (and for JSON and YAML,
+// whether it is an OpenAPI document).
+func LoadModel(path string) (*AST, error) {
+	return LoadModelContext(context.Background(), path)
+}
+
+// LoadModelContext loads a model file as LoadModel does, stopping with the error of the context if it is cancelled.
+// IDL files, and archives of them, are checked as they are parsed, other kinds only before they are loaded. IDL files
+// are parsed with the options.
+func LoadModelContext(ctx context.Context, path string, opts ...ParserOption) (*AST, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	switch ext {
+	case ".json", ".yaml", ".yml":
+		if IsOpenAPI(path) {
+			return LoadOpenAPI(path)
+		}
+		return LoadAST(path)
+	case ".smithy":
+		return Parse(path, append([]ParserOption{WithContext(ctx)}, opts...)...)
+	case ".sadl":
+		return ParseSadl(path)
+	case ".zip", ".jar":
+		return loadArchive(ctx, path, opts...)
+	}
+	return nil, fmt.Errorf("parse for file type %q not implemented", ext)
+}
+
+// loadModelBytes loads the content of a model file that has already been read, as LoadModelContext loads the file.
+func loadModelBytes(ctx context.Context, path string, b []byte, opts ...ParserOption) (*AST, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	switch ext {
+	case ".json", ".yaml", ".yml":
+		if isOpenAPIDocument(b) {
+			return loadOpenAPIBytes(path, b)
+		}
+		return loadAST(path, b)
+	case ".smithy":
+		return ParseString(string(b), path, append([]ParserOption{WithContext(ctx)}, opts...)...)
+	case ".sadl":
+		return parseSadlString(path, string(b))
+	case ".zip", ".jar":
+		r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, fmt.Errorf("Cannot open model archive: %v", err)
+		}
+		return loadZip(ctx, path, r, opts...)
+	}
+	return nil, fmt.Errorf("parse for file type %q not implemented", ext)
+}
+
+// ExpandPaths replaces each directory in the list with the model files found beneath it.
+func ExpandPaths(paths []string) ([]string, error) {
+	var result []string
+	for _, path := range paths {
+		ext := filepath.Ext(path)
+		if containsString(ModelFileExtensions, ext) {
+			result = append(result, path)
+		} else {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if fi.IsDir() {
+				err = filepath.Walk(path, func(wpath string, info os.FileInfo, errIncoming error) error {
+					if errIncoming != nil {
+						return errIncoming
+					}
+					if !info.IsDir() && containsString(ModelFileExtensions, filepath.Ext(wpath)) {
+						result = append(result, wpath)
+					}
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// Assembler assembles a model from files, keeping the result of loading each file so that on subsequent calls to
+// Assemble only the files whose content has changed are loaded again. The files are merged with the MergeOptions,
+// if they are set. With StrictTraits, it is an error for the model to apply a trait that it does not define (see
+// AST.CheckTraitsDefined). IDL files are parsed with the ParserOptions, and the files loaded are reported to the
+// Logger, if it is set. The zero value is ready to use.
+type Assembler struct {
+	MergeOptions  *MergeOptions
+	StrictTraits  bool
+	ParserOptions []ParserOption
+	Logger        Logger
+
+	files map[string]*assembledFile
+}
+
+type assembledFile struct {
+	hash [sha256.Size]byte
+	ast  *AST
+}
+
+func NewAssembler() *Assembler {
+	return &Assembler{
+		files: make(map[string]*assembledFile, 0),
+	}
+}
+
+// Assemble loads and merges the model files, and the model files in the directories, in the list of paths.
+func (a *Assembler) Assemble(paths []string) (*AST, error) {
+	return a.AssembleContext(context.Background(), paths)
+}
+
+// AssembleContext assembles the model as Assemble does, stopping with the error of the context if it is cancelled or
+// its deadline passes first.
+func (a *Assembler) AssembleContext(ctx context.Context, paths []string) (*AST, error) {
+	files, err := ExpandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	assembly := &AST{
+		Smithy: "1.0",
+	}
+	seen := make(map[string]bool, 0)
+	for _, path := range files {
+		ast, err := a.load(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		seen[path] = true
+		//merged as a copy, since merging changes the shapes, i.e. by applying traits, and the cached one must not
+		err = assembly.MergeWithOptions(ast.Clone(), a.MergeOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for path := range a.files {
+		if !seen[path] {
+			delete(a.files, path)
+		}
+	}
+	if a.StrictTraits {
+		err = assembly.CheckTraitsDefined()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return assembly, nil
+}
+
+func (a *Assembler) load(ctx context.Context, path string) (*AST, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(b)
+	if a.files == nil {
+		a.files = make(map[string]*assembledFile, 0)
+	}
+	if f, ok := a.files[path]; ok && f.hash == hash {
+		return f.ast, nil
+	}
+	logger := orNopLogger(a.Logger)
+	ast, err := loadModelBytes(ctx, path, b, a.ParserOptions...)
+	if err != nil {
+		logger.Debug("Cannot load model file", "path", path, "error", err)
+		delete(a.files, path)
+		return nil, err
+	}
+	logger.Debug("Loaded model file", "path", path, "shapes", ast.Shapes.Length())
+	a.files[path] = &assembledFile{hash: hash, ast: ast}
+	return ast, nil
+}
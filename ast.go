@@ -16,11 +16,16 @@ limitations under the License.
 package smithy
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/boynton/data"
 )
@@ -32,6 +37,11 @@ type AST struct {
 	Smithy   string       `json:"smithy"`
 	Metadata *data.Object `json:"metadata,omitempty"`
 	Shapes   *Shapes      `json:"shapes,omitempty"`
+
+	warnings []*Warning
+	applied  *data.Object        //traits of "apply" statements whose shape or member is not defined yet, by id
+	index    *Index              //built when first needed, and reset when the model is changed
+	uses     map[string][]string //the ids imported by the use statements of the IDL parsed, by namespace
 }
 
 func (ast *AST) AssemblyVersion() int {
@@ -41,10 +51,98 @@ func (ast *AST) AssemblyVersion() int {
 	return 2
 }
 
+// WriteJSON writes the JSON AST of the model, as data.Pretty formats it, a shape at a time, so that the whole of it is
+// never held in memory at once.
+func (ast *AST) WriteJSON(out io.Writer) error {
+	return ast.writeJSON(out, "  ")
+}
+
+// WriteCompactJSON writes the JSON AST of the model as WriteJSON does, but without any whitespace.
+func (ast *AST) WriteCompactJSON(out io.Writer) error {
+	return ast.writeJSON(out, "")
+}
+
+func (ast *AST) writeJSON(out io.Writer, indent string) error {
+	nl, colon := "", ":"
+	if indent != "" {
+		nl, colon = "\n", ": "
+	}
+	w := bufio.NewWriter(out)
+	var err error
+	encode := func(prefix string, v interface{}) {
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if indent != "" {
+			enc.SetIndent(prefix, indent)
+		}
+		if err = enc.Encode(v); err == nil {
+			w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+		}
+	}
+	w.WriteString("{" + nl + indent + "\"smithy\"" + colon)
+	encode(indent, ast.Smithy)
+	if ast.Metadata != nil {
+		w.WriteString("," + nl + indent + "\"metadata\"" + colon)
+		encode(indent, ast.Metadata)
+	}
+	if ast.Shapes != nil {
+		w.WriteString("," + nl + indent + "\"shapes\"" + colon + "{")
+		for i, k := range ast.Shapes.Keys() {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			w.WriteString(nl + indent + indent)
+			encode(indent+indent, k)
+			w.WriteString(colon)
+			encode(indent+indent, ast.GetShape(k))
+		}
+		if ast.Shapes.Length() > 0 {
+			w.WriteString(nl + indent)
+		}
+		w.WriteString("}")
+	}
+	w.WriteString(nl + "}\n")
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Uses returns the ids that the use statements of the IDL files the model was parsed from imported into the
+// namespace, in order, so that the IDL written for it can import the same ones. They are not part of the JSON AST.
+func (ast *AST) Uses(ns string) []string {
+	return ast.uses[ns]
+}
+
+func (ast *AST) addUse(ns string, id string) {
+	if containsString(ast.uses[ns], id) {
+		return
+	}
+	if ast.uses == nil {
+		ast.uses = make(map[string][]string, 0)
+	}
+	ast.uses[ns] = append(ast.uses[ns], id)
+}
+
 // a Shapes object is a map from Shape ID to *Shape. It preserves the order of its keys, unlike a Go map
 type Shapes struct {
-	keys     []string
+	keys     orderedKeys
 	bindings map[string]*Shape
+	lazy     *lazyShapes
+}
+
+// lazyShapes holds the shapes of a model loaded with LoadASTLazy, as JSON until they are looked up, and then decoded.
+// Decoding is done under the lock, into a map of its own, so that lookups are as safe for concurrent use as they are
+// in a model that is not lazy.
+type lazyShapes struct {
+	lock    sync.Mutex
+	raw     map[string]json.RawMessage
+	decoded map[string]*Shape
+	errs    map[string]error //the shapes whose JSON could not be decoded
 }
 
 func NewShapes() *Shapes {
@@ -54,13 +152,13 @@ func NewShapes() *Shapes {
 }
 
 func (s *Shapes) UnmarshalJSON(raw []byte) error {
-	keys, err := data.JsonKeysInOrder(raw)
-	if err != nil {
-		return err
-	}
 	shapes := NewShapes()
-	shapes.keys = keys
-	err = json.Unmarshal(raw, &shapes.bindings)
+	err := decodeOrderedJSON(raw, func(key string, dec *json.Decoder) error {
+		var shape *Shape
+		err := dec.Decode(&shape)
+		shapes.Put(key, shape)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -69,13 +167,293 @@ func (s *Shapes) UnmarshalJSON(raw []byte) error {
 }
 
 func (s Shapes) MarshalJSON() ([]byte, error) {
+	return marshalOrderedJSON(s.Keys(), func(key string) interface{} {
+		if raw := s.lazy.get(key); raw != nil {
+			return raw
+		}
+		if shape, ok := s.lazy.shape(key); ok {
+			return shape
+		}
+		return s.bindings[key]
+	})
+}
+
+// decodeLazyShapes returns the Shapes of the JSON object, without decoding the shapes until they are looked up.
+func decodeLazyShapes(raw []byte) (*Shapes, error) {
+	shapes := NewShapes()
+	shapes.lazy = &lazyShapes{
+		raw:     make(map[string]json.RawMessage, 0),
+		decoded: make(map[string]*Shape, 0),
+		errs:    make(map[string]error, 0),
+	}
+	err := decodeOrderedJSON(raw, func(key string, dec *json.Decoder) error {
+		var shape json.RawMessage
+		err := dec.Decode(&shape)
+		if err != nil {
+			return err
+		}
+		err = checkLazyShape(key, shape)
+		if err != nil {
+			return err
+		}
+		shapes.keys.add(key)
+		shapes.lazy.raw[key] = shape
+		return nil
+	})
+	return shapes, err
+}
+
+// checkLazyShape checks as much of the JSON of a shape as can be checked without decoding it: that it is an object
+// with a type, and members, if any, that are an object.
+func checkLazyShape(key string, raw json.RawMessage) error {
+	var head struct {
+		Type    string          `json:"type"`
+		Members json.RawMessage `json:"members"`
+	}
+	err := json.Unmarshal(raw, &head)
+	if err != nil || head.Type == "" {
+		return fmt.Errorf("Bad shape %s: not an object with a type", key)
+	}
+	if len(head.Members) > 0 && head.Members[0] != '{' && string(head.Members) != "null" {
+		return fmt.Errorf("Bad shape %s: the members are not an object", key)
+	}
+	return nil
+}
+
+// get returns the JSON of the shape, if it has not been decoded.
+func (l *lazyShapes) get(key string) json.RawMessage {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.raw[key]
+}
+
+// shape returns the shape, decoding it if it has not been decoded, and true, or false if it is not a lazy shape. A
+// shape whose JSON cannot be decoded, despite the checks made when it was loaded, is nil, and its error is kept.
+func (l *lazyShapes) shape(key string) (*Shape, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if raw, ok := l.raw[key]; ok {
+		delete(l.raw, key)
+		var shape *Shape
+		err := json.Unmarshal(raw, &shape)
+		if err == nil && shape == nil {
+			err = fmt.Errorf("null")
+		}
+		if err != nil {
+			l.errs[key] = fmt.Errorf("Bad shape %s: %v", key, err)
+			return nil, true
+		}
+		l.decoded[key] = shape
+	}
+	if _, ok := l.errs[key]; ok {
+		return nil, true
+	}
+	shape, ok := l.decoded[key]
+	return shape, ok
+}
+
+// has returns true if the shape is a lazy shape that has not failed to decode.
+func (l *lazyShapes) has(key string) bool {
+	if l == nil {
+		return false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, raw := l.raw[key]
+	_, decoded := l.decoded[key]
+	return raw || decoded
+}
+
+// failed returns true if the JSON of the shape could not be decoded.
+func (l *lazyShapes) failed(key string) bool {
+	if l == nil {
+		return false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, ok := l.errs[key]
+	return ok
+}
+
+// keys returns the keys without those of the shapes that failed to decode, in a new slice if there are any.
+func (l *lazyShapes) keys(keys []string) []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.errs) == 0 {
+		return keys
+	}
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if _, ok := l.errs[k]; !ok {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// forget drops the shape, which has been replaced or deleted.
+func (l *lazyShapes) forget(key string) {
+	if l != nil {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		delete(l.raw, key)
+		delete(l.decoded, key)
+		delete(l.errs, key)
+	}
+}
+
+func (s *Shapes) Put(key string, val *Shape) {
+	s.keys.add(key)
+	s.lazy.forget(key)
+	s.bindings[key] = val
+}
+
+// Has returns true if there is a shape with the key.
+func (s *Shapes) Has(key string) bool {
+	if s == nil {
+		return false
+	}
+	if s.lazy.has(key) {
+		return true
+	}
+	_, ok := s.bindings[key]
+	return ok
+}
+
+func (s *Shapes) Get(key string) *Shape {
+	if s == nil {
+		return nil
+	}
+	if shape, ok := s.lazy.shape(key); ok {
+		return shape
+	}
+	return s.bindings[key]
+}
+
+// Keys returns the keys of the shapes, in order. Those of a model loaded with LoadASTLazy whose JSON could not be
+// decoded when they were looked up are left out (see Err).
+func (s *Shapes) Keys() []string {
+	if s == nil {
+		return nil
+	}
+	if s.lazy != nil {
+		return s.lazy.keys(s.keys.keys())
+	}
+	return s.keys.keys()
+}
+
+func (s *Shapes) Length() int {
+	if s == nil {
+		return 0
+	}
+	if s.lazy != nil {
+		return len(s.Keys())
+	}
+	return s.keys.length()
+}
+
+// Err returns an error for the shapes of a model loaded with LoadASTLazy whose JSON could not be decoded when they
+// were looked up, or nil if there are none.
+func (s *Shapes) Err() error {
+	if s == nil || s.lazy == nil {
+		return nil
+	}
+	s.lazy.lock.Lock()
+	defer s.lazy.lock.Unlock()
+	var msgs []string
+	for _, k := range s.keys.keys() {
+		if err, ok := s.lazy.errs[k]; ok {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+// Delete removes the shape with the key, returning false if there was none. It does not rebuild the order of the keys,
+// so deleting any number of shapes costs O(1) each. Changing the shapes of an AST this way does not reset its index:
+// call AST.ResetIndex after.
+func (s *Shapes) Delete(key string) bool {
+	if s == nil || !s.keys.remove(key) {
+		return false
+	}
+	s.lazy.forget(key)
+	delete(s.bindings, key)
+	return true
+}
+
+// Rename changes the key of a shape, keeping its position, returning false if there is no shape with the old key or
+// there is already one with the new key.
+func (s *Shapes) Rename(oldKey string, newKey string) bool {
+	if !s.Has(oldKey) || s.Has(newKey) {
+		return false
+	}
+	val := s.Get(oldKey)
+	s.lazy.forget(oldKey)
+	s.lazy.forget(newKey)
+	delete(s.bindings, oldKey)
+	s.bindings[newKey] = val
+	s.keys.rename(oldKey, newKey)
+	return true
+}
+
+// InsertBefore puts the shape immediately before the one with the mark key, moving it there if the key is already
+// present. If the mark is not a key, the shape is put at the end, as Put does.
+func (s *Shapes) InsertBefore(mark string, key string, val *Shape) {
+	s.lazy.forget(key)
+	s.bindings[key] = val
+	s.keys.insert(mark, key, false)
+}
+
+// InsertAfter puts the shape immediately after the one with the mark key, as InsertBefore does.
+func (s *Shapes) InsertAfter(mark string, key string, val *Shape) {
+	s.lazy.forget(key)
+	s.bindings[key] = val
+	s.keys.insert(mark, key, true)
+}
+
+// decodeOrderedJSON decodes a JSON object in a single pass, calling the put function with each key, in order, and the
+// decoder, from which the function decodes its value.
+func decodeOrderedJSON(raw []byte, put func(key string, dec *json.Decoder) error) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("Expected a JSON object")
+	}
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		err = put(tok.(string), dec)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// marshalOrderedJSON writes a JSON object with the keys in order, as Shapes and Members do, since a Go map would
+// sort them.
+func marshalOrderedJSON(keys []string, value func(key string) interface{}) ([]byte, error) {
 	buffer := bytes.NewBufferString("{")
-	for i, key := range s.keys {
-		value := s.bindings[key]
+	for i, key := range keys {
 		if i > 0 {
 			buffer.WriteString(",")
 		}
-		jsonValue, err := json.Marshal(value)
+		jsonValue, err := json.Marshal(value(key))
 		if err != nil {
 			return nil, err
 		}
@@ -85,26 +463,113 @@ func (s Shapes) MarshalJSON() ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func (s *Shapes) Put(key string, val *Shape) {
-	if _, ok := s.bindings[key]; !ok {
-		s.keys = append(s.keys, key)
+// orderedKeys is the order of the keys of Shapes and Members, with an index of the position of each. Deleting a key
+// only drops it from the index, leaving a hole that keys skips, and the holes are compacted into a new slice once they
+// are half of it, so that a delete costs O(1), amortized. The slice is never changed in place once keys has returned
+// it, so that a caller can delete while looping over the keys.
+type orderedKeys struct {
+	order []string
+	index map[string]int
+	holes int
+}
+
+// add puts the key at the end, unless it is already there.
+func (o *orderedKeys) add(key string) {
+	if _, ok := o.index[key]; ok {
+		return
 	}
-	s.bindings[key] = val
+	if o.index == nil {
+		o.index = make(map[string]int, 0)
+	}
+	o.index[key] = len(o.order)
+	o.order = append(o.order, key)
 }
 
-func (s *Shapes) Get(key string) *Shape {
-	return s.bindings[key]
+// remove drops the key, returning false if it was not there.
+func (o *orderedKeys) remove(key string) bool {
+	if _, ok := o.index[key]; !ok {
+		return false
+	}
+	delete(o.index, key)
+	o.holes++
+	if o.holes*2 > len(o.order) {
+		o.reset(o.keys())
+	}
+	return true
 }
 
-func (s *Shapes) Keys() []string {
-	return s.keys
+// keys returns the keys in order, in a new slice if there are holes.
+func (o *orderedKeys) keys() []string {
+	if o.holes == 0 {
+		return o.order
+	}
+	result := make([]string, 0, len(o.order)-o.holes)
+	for i, k := range o.order {
+		if j, ok := o.index[k]; ok && j == i {
+			result = append(result, k)
+		}
+	}
+	return result
 }
 
-func (s *Shapes) Length() int {
-	if s == nil || s.keys == nil {
-		return 0
+func (o *orderedKeys) length() int {
+	return len(o.order) - o.holes
+}
+
+// rename changes the key in its position. Unlike remove, it rebuilds the order.
+func (o *orderedKeys) rename(oldKey string, newKey string) {
+	o.reset(renameKey(o.keys(), oldKey, newKey))
+}
+
+// insert puts the key before or after the mark, as insertKey does. Unlike remove, it rebuilds the order.
+func (o *orderedKeys) insert(mark string, key string, after bool) {
+	o.reset(insertKey(o.keys(), mark, key, after))
+}
+
+func (o *orderedKeys) reset(keys []string) {
+	o.order = keys
+	o.index = make(map[string]int, len(keys))
+	o.holes = 0
+	for i, k := range keys {
+		o.index[k] = i
+	}
+}
+
+// removeKey returns the keys without the key, in a new slice, since Keys returns the slice itself.
+func removeKey(keys []string, key string) []string {
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k != key {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+func renameKey(keys []string, oldKey string, newKey string) []string {
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		if k == oldKey {
+			k = newKey
+		}
+		result[i] = k
+	}
+	return result
+}
+
+// insertKey returns the keys with the key before or after the mark, and not anywhere else, or at the end if the mark
+// is not one of them.
+func insertKey(keys []string, mark string, key string, after bool) []string {
+	keys = removeKey(keys, key)
+	for i, k := range keys {
+		if k == mark {
+			if after {
+				i++
+			}
+			return append(keys[:i], append([]string{key}, keys[i:]...)...)
+		}
 	}
-	return len(s.keys)
+	return append(keys, key)
 }
 
 func (ast *AST) PutShape(id string, shape *Shape) {
@@ -112,6 +577,7 @@ func (ast *AST) PutShape(id string, shape *Shape) {
 		ast.Shapes = NewShapes()
 	}
 	ast.Shapes.Put(id, shape)
+	ast.ResetIndex()
 }
 
 func (ast *AST) GetShape(id string) *Shape {
@@ -123,7 +589,7 @@ func (ast *AST) GetShape(id string) *Shape {
 
 // a Members object is a map from string to *Member. It preserves the order of its keys, unlike a Go map
 type Members struct {
-	keys     []string
+	keys     orderedKeys
 	bindings map[string]*Member
 }
 
@@ -134,13 +600,13 @@ func NewMembers() *Members {
 }
 
 func (m *Members) UnmarshalJSON(raw []byte) error {
-	keys, err := data.JsonKeysInOrder(raw)
-	if err != nil {
-		return err
-	}
 	members := NewMembers()
-	members.keys = keys
-	err = json.Unmarshal(raw, &members.bindings)
+	err := decodeOrderedJSON(raw, func(key string, dec *json.Decoder) error {
+		var member *Member
+		err := dec.Decode(&member)
+		members.Put(key, member)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -149,45 +615,77 @@ func (m *Members) UnmarshalJSON(raw []byte) error {
 }
 
 func (m Members) MarshalJSON() ([]byte, error) {
-	buffer := bytes.NewBufferString("{")
-	for i, key := range m.keys {
-		value := m.bindings[key]
-		if i > 0 {
-			buffer.WriteString(",")
-		}
-		jsonValue, err := json.Marshal(value)
-		if err != nil {
-			return nil, err
-		}
-		buffer.WriteString(fmt.Sprintf("%q:%s", key, string(jsonValue)))
-	}
-	buffer.WriteString("}")
-	return buffer.Bytes(), nil
+	return marshalOrderedJSON(m.keys.keys(), func(key string) interface{} { return m.bindings[key] })
 }
 
 func (m *Members) Put(key string, val *Member) {
-	if _, ok := m.bindings[key]; !ok {
-		m.keys = append(m.keys, key)
-	}
+	m.keys.add(key)
 	m.bindings[key] = val
 }
 
+// Has returns true if there is a member with the key.
+func (m *Members) Has(key string) bool {
+	if m == nil {
+		return false
+	}
+	_, ok := m.bindings[key]
+	return ok
+}
+
 func (m *Members) Get(key string) *Member {
+	if m == nil {
+		return nil
+	}
 	return m.bindings[key]
 }
 
 func (m *Members) Keys() []string {
 	if m != nil {
-		return m.keys
+		return m.keys.keys()
 	}
 	return nil
 }
 
 func (m *Members) Length() int {
-	if m == nil || m.keys == nil {
+	if m == nil {
 		return 0
 	}
-	return len(m.keys)
+	return m.keys.length()
+}
+
+// Delete removes the member with the key, returning false if there was none. Like that of Shapes, it costs O(1).
+func (m *Members) Delete(key string) bool {
+	if m == nil || !m.keys.remove(key) {
+		return false
+	}
+	delete(m.bindings, key)
+	return true
+}
+
+// Rename changes the name of a member, keeping its position, returning false if there is no member with the old name
+// or there is already one with the new name.
+func (m *Members) Rename(oldKey string, newKey string) bool {
+	val, ok := m.bindings[oldKey]
+	if !ok || m.bindings[newKey] != nil {
+		return false
+	}
+	delete(m.bindings, oldKey)
+	m.bindings[newKey] = val
+	m.keys.rename(oldKey, newKey)
+	return true
+}
+
+// InsertBefore puts the member immediately before the one with the mark key, moving it there if the key is already
+// present. If the mark is not a key, the member is put at the end, as Put does.
+func (m *Members) InsertBefore(mark string, key string, val *Member) {
+	m.bindings[key] = val
+	m.keys.insert(mark, key, false)
+}
+
+// InsertAfter puts the member immediately after the one with the mark key, as InsertBefore does.
+func (m *Members) InsertAfter(mark string, key string, val *Member) {
+	m.bindings[key] = val
+	m.keys.insert(mark, key, true)
 }
 
 type Shape struct {
@@ -207,6 +705,7 @@ type Shape struct {
 
 	//Resource
 	Identifiers map[string]*ShapeRef `json:"identifiers,omitempty"`
+	Properties  map[string]*ShapeRef `json:"properties,omitempty"`
 	//FIXME preserve resource identifier order?
 	Create               *ShapeRef   `json:"create,omitempty"`
 	Put                  *ShapeRef   `json:"put,omitempty"`
@@ -227,6 +726,9 @@ type Shape struct {
 
 	//Service
 	Version string `json:"version,omitempty"`
+
+	location *SourceLocation //where the shape was parsed from, if it was parsed from IDL
+	source   string          //the source file annotation, if it was parsed WithSourceAnnotations
 }
 
 type ShapeRef struct {
@@ -236,25 +738,132 @@ type ShapeRef struct {
 type Member struct {
 	Target string       `json:"target"`
 	Traits *data.Object `json:"traits,omitempty"`
+
+	location *SourceLocation
 }
 
-func shapeIdNamespace(id string) string {
-	//name.space#entity$member
-	lst := strings.Split(id, "#")
-	return lst[0]
+// The node values of the JSON AST, i.e. traits and metadata, are decoded with DecodeObjectJSON, since encoding/json
+// would make every number a float64, and every nested object an unordered map.
+
+func (ast *AST) UnmarshalJSON(raw []byte) error {
+	type plain AST
+	doc := struct {
+		*plain
+		Metadata json.RawMessage `json:"metadata"`
+	}{plain: (*plain)(ast)}
+	err := json.Unmarshal(raw, &doc)
+	if err == nil {
+		ast.Metadata, err = decodeNodeJSON(doc.Metadata)
+	}
+	return err
 }
 
-func (ast *AST) Validate() error {
-	alreadyChecked := make(map[string]*Shape, 0)
-	for _, id := range ast.Shapes.Keys() {
-		err := ast.ValidateDefined(id, alreadyChecked)
-		if err != nil {
-			return err
+func (shape *Shape) UnmarshalJSON(raw []byte) error {
+	type plain Shape
+	doc := struct {
+		*plain
+		Traits json.RawMessage `json:"traits"`
+	}{plain: (*plain)(shape)}
+	err := json.Unmarshal(raw, &doc)
+	if err == nil {
+		shape.Traits, err = decodeNodeJSON(doc.Traits)
+	}
+	return err
+}
+
+func (mem *Member) UnmarshalJSON(raw []byte) error {
+	type plain Member
+	doc := struct {
+		*plain
+		Traits json.RawMessage `json:"traits"`
+	}{plain: (*plain)(mem)}
+	err := json.Unmarshal(raw, &doc)
+	if err == nil {
+		mem.Traits, err = decodeNodeJSON(doc.Traits)
+	}
+	return err
+}
+
+func decodeNodeJSON(raw json.RawMessage) (*data.Object, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	return DecodeObjectJSON(raw)
+}
+
+// SourceLocation is the position in a model file where a shape or member was defined.
+type SourceLocation struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (loc *SourceLocation) String() string {
+	return fmt.Sprintf("%s:%d:%d", loc.File, loc.Line, loc.Column)
+}
+
+// SourceLocation returns the location that the shape or member (i.e. "ns#Shape$member") with the given id was parsed
+// from, or nil if it is not known, as for models loaded from JSON.
+func (ast *AST) SourceLocation(id string) *SourceLocation {
+	if strings.Index(id, "$") >= 0 {
+		if mem := ast.getMember(id); mem != nil {
+			return mem.location
 		}
+		return nil
+	}
+	if shape := ast.GetShape(id); shape != nil {
+		return shape.location
 	}
 	return nil
 }
 
+// SourceAnnotation returns the source file, relative to the working directory, of the shape with the id, if it was
+// parsed WithSourceAnnotations, otherwise "". The IDL and SADL generators write it as a comment before the shape.
+func (ast *AST) SourceAnnotation(id string) string {
+	if shape := ast.GetShape(id); shape != nil {
+		return shape.source
+	}
+	return ""
+}
+
+// getMember returns the member with the id, i.e. "ns#Shape$member", or nil if it is not defined.
+func (ast *AST) getMember(id string) *Member {
+	sid := ShapeID(id)
+	memberName := sid.Member()
+	if memberName == "" {
+		return nil
+	}
+	shape := ast.ShapeByID(sid.Shape())
+	if shape == nil {
+		return nil
+	}
+	switch {
+	case shape.Members != nil:
+		return shape.Members.Get(memberName)
+	case memberName == "member":
+		return shape.Member
+	case memberName == "key":
+		return shape.Key
+	case memberName == "value":
+		return shape.Value
+	}
+	return nil
+}
+
+// the names of a resource's identifiers, in sorted order
+func sortedIdentifierNames(identifiers map[string]*ShapeRef) []string {
+	var names []string
+	for k := range identifiers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func shapeIdNamespace(id string) string {
+	return ShapeID(id).Namespace()
+}
+
 // check that all references are defined in this assembly
 func (ast *AST) ValidateDefined(id string, alreadyChecked map[string]*Shape) error {
 	if _, ok := alreadyChecked[id]; ok {
@@ -318,6 +927,7 @@ func (ast *AST) Namespaces() []string {
 	for k, _ := range m {
 		nss = append(nss, k)
 	}
+	sort.Strings(nss)
 	return nss
 }
 
@@ -332,12 +942,6 @@ func (ast *AST) RequiresDocumentType() bool {
 	return false
 }
 
-func (ast *AST) noteDependenciesFromRef(included map[string]bool, ref *ShapeRef) {
-	if ref != nil {
-		ast.noteDependencies(included, ref.Target)
-	}
-}
-
 func (ast *AST) noteDependencies(included map[string]bool, name string) {
 	//note traits
 	if name == "smithy.api#Document" {
@@ -355,59 +959,71 @@ func (ast *AST) noteDependencies(included map[string]bool, name string) {
 	if shape == nil {
 		return
 	}
-	if shape.Traits != nil {
-		for _, tk := range shape.Traits.Keys() {
-			ast.noteDependencies(included, tk)
+	for _, dep := range shape.Dependencies() {
+		ast.noteDependencies(included, dep)
+	}
+}
+
+// Dependencies returns the IDs of the shapes directly referenced by the shape: its traits, followed by the
+// targets of its members and any shape references specific to its type.
+func (shape *Shape) Dependencies() []string {
+	var deps []string
+	addRef := func(ref *ShapeRef) {
+		if ref != nil {
+			deps = append(deps, ref.Target)
 		}
 	}
+	if shape.Traits != nil {
+		deps = append(deps, shape.Traits.Keys()...)
+	}
 	switch shape.Type {
 	case "service":
 		for _, o := range shape.Operations {
-			ast.noteDependenciesFromRef(included, o)
+			addRef(o)
 		}
 		for _, r := range shape.Resources {
-			ast.noteDependenciesFromRef(included, r)
+			addRef(r)
 		}
 	case "operation":
-		ast.noteDependenciesFromRef(included, shape.Input)
-		ast.noteDependenciesFromRef(included, shape.Output)
+		addRef(shape.Input)
+		addRef(shape.Output)
 		for _, e := range shape.Errors {
-			ast.noteDependenciesFromRef(included, e)
+			addRef(e)
 		}
 	case "resource":
-		if shape.Identifiers != nil {
-			for _, v := range shape.Identifiers {
-				ast.noteDependenciesFromRef(included, v)
-			}
+		for _, k := range sortedIdentifierNames(shape.Identifiers) {
+			addRef(shape.Identifiers[k])
+		}
+		for _, k := range sortedIdentifierNames(shape.Properties) {
+			addRef(shape.Properties[k])
 		}
 		for _, o := range shape.Operations {
-			ast.noteDependenciesFromRef(included, o)
+			addRef(o)
 		}
 		for _, r := range shape.Resources {
-			ast.noteDependenciesFromRef(included, r)
-		}
-		ast.noteDependenciesFromRef(included, shape.Create)
-		ast.noteDependenciesFromRef(included, shape.Put)
-		ast.noteDependenciesFromRef(included, shape.Read)
-		ast.noteDependenciesFromRef(included, shape.Update)
-		ast.noteDependenciesFromRef(included, shape.Delete)
-		ast.noteDependenciesFromRef(included, shape.List)
+			addRef(r)
+		}
+		addRef(shape.Create)
+		addRef(shape.Put)
+		addRef(shape.Read)
+		addRef(shape.Update)
+		addRef(shape.Delete)
+		addRef(shape.List)
 		for _, o := range shape.CollectionOperations {
-			ast.noteDependenciesFromRef(included, o)
+			addRef(o)
 		}
 	case "structure", "union":
 		for _, n := range shape.Members.Keys() {
-			m := shape.Members.Get(n)
-			ast.noteDependencies(included, m.Target)
+			deps = append(deps, shape.Members.Get(n).Target)
 		}
 	case "list", "set":
-		ast.noteDependencies(included, shape.Member.Target)
+		deps = append(deps, shape.Member.Target)
 	case "map":
-		ast.noteDependencies(included, shape.Key.Target)
-		ast.noteDependencies(included, shape.Value.Target)
+		deps = append(deps, shape.Key.Target, shape.Value.Target)
 	case "string", "integer", "long", "short", "byte", "float", "double", "boolean", "bigInteger", "bigDecimal", "blob", "timestamp":
 		//smithy primitives
 	}
+	return deps
 }
 
 func (ast *AST) ShapeNames() []string {
@@ -418,90 +1034,265 @@ func (ast *AST) ShapeNames() []string {
 	return lst
 }
 
+// LoadAST reads a Smithy AST file. Files with a ".yaml" or ".yml" extension are read as YAML, others as JSON.
 func LoadAST(path string) (*AST, error) {
-	var ast *AST
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("Cannot read smithy AST file: %v\n", err)
 	}
-	err = json.Unmarshal(data, &ast)
+	return loadAST(path, data)
+}
+
+func loadAST(path string, data []byte) (*AST, error) {
+	data, err := astJSON(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return LoadASTBytes(data)
+}
+
+// astJSON returns the JSON of the Smithy AST file, converting it from YAML if the path has a ".yaml" or ".yml"
+// extension.
+func astJSON(path string, data []byte) ([]byte, error) {
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		b, err := yamlToJson(data)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse Smithy AST file: %v\n", err)
+		}
+		return b, nil
+	}
+	return data, nil
+}
+
+// LoadASTLazy loads the Smithy AST file as LoadAST does, except that each shape is decoded when it is first looked
+// up, so that a tool that needs only a few of the shapes of a huge model does not decode all of them. Iterating over
+// all of the shapes, as validation, indexing and most generators do, decodes them all.
+func LoadASTLazy(path string) (*AST, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read smithy AST file: %v\n", err)
+	}
+	data, err = astJSON(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return LoadASTBytesLazy(data)
+}
+
+// LoadASTBytesLazy decodes a Smithy AST in JSON as LoadASTBytes does, except that the shapes are decoded lazily, as
+// LoadASTLazy does.
+func LoadASTBytesLazy(data []byte) (*AST, error) {
+	var doc struct {
+		Smithy   string          `json:"smithy"`
+		Metadata json.RawMessage `json:"metadata"`
+		Shapes   json.RawMessage `json:"shapes"`
+	}
+	err := json.Unmarshal(data, &doc)
 	if err != nil {
 		return nil, fmt.Errorf("Cannot parse Smithy AST file: %v\n", err)
 	}
-	if ast.Smithy == "" {
+	if doc.Smithy == "" {
+		return nil, fmt.Errorf("Cannot parse Smithy AST file: missing smithy version\n")
+	}
+	ast := &AST{Smithy: doc.Smithy}
+	ast.Metadata, err = decodeNodeJSON(doc.Metadata)
+	if err == nil && len(doc.Shapes) > 0 && string(doc.Shapes) != "null" {
+		ast.Shapes, err = decodeLazyShapes(doc.Shapes)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("Cannot parse Smithy AST file: %v\n", err)
 	}
 	return ast, nil
 }
 
+// LoadASTBytes decodes a Smithy AST in JSON.
+func LoadASTBytes(data []byte) (*AST, error) {
+	var ast *AST
+	err := json.Unmarshal(data, &ast)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse Smithy AST file: %v\n", err)
+	}
+	if ast == nil || ast.Smithy == "" {
+		return nil, fmt.Errorf("Cannot parse Smithy AST file: missing smithy version\n")
+	}
+	return ast, nil
+}
+
+// LoadASTReader decodes a Smithy AST in JSON read from r.
+func LoadASTReader(r io.Reader) (*AST, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read smithy AST: %v\n", err)
+	}
+	return LoadASTBytes(data)
+}
+
+// MergeStrategy says how Merge resolves a conflict, i.e. two different shapes with the same id.
+type MergeStrategy string
+
+const (
+	MergeError      MergeStrategy = "error"       //fail the merge (the default)
+	MergeFirstWins  MergeStrategy = "first-wins"  //keep what is already in the model
+	MergeNewestWins MergeStrategy = "newest-wins" //replace it with what is being merged
+)
+
+// MergeOptions configures MergeWithOptions.
+type MergeOptions struct {
+	Conflicts MergeStrategy
+}
+
+// Merge merges the shapes and metadata of the src model into this one. It is MergeWithOptions with the default options.
 func (ast *AST) Merge(src *AST) error {
+	return ast.MergeWithOptions(src, nil)
+}
+
+// MergeWithOptions merges the shapes and metadata of the src model into this one. As in Smithy, a shape that is
+// defined identically in both is accepted, as is a metadata value that is identical in both, and metadata values that
+// are both lists are concatenated. Any other duplicate is a conflict, which is an error unless the options have
+// another strategy, in which case a warning is added to the model.
+func (ast *AST) MergeWithOptions(src *AST, opts *MergeOptions) error {
+	strategy := MergeError
+	if opts != nil && opts.Conflicts != "" {
+		strategy = opts.Conflicts
+	}
+	switch strategy {
+	case MergeError, MergeFirstWins, MergeNewestWins:
+	default:
+		return fmt.Errorf("Unknown merge strategy: %q", strategy)
+	}
+	ast.warnings = append(ast.warnings, src.warnings...)
 	if ast.Smithy != src.Smithy {
 		if strings.HasPrefix(ast.Smithy, "1") && strings.HasPrefix(src.Smithy, "2") {
 			ast.Smithy = src.Smithy
 		} else {
-			fmt.Println("//WARNING: smithy version mismatch:", ast.Smithy, "and", src.Smithy)
+			ast.AddWarning(SeverityWarning, nil, fmt.Sprintf("smithy version mismatch: %s and %s", ast.Smithy, src.Smithy))
 		}
 	}
 	if src.Metadata != nil {
 		if ast.Metadata == nil {
-			ast.Metadata = src.Metadata
-		} else {
-			for _, k := range src.Metadata.Keys() {
-				v := src.Metadata.Get(k)
-				prev := ast.Metadata.Get(k)
-				if prev != nil {
-					err := ast.mergeConflict(k, prev, v)
-					if err != nil {
-						return err
-					}
-				}
-				ast.Metadata.Put(k, v)
-			}
+			//copied, so that merging more models into this one does not change the source
+			ast.Metadata = data.NewObject()
+		}
+		var err error
+		ast.Metadata, err = ObjectMerge(ast.Metadata, src.Metadata, &ObjectMergeStrategy{
+			ConcatLists: true,
+			Conflict: func(k string) (bool, error) {
+				return ast.mergeConflict(strategy, "metadata", k)
+			},
+		})
+		if err != nil {
+			return err
 		}
 	}
 	if src.Shapes != nil {
 		for _, k := range src.Shapes.Keys() {
-			if tmp := ast.GetShape(k); tmp != nil {
-				return fmt.Errorf("Duplicate shape in assembly: %s\n", k)
+			shape := src.GetShape(k)
+			if prev := ast.GetShape(k); prev != nil {
+				if nodeEqual(prev, shape) {
+					continue
+				}
+				keep, err := ast.mergeConflict(strategy, "shape", k)
+				if err != nil {
+					return err
+				}
+				if keep {
+					continue
+				}
 			}
-			ast.PutShape(k, src.GetShape(k))
+			ast.PutShape(k, shape)
 		}
 	}
+	for ns, ids := range src.uses {
+		for _, id := range ids {
+			ast.addUse(ns, id)
+		}
+	}
+	for _, id := range src.applied.Keys() {
+		ast.applyTraits(id, src.applied.GetObject(id))
+	}
+	for _, id := range ast.applied.Keys() {
+		ast.resolveApplied(id)
+	}
+	ast.ResetIndex()
 	return nil
 }
 
-func (ast *AST) mergeConflict(k string, v1 interface{}, v2 interface{}) error {
-	//todo: if values are identical, accept one of them
-	//todo: concat list values
-	return fmt.Errorf("Conflict when merging metadata in models: %s\n", k)
+// mergeConflict returns true if the existing value of the metadata key or shape should be kept, according to the
+// strategy, or an error if the strategy is MergeError.
+func (ast *AST) mergeConflict(strategy MergeStrategy, what string, k string) (bool, error) {
+	switch strategy {
+	case MergeFirstWins:
+		ast.AddWarning(SeverityWarning, nil, fmt.Sprintf("Conflicting %s when merging models, the first is kept: %s", what, k))
+		return true, nil
+	case MergeNewestWins:
+		ast.AddWarning(SeverityWarning, nil, fmt.Sprintf("Conflicting %s when merging models, the newest is kept: %s", what, k))
+		return false, nil
+	}
+	if what == "shape" {
+		return false, fmt.Errorf("Duplicate shape in assembly: %s\n", k)
+	}
+	return false, fmt.Errorf("Conflict when merging metadata in models: %s\n", k)
 }
 
+// Filter keeps only the shapes with any of the tags, and the shapes they depend on.
 func (ast *AST) Filter(tags []string) {
-	var root []string
-	for _, k := range ast.Shapes.Keys() {
-		shape := ast.Shapes.Get(k)
-		shapeTags := shape.Traits.GetStringArray("smithy.api#tags")
-		if shapeTags != nil {
-			for _, t := range shapeTags {
-				if containsString(tags, t) {
-					root = append(root, k)
-				}
-			}
+	ast.filterToClosure(ast.shapesWithTags(tags))
+}
+
+// FilterExcludeTags removes the shapes with any of the tags, and the references to them (see RemoveShapes).
+func (ast *AST) FilterExcludeTags(tags []string) {
+	ast.RemoveShapes(ast.shapesWithTags(tags))
+}
+
+// FilterBySelector keeps only the shapes matched by the selector expression, or that have a member matched by it,
+// and the shapes they depend on.
+func (ast *AST) FilterBySelector(expr string) error {
+	matches, err := ast.Select(expr)
+	if err != nil {
+		return err
+	}
+	var roots []string
+	seen := make(map[string]bool, 0)
+	for _, id := range matches {
+		id = strings.SplitN(id, "$", 2)[0]
+		if !seen[id] {
+			seen[id] = true
+			roots = append(roots, id)
 		}
 	}
+	ast.filterToClosure(roots)
+	return nil
+}
+
+// FilterByServiceClosure keeps only the service and the shapes it depends on, directly or indirectly.
+func (ast *AST) FilterByServiceClosure(serviceId string) error {
+	shape := ast.GetShape(serviceId)
+	if shape == nil || shape.Type != "service" {
+		return fmt.Errorf("Not a service: %s", serviceId)
+	}
+	ast.filterToClosure([]string{serviceId})
+	return nil
+}
+
+// filterToClosure keeps only the root shapes and the shapes they depend on, in their original order.
+func (ast *AST) filterToClosure(roots []string) {
+	if ast.Shapes == nil {
+		return
+	}
 	included := make(map[string]bool, 0)
-	for _, k := range root {
+	for _, k := range roots {
 		if _, ok := included[k]; !ok {
 			ast.noteDependencies(included, k)
 		}
 	}
 	filtered := NewShapes()
-	for name, _ := range included {
-		if !strings.HasPrefix(name, "smithy.api#") {
+	for _, name := range ast.Shapes.Keys() {
+		if included[name] && !strings.HasPrefix(name, "smithy.api#") {
 			filtered.Put(name, ast.GetShape(name))
 		}
 	}
 	ast.Shapes = filtered
+	ast.ResetIndex()
 }
 
 func containsString(ary []string, val string) bool {
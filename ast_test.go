@@ -0,0 +1,138 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// edits of the members a, b, c, d, and the keys after each: "+k" puts k, "-k" deletes it, "k>n" renames k to n, and
+// "k<m" and "m>>k" insert k before and after m
+var memberEditTests = []struct {
+	name     string
+	edits    []string
+	expected []string
+}{
+	{"put existing", []string{"+b"}, []string{"a", "b", "c", "d"}},
+	{"delete", []string{"-b"}, []string{"a", "c", "d"}},
+	{"delete missing", []string{"-x"}, []string{"a", "b", "c", "d"}},
+	{"delete all", []string{"-a", "-b", "-c", "-d"}, nil},
+	{"delete and put back", []string{"-b", "+b"}, []string{"a", "c", "d", "b"}},
+	{"delete twice and put back", []string{"-b", "+b", "-b", "+b"}, []string{"a", "c", "d", "b"}},
+	{"delete then rename", []string{"-a", "c>x"}, []string{"b", "x", "d"}},
+	{"delete then insert", []string{"-c", "x<b"}, []string{"a", "x", "b", "d"}},
+	{"delete then insert after", []string{"-a", "d>>x", "b>>y"}, []string{"b", "y", "c", "d", "x"}},
+	{"delete past compaction", []string{"-a", "-c", "-d", "+e", "-b", "+f"}, []string{"e", "f"}},
+}
+
+func editMembers(m *Members, edit string) {
+	switch {
+	case strings.HasPrefix(edit, "+"):
+		m.Put(edit[1:], &Member{Target: "smithy.api#String"})
+	case strings.HasPrefix(edit, "-"):
+		m.Delete(edit[1:])
+	case strings.Contains(edit, ">>"):
+		lst := strings.Split(edit, ">>")
+		m.InsertAfter(lst[0], lst[1], &Member{Target: "smithy.api#String"})
+	case strings.Contains(edit, ">"):
+		lst := strings.Split(edit, ">")
+		m.Rename(lst[0], lst[1])
+	case strings.Contains(edit, "<"):
+		lst := strings.Split(edit, "<")
+		m.InsertBefore(lst[1], lst[0], &Member{Target: "smithy.api#String"})
+	}
+}
+
+func TestMemberEdits(t *testing.T) {
+	for _, tc := range memberEditTests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMembers()
+			for _, k := range []string{"a", "b", "c", "d"} {
+				m.Put(k, &Member{Target: "smithy.api#String"})
+			}
+			for _, edit := range tc.edits {
+				editMembers(m, edit)
+			}
+			keys := m.Keys()
+			if len(keys)+len(tc.expected) > 0 && !reflect.DeepEqual(keys, tc.expected) {
+				t.Errorf("Expected keys %v, got %v", tc.expected, keys)
+			}
+			if m.Length() != len(tc.expected) {
+				t.Errorf("Expected length %d, got %d", len(tc.expected), m.Length())
+			}
+			for _, k := range keys {
+				if m.Get(k) == nil {
+					t.Errorf("No member for key %q", k)
+				}
+			}
+			b, err := m.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var parsed Members
+			if err := parsed.UnmarshalJSON(b); err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) > 0 && !reflect.DeepEqual(parsed.Keys(), keys) {
+				t.Errorf("The JSON has keys %v, not %v", parsed.Keys(), keys)
+			}
+		})
+	}
+}
+
+func TestOrderedKeysCompaction(t *testing.T) {
+	var o orderedKeys
+	for i := 0; i < 1000; i++ {
+		o.add(fmt.Sprint(i))
+	}
+	for i := 0; i < 1000; i += 2 {
+		o.remove(fmt.Sprint(i))
+		if o.holes*2 > len(o.order) {
+			t.Fatalf("Too many holes after removing %d: %d of %d", i, o.holes, len(o.order))
+		}
+	}
+	if o.length() != 500 || len(o.keys()) != 500 {
+		t.Fatalf("Expected 500 keys, got %d", len(o.keys()))
+	}
+	for i, k := range o.keys() {
+		if k != fmt.Sprint(i*2+1) {
+			t.Fatalf("Expected key %d at %d, got %s", i*2+1, i, k)
+		}
+	}
+}
+
+func TestDeleteWhileLooping(t *testing.T) {
+	shapes := NewShapes()
+	for i := 0; i < 10; i++ {
+		shapes.Put(fmt.Sprintf("test#S%d", i), &Shape{Type: "string"})
+	}
+	keys := shapes.Keys()
+	for _, k := range keys {
+		shapes.Delete(k)
+	}
+	if shapes.Length() != 0 || len(shapes.Keys()) != 0 {
+		t.Errorf("Expected no shapes, got %v", shapes.Keys())
+	}
+	for i, k := range keys {
+		if k != fmt.Sprintf("test#S%d", i) {
+			t.Errorf("The keys changed while deleting: %v", keys)
+			break
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+
+	"github.com/boynton/data"
+)
+
+const AsyncApiVersion = "2.6.0"
+
+type AsyncApiGenerator struct {
+	BaseGenerator
+}
+
+// Generate an AsyncAPI document for the event-style operations in the model. An operation is considered an
+// event operation if it is tagged with the "eventTag" option (default "event"), or if its input or output has a
+// member targeting a @streaming union.
+func (gen *AsyncApiGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	doc, err := gen.ToAsyncApi(ast)
+	if err != nil {
+		return err
+	}
+	return gen.Emit(data.Pretty(doc), "asyncapi.json", "")
+}
+
+func (gen *AsyncApiGenerator) ToAsyncApi(ast *AST) (*data.Object, error) {
+	eventTag := gen.Config.GetString("eventTag")
+	if eventTag == "" {
+		eventTag = "event"
+	}
+	_, serviceName, serviceVersion := ast.NamespaceAndServiceVersion()
+	if serviceName == "" {
+		serviceName = "Untitled"
+	}
+	if serviceVersion == "" {
+		serviceVersion = UnspecifiedVersion
+	}
+	info := data.NewObject()
+	info.Put("title", serviceName)
+	info.Put("version", serviceVersion)
+	doc := data.NewObject()
+	doc.Put("asyncapi", AsyncApiVersion)
+	doc.Put("info", info)
+	channels := data.NewObject()
+	for _, k := range ast.Shapes.Keys() {
+		shape := ast.GetShape(k)
+		if shape.Type != "operation" {
+			continue
+		}
+		inStream := gen.streamingMember(ast, shape.Input)
+		outStream := gen.streamingMember(ast, shape.Output)
+		if inStream == nil && outStream == nil && !containsString(shape.Traits.GetStringArray("smithy.api#tags"), eventTag) {
+			continue
+		}
+		name := StripNamespace(k)
+		channel := data.NewObject()
+		if doc := shape.Traits.GetString("smithy.api#documentation"); doc != "" {
+			channel.Put("description", doc)
+		}
+		if shape.Input != nil {
+			op, err := gen.channelOperation(ast, Uncapitalize(name)+"Publish", shape.Input.Target, inStream)
+			if err != nil {
+				return nil, err
+			}
+			channel.Put("publish", op)
+		}
+		if shape.Output != nil {
+			op, err := gen.channelOperation(ast, Uncapitalize(name)+"Subscribe", shape.Output.Target, outStream)
+			if err != nil {
+				return nil, err
+			}
+			channel.Put("subscribe", op)
+		}
+		channelName := Uncapitalize(name)
+		if httpTrait := shape.Traits.GetObject("smithy.api#http"); httpTrait != nil {
+			channelName = httpTrait.GetString("uri")
+		}
+		channels.Put(channelName, channel)
+	}
+	doc.Put("channels", channels)
+	schemas := data.NewObject()
+	for _, k := range ast.Shapes.Keys() {
+		shape := ast.GetShape(k)
+		switch shape.Type {
+		case "service", "resource", "operation":
+		default:
+			schemas.Put(schemaName(k), openApiShapeSchema(ast, shape, openApiSchemaRef))
+		}
+	}
+	if schemas.Length() > 0 {
+		components := data.NewObject()
+		components.Put("schemas", schemas)
+		doc.Put("components", components)
+	}
+	return doc, nil
+}
+
+// streamingMember returns the member of the referenced structure that targets a @streaming union, if any
+func (gen *AsyncApiGenerator) streamingMember(ast *AST, ref *ShapeRef) *Member {
+	if ref == nil {
+		return nil
+	}
+	shape := ast.GetShape(ref.Target)
+	if shape == nil {
+		return nil
+	}
+	for _, k := range shape.Members.Keys() {
+		mem := shape.Members.Get(k)
+		if target := ast.GetShape(mem.Target); target != nil && target.Type == "union" && target.Traits.Has("smithy.api#streaming") {
+			return mem
+		}
+	}
+	return nil
+}
+
+// a streaming member produces one message per member of its union, otherwise the whole structure is the message
+func (gen *AsyncApiGenerator) channelOperation(ast *AST, opId string, target string, stream *Member) (*data.Object, error) {
+	op := data.NewObject()
+	op.Put("operationId", opId)
+	if stream == nil {
+		op.Put("message", asyncApiMessage(StripNamespace(target), openApiRef(target, openApiSchemaRef)))
+		return op, nil
+	}
+	union := ast.GetShape(stream.Target)
+	if union == nil {
+		return nil, fmt.Errorf("Undefined shape: %s", stream.Target)
+	}
+	var messages []interface{}
+	for _, k := range union.Members.Keys() {
+		mem := union.Members.Get(k)
+		messages = append(messages, asyncApiMessage(k, openApiMemberSchema(ast, mem, openApiSchemaRef)))
+	}
+	oneOf := data.NewObject()
+	oneOf.Put("oneOf", messages)
+	op.Put("message", oneOf)
+	return op, nil
+}
+
+func asyncApiMessage(name string, payload *data.Object) *data.Object {
+	msg := data.NewObject()
+	msg.Put("name", name)
+	msg.Put("contentType", "application/json")
+	msg.Put("payload", payload)
+	return msg
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+	"sync"
+)
+
+// The definitions of the commonly used AWS traits, so that their values can be checked like those of traits defined in
+// the model itself. A model that defines any of these shapes itself uses its own definition instead.
+var awsTraitSources = map[string]string{
+	"aws.protocols": `$version: "2"
+namespace aws.protocols
+
+@trait
+structure restJson1 {
+    http: StringList
+    eventStreamHttp: StringList
+}
+
+@trait
+structure awsJson1_0 {
+    http: StringList
+    eventStreamHttp: StringList
+}
+
+@trait
+structure awsJson1_1 {
+    http: StringList
+    eventStreamHttp: StringList
+}
+
+@trait
+structure restXml {
+    http: StringList
+    eventStreamHttp: StringList
+    noErrorWrapping: Boolean
+}
+
+@trait
+structure awsQuery {}
+
+@trait
+structure ec2Query {}
+
+@trait
+structure awsQueryCompatible {}
+
+@trait
+structure awsQueryError {
+    @required
+    code: String
+    @required
+    httpResponseCode: Integer
+}
+
+@trait
+string ec2QueryName
+
+@trait
+structure httpChecksum {
+    requestAlgorithmMember: String
+    requestChecksumRequired: Boolean
+    requestValidationModeMember: String
+    responseAlgorithms: StringList
+}
+
+list StringList {
+    member: String
+}
+`,
+	"aws.api": `$version: "2"
+namespace aws.api
+
+@trait
+structure service {
+    @required
+    sdkId: String
+    arnNamespace: String
+    cloudFormationName: String
+    cloudTrailEventSource: String
+    docId: String
+    endpointPrefix: String
+}
+
+@trait
+structure arn {
+    @required
+    template: String
+    absolute: Boolean
+    noRegion: Boolean
+    noAccount: Boolean
+}
+
+@trait
+structure arnReference {
+    type: String
+    service: String
+    resource: String
+}
+
+@trait
+structure clientEndpointDiscovery {
+    @required
+    operation: String
+    error: String
+}
+
+@trait
+structure clientDiscoveredEndpoint {
+    @required
+    required: Boolean
+}
+
+@trait
+structure clientEndpointDiscoveryId {}
+
+@trait
+structure controlPlane {}
+
+@trait
+structure dataPlane {}
+
+@trait
+enum data {
+    CUSTOMER_CONTENT = "content"
+    CUSTOMER_ACCOUNT_INFORMATION = "account"
+    SERVICE_ATTRIBUTES = "usage"
+    TAG_INFORMATION = "tagging"
+    PERMISSIONS_CONFIGURATION = "permissions"
+}
+
+@trait
+structure tagEnabled {
+    disableDefaultOperations: Boolean
+}
+
+@trait
+structure taggable {
+    property: String
+    apiConfig: TaggableApiConfig
+    disableSystemTags: Boolean
+}
+
+structure TaggableApiConfig {
+    @required
+    tagApi: String
+    @required
+    untagApi: String
+    @required
+    listTagsApi: String
+}
+`,
+	"aws.auth": `$version: "2"
+namespace aws.auth
+
+@trait
+structure sigv4 {
+    @required
+    name: String
+}
+
+@trait
+structure sigv4a {
+    @required
+    name: String
+}
+
+@trait
+structure unsignedPayload {}
+
+@trait
+structure cognitoUserPools {
+    @required
+    providerArns: StringList
+}
+
+list StringList {
+    member: String
+}
+`,
+}
+
+var awsTraits *AST
+var awsTraitsOnce sync.Once
+
+// AwsTraitDefinitions returns an assembly of the definitions of the AWS traits in the aws.protocols, aws.api and
+// aws.auth namespaces.
+func AwsTraitDefinitions() *AST {
+	awsTraitsOnce.Do(func() {
+		assembly := &AST{
+			Smithy: "2",
+		}
+		for _, ns := range []string{"aws.api", "aws.auth", "aws.protocols"} {
+			ast, err := ParseString(awsTraitSources[ns], ns+".smithy")
+			if err == nil {
+				err = assembly.Merge(ast)
+			}
+			if err != nil {
+				panic("bad AWS trait definition: " + err.Error())
+			}
+		}
+		awsTraits = assembly
+	})
+	return awsTraits
+}
+
+// traitDefinition returns the shape defining the trait, and the assembly it is in, or nil if the trait is not defined.
+func (ast *AST) traitDefinition(id string) (*AST, *Shape) {
+	if def := ast.GetShape(id); def != nil {
+		if def.Traits.Has("smithy.api#trait") {
+			return ast, def
+		}
+		return nil, nil
+	}
+	if strings.HasPrefix(id, "aws.") {
+		defs := AwsTraitDefinitions()
+		if def := defs.GetShape(id); def != nil {
+			return defs, def
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the sizes of the generated benchmark models, as the number of operations, each with an input, an output and an
+// item structure, and a list of items
+var benchSizes = []struct {
+	name       string
+	operations int
+}{
+	{"small", 10},
+	{"medium", 100},
+	{"large", 1000},
+}
+
+// benchIDL returns the IDL of a generated model in the namespace: a service with operations bound to HTTP, each with
+// documented input, output and item structures, a list, and an error shared by all of them.
+func benchIDL(ns string, operations int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "$version: \"2\"\n\nnamespace %s\n\n", ns)
+	sb.WriteString("/// The benchmark service.\nservice Bench {\n    version: \"2021-01-01\"\n    operations: [\n")
+	for i := 0; i < operations; i++ {
+		fmt.Fprintf(&sb, "        Op%d\n", i)
+	}
+	sb.WriteString("    ]\n}\n\n@error(\"client\")\n@httpError(400)\nstructure BadRequest {\n    message: String\n}\n\n")
+	for i := 0; i < operations; i++ {
+		fmt.Fprintf(&sb, "/// Operation %d.\n@http(method: \"POST\", uri: \"/items/%d/{id}\", code: 200)\n", i, i)
+		fmt.Fprintf(&sb, "operation Op%d {\n    input: Op%dInput\n    output: Op%dOutput\n    errors: [BadRequest]\n}\n\n", i, i, i)
+		fmt.Fprintf(&sb, "@input\nstructure Op%dInput {\n    @required\n    @httpLabel\n    id: String\n\n    @httpPayload\n    item: Item%d\n}\n\n", i, i)
+		fmt.Fprintf(&sb, "@output\nstructure Op%dOutput {\n    items: ItemList%d\n\n    @httpHeader(\"X-Count\")\n    count: Integer\n}\n\n", i, i)
+		fmt.Fprintf(&sb, "/// An item.\n@tags([\"item\"])\nstructure Item%d {\n    @length(min: 1, max: 100)\n    name: String\n\n    @range(min: 0)\n    size: Long = 0\n\n    tags: StringList\n}\n\n", i)
+		fmt.Fprintf(&sb, "list ItemList%d {\n    member: Item%d\n}\n\n", i, i)
+	}
+	sb.WriteString("list StringList {\n    member: String\n}\n")
+	return sb.String()
+}
+
+func benchModel(b *testing.B, ns string, operations int) *AST {
+	ast, err := ParseString(benchIDL(ns, operations), ns+".smithy")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return ast
+}
+
+// benchFile writes the data to a file of the name in a temporary directory, returning its path.
+func benchFile(b *testing.B, name string, data []byte) string {
+	path := filepath.Join(b.TempDir(), name)
+	err := ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// benchASTFile writes the JSON AST of the generated model to a temporary file, returning its path.
+func benchASTFile(b *testing.B, operations int) string {
+	var buf bytes.Buffer
+	err := benchModel(b, "bench", operations).WriteJSON(&buf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return benchFile(b, "model.json", buf.Bytes())
+}
+
+// benchRun runs the benchmark once for each of the model sizes.
+func benchRun(b *testing.B, fn func(b *testing.B, operations int)) {
+	for _, size := range benchSizes {
+		operations := size.operations
+		b.Run(size.name, func(b *testing.B) {
+			fn(b, operations)
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		src := benchIDL("bench", operations)
+		b.SetBytes(int64(len(src)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := ParseString(src, "bench.smithy")
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		src := benchIDL("bench", operations)
+		b.SetBytes(int64(len(src)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			Tokenize(src)
+		}
+	})
+}
+
+// BenchmarkAssemble assembles an IDL file and a JSON AST file of another namespace, with a new Assembler each time so
+// that nothing is cached.
+func BenchmarkAssemble(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		idl := benchFile(b, "one.smithy", []byte(benchIDL("bench.one", operations)))
+		var buf bytes.Buffer
+		err := benchModel(b, "bench.two", operations).WriteJSON(&buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		json := benchFile(b, "two.json", buf.Bytes())
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := NewAssembler().Assemble([]string{idl, json})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkLoadAST(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		path := benchASTFile(b, operations)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := LoadAST(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkLoadASTLazy loads the model lazily, and looks up a single shape and its closure, as "smithy show -lazy"
+// does.
+func BenchmarkLoadASTLazy(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		path := benchASTFile(b, operations)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ast, err := LoadASTLazy(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(ast.ShapeClosure("bench#Op7")) == 0 {
+				b.Fatal("No closure for bench#Op7")
+			}
+		}
+	})
+}
+
+// BenchmarkFilter filters a copy of the model by tag and by the closure of its service.
+func BenchmarkFilter(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		ast := benchModel(b, "bench", operations)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			tagged, closed := ast.Clone(), ast.Clone()
+			b.StartTimer()
+			tagged.Filter([]string{"item"})
+			err := closed.FilterByServiceClosure("bench#Bench")
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkIDL(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		ast := benchModel(b, "bench", operations)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			err := ast.WriteIDL(ioutil.Discard, "bench", nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkIndex(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		ast := benchModel(b, "bench", operations)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ast.ResetIndex()
+			ast.Index()
+		}
+	})
+}
+
+func BenchmarkMerge(b *testing.B) {
+	benchRun(b, func(b *testing.B, operations int) {
+		first := benchModel(b, "bench.one", operations)
+		second := benchModel(b, "bench.two", operations)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			assembly := &AST{Smithy: "1.0"}
+			err := assembly.Merge(first)
+			if err == nil {
+				err = assembly.Merge(second)
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDelete removes every shape of a big model, one at a time, in the order they were put.
+func BenchmarkDelete(b *testing.B) {
+	const count = 20000
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		shapes := NewShapes()
+		for j := 0; j < count; j++ {
+			shapes.Put(fmt.Sprintf("bench#Item%d", j), &Shape{Type: "structure"})
+		}
+		b.StartTimer()
+		for _, key := range shapes.Keys() {
+			shapes.Delete(key)
+		}
+		if shapes.Length() != 0 {
+			b.Fatal("Shapes left after deleting them all")
+		}
+	}
+}
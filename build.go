@@ -0,0 +1,153 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/boynton/data"
+)
+
+// BuildConfig is the subset of a smithy-build.json file that this tool understands: the model files, Maven
+// dependencies, projections, plugins and lint configuration.
+type BuildConfig struct {
+	Version         string                       `json:"version"`
+	Sources         []string                     `json:"sources,omitempty"`
+	Imports         []string                     `json:"imports,omitempty"`
+	OutputDirectory string                       `json:"outputDirectory,omitempty"`
+	Projections     map[string]*ProjectionConfig `json:"projections,omitempty"`
+	Plugins         map[string]*data.Object      `json:"plugins,omitempty"`
+	Maven           *MavenConfig                 `json:"maven,omitempty"`
+	Lint            *LintConfig                  `json:"lint,omitempty"`
+}
+
+// ProjectionConfig is a view of the model, built from the sources and imports of the config and those of the
+// projection, with the transforms applied in order. The plugins of the config are run on every projection, in
+// addition to the projection's own. An abstract projection is not built.
+type ProjectionConfig struct {
+	Abstract   bool                    `json:"abstract,omitempty"`
+	Imports    []string                `json:"imports,omitempty"`
+	Transforms []*TransformConfig      `json:"transforms,omitempty"`
+	Plugins    map[string]*data.Object `json:"plugins,omitempty"`
+}
+
+// TransformConfig names a transform of a projection, and its arguments.
+type TransformConfig struct {
+	Name string       `json:"name"`
+	Args *data.Object `json:"args,omitempty"`
+}
+
+// DefaultBuildConfigFile is the config file that is used when no model files or config are given.
+const DefaultBuildConfigFile = "smithy-build.json"
+
+// LoadBuildConfig reads a smithy-build.json style configuration file. Relative paths in the sources, imports and
+// outputDirectory (which is "build/smithy" by default) are resolved against the directory of the file.
+func LoadBuildConfig(path string) (*BuildConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read build config: %v", err)
+	}
+	var config BuildConfig
+	err = json.Unmarshal(b, &config)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse build config %q: %v", path, err)
+	}
+	dir := filepath.Dir(path)
+	config.Sources = resolvePaths(dir, config.Sources)
+	config.Imports = resolvePaths(dir, config.Imports)
+	for _, proj := range config.Projections {
+		proj.Imports = resolvePaths(dir, proj.Imports)
+	}
+	if config.OutputDirectory == "" {
+		config.OutputDirectory = filepath.Join("build", "smithy")
+	}
+	config.OutputDirectory = resolvePaths(dir, []string{config.OutputDirectory})[0]
+	return &config, nil
+}
+
+func resolvePaths(dir string, paths []string) []string {
+	var result []string
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+// HasProjections returns true if the config describes a build, with sources, projections or plugins, rather than
+// just the dependencies of the model.
+func (config *BuildConfig) HasProjections() bool {
+	return len(config.Sources) > 0 || len(config.Projections) > 0 || len(config.Plugins) > 0
+}
+
+// ProjectionNames returns the names of the projections to build, in order. The "source" projection, the model with
+// no transforms, is always first.
+func (config *BuildConfig) ProjectionNames() []string {
+	names := []string{"source"}
+	var others []string
+	for name, proj := range config.Projections {
+		if name != "source" && !proj.Abstract {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// Projection returns the named projection. The "source" projection is empty unless the config has one.
+func (config *BuildConfig) Projection(name string) *ProjectionConfig {
+	if proj, ok := config.Projections[name]; ok {
+		return proj
+	}
+	return &ProjectionConfig{}
+}
+
+// ProjectionPaths returns the model files of the named projection.
+func (config *BuildConfig) ProjectionPaths(name string) []string {
+	var paths []string
+	paths = append(paths, config.Sources...)
+	paths = append(paths, config.Imports...)
+	return append(paths, config.Projection(name).Imports...)
+}
+
+// ProjectionPlugins returns the plugins to run on the named projection, with their configuration. The projection's
+// configuration of a plugin replaces that of the config.
+func (config *BuildConfig) ProjectionPlugins(name string) map[string]*data.Object {
+	plugins := make(map[string]*data.Object, 0)
+	for k, v := range config.Plugins {
+		plugins[k] = v
+	}
+	for k, v := range config.Projection(name).Plugins {
+		plugins[k] = v
+	}
+	return plugins
+}
+
+// OutputDir returns the directory for the output of the plugin in the projection, which is under the
+// outputDirectory of the config, as with smithy-build.
+func (config *BuildConfig) OutputDir(projection string, plugin string) string {
+	dir := config.OutputDirectory
+	if dir == "" {
+		dir = filepath.Join("build", "smithy")
+	}
+	return filepath.Join(dir, projection, plugin)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"github.com/boynton/data"
+)
+
+// Clone returns a deep copy of the model: its shapes, members, metadata and trait values are all copied, so that the
+// copy can be changed, i.e. by transforms, without affecting the original. Source locations and warnings are kept.
+func (ast *AST) Clone() *AST {
+	if ast == nil {
+		return nil
+	}
+	c := &AST{
+		Smithy:   ast.Smithy,
+		Metadata: cloneObject(ast.Metadata),
+		warnings: append([]*Warning(nil), ast.warnings...),
+		applied:  cloneObject(ast.applied),
+	}
+	for ns, ids := range ast.uses {
+		for _, id := range ids {
+			c.addUse(ns, id)
+		}
+	}
+	if ast.Shapes != nil {
+		c.Shapes = NewShapes()
+		for _, k := range ast.Shapes.Keys() {
+			c.Shapes.Put(k, ast.GetShape(k).Clone())
+		}
+	}
+	return c
+}
+
+// Clone returns a deep copy of the shape, including its members and traits.
+func (shape *Shape) Clone() *Shape {
+	if shape == nil {
+		return nil
+	}
+	c := *shape
+	c.Traits = cloneObject(shape.Traits)
+	c.Member = shape.Member.Clone()
+	c.Key = shape.Key.Clone()
+	c.Value = shape.Value.Clone()
+	if shape.Members != nil {
+		c.Members = NewMembers()
+		for _, k := range shape.Members.Keys() {
+			c.Members.Put(k, shape.Members.Get(k).Clone())
+		}
+	}
+	c.Mixins = cloneRefs(shape.Mixins)
+	c.Identifiers = cloneRefMap(shape.Identifiers)
+	c.Properties = cloneRefMap(shape.Properties)
+	c.Create = shape.Create.clone()
+	c.Put = shape.Put.clone()
+	c.Read = shape.Read.clone()
+	c.Update = shape.Update.clone()
+	c.Delete = shape.Delete.clone()
+	c.List = shape.List.clone()
+	c.CollectionOperations = cloneRefs(shape.CollectionOperations)
+	c.Operations = cloneRefs(shape.Operations)
+	c.Resources = cloneRefs(shape.Resources)
+	c.Input = shape.Input.clone()
+	c.Output = shape.Output.clone()
+	c.Errors = cloneRefs(shape.Errors)
+	return &c
+}
+
+// Clone returns a deep copy of the member, including its traits.
+func (mem *Member) Clone() *Member {
+	if mem == nil {
+		return nil
+	}
+	c := *mem
+	c.Traits = cloneObject(mem.Traits)
+	return &c
+}
+
+func (ref *ShapeRef) clone() *ShapeRef {
+	if ref == nil {
+		return nil
+	}
+	c := *ref
+	return &c
+}
+
+func cloneRefs(refs []*ShapeRef) []*ShapeRef {
+	if refs == nil {
+		return nil
+	}
+	c := make([]*ShapeRef, 0, len(refs))
+	for _, ref := range refs {
+		c = append(c, ref.clone())
+	}
+	return c
+}
+
+func cloneRefMap(refs map[string]*ShapeRef) map[string]*ShapeRef {
+	if refs == nil {
+		return nil
+	}
+	c := make(map[string]*ShapeRef, len(refs))
+	for k, ref := range refs {
+		c[k] = ref.clone()
+	}
+	return c
+}
+
+func cloneObject(obj *data.Object) *data.Object {
+	if obj == nil {
+		return nil
+	}
+	c := data.NewObject()
+	for _, k := range obj.Keys() {
+		c.Put(k, cloneNode(obj.Get(k)))
+	}
+	return c
+}
+
+// cloneNode returns a deep copy of a node value, i.e. a trait or metadata value. Scalars are immutable, so they are
+// returned as is.
+func cloneNode(v interface{}) interface{} {
+	switch n := v.(type) {
+	case *data.Object:
+		return cloneObject(n)
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(n))
+		for k, e := range n {
+			c[k] = cloneNode(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(n))
+		for i, e := range n {
+			c[i] = cloneNode(e)
+		}
+		return c
+	case []string:
+		return append([]string(nil), n...)
+	case []map[string]interface{}:
+		c := make([]map[string]interface{}, len(n))
+		for i, e := range n {
+			c[i] = cloneNode(e).(map[string]interface{})
+		}
+		return c
+	case []*data.Object:
+		c := make([]*data.Object, len(n))
+		for i, e := range n {
+			c[i] = cloneObject(e)
+		}
+		return c
+	}
+	return v
+}
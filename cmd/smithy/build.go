@@ -0,0 +1,95 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// buildProjections builds each projection of the config, running its plugins into
+// <outputDirectory>/<projection>/<plugin>, as smithy-build does. Any files given are added to the sources. Projections
+// with the same model files share the assembled model, each transforming its own copy of it.
+func buildProjections(config *smithy.BuildConfig, files []string, tags []string, strict bool, of *outputFlags, opts ...smithy.ParserOption) error {
+	config.Sources = append(config.Sources, files...)
+	assembled := make(map[string]*smithy.AST, 0)
+	for _, name := range config.ProjectionNames() {
+		paths := config.ProjectionPaths(name)
+		key := strings.Join(paths, "\n")
+		model, ok := assembled[key]
+		if !ok {
+			var err error
+			model, err = AssembleModel(paths, tags, config, strict, opts...)
+			if err != nil {
+				return err
+			}
+			assembled[key] = model
+		}
+		ast := model.Clone()
+		err := ast.ApplyTransforms(config.Projection(name).Transforms)
+		if err != nil {
+			return fmt.Errorf("Projection %q: %v", name, err)
+		}
+		plugins := config.ProjectionPlugins(name)
+		var pluginNames []string
+		for k := range plugins {
+			pluginNames = append(pluginNames, k)
+		}
+		sort.Strings(pluginNames)
+		for _, pname := range pluginNames {
+			err = runPlugin(config, name, pname, plugins[pname], ast, of)
+			if err != nil {
+				return fmt.Errorf("Projection %q, plugin %q: %v", name, pname, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runPlugin runs the generator for the plugin, with its configuration. The "model" plugin of smithy-build is the
+// "ast" generator, and other plugins are the generators with the same names.
+func runPlugin(config *smithy.BuildConfig, projection string, plugin string, pluginConfig *data.Object, ast *smithy.AST, of *outputFlags) error {
+	genName := plugin
+	if genName == "model" {
+		genName = "ast"
+	}
+	generator, err := Generator(genName)
+	if err != nil {
+		return err
+	}
+	outdir := config.OutputDir(projection, plugin)
+	conf := data.NewObject()
+	for _, k := range pluginConfig.Keys() {
+		conf.Put(k, pluginConfig.Get(k))
+	}
+	conf.Put("outdir", outdir)
+	conf.Put("force", true)
+	of.configure(conf)
+	if !conf.GetBool("dryRun") {
+		err = os.MkdirAll(outdir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	err = generator.Generate(ast, conf)
+	of.record(generator)
+	return err
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// the exit code when the diff finds breaking changes
+const exitBreakingChanges = 3
+
+// diffCommand compares two versions of a model, i.e. "smithy diff old.smithy new.json", and exits with a nonzero
+// status if any of the changes are breaking.
+func diffCommand(args []string) {
+	flags := newFlagSet("diff", "smithy diff [--json] old new")
+	pJson := flags.Bool("json", false, "Output the changes as JSON")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	status, err := diffModels(flags.Arg(0), flags.Arg(1), *pJson, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	if status != 0 {
+		os.Exit(status)
+	}
+}
+
+// diffModels writes the changes from the old model to the new one, returning the exit status of the diff command: 0 if
+// none of them are breaking, exitBreakingChanges if any are, or 2 if a model cannot be loaded.
+func diffModels(oldPath, newPath string, asJson bool, out io.Writer) (int, error) {
+	var models []*smithy.AST
+	for _, path := range []string{oldPath, newPath} {
+		ast, err := smithy.NewAssembler().Assemble([]string{path})
+		if err != nil {
+			return 2, err
+		}
+		models = append(models, ast)
+	}
+	changes := smithy.Diff(models[0], models[1])
+	if asJson {
+		lst := changes.Changes
+		if lst == nil {
+			lst = make([]*smithy.Change, 0)
+		}
+		fmt.Fprint(out, data.Pretty(lst))
+	} else {
+		for _, c := range changes.Changes {
+			fmt.Fprintln(out, c)
+		}
+	}
+	if len(changes.Breaking()) > 0 {
+		return exitBreakingChanges, nil
+	}
+	return 0, nil
+}
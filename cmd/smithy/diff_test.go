@@ -0,0 +1,76 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const diffOld = `$version: "2"
+namespace test
+structure Item {
+    id: String
+    name: String
+}
+`
+
+// the exit status of "smithy diff" for a change to diffOld
+var diffStatusTests = []struct {
+	name   string
+	new    string
+	status int
+}{
+	{"no change", diffOld, 0},
+	{"added shape", diffOld + "string Tag\n", 0},
+	{"removed member", "$version: \"2\"\nnamespace test\nstructure Item {\n    id: String\n}\n", exitBreakingChanges},
+	{"unparseable", "$version: \"2\"\nnamespace test\nstructure Item {\n", 2},
+}
+
+func TestDiffStatus(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.smithy")
+	if err := os.WriteFile(oldPath, []byte(diffOld), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range diffStatusTests {
+		t.Run(tc.name, func(t *testing.T) {
+			newPath := filepath.Join(dir, "new.smithy")
+			if err := os.WriteFile(newPath, []byte(tc.new), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for _, asJson := range []bool{false, true} {
+				var out bytes.Buffer
+				status, err := diffModels(oldPath, newPath, asJson, &out)
+				if status != tc.status {
+					t.Errorf("Expected status %d, got %d (json=%v)", tc.status, status, asJson)
+				}
+				if (err != nil) != (tc.status == 2) {
+					t.Errorf("Unexpected error result: %v", err)
+				}
+				if asJson && err == nil && !strings.HasPrefix(out.String(), "[") {
+					t.Errorf("Expected a JSON array, got %q", out.String())
+				}
+			}
+		})
+	}
+	if status, _ := diffModels(filepath.Join(dir, "missing.smithy"), oldPath, false, &bytes.Buffer{}); status != 2 {
+		t.Errorf("Expected status 2 for a missing model, got %d", status)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// errorFormat is the --error-format flag, for how problems with the model are written to stderr: "text", for
+// people, or "json", one smithy.Diagnostic object per line, for editors and CI annotation tools.
+type errorFormat struct {
+	format *string
+}
+
+func addErrorFormatFlag(flags *flag.FlagSet) *errorFormat {
+	return &errorFormat{
+		format: flags.String("error-format", "text", "The format of errors and warnings: text, or json for one object per line"),
+	}
+}
+
+// check exits if the flag is not a known format.
+func (ef *errorFormat) check(flags *flag.FlagSet) {
+	if *ef.format != "text" && *ef.format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown error format: %q\n", *ef.format)
+		flags.Usage()
+		os.Exit(1)
+	}
+}
+
+// fail reports the error and exits with the code.
+func (ef *errorFormat) fail(err error, code int) {
+	if *ef.format == "json" {
+		for _, d := range smithy.ErrorDiagnostics(err) {
+			fmt.Fprintln(os.Stderr, data.Json(d))
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	os.Exit(code)
+}
+
+func (ef *errorFormat) warnings(warnings []*smithy.Warning) {
+	for _, w := range warnings {
+		if *ef.format == "json" {
+			fmt.Fprintln(os.Stderr, data.Json(w.Diagnostic()))
+		} else {
+			fmt.Fprintln(os.Stderr, w)
+		}
+	}
+}
+
+// checkOutput exits if the --output flag of a command that reports problems is not one of the formats.
+func checkOutput(flags *flag.FlagSet, output string, formats ...string) {
+	for _, f := range formats {
+		if output == f {
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown output format: %q\n", output)
+	flags.Usage()
+	os.Exit(1)
+}
+
+// writeDiagnostics writes the problems to stdout in the format of the --output flag: "sarif", for code scanning
+// tools, or "github", as GitHub Actions annotations.
+func writeDiagnostics(output string, diags []*smithy.Diagnostic) {
+	var err error
+	if output == "sarif" {
+		err = smithy.WriteSARIF(os.Stdout, "smithy", smithy.ToolVersion, diags)
+	} else {
+		err = smithy.WriteGitHubAnnotations(os.Stdout, diags)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
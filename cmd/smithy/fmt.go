@@ -0,0 +1,84 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/boynton/smithy"
+)
+
+// the exit code when --check finds files that are not formatted
+const exitUnformatted = 3
+
+// fmtCommand rewrites .smithy files in canonical style, i.e. "smithy fmt -w model.smithy". By default the result is
+// written to stdout. Files in the arguments other than .smithy files are skipped. With --check nothing is written,
+// the files that would change are listed, and the exit status is nonzero if there are any.
+func fmtCommand(args []string) {
+	flags := newFlagSet("fmt", "smithy fmt [-w | --check] [--error-format json] file ...")
+	pWrite := flags.Bool("w", false, "Rewrite the files in place instead of writing to stdout")
+	pCheck := flags.Bool("check", false, "Only list the files that are not formatted, and fail if there are any")
+	ef := addErrorFormatFlag(flags)
+	flags.Parse(args)
+	ef.check(flags)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	paths, err := smithy.ExpandPaths(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	unformatted := false
+	for _, path := range paths {
+		if filepath.Ext(path) != ".smithy" {
+			continue
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		out, err := smithy.Format(string(src), path)
+		if err != nil {
+			ef.fail(err, 2)
+		}
+		switch {
+		case *pCheck:
+			if out != string(src) {
+				fmt.Println(path)
+				unformatted = true
+			}
+		case *pWrite:
+			if out != string(src) {
+				err = ioutil.WriteFile(path, []byte(out), 0644)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					os.Exit(2)
+				}
+			}
+		default:
+			fmt.Print(out)
+		}
+	}
+	if unformatted {
+		os.Exit(exitUnformatted)
+	}
+}
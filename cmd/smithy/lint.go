@@ -0,0 +1,97 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// the exit code when lint reports ERROR or DANGER events
+const exitLintFailed = 3
+
+// lintCommand checks the style of a model, i.e. "smithy lint -c smithy-build.json model.smithy", where the "lint"
+// property of the config sets the severity of each rule, or turns it off.
+func lintCommand(args []string) {
+	flags := newFlagSet("lint", "smithy lint [-c config] [--output text|json|sarif|github] [--rules] file ...")
+	pOutput := flags.String("output", "text", "The format of the events: text, json, sarif, or github for GitHub Actions annotations")
+	pJson := flags.Bool("json", false, "Output the events as JSON, the same as --output json")
+	pRules := flags.Bool("rules", false, "List the lint rules and exit")
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	if *pRules {
+		for _, rule := range smithy.LintRules() {
+			fmt.Printf("%-22s %-8s %s\n", rule.Id, rule.Severity, rule.Description)
+		}
+		return
+	}
+	if *pJson {
+		*pOutput = "json"
+	}
+	checkOutput(flags, *pOutput, "text", "json", "sarif", "github")
+	var ast *smithy.AST
+	var diags []*smithy.Diagnostic
+	if *pOutput == "sarif" || *pOutput == "github" {
+		//assembly errors and warnings are reported in the same format as the lint events, as validate does
+		files, buildConfig := mf.files(flags)
+		var err error
+		ast, err = mf.assembleFiles(files, buildConfig)
+		if err != nil {
+			writeDiagnostics(*pOutput, smithy.ErrorDiagnostics(err))
+			os.Exit(2)
+		}
+		for _, w := range ast.Warnings() {
+			diags = append(diags, w.Diagnostic())
+		}
+	} else {
+		ast = mf.assemble(flags)
+	}
+	var lintConfig *smithy.LintConfig
+	if buildConfig := mf.buildConfig(flags); buildConfig != nil {
+		lintConfig = buildConfig.Lint
+	}
+	events, err := ast.Lint(lintConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	switch *pOutput {
+	case "json":
+		if events == nil {
+			events = make([]*smithy.ValidationEvent, 0)
+		}
+		fmt.Print(data.Pretty(events))
+	case "sarif", "github":
+		for _, ev := range events {
+			if ev.Severity != smithy.SeveritySuppressed {
+				diags = append(diags, ev.Diagnostic())
+			}
+		}
+		writeDiagnostics(*pOutput, diags)
+	default:
+		for _, ev := range events {
+			fmt.Println(ev)
+		}
+	}
+	for _, ev := range events {
+		if ev.Severity == smithy.SeverityError || ev.Severity == smithy.SeverityDanger {
+			os.Exit(exitLintFailed)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// lspCommand runs a minimal Language Server Protocol server on stdin and stdout, i.e. "smithy lsp -c
+// smithy-build.json", for editors. The model is the open .smithy documents, as edited, along with the files of the
+// arguments and the sources and imports of the config. It publishes the parse errors and validation events of the
+// model as diagnostics, and provides go to definition and hover for shape ids.
+func lspCommand(args []string) {
+	flags := newFlagSet("lsp", "smithy lsp [-c config] [file ...]")
+	pConfig := flags.String("c", "", "A smithy-build.json style config file, for the model sources")
+	flags.Parse(args)
+	var files []string
+	config := *pConfig
+	if config == "" && flags.NArg() == 0 {
+		if _, err := os.Stat(smithy.DefaultBuildConfigFile); err == nil {
+			config = smithy.DefaultBuildConfigFile
+		}
+	}
+	if config != "" {
+		buildConfig, err := smithy.LoadBuildConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		files = append(files, buildConfig.Sources...)
+		files = append(files, buildConfig.Imports...)
+	}
+	files = append(files, flags.Args()...)
+	server := &lspServer{
+		in:        bufio.NewReader(os.Stdin),
+		out:       os.Stdout,
+		files:     files,
+		assembler: smithy.NewAssembler(),
+		documents: make(map[string]string, 0),
+		published: make(map[string]bool, 0),
+	}
+	err := server.serve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type lspServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	files     []string
+	assembler *smithy.Assembler
+	documents map[string]string //the text of the open documents, by absolute path
+	published map[string]bool   //the paths that have diagnostics
+	ast       *smithy.AST       //the last model that was assembled without errors
+	shutdown  bool
+}
+
+type lspMessage struct {
+	Jsonrpc string           `json:"jsonrpc"`
+	Id      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	Uri   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocumentParams struct {
+	TextDocument struct {
+		Uri  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Position lspPosition `json:"position"`
+}
+
+func (s *lspServer) serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result, rerr := s.handleRecovered(msg)
+		if msg.Id == nil {
+			continue
+		}
+		resp := &lspMessage{Jsonrpc: "2.0", Id: msg.Id, Error: rerr}
+		if rerr == nil {
+			//a null result must still be present in the response
+			resp.Result = json.RawMessage("null")
+			if result != nil {
+				resp.Result = result
+			}
+		}
+		err = s.write(resp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// read reads the next message, which is preceded by headers, of which only Content-Length is used.
+func (s *lspServer) read() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[len("content-length:"):]))
+			if err != nil {
+				return nil, fmt.Errorf("Bad Content-Length header: %q", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("Missing Content-Length header")
+	}
+	b := make([]byte, length)
+	_, err := io.ReadFull(s.in, b)
+	if err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	err = json.Unmarshal(b, &msg)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *lspServer) write(msg *lspMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	return err
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&lspMessage{Jsonrpc: "2.0", Method: method, Params: b})
+}
+
+// handleRecovered handles the message, recovering from a panic in doing so, which is reported as a diagnostic and an
+// error response rather than ending the server. The documents being edited are often not valid IDL, which is more
+// than the code behind the server has been exercised with.
+func (s *lspServer) handleRecovered(msg *lspMessage) (result interface{}, rerr *lspError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("Internal error handling %s: %v", msg.Method, r)
+			diags := make(map[string][]*lspDiagnostic, 0)
+			for _, d := range smithy.ErrorDiagnostics(err) {
+				s.addDiagnostic(diags, d)
+			}
+			s.publish(diags)
+			result, rerr = nil, &lspError{Code: -32603, Message: err.Error()}
+		}
+	}()
+	return s.handle(msg)
+}
+
+func (s *lspServer) handle(msg *lspMessage) (interface{}, *lspError) {
+	var params lspTextDocumentParams
+	if len(msg.Params) > 0 && strings.HasPrefix(msg.Method, "textDocument/") {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+	}
+	switch msg.Method {
+	case "initialize":
+		s.check()
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, //the full text is sent on each change
+				"definitionProvider": true,
+				"hoverProvider":      true,
+			},
+			"serverInfo": map[string]string{"name": "smithy", "version": smithy.ToolVersion},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "exit":
+		if s.shutdown {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	case "textDocument/didOpen":
+		s.documents[uriPath(params.TextDocument.Uri)] = params.TextDocument.Text
+		s.check()
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.documents[uriPath(params.TextDocument.Uri)] = params.ContentChanges[n-1].Text
+			s.check()
+		}
+	case "textDocument/didClose":
+		delete(s.documents, uriPath(params.TextDocument.Uri))
+		s.check()
+	case "textDocument/definition":
+		id := s.shapeIdAt(uriPath(params.TextDocument.Uri), params.Position)
+		if id != "" {
+			if loc := s.ast.SourceLocation(id); loc != nil {
+				return s.location(loc), nil
+			}
+		}
+		return nil, nil
+	case "textDocument/hover":
+		id := s.shapeIdAt(uriPath(params.TextDocument.Uri), params.Position)
+		if id != "" {
+			return map[string]interface{}{
+				"contents": map[string]string{"kind": "markdown", "value": s.hover(id)},
+			}, nil
+		}
+		return nil, nil
+	default:
+		if msg.Id != nil && msg.Method != "" {
+			return nil, &lspError{Code: -32601, Message: "Method not found: " + msg.Method}
+		}
+	}
+	return nil, nil
+}
+
+// check assembles the model from the files and open documents, and publishes its problems as diagnostics.
+func (s *lspServer) check() {
+	diags := make(map[string][]*lspDiagnostic, 0)
+	ast, err := s.assemble()
+	if err == nil {
+		events := ast.ValidationEvents()
+		for _, ev := range events {
+			if ev.Severity != smithy.SeveritySuppressed {
+				s.addDiagnostic(diags, ev.Diagnostic())
+			}
+		}
+		for _, w := range ast.Warnings() {
+			s.addDiagnostic(diags, w.Diagnostic())
+		}
+		s.ast = ast
+	} else {
+		for _, d := range smithy.ErrorDiagnostics(err) {
+			s.addDiagnostic(diags, d)
+		}
+	}
+	s.publish(diags)
+}
+
+// publish publishes the diagnostics of each file, and clears those of the files previously published that have none.
+func (s *lspServer) publish(diags map[string][]*lspDiagnostic) {
+	for path := range s.published {
+		if _, ok := diags[path]; !ok {
+			s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": pathUri(path), "diagnostics": []*lspDiagnostic{}})
+		}
+	}
+	s.published = make(map[string]bool, 0)
+	for path, list := range diags {
+		s.published[path] = true
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": pathUri(path), "diagnostics": list})
+	}
+}
+
+// assemble merges the model files, other than those open as documents, with the open .smithy documents.
+func (s *lspServer) assemble() (*smithy.AST, error) {
+	paths, err := smithy.ExpandPaths(s.files)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, path := range paths {
+		if _, ok := s.documents[absPath(path)]; !ok {
+			files = append(files, path)
+		}
+	}
+	ast, err := s.assembler.Assemble(files)
+	if err != nil {
+		return nil, err
+	}
+	var docs []string
+	for path := range s.documents {
+		if filepath.Ext(path) == ".smithy" {
+			docs = append(docs, path)
+		}
+	}
+	sort.Strings(docs)
+	for _, path := range docs {
+		doc, err := smithy.ParseString(s.documents[path], path)
+		if err == nil {
+			err = ast.Merge(doc)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ast, nil
+}
+
+// addDiagnostic adds the diagnostic to the list for its file. Those with no file are reported on the first line of
+// each open document, so that they are seen.
+func (s *lspServer) addDiagnostic(diags map[string][]*lspDiagnostic, d *smithy.Diagnostic) {
+	severity := 3 //information
+	switch d.Severity {
+	case smithy.SeverityError, smithy.SeverityDanger:
+		severity = 1
+	case smithy.SeverityWarning:
+		severity = 2
+	}
+	pos := lspPosition{}
+	if d.Line > 0 {
+		pos = lspPosition{Line: d.Line - 1, Character: d.Column - 1}
+	}
+	ld := &lspDiagnostic{Range: lspRange{Start: pos, End: pos}, Severity: severity, Code: d.Id, Source: "smithy", Message: d.Message}
+	if d.ShapeId != "" {
+		ld.Message = d.ShapeId + ": " + d.Message
+	}
+	if d.File != "" {
+		path := absPath(d.File)
+		diags[path] = append(diags[path], ld)
+		return
+	}
+	for path := range s.documents {
+		diags[path] = append(diags[path], ld)
+	}
+}
+
+func (s *lspServer) location(loc *smithy.SourceLocation) *lspLocation {
+	pos := lspPosition{Line: loc.Line - 1, Character: loc.Column - 1}
+	return &lspLocation{Uri: pathUri(absPath(loc.File)), Range: lspRange{Start: pos, End: pos}}
+}
+
+var lspNamespacePattern = regexp.MustCompile(`(?m)^\s*namespace\s+([A-Za-z0-9_.]+)`)
+var lspUsePattern = regexp.MustCompile(`(?m)^\s*use\s+([A-Za-z0-9_.]+#[A-Za-z0-9_]+)`)
+
+// shapeIdAt returns the absolute id of the shape or member named at the position of the document, resolved as the
+// IDL would: a relative name is imported with "use", or in the namespace of the document, or in the prelude.
+func (s *lspServer) shapeIdAt(path string, pos lspPosition) string {
+	if s.ast == nil {
+		return ""
+	}
+	lines := strings.Split(s.documents[path], "\n")
+	if pos.Line >= len(lines) {
+		return ""
+	}
+	line := lines[pos.Line]
+	isNameChar := func(c byte) bool {
+		return c == '_' || c == '.' || c == '#' || c == '$' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+	start, end := pos.Character, pos.Character
+	if start > len(line) {
+		return ""
+	}
+	for start > 0 && isNameChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isNameChar(line[end]) {
+		end++
+	}
+	name := line[start:end]
+	if name == "" {
+		return ""
+	}
+	if strings.Contains(name, "#") {
+		return name
+	}
+	shapeName, member := name, ""
+	if n := strings.Index(name, "$"); n >= 0 {
+		shapeName, member = name[:n], name[n:]
+	}
+	var candidates []string
+	for _, m := range lspUsePattern.FindAllStringSubmatch(s.documents[path], -1) {
+		if smithy.StripNamespace(m[1]) == shapeName {
+			candidates = append(candidates, m[1])
+		}
+	}
+	if m := lspNamespacePattern.FindStringSubmatch(s.documents[path]); m != nil {
+		candidates = append(candidates, m[1]+"#"+shapeName)
+	}
+	for _, id := range candidates {
+		if s.ast.GetShape(id) != nil {
+			return id + member
+		}
+	}
+	if member == "" && (smithy.IsPreludeType(shapeName) || smithy.IsPreludeTrait(shapeName)) {
+		return "smithy.api#" + shapeName
+	}
+	return ""
+}
+
+// hover returns a description of the shape or member in markdown: its id and type, documentation, and traits,
+// including those from mixins and apply statements.
+func (s *lspServer) hover(id string) string {
+	sid := smithy.ShapeID(id)
+	var kind string
+	var traits *data.Object
+	if sid.Member() != "" {
+		mem := s.ast.MemberByID(sid)
+		if mem == nil {
+			return "`" + id + "`"
+		}
+		kind = "member targeting `" + mem.Target + "`"
+		traits = s.ast.EffectiveMemberTraits(string(sid.Shape()), sid.Member())
+	} else if shape := s.ast.GetShape(id); shape != nil {
+		kind = shape.Type
+		traits = s.ast.EffectiveTraits(id)
+	} else {
+		return "**" + id + "** (prelude)"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**%s** (%s)\n", id, kind)
+	if doc := traits.GetString("smithy.api#documentation"); doc != "" {
+		fmt.Fprintf(&sb, "\n%s\n", doc)
+	}
+	var lines []string
+	for _, tid := range traits.Keys() {
+		if tid != "smithy.api#documentation" {
+			lines = append(lines, fmt.Sprintf("- `@%s` %s", smithy.StripNamespace(tid), data.Json(traits.Get(tid))))
+		}
+	}
+	if len(lines) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", strings.Join(lines, "\n"))
+	}
+	return sb.String()
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathUri(path string) string {
+	u := &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
@@ -18,54 +18,328 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/boynton/data"
 	"github.com/boynton/smithy"
 )
 
+// A command is one of the subcommands of the tool, i.e. "smithy validate model.smithy".
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []*command{
+	{"build", "Assemble the model and generate output from it (the default)", buildCommand},
+	{"validate", "Assemble and validate the model, reporting any problems", validateCommand},
+	{"fmt", "Rewrite .smithy files in canonical style", fmtCommand},
+	{"lint", "Check the style of the model, with configurable rules", lintCommand},
+	{"diff", "Compare two versions of a model, reporting the changes", diffCommand},
+	{"list", "List the names of the shapes in the model", listCommand},
+	{"show", "Show a shape of the assembled model, and optionally the shapes it depends on", showCommand},
+	{"query", "Query the model with a JMESPath expression or a Smithy selector", queryCommand},
+	{"ast", "Output the assembled model as a JSON (or YAML) AST", astCommand},
+	{"lsp", "Run a Language Server Protocol server for editors, on stdin and stdout", lspCommand},
+	{"serve-docs", "Serve the HTML documentation of the model, rebuilt when the model files change", serveDocsCommand},
+	{"version", "Show the tool version", versionCommand},
+}
+
 func main() {
-	conf := data.NewObject()
-	pVersion := flag.Bool("v", false, "Show api tool version and exit")
-	pList := flag.Bool("l", false, "Show only the list of shape names")
-	pForce := flag.Bool("f", false, "Force overwrite if output file exists")
-	pGen := flag.String("g", "idl", "The generator for output")
-	pOutdir := flag.String("o", "", "The directory to generate output into (defaults to stdout)")
-	pSources := flag.Bool("s", false, "Add the source file name as a comment to each parsed shape")
-	var params Params
-	flag.Var(&params, "a", "Additional named arguments for a generator")
-	var tags Tags
-	flag.Var(&tags, "t", "Tag of shapes to include")
+	if len(os.Args) > 1 {
+		name := os.Args[1]
+		if name == "help" || name == "-h" || name == "--help" {
+			helpCommand(os.Args[2:])
+			return
+		}
+		for _, cmd := range commands {
+			if cmd.name == name {
+				cmd.run(os.Args[2:])
+				return
+			}
+		}
+	}
+	//the legacy form, with flags and no command, is the same as "build"
+	buildCommand(os.Args[1:])
+}
 
-	flag.Parse()
-	if *pVersion {
-		fmt.Printf("Smithy tool %s [%s]\n", smithy.ToolVersion, "https://github.com/boynton/smithy")
-		os.Exit(0)
+func helpCommand(args []string) {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				cmd.run([]string{"-h"})
+				return
+			}
+		}
+	}
+	fmt.Println("usage: smithy <command> [flags] file ...")
+	fmt.Println("\nThe commands are:")
+	for _, cmd := range commands {
+		fmt.Printf("    %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Println("\nUse \"smithy help <command>\" for the flags of a command. With no command, the build flags are accepted.")
+}
+
+func versionCommand(args []string) {
+	fmt.Printf("Smithy tool %s [%s]\n", smithy.ToolVersion, "https://github.com/boynton/smithy")
+}
+
+// newFlagSet returns a flag set for the command with a usage message that lists its flags.
+func newFlagSet(name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// modelFlags are the flags of the commands that assemble a model from files.
+type modelFlags struct {
+	config      *string
+	tags        Tags
+	excludeTags Tags
+	selector    *string
+	service     *string
+	strict      *bool
+	errors      *errorFormat
+	parserOpts  []smithy.ParserOption
+}
+
+func addModelFlags(flags *flag.FlagSet) *modelFlags {
+	mf := &modelFlags{
+		config:   flags.String("c", "", "A smithy-build.json style config file, for model sources, dependencies and projections"),
+		selector: flags.String("select", "", "A selector for the shapes to include"),
+		service:  flags.String("service", "", "The id of a service, to include only the shapes it depends on"),
+		strict:   flags.Bool("strict", false, "Reject traits that the model does not define, rather than warning of them"),
+		errors:   addErrorFormatFlag(flags),
+	}
+	flags.Var(&mf.tags, "t", "Tag of shapes to include")
+	flags.Var(&mf.excludeTags, "x", "Tag of shapes to exclude")
+	return mf
+}
+
+// filter removes the shapes that the flags exclude from the model, after it has been validated.
+func (mf *modelFlags) filter(ast *smithy.AST) error {
+	if len(mf.excludeTags) > 0 {
+		ast.FilterExcludeTags(mf.excludeTags)
+	}
+	if *mf.selector != "" {
+		err := ast.FilterBySelector(*mf.selector)
+		if err != nil {
+			return err
+		}
+	}
+	if *mf.service != "" {
+		return ast.FilterByServiceClosure(*mf.service)
+	}
+	return nil
+}
+
+// buildConfig loads the config file, if there is one, exiting if that fails. With no config or files in the
+// arguments, the smithy-build.json in the current directory is used, if there is one.
+func (mf *modelFlags) buildConfig(flags *flag.FlagSet) *smithy.BuildConfig {
+	mf.errors.check(flags)
+	if *mf.config == "" && flags.NArg() == 0 {
+		if _, err := os.Stat(smithy.DefaultBuildConfigFile); err == nil {
+			*mf.config = smithy.DefaultBuildConfigFile
+		}
+	}
+	if *mf.config == "" {
+		return nil
 	}
-	smithy.AnnotateSources = *pSources
-	gen := *pGen
-	outdir := *pOutdir
-	files := flag.Args()
-	if len(files) == 0 {
-		fmt.Println("usage: smithy [-v] [-o outfile] [-g generator] [-a key=val]* file ...")
-		flag.PrintDefaults()
+	buildConfig, err := smithy.LoadBuildConfig(*mf.config)
+	if err != nil {
+		mf.errors.fail(err, 1)
+	}
+	return buildConfig
+}
+
+// files returns the model files of the config and the arguments, and the config, exiting if there is nothing to load.
+func (mf *modelFlags) files(flags *flag.FlagSet) ([]string, *smithy.BuildConfig) {
+	buildConfig := mf.buildConfig(flags)
+	var files []string
+	if buildConfig != nil {
+		files = append(files, buildConfig.Sources...)
+		files = append(files, buildConfig.Imports...)
+	}
+	files = append(files, flags.Args()...)
+	if len(files) == 0 && (buildConfig == nil || buildConfig.Maven == nil) {
+		flags.Usage()
 		os.Exit(1)
 	}
-	ast, err := AssembleModel(files, tags)
+	return files, buildConfig
+}
+
+// assembleFiles loads, validates and filters the model.
+func (mf *modelFlags) assembleFiles(files []string, buildConfig *smithy.BuildConfig) (*smithy.AST, error) {
+	ast, err := AssembleModel(files, mf.tags, buildConfig, *mf.strict, mf.parserOpts...)
+	if err == nil {
+		err = mf.filter(ast)
+	}
+	return ast, err
+}
+
+// assemble loads the files and dependencies of the model, including the sources and imports of the config, validates
+// it, and reports any warnings. It exits if that fails, or if there is nothing to load.
+func (mf *modelFlags) assemble(flags *flag.FlagSet) *smithy.AST {
+	files, buildConfig := mf.files(flags)
+	ast, err := mf.assembleFiles(files, buildConfig)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(2)
+		mf.errors.fail(err, 2)
+	}
+	mf.errors.warnings(ast.Warnings())
+	return ast
+}
+
+func buildCommand(args []string) {
+	flags := newFlagSet("build", "smithy [build] [-c config] [-o outdir] [-g generator] [-a key=val]* [--dry-run] [--manifest file] file ...")
+	pVersion := flags.Bool("v", false, "Show api tool version and exit")
+	pList := flags.Bool("l", false, "Show only the list of shape names (the list command can also filter them)")
+	pForce := flags.Bool("f", false, "Force overwrite if output file exists")
+	pGen := flags.String("g", "idl", "The generator for output ("+strings.Join(smithy.GeneratorNames(), ", ")+"), or exec:program for an external generator")
+	pOutdir := flags.String("o", "", "The directory to generate output into (defaults to stdout)")
+	pSources := flags.Bool("s", false, "Add the source file name as a comment to each parsed shape")
+	var params Params
+	flags.Var(&params, "a", "Additional named arguments for a generator")
+	of := addOutputFlags(flags)
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	if *pVersion {
+		versionCommand(nil)
+		os.Exit(0)
+	}
+	if *pSources {
+		mf.parserOpts = append(mf.parserOpts, smithy.WithSourceAnnotations())
+	}
+	//a config with projections is built as smithy-build would, unless a generator or output is asked for
+	explicit := false
+	flags.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "g" || f.Name == "o" || f.Name == "l"
+	})
+	if buildConfig := mf.buildConfig(flags); buildConfig != nil && buildConfig.HasProjections() && !explicit {
+		err := buildProjections(buildConfig, flags.Args(), mf.tags, *mf.strict, of, mf.parserOpts...)
+		if err == nil {
+			err = of.finish()
+		}
+		if err != nil {
+			mf.errors.fail(err, 4)
+		}
+		return
 	}
+	ast := mf.assemble(flags)
 	if *pList {
-		for _, n := range ast.ShapeNames() {
-			fmt.Println(n)
+		printShapeNames(ast)
+		return
+	}
+	generate(ast, *pGen, *pOutdir, *pForce, params, of)
+}
+
+func validateCommand(args []string) {
+	flags := newFlagSet("validate", "smithy validate [-c config] [-stats [-json]] [--error-format json] [--output sarif|github] file ...")
+	pStats := flags.Bool("stats", false, "Print statistics of the model: shape counts, documentation coverage and trait usage")
+	pJson := flags.Bool("json", false, "Print the statistics as JSON instead of a table")
+	pOutput := flags.String("output", "text", "The format of the errors and warnings: text, to stderr as --error-format says, or sarif or github (GitHub Actions annotations) to stdout")
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	checkOutput(flags, *pOutput, "text", "sarif", "github")
+	if *pOutput != "text" && *pStats {
+		fmt.Fprintf(os.Stderr, "The statistics cannot be printed with --output %s\n", *pOutput)
+		os.Exit(1)
+	}
+	if *pOutput != "text" {
+		//all of the problems are written as one report, and the exit status is as it would be otherwise
+		files, buildConfig := mf.files(flags)
+		ast, err := mf.assembleFiles(files, buildConfig)
+		diags := smithy.ErrorDiagnostics(err)
+		if err == nil {
+			for _, w := range ast.Warnings() {
+				diags = append(diags, w.Diagnostic())
+			}
 		}
-		os.Exit(0)
+		writeDiagnostics(*pOutput, diags)
+		if err != nil {
+			os.Exit(2)
+		}
+		return
+	}
+	ast := mf.assemble(flags)
+	if *pStats {
+		printStats(ast.Stats(), *pJson)
+	}
+}
+
+func listCommand(args []string) {
+	flags := newFlagSet("list", "smithy list [-c config] [-t tag]* [--type type] [--trait trait] [--namespace ns] [--json] file ...")
+	pType := flags.String("type", "", "List only the shapes of the type, i.e. \"operation\"")
+	pTrait := flags.String("trait", "", "List only the shapes with the trait, i.e. \"smithy.api#deprecated\" (prelude traits may omit the namespace)")
+	pNamespace := flags.String("namespace", "", "List only the shapes in the namespace")
+	pJson := flags.Bool("json", false, "Print a JSON array of objects with the id and type of each shape")
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	ast := mf.assemble(flags)
+	ids := ast.ShapeNames()
+	if *pTrait != "" {
+		tid := *pTrait
+		if !strings.Contains(tid, "#") {
+			tid = "smithy.api#" + tid
+		}
+		ids = ast.ShapesWithTrait(tid)
 	}
+	var shapes []map[string]string
+	for _, id := range ids {
+		shape := ast.GetShape(id)
+		if shape == nil {
+			continue //a member
+		}
+		sid := smithy.ShapeID(id)
+		if (*pType != "" && shape.Type != *pType) || (*pNamespace != "" && sid.Namespace() != *pNamespace) {
+			continue
+		}
+		if *pJson {
+			shapes = append(shapes, map[string]string{"id": id, "type": shape.Type})
+		} else {
+			fmt.Println(id)
+		}
+	}
+	if *pJson {
+		if shapes == nil {
+			shapes = make([]map[string]string, 0)
+		}
+		fmt.Print(data.Pretty(shapes))
+	}
+}
+
+func astCommand(args []string) {
+	flags := newFlagSet("ast", "smithy ast [-c config] [-o outdir] [-yaml] file ...")
+	pYaml := flags.Bool("yaml", false, "Output YAML instead of JSON")
+	pForce := flags.Bool("f", false, "Force overwrite if output file exists")
+	pOutdir := flags.String("o", "", "The directory to generate output into (defaults to stdout)")
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	ast := mf.assemble(flags)
+	var params Params
+	if *pYaml {
+		params = append(params, "format=yaml")
+	}
+	generate(ast, "ast", *pOutdir, *pForce, params, nil)
+}
+
+func printShapeNames(ast *smithy.AST) {
+	for _, n := range ast.ShapeNames() {
+		fmt.Println(n)
+	}
+}
+
+// generate runs the generator, with the output flags if they are not nil.
+func generate(ast *smithy.AST, gen string, outdir string, force bool, params Params, of *outputFlags) {
+	conf := data.NewObject()
 	conf.Put("outdir", outdir)
-	conf.Put("force", *pForce)
+	conf.Put("force", force)
+	of.configure(conf)
 	for _, a := range params {
 		kv := strings.Split(a, "=")
 		if len(kv) > 1 {
@@ -75,8 +349,39 @@ func main() {
 		}
 	}
 	generator, err := Generator(gen)
+	//an output path ending in ".zip" is written as a zip archive
+	var zipSink *smithy.ZipSink
+	var zipFile *os.File
+	if err == nil && strings.HasSuffix(outdir, ".zip") {
+		if conf.GetBool("dryRun") {
+			zipSink = smithy.NewZipSink(ioutil.Discard)
+		} else if zipFile, err = os.Create(outdir); err == nil {
+			zipSink = smithy.NewZipSink(zipFile)
+		}
+		if err == nil {
+			if ss, ok := generator.(smithy.SinkSetter); ok {
+				ss.SetSink(zipSink)
+			} else {
+				err = fmt.Errorf("The %s generator cannot write a zip archive", gen)
+			}
+		}
+	}
 	if err == nil {
 		err = generator.Generate(ast, conf)
+		of.record(generator)
+	}
+	if zipSink != nil {
+		if cerr := zipSink.Close(); err == nil {
+			err = cerr
+		}
+		if zipFile != nil {
+			if cerr := zipFile.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err == nil {
+		err = of.finish()
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -104,43 +409,40 @@ func (p *Tags) Set(value string) error {
 	return nil
 }
 
+// Generator returns a new instance of the generator with the name, which is one registered with
+// smithy.RegisterGenerator, or "exec:program" for an external generator.
 func Generator(genName string) (smithy.Generator, error) {
-	switch genName {
-	case "ast":
-		return new(smithy.AstGenerator), nil
-	case "idl":
-		return new(smithy.IdlGenerator), nil
-	case "sadl":
-		return new(smithy.SadlGenerator), nil
-	default:
-		return nil, fmt.Errorf("Unknown generator: %q", genName)
+	if gen := smithy.LookupGenerator(genName); gen != nil {
+		return gen, nil
 	}
+	return nil, fmt.Errorf("Unknown generator: %q", genName)
 }
 
-func AssembleModel(paths []string, tags []string) (*smithy.AST, error) {
-	flatPathList, err := expandPaths(paths)
+func AssembleModel(paths []string, tags []string, config *smithy.BuildConfig, strict bool, opts ...smithy.ParserOption) (*smithy.AST, error) {
+	assembler := smithy.NewAssembler()
+	assembler.ParserOptions = opts
+	assembly, err := assembler.Assemble(paths)
 	if err != nil {
 		return nil, err
 	}
-	assembly := &smithy.AST{
-		Smithy: "1.0",
-	}
-	for _, path := range flatPathList {
-		var ast *smithy.AST
-		var err error
-		ext := filepath.Ext(path)
-		switch ext {
-		case ".json":
-			ast, err = smithy.LoadAST(path)
-		case ".smithy":
-			ast, err = smithy.Parse(path)
-		default:
-			return nil, fmt.Errorf("parse for file type %q not implemented", ext)
-		}
+	if config != nil && config.Maven != nil {
+		jars, err := smithy.ResolveDependencies(config.Maven, "")
 		if err != nil {
 			return nil, err
 		}
-		err = assembly.Merge(ast)
+		for _, jar := range jars {
+			ast, err := smithy.LoadArchive(jar)
+			if err != nil {
+				return nil, err
+			}
+			err = assembly.Merge(ast)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if strict {
+		err = assembly.CheckTraitsDefined()
 		if err != nil {
 			return nil, err
 		}
@@ -154,36 +456,3 @@ func AssembleModel(paths []string, tags []string) (*smithy.AST, error) {
 	}
 	return assembly, nil
 }
-
-var ImportFileExtensions = map[string][]string{
-	".smithy": []string{"smithy"},
-	".json":   []string{"smithy"},
-}
-
-func expandPaths(paths []string) ([]string, error) {
-	var result []string
-	for _, path := range paths {
-		ext := filepath.Ext(path)
-		if _, ok := ImportFileExtensions[ext]; ok {
-			result = append(result, path)
-		} else {
-			fi, err := os.Stat(path)
-			if err != nil {
-				return nil, err
-			}
-			if fi.IsDir() {
-				err = filepath.Walk(path, func(wpath string, info os.FileInfo, errIncoming error) error {
-					if errIncoming != nil {
-						return errIncoming
-					}
-					ext := filepath.Ext(wpath)
-					if _, ok := ImportFileExtensions[ext]; ok {
-						result = append(result, wpath)
-					}
-					return nil
-				})
-			}
-		}
-	}
-	return result, nil
-}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// outputFlags are the flags for the files written by generators: --dry-run, to only print the manifest of the files
+// that would be written, and --manifest, to record the files that were written.
+type outputFlags struct {
+	dryRun   *bool
+	manifest *string
+	files    []*smithy.GeneratedFile
+}
+
+func addOutputFlags(flags *flag.FlagSet) *outputFlags {
+	return &outputFlags{
+		dryRun:   flags.Bool("dry-run", false, "Print the manifest of the files that would be generated, as JSON, without writing them"),
+		manifest: flags.String("manifest", "", "A file to record the generated files in, as JSON, with their sizes and SHA-256 hashes"),
+	}
+}
+
+// configure adds the options of the flags to the config of a generator.
+func (of *outputFlags) configure(conf *data.Object) {
+	if of != nil && *of.dryRun {
+		conf.Put("dryRun", true)
+	}
+}
+
+// record adds the files emitted by the generator to the manifest.
+func (of *outputFlags) record(gen smithy.Generator) {
+	if m, ok := gen.(smithy.ManifestReporter); of != nil && ok {
+		of.files = append(of.files, m.Manifest()...)
+	}
+}
+
+// finish prints the manifest for a dry run, or writes it to the manifest file if there is one.
+func (of *outputFlags) finish() error {
+	if of == nil {
+		return nil
+	}
+	files := of.files
+	if files == nil {
+		files = make([]*smithy.GeneratedFile, 0)
+	}
+	if *of.dryRun {
+		fmt.Print(data.Pretty(files))
+		return nil
+	}
+	if *of.manifest != "" {
+		return ioutil.WriteFile(*of.manifest, []byte(data.Pretty(files)), 0644)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/boynton/data"
+	"github.com/jmespath/go-jmespath"
+)
+
+// queryCommand runs a JMESPath expression over the JSON AST of the assembled model, i.e.
+// "smithy query 'shapes.*.type' model", or with -selector a Smithy selector over the model, i.e.
+// "smithy query -selector 'operation [trait|readonly]' model". Selector matches are printed one id per line. A
+// JMESPath result that is a string, or a list of strings, is printed the same way, and other results as JSON, so the
+// output can be used in shell pipelines.
+func queryCommand(args []string) {
+	flags := newFlagSet("query", "smithy query [-selector] [-json] expression file ...")
+	pSelector := flags.Bool("selector", false, "The expression is a Smithy selector, rather than JMESPath")
+	pJson := flags.Bool("json", false, "Print the result as JSON, even if it is a list of strings")
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	expr := flags.Arg(0)
+	flags.Parse(flags.Args()[1:])
+	ast := mf.assemble(flags)
+	var result interface{}
+	if *pSelector {
+		ids, err := ast.Select(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		result = ids
+	} else {
+		var doc interface{}
+		b, err := json.Marshal(ast)
+		if err == nil {
+			err = json.Unmarshal(b, &doc)
+		}
+		if err == nil {
+			result, err = jmespath.Search(expr, doc)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if !*pJson {
+		if lines, ok := stringLines(result); ok {
+			for _, line := range lines {
+				fmt.Println(line)
+			}
+			return
+		}
+	}
+	fmt.Print(data.Pretty(result))
+}
+
+// stringLines returns the result as lines, if it is a string or a list of strings.
+func stringLines(result interface{}) ([]string, bool) {
+	switch v := result.(type) {
+	case string:
+		return []string{v}, true
+	case []string:
+		return v, true
+	case []interface{}:
+		var lines []string
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			lines = append(lines, s)
+		}
+		return lines, true
+	}
+	return nil, false
+}
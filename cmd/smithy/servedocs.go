@@ -0,0 +1,182 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// the script added to the page, which reloads it when the version served by /_version changes
+const docsReloadScript = `<script>
+(function() {
+  var version = "%d";
+  setInterval(function() {
+    fetch("/_version").then(function(r) { return r.text(); }).then(function(v) {
+      if (v !== version) { location.reload(); }
+    }).catch(function() {});
+  }, 1000);
+})();
+</script>
+`
+
+// serveDocsCommand assembles the model and serves its HTML documentation, i.e. "smithy serve-docs -addr :8000
+// model.smithy". The model files are checked for changes every second, and the page is rebuilt and reloaded in the
+// browser when they do. If the model fails to assemble, its errors are shown instead, until it is fixed.
+func serveDocsCommand(args []string) {
+	flags := newFlagSet("serve-docs", "smithy serve-docs [-c config] [-addr host:port] [-a key=val]* file ...")
+	pAddr := flags.String("addr", "localhost:8000", "The address to serve the documentation on")
+	var params Params
+	flags.Var(&params, "a", "Additional named arguments for the html generator, i.e. title=\"My API\"")
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	files, buildConfig := mf.files(flags)
+	docs := &docsServer{mf: mf, files: files, buildConfig: buildConfig, params: params}
+	docs.rebuild()
+	go docs.watch(time.Second)
+	http.HandleFunc("/_version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, docs.currentVersion())
+	})
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(docs.currentPage())
+	})
+	fmt.Fprintf(os.Stderr, "Serving documentation on http://%s/\n", *pAddr)
+	err := http.ListenAndServe(*pAddr, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type docsServer struct {
+	mf          *modelFlags
+	files       []string
+	buildConfig *smithy.BuildConfig
+	params      Params
+	lock        sync.Mutex
+	version     int
+	page        []byte
+}
+
+func (s *docsServer) currentVersion() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.version
+}
+
+func (s *docsServer) currentPage() []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.page
+}
+
+// rebuild assembles the model and generates the page, or an error page if that fails, with the reload script.
+func (s *docsServer) rebuild() {
+	page, err := s.generate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		var sb strings.Builder
+		sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Model errors</title>\n</head>\n<body>\n")
+		sb.WriteString("<h1>The model has errors</h1>\n<pre>\n")
+		for _, d := range smithy.ErrorDiagnostics(err) {
+			if d.File != "" {
+				fmt.Fprintf(&sb, "%s:%d:%d: ", html.EscapeString(d.File), d.Line, d.Column)
+			}
+			fmt.Fprintf(&sb, "[%s] %s\n", d.Severity, html.EscapeString(d.Message))
+		}
+		sb.WriteString("</pre>\n</body>\n</html>\n")
+		page = sb.String()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.version++
+	script := fmt.Sprintf(docsReloadScript, s.version)
+	if n := strings.LastIndex(page, "</body>"); n >= 0 {
+		page = page[:n] + script + page[n:]
+	} else {
+		page = page + script
+	}
+	s.page = []byte(page)
+}
+
+func (s *docsServer) generate() (string, error) {
+	ast, err := s.mf.assembleFiles(s.files, s.buildConfig)
+	if err != nil {
+		return "", err
+	}
+	sink := smithy.NewMemorySink()
+	generator := smithy.LookupGenerator("html")
+	generator.(smithy.SinkSetter).SetSink(sink)
+	conf := data.NewObject()
+	for _, a := range s.params {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) > 1 {
+			conf.Put(kv[0], kv[1])
+		} else {
+			conf.Put(a, true)
+		}
+	}
+	err = generator.Generate(ast, conf)
+	if err != nil {
+		return "", err
+	}
+	return string(sink.Files["index.html"]), nil
+}
+
+// watch rebuilds the page whenever the size or modification time of a model file changes, or files are added or
+// removed.
+func (s *docsServer) watch(interval time.Duration) {
+	last := s.snapshot()
+	for {
+		time.Sleep(interval)
+		current := s.snapshot()
+		if current != last {
+			last = current
+			s.rebuild()
+		}
+	}
+}
+
+func (s *docsServer) snapshot() string {
+	paths, err := smithy.ExpandPaths(s.files)
+	if err != nil {
+		return err.Error()
+	}
+	var sb strings.Builder
+	for _, path := range paths {
+		sb.WriteString(path)
+		if fi, err := os.Stat(path); err == nil {
+			sb.WriteString(" " + strconv.FormatInt(fi.Size(), 10) + " " + fi.ModTime().String())
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boynton/smithy"
+)
+
+// showCommand prints one shape of the assembled model, i.e. "smithy show weather#GetForecast model", as IDL or JSON.
+// The mixins of the shape are resolved, so it has all of its members and traits. An operation is shown with its input
+// and output, and with -closure all the shapes it depends on are shown too. With -lazy, the model is a single JSON AST
+// file, which is not validated, and only the shapes shown are decoded, which is much faster for huge models.
+func showCommand(args []string) {
+	flags := newFlagSet("show", "smithy show [-closure] [-json] [-lazy] shapeId file ...")
+	pClosure := flags.Bool("closure", false, "Also show the shapes that the shape depends on")
+	pJson := flags.Bool("json", false, "Show the JSON AST instead of IDL")
+	pLazy := flags.Bool("lazy", false, "Load the model from a single JSON AST file, decoding only the shapes shown")
+	mf := addModelFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	id := flags.Arg(0)
+	if _, err := smithy.ParseShapeID(id); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	flags.Parse(flags.Args()[1:])
+	var ast *smithy.AST
+	if *pLazy {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(1)
+		}
+		var err error
+		ast, err = smithy.LoadASTLazy(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(2)
+		}
+	} else {
+		ast = mf.assemble(flags)
+	}
+	shape := ast.GetShape(id)
+	if shape == nil {
+		fmt.Fprintf(os.Stderr, "Shape not found: %s\n", id)
+		os.Exit(2)
+	}
+	closure := ast.ShapeClosure(id)
+	if err := ast.Shapes.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	resolved := &smithy.AST{Smithy: ast.Smithy}
+	for _, sid := range closure {
+		resolved.PutShape(sid, ast.GetShape(sid).Clone())
+	}
+	if !shape.Traits.Has("smithy.api#mixin") {
+		resolved.FlattenMixins()
+	}
+	//an operation is shown with its input and output, which IDL shows inline
+	included := map[string]bool{id: true}
+	for _, ref := range []*smithy.ShapeRef{shape.Input, shape.Output} {
+		if ref != nil {
+			included[ref.Target] = true
+		}
+	}
+	shown := &smithy.AST{Smithy: ast.Smithy}
+	for _, sid := range closure {
+		if (*pClosure || included[sid]) && resolved.GetShape(sid) != nil {
+			shown.PutShape(sid, resolved.GetShape(sid))
+		}
+	}
+	gen := "idl"
+	if *pJson {
+		gen = "ast"
+	}
+	generate(shown, gen, "", false, nil, nil)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/boynton/data"
+	"github.com/boynton/smithy"
+)
+
+// printStats prints the statistics of the model as a table, or as JSON.
+func printStats(stats *smithy.Stats, asJson bool) {
+	if asJson {
+		fmt.Print(data.Pretty(stats))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "shapes\t%d\n", stats.Shapes)
+	fmt.Fprintf(w, "members\t%d\n", stats.Members)
+	fmt.Fprintf(w, "documented\t%d\t%.1f%%\n", stats.Documented, stats.DocumentationCoverage)
+	printCounts(w, "type", stats.ShapeTypes, false)
+	printCounts(w, "namespace", stats.Namespaces, false)
+	printCounts(w, "trait", stats.Traits, true)
+	w.Flush()
+}
+
+// printCounts prints a row for each of the counts, sorted by key, or by descending count if byCount is true.
+func printCounts(w *tabwriter.Writer, label string, counts map[string]int, byCount bool) {
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if byCount && counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", label, k, counts[k])
+	}
+}
@@ -0,0 +1,613 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// CST is the lossless form of a parsed IDL file: its AST, and what the AST does not keep, which is every token of the
+// source with its position, including comments and newlines, and the comments other than documentation, attached to
+// the statements they are next to. Writing the AST as IDL with the CST in the IdlOptions puts the comments back.
+type CST struct {
+	AST              *AST
+	Namespace        string     //the namespace the file declares
+	Tokens           []Token    //every token of the source, in order
+	Header           []*Comment //the comments before the $version statement, or the namespace statement if there is none
+	Version          *Comment   //the comment at the end of the line of the $version statement
+	NamespaceComment *Comment   //the comment at the end of the line of the namespace statement
+	Preamble         []*Comment //the other comments before the namespace statement, that are not before metadata
+	Trailer          []*Comment //the comments after the last statement
+	src              string
+	nodes            map[string]*CSTNode
+}
+
+// CSTNode is what the CST keeps about a statement beyond the AST. The statement is a shape or member, a property of a
+// service, resource or operation, a metadata statement, or the apply statements of a shape or member.
+type CSTNode struct {
+	Id            string
+	Line          int
+	Column        int
+	EndLine       int                 //the line of the closing brace of a shape with a body, or the last line of the statement
+	Comments      []*Comment          //the comments before it, and those at the end of the first line of a shape
+	Trailing      *Comment            //the comment at the end of the last line of a statement that is not a shape
+	Closing       []*Comment          //the comments after the last member of a shape, before its closing brace
+	Traits        map[string]string   //the source of the traits applied to it that have comments in their arguments, by id
+	TraitComments map[string]*Comment //the comments at the end of the lines of the traits applied to it, by id
+	Source        string              //the source of a property that has comments in its value
+	offset        int                 //of its first token
+	end           int                 //the offset after the last token of a property that has its source kept
+	body          [2]int              //the offsets of the braces around the body of a shape, or zero if it has none
+	scope         *CSTNode            //the shape in the body of which the statement is, if any
+	shape         bool
+}
+
+// Comment is a line ("//") or block ("/* */") comment that is not documentation.
+type Comment struct {
+	Text       string //without the delimiters
+	Block      bool
+	Line       int
+	Column     int
+	BlankAfter bool //followed by a blank line
+}
+
+func (c *Comment) String() string {
+	if c.Block {
+		return "/*" + c.Text + "*/"
+	}
+	return "//" + c.Text
+}
+
+// ParseCST parses the IDL source text as ParseString does, keeping the comments and token positions as well. The traits
+// of apply statements are kept pending on the AST rather than applied, so that they are written back as apply
+// statements.
+func ParseCST(src string, name string, opts ...ParserOption) (*CST, error) {
+	ast, err := ParseString(src, name, append(opts, withAppliedKept())...)
+	if err != nil {
+		return nil, err
+	}
+	cst := &CST{
+		AST:    ast,
+		Tokens: Tokenize(src),
+		src:    src,
+		nodes:  make(map[string]*CSTNode, 0),
+	}
+	cst.attachComments()
+	return cst, nil
+}
+
+// Node returns what the CST keeps about the statement with the id, or nil if it was not parsed from the source. The id
+// of a shape or member is its shape id (i.e. "ns#Shape$member"), that of a property of a service, resource or
+// operation is the shape id and the property name (i.e. "ns#Service.operations"), that of a metadata statement is
+// "metadata key", and that of the apply statements of a shape or member is "apply ns#Shape$member".
+func (cst *CST) Node(id string) *CSTNode {
+	if cst == nil {
+		return nil
+	}
+	return cst.nodes[id]
+}
+
+// Comments returns all of the comments of the source other than documentation, in order.
+func (cst *CST) Comments() []*Comment {
+	var comments []*Comment
+	for i := range cst.Tokens {
+		if c := cst.comment(i); c != nil {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+func (cst *CST) comment(i int) *Comment {
+	tok := cst.Tokens[i]
+	if tok.Type == BLOCK_COMMENT || (tok.Type == LINE_COMMENT && !tok.IsDocComment()) {
+		c := &Comment{Text: tok.Text, Block: tok.Type == BLOCK_COMMENT, Line: tok.Line, Column: tok.Start}
+		next := i + 1
+		if next < len(cst.Tokens) && cst.Tokens[next].Type == NEWLINE {
+			next++
+		}
+		c.BlankAfter = next < len(cst.Tokens) && cst.Tokens[next].Type == NEWLINE
+		return c
+	}
+	return nil
+}
+
+// significant returns true if the token is neither a comment nor a newline.
+func significant(tok Token) bool {
+	return tok.Type != LINE_COMMENT && tok.Type != BLOCK_COMMENT && tok.Type != NEWLINE
+}
+
+// nextSignificant returns the index of the first significant token at or after i, or the number of tokens if there is
+// none.
+func (cst *CST) nextSignificant(i int) int {
+	for i < len(cst.Tokens) && !significant(cst.Tokens[i]) {
+		i++
+	}
+	return i
+}
+
+// startsLine returns true if the token at i is the first significant token of its line.
+func (cst *CST) startsLine(i int) bool {
+	for j := i - 1; j >= 0 && cst.Tokens[j].Line == cst.Tokens[i].Line; j-- {
+		if significant(cst.Tokens[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+// shapeIdAt returns the shape id (or name) written as the adjacent tokens starting at i, and the index after them.
+func (cst *CST) shapeIdAt(i int) (string, int) {
+	start := i
+	for i < len(cst.Tokens) {
+		tok := cst.Tokens[i]
+		if tok.Type != SYMBOL && tok.Type != DOT && tok.Type != HASH && tok.Type != DOLLAR {
+			break
+		}
+		if i > start && tok.Offset != cst.Tokens[i-1].End {
+			break
+		}
+		i++
+	}
+	if i == start {
+		return "", i
+	}
+	return cst.src[cst.Tokens[start].Offset:cst.Tokens[i-1].End], i
+}
+
+// closing returns the index of the token that closes the bracket, brace or parenthesis at i.
+func (cst *CST) closing(i int) int {
+	depth := 0
+	for j := i; j < len(cst.Tokens); j++ {
+		switch cst.Tokens[j].Type {
+		case OPEN_BRACE, OPEN_BRACKET, OPEN_PAREN:
+			depth++
+		case CLOSE_BRACE, CLOSE_BRACKET, CLOSE_PAREN:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(cst.Tokens) - 1
+}
+
+// lastLine returns the line of the last character of the token, which is a later line than the first for a text block.
+func (cst *CST) lastLine(i int) int {
+	tok := cst.Tokens[i]
+	return tok.Line + strings.Count(cst.src[tok.Offset:tok.End], "\n")
+}
+
+// source returns the source from the token at i through the one at j, without the indentation of the line of the
+// first in the lines after it, so that it can be written at another indentation.
+func (cst *CST) source(i, j int) string {
+	text := cst.src[cst.Tokens[i].Offset:cst.Tokens[j].End]
+	lineStart := strings.LastIndex(cst.src[:cst.Tokens[i].Offset], "\n") + 1
+	if indent := cst.src[lineStart:cst.Tokens[i].Offset]; strings.TrimSpace(indent) == "" && indent != "" {
+		text = strings.ReplaceAll(text, "\n"+indent, "\n")
+	}
+	return text
+}
+
+// hasComment returns true if there is a comment after the token at i, and before the one at j.
+func (cst *CST) hasComment(i, j int) bool {
+	for k := i + 1; k < j; k++ {
+		if cst.comment(k) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (cst *CST) addNode(node *CSTNode) *CSTNode {
+	if prev, ok := cst.nodes[node.Id]; ok {
+		//apply statements of the same shape or member share a node
+		prev.EndLine = node.EndLine
+		return prev
+	}
+	cst.nodes[node.Id] = node
+	return node
+}
+
+// attachComments finds the statements of the source, and attaches each comment to the next one within the innermost
+// shape the comment is in, or to the end of that shape if there is none. A comment at the end of the line of a
+// statement is attached as its trailing comment, except that of a shape, which is attached before it. Comments in the
+// arguments of a trait, or in the value of a property, are kept with the source of the trait or property. Comments
+// before the namespace statement are the header, those at the end of the $version statement its comment, and those
+// after the last statement the trailer.
+func (cst *CST) attachComments() {
+	index := make(map[[2]int]int, 0) //the index of the token at each line and column
+	for i, tok := range cst.Tokens {
+		index[[2]int{tok.Line, tok.Start}] = i
+	}
+	var nodes []*CSTNode
+	add := func(node *CSTNode) {
+		if n := cst.addNode(node); n == node {
+			nodes = append(nodes, node)
+		}
+	}
+	for _, id := range cst.AST.Shapes.Keys() {
+		shape := cst.AST.GetShape(id)
+		if shape.location == nil {
+			continue
+		}
+		node := &CSTNode{Id: id, Line: shape.location.Line, Column: shape.location.Column, EndLine: shape.location.Line, shape: true}
+		if i, ok := index[[2]int{node.Line, node.Column}]; ok {
+			node.offset = cst.Tokens[i].Offset
+			if open, close := cst.shapeBody(shape, i); open >= 0 {
+				node.body = [2]int{cst.Tokens[open].Offset, cst.Tokens[close].Offset}
+				node.EndLine = cst.Tokens[close].Line
+				if shape.Type == "service" || shape.Type == "resource" || shape.Type == "operation" {
+					for _, prop := range cst.properties(id, open, close) {
+						add(prop)
+					}
+				}
+			}
+		}
+		add(node)
+		for _, mid := range memberIds(id, shape) {
+			if mem := cst.AST.getMember(mid); mem != nil && mem.location != nil {
+				mnode := &CSTNode{Id: mid, Line: mem.location.Line, Column: mem.location.Column, EndLine: mem.location.Line}
+				if i, ok := index[[2]int{mnode.Line, mnode.Column}]; ok {
+					mnode.offset = cst.Tokens[i].Offset
+				}
+				add(mnode)
+			}
+		}
+	}
+	nsLine, versionLine := 0, 0
+	depth := 0
+	applyTraits := make(map[int]*CSTNode, 0) //the apply statement of the trait at each index
+	for i, tok := range cst.Tokens {
+		switch tok.Type {
+		case OPEN_BRACE, OPEN_BRACKET, OPEN_PAREN:
+			depth++
+		case CLOSE_BRACE, CLOSE_BRACKET, CLOSE_PAREN:
+			depth--
+		}
+		if depth != 0 || !cst.startsLine(i) {
+			continue
+		}
+		switch {
+		case tok.Type == SYMBOL && tok.Text == "namespace" && nsLine == 0:
+			nsLine = tok.Line
+			cst.Namespace, _ = cst.shapeIdAt(cst.nextSignificant(i + 1))
+		case tok.Type == DOLLAR && i+1 < len(cst.Tokens) && cst.Tokens[i+1].Text == "version":
+			versionLine = tok.Line
+		case tok.Type == SYMBOL && tok.Text == "metadata":
+			k := cst.nextSignificant(i + 1)
+			if k < len(cst.Tokens) {
+				node := &CSTNode{Id: "metadata " + cst.Tokens[k].Text, Line: tok.Line, Column: tok.Start, offset: tok.Offset}
+				node.EndLine = cst.lastLine(cst.statementEnd(i))
+				add(node)
+			}
+		case tok.Type == SYMBOL && tok.Text == "apply":
+			target, next := cst.shapeIdAt(cst.nextSignificant(i + 1))
+			node := &CSTNode{Id: "apply " + cst.resolveId(target), Line: tok.Line, Column: tok.Start, offset: tok.Offset}
+			node.EndLine = cst.lastLine(cst.statementEnd(i))
+			node = cst.addNode(node)
+			if node.offset == tok.Offset {
+				nodes = append(nodes, node)
+			}
+			if at := cst.nextSignificant(next); at < len(cst.Tokens) && cst.Tokens[at].Type == AT {
+				applyTraits[at] = node
+			}
+		}
+	}
+	for _, n := range nodes {
+		n.scope = cst.scopeAt(nodes, n.offset, n)
+	}
+	kept := make(map[int]bool, 0)             //the comments kept in the source of a trait or property
+	traitLines := make(map[int]*traitLine, 0) //the trait that ends each line that one does
+	for _, n := range nodes {
+		if n.Source != "" {
+			for i, tok := range cst.Tokens {
+				if tok.Offset > n.offset && tok.End <= n.end && cst.comment(i) != nil {
+					kept[i] = true
+				}
+			}
+		}
+	}
+	for i, tok := range cst.Tokens {
+		if tok.Type != AT || kept[i] {
+			continue
+		}
+		name, next := cst.shapeIdAt(i + 1)
+		end := next - 1
+		open := cst.nextSignificant(next)
+		if open < len(cst.Tokens) && cst.Tokens[open].Type == OPEN_PAREN {
+			end = cst.closing(open)
+		}
+		owner := applyTraits[i]
+		applied := owner != nil
+		if owner == nil {
+			owner = cst.nextNode(nodes, cst.scopeAt(nodes, tok.Offset, nil), cst.Tokens[end].End)
+		}
+		if owner == nil {
+			continue
+		}
+		tid := cst.traitId(owner, name)
+		if tid == "" {
+			continue
+		}
+		if line := cst.lastLine(end); !applied && line != owner.Line {
+			//a comment at the end of the line of the statement itself trails the statement
+			traitLines[line] = &traitLine{owner, tid}
+		}
+		if end > open && cst.hasComment(open, end) {
+			if owner.Traits == nil {
+				owner.Traits = make(map[string]string, 0)
+			}
+			owner.Traits[tid] = cst.source(i, end)
+			for k := open + 1; k < end; k++ {
+				kept[k] = true
+			}
+		}
+	}
+	endsLine := make(map[int]bool, 0) //lines with something other than a comment before their end
+	for i, tok := range cst.Tokens {
+		c := cst.comment(i)
+		if c == nil {
+			if tok.Type != NEWLINE && tok.Type != LINE_COMMENT {
+				endsLine[tok.Line] = true
+			}
+			continue
+		}
+		if c.Block {
+			endsLine[tok.Line+strings.Count(tok.Text, "\n")] = true
+		}
+		if kept[i] {
+			continue
+		}
+		scope := cst.scopeAt(nodes, tok.Offset, nil)
+		if endsLine[c.Line] && !c.Block {
+			if scope == nil && c.Line == versionLine {
+				cst.Version = c
+				continue
+			}
+			if scope == nil && c.Line == nsLine {
+				cst.NamespaceComment = c
+				continue
+			}
+			if t := traitLines[c.Line]; t != nil {
+				if t.node.TraitComments == nil {
+					t.node.TraitComments = make(map[string]*Comment, 0)
+				}
+				t.node.TraitComments[t.id] = c
+				continue
+			}
+			if n := trailed(nodes, scope, c.Line); n != nil {
+				if n.shape {
+					n.Comments = append(n.Comments, c)
+				} else {
+					n.Trailing = c
+				}
+				continue
+			}
+		}
+		next := cst.nextNode(nodes, scope, tok.End)
+		switch {
+		case scope == nil && c.Line < versionLine:
+			cst.Header = append(cst.Header, c)
+		case nsLine > 0 && c.Line < nsLine && (next == nil || next.Line > nsLine):
+			if versionLine == 0 {
+				cst.Header = append(cst.Header, c)
+			} else {
+				cst.Preamble = append(cst.Preamble, c)
+			}
+		case next != nil:
+			next.Comments = append(next.Comments, c)
+		case scope != nil:
+			scope.Closing = append(scope.Closing, c)
+		default:
+			cst.Trailer = append(cst.Trailer, c)
+		}
+	}
+	for _, n := range nodes {
+		if len(n.Closing) > 0 {
+			n.Closing[len(n.Closing)-1].BlankAfter = false
+		}
+	}
+	for _, comments := range [][]*Comment{cst.Preamble, cst.Trailer} {
+		if len(comments) > 0 {
+			comments[len(comments)-1].BlankAfter = false
+		}
+	}
+}
+
+// traitLine is a trait applied to a statement, as the last thing on a line.
+type traitLine struct {
+	node *CSTNode
+	id   string
+}
+
+// trailed returns the statement in the scope that a comment at the end of the line ends, if any: a member or shape that
+// starts on the line, or another statement that ends on it.
+func trailed(nodes []*CSTNode, scope *CSTNode, line int) *CSTNode {
+	for _, n := range nodes {
+		if n.scope == scope && n.Trailing == nil && ((n.shape && n.Line == line) || (!n.shape && n.EndLine == line)) {
+			return n
+		}
+	}
+	for _, n := range nodes {
+		//a member of a shape without a body on the line, i.e. that of a list written on one line
+		if n.Line == line && !n.shape && n.Trailing == nil && strings.Contains(n.Id, "$") && !strings.HasPrefix(n.Id, "apply ") {
+			return n
+		}
+	}
+	return nil
+}
+
+// scopeAt returns the innermost shape with a body that the offset is in, other than the node itself.
+func (cst *CST) scopeAt(nodes []*CSTNode, offset int, self *CSTNode) *CSTNode {
+	var scope *CSTNode
+	for _, n := range nodes {
+		if n != self && n.body[1] > 0 && n.body[0] < offset && offset < n.body[1] {
+			if scope == nil || n.body[0] > scope.body[0] {
+				scope = n
+			}
+		}
+	}
+	return scope
+}
+
+// nextNode returns the first statement in the scope that starts after the offset, or nil if there is none.
+func (cst *CST) nextNode(nodes []*CSTNode, scope *CSTNode, offset int) *CSTNode {
+	var next *CSTNode
+	for _, n := range nodes {
+		if n.scope == scope && n.offset >= offset && (next == nil || n.offset < next.offset) {
+			next = n
+		}
+	}
+	return next
+}
+
+// statementEnd returns the index of the last significant token of the top level statement that starts at i.
+func (cst *CST) statementEnd(i int) int {
+	last := i
+	for j := i; j < len(cst.Tokens); j++ {
+		tok := cst.Tokens[j]
+		if j > i && significant(tok) && cst.startsLine(j) {
+			break
+		}
+		switch tok.Type {
+		case OPEN_BRACE, OPEN_BRACKET, OPEN_PAREN:
+			j = cst.closing(j)
+			last = j
+			continue
+		}
+		if significant(tok) {
+			last = j
+		}
+	}
+	return last
+}
+
+// shapeBody returns the indexes of the braces around the body of the shape whose statement starts at i, or -1 if it
+// has none.
+func (cst *CST) shapeBody(shape *Shape, i int) (int, int) {
+	switch shape.Type {
+	case "structure", "union", "enum", "intEnum", "list", "set", "map", "service", "resource", "operation":
+	default:
+		return -1, -1
+	}
+	for j := i; j < len(cst.Tokens); j++ {
+		switch cst.Tokens[j].Type {
+		case OPEN_BRACE:
+			return j, cst.closing(j)
+		case OPEN_PAREN, OPEN_BRACKET:
+			j = cst.closing(j)
+		}
+	}
+	return -1, -1
+}
+
+// properties returns the properties of the service, resource or operation with the id whose body is between the
+// braces at open and close, other than inline input and output structures, which are shapes of their own.
+func (cst *CST) properties(id string, open, close int) []*CSTNode {
+	var props []*CSTNode
+	var prop *CSTNode
+	var start, last int
+	end := func() {
+		if prop != nil {
+			prop.EndLine = cst.lastLine(last)
+			if cst.hasComment(start, last) {
+				prop.Source = cst.source(start-1, last)
+				prop.end = cst.Tokens[last].End
+			}
+			props = append(props, prop)
+			prop = nil
+		}
+	}
+	for i := open + 1; i < close; i++ {
+		tok := cst.Tokens[i]
+		if tok.Type == SYMBOL && i+1 < close && cst.Tokens[i+1].Type == COLON {
+			end()
+			if i+2 < close && cst.Tokens[i+2].Type == EQUALS {
+				i = cst.skipInline(i+3, close)
+				continue
+			}
+			prop = &CSTNode{Id: id + "." + tok.Text, Line: tok.Line, Column: tok.Start, offset: tok.Offset}
+			start, last = i+1, i+1
+			continue
+		}
+		switch tok.Type {
+		case OPEN_BRACE, OPEN_BRACKET, OPEN_PAREN:
+			i = cst.closing(i)
+			last = i
+		case COMMA:
+		default:
+			if significant(tok) {
+				last = i
+			}
+		}
+	}
+	end()
+	return props
+}
+
+// skipInline returns the index of the closing brace of the inline structure after the ":=" at i.
+func (cst *CST) skipInline(i, close int) int {
+	for ; i < close; i++ {
+		switch cst.Tokens[i].Type {
+		case OPEN_BRACE:
+			return cst.closing(i)
+		case OPEN_PAREN, OPEN_BRACKET:
+			i = cst.closing(i)
+		}
+	}
+	return close
+}
+
+// resolveId returns the absolute shape id of the target of an apply statement, as the parser resolves it.
+func (cst *CST) resolveId(target string) string {
+	if strings.Contains(target, "#") {
+		return target
+	}
+	sid := ShapeID(target)
+	name := string(sid.Shape())
+	id := ShapeID(cst.Namespace + "#" + name)
+	for _, used := range cst.AST.Uses(cst.Namespace) {
+		if ShapeID(used).Name() == name {
+			id = ShapeID(used)
+		}
+	}
+	if m := sid.Member(); m != "" {
+		id = id.WithMember(m)
+	}
+	return string(id)
+}
+
+// traitId returns the id of the trait with the name as written that is applied to the statement, or "" if there is
+// none.
+func (cst *CST) traitId(node *CSTNode, name string) string {
+	var traits *data.Object
+	if id := strings.TrimPrefix(node.Id, "apply "); id != node.Id {
+		traits = cst.AST.applied.GetObject(id)
+	} else if strings.Contains(node.Id, "$") {
+		traits = cst.AST.getMember(node.Id).Traits
+	} else if shape := cst.AST.GetShape(node.Id); shape != nil {
+		traits = shape.Traits
+	}
+	for _, k := range traits.Keys() {
+		if k == name || ShapeID(k).Name() == name {
+			return k
+		}
+	}
+	return ""
+}
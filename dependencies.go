@@ -0,0 +1,289 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const DefaultMavenRepository = "https://repo.maven.apache.org/maven2"
+
+// MavenConfig describes the model dependencies of a build, as coordinates of the form "group:artifact:version".
+type MavenConfig struct {
+	Dependencies []string           `json:"dependencies,omitempty"`
+	Repositories []*MavenRepository `json:"repositories,omitempty"`
+}
+
+type MavenRepository struct {
+	Url             string `json:"url"`
+	HttpCredentials string `json:"httpCredentials,omitempty"` //"user:password"
+}
+
+// DefaultMavenCache returns the local repository directory that downloaded artifacts are kept in.
+func DefaultMavenCache() string {
+	if dir := os.Getenv("SMITHY_MAVEN_CACHE"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "smithy-maven")
+	}
+	return filepath.Join(home, ".m2", "repository")
+}
+
+// ResolveDependencies returns the local paths of the JARs for the dependencies in the config, downloading any that are
+// not already in the cache directory. Only the listed artifacts are fetched: their own dependencies (from the pom) are
+// not followed, so every model JAR needed must be listed.
+func ResolveDependencies(config *MavenConfig, cacheDir string) ([]string, error) {
+	return ResolveDependenciesContext(context.Background(), config, cacheDir)
+}
+
+// ResolveDependenciesContext resolves the dependencies as ResolveDependencies does, with the downloads stopped if
+// the context is cancelled or its deadline passes.
+func ResolveDependenciesContext(ctx context.Context, config *MavenConfig, cacheDir string) ([]string, error) {
+	if config == nil || len(config.Dependencies) == 0 {
+		return nil, nil
+	}
+	if cacheDir == "" {
+		cacheDir = DefaultMavenCache()
+	}
+	repos := config.Repositories
+	if len(repos) == 0 {
+		repos = []*MavenRepository{&MavenRepository{Url: DefaultMavenRepository}}
+	}
+	var result []string
+	for _, coord := range config.Dependencies {
+		rel, err := mavenArtifactPath(coord)
+		if err != nil {
+			return nil, err
+		}
+		local := filepath.Join(cacheDir, filepath.FromSlash(rel))
+		if _, err := os.Stat(local); err != nil {
+			err = downloadArtifact(ctx, repos, rel, local)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot resolve dependency %q: %v", coord, err)
+			}
+		}
+		result = append(result, local)
+	}
+	return result, nil
+}
+
+// mavenArtifactPath maps "group:artifact:version" to the repository-relative path of the artifact's JAR.
+func mavenArtifactPath(coord string) (string, error) {
+	parts := strings.Split(coord, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("Bad maven coordinate, expected group:artifact:version: %q", coord)
+	}
+	group, artifact, version := parts[0], parts[1], parts[2]
+	return path.Join(strings.Replace(group, ".", "/", -1), artifact, version, artifact+"-"+version+".jar"), nil
+}
+
+func downloadArtifact(ctx context.Context, repos []*MavenRepository, rel string, local string) error {
+	var errs []string
+	for _, repo := range repos {
+		url := strings.TrimSuffix(repo.Url, "/") + "/" + rel
+		err := downloadFile(ctx, repo, url, local)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		errs = append(errs, err.Error())
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+func fetch(ctx context.Context, repo *MavenRepository, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if repo.HttpCredentials != "" {
+		i := strings.Index(repo.HttpCredentials, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("Bad httpCredentials for %s, expected user:password", repo.Url)
+		}
+		req.SetBasicAuth(repo.HttpCredentials[:i], repo.HttpCredentials[i+1:])
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	return res, nil
+}
+
+// downloadFile fetches url into local, verifying the checksum when the repository publishes one. The file is written
+// to a temporary name first so that an interrupted download never leaves a partial artifact in the cache.
+func downloadFile(ctx context.Context, repo *MavenRepository, url string, local string) error {
+	res, err := fetch(ctx, repo, url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	err = os.MkdirAll(filepath.Dir(local), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(local), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha1.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), res.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if sres, err := fetch(ctx, repo, url+".sha1"); err == nil {
+		b, _ := ioutil.ReadAll(sres.Body)
+		sres.Body.Close()
+		fields := strings.Fields(string(b))
+		if len(fields) > 0 && !strings.EqualFold(fields[0], hex.EncodeToString(h.Sum(nil))) {
+			return fmt.Errorf("Checksum mismatch for %s", url)
+		}
+	}
+	return os.Rename(tmp.Name(), local)
+}
+
+// LoadArchive loads the models embedded in a JAR or zip file. If the archive has a META-INF/smithy/manifest, the
+// files it lists are loaded, otherwise every .smithy and .json file under META-INF/smithy is. An archive with nothing
+// under META-INF/smithy, such as a plain zip of a model directory, has all of its .smithy and .json files loaded.
+func LoadArchive(archivePath string) (*AST, error) {
+	return loadArchive(context.Background(), archivePath)
+}
+
+func loadArchive(ctx context.Context, archivePath string, opts ...ParserOption) (*AST, error) {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open model archive: %v", err)
+	}
+	defer r.Close()
+	return loadZip(ctx, archivePath, &r.Reader, opts...)
+}
+
+// loadZip loads the model files of an archive that has been opened, as loadArchive does.
+func loadZip(ctx context.Context, archivePath string, r *zip.Reader, opts ...ParserOption) (*AST, error) {
+	const prefix = "META-INF/smithy/"
+	files := make(map[string]*zip.File)
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+	var names []string
+	if mf, ok := files[prefix+"manifest"]; ok {
+		rc, err := mf.Open()
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(rc)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				names = append(names, prefix+line)
+			}
+		}
+		rc.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		var others []string
+		for name := range files {
+			if isModelFile(name) {
+				if strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+				} else {
+					others = append(others, name)
+				}
+			}
+		}
+		if len(names) == 0 {
+			names = others
+		}
+		sort.Strings(names)
+	}
+	assembly := &AST{
+		Smithy: "1.0",
+	}
+	for _, name := range names {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: manifest entry not found: %s", archivePath, name)
+		}
+		ast, err := loadArchiveEntry(ctx, archivePath, f, opts)
+		if err != nil {
+			return nil, err
+		}
+		err = assembly.Merge(ast)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return assembly, nil
+}
+
+func isModelFile(name string) bool {
+	switch path.Ext(name) {
+	case ".smithy", ".json":
+		return true
+	}
+	return false
+}
+
+func loadArchiveEntry(ctx context.Context, archivePath string, f *zip.File, opts []ParserOption) (*AST, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	name := archivePath + "!/" + f.Name
+	switch path.Ext(f.Name) {
+	case ".smithy":
+		return ParseString(string(b), name, append([]ParserOption{WithContext(ctx)}, opts...)...)
+	case ".json":
+		return loadAST(name, b)
+	default:
+		return nil, fmt.Errorf("%s: not a model file", name)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Diagnostic is a problem with a model in a form for tools, i.e. editors and CI annotations, to consume as JSON.
+// The position is omitted if it is not known.
+type Diagnostic struct {
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Id       string   `json:"id,omitempty"`      //the validation check, if any
+	ShapeId  string   `json:"shapeId,omitempty"` //the shape or member the problem is about, if any
+	Message  string   `json:"message"`
+}
+
+func newDiagnostic(loc *SourceLocation, severity Severity, msg string) *Diagnostic {
+	d := &Diagnostic{Severity: severity, Message: msg}
+	if loc != nil {
+		d.File = loc.File
+		d.Line = loc.Line
+		d.Column = loc.Column
+	}
+	return d
+}
+
+// Diagnostic returns the warning as a Diagnostic.
+func (w *Warning) Diagnostic() *Diagnostic {
+	return newDiagnostic(w.Location, w.Severity, w.Message)
+}
+
+// Diagnostic returns the event as a Diagnostic.
+func (e *ValidationEvent) Diagnostic() *Diagnostic {
+	d := newDiagnostic(e.Location, e.Severity, e.Message)
+	d.Id = e.Id
+	d.ShapeId = e.ShapeId
+	return d
+}
+
+// ErrorDiagnostics returns the problems reported by an error from loading or validating a model: the position and
+// message of a *ParseError, or the events of a *ValidationError other than the suppressed ones. Any other error is a
+// single ERROR with no position.
+func ErrorDiagnostics(err error) []*Diagnostic {
+	if err == nil {
+		return nil
+	}
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		d := &Diagnostic{File: perr.File, Line: perr.Line, Column: perr.Column, Severity: SeverityError, Message: perr.Message}
+		return []*Diagnostic{d}
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		var result []*Diagnostic
+		for _, ev := range verr.Events {
+			if ev.Severity != SeveritySuppressed {
+				result = append(result, ev.Diagnostic())
+			}
+		}
+		return result
+	}
+	return []*Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+}
+
+// the SARIF result level and GitHub Actions annotation command of the severities that are not just notes
+var diagnosticLevels = map[Severity]string{SeverityError: "error", SeverityDanger: "error", SeverityWarning: "warning"}
+
+type sarifLog struct {
+	Version string      `json:"version"`
+	Schema  string      `json:"$schema"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool      `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	Version        string       `json:"version,omitempty"`
+	InformationUri string       `json:"informationUri,omitempty"`
+	Rules          []*sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	Id string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleId    string           `json:"ruleId,omitempty"`
+	Level     string           `json:"level"`
+	Message   sarifMessage     `json:"message"`
+	Locations []*sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []*sarifLogical        `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifLogical struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+// WriteSARIF writes the diagnostics as a SARIF 2.1.0 log of a single run of the tool with the name and version, for
+// code scanning tools to consume. The ids of the checks are the rules of the run, and the shape a problem is about, if
+// any, is its logical location. ERROR and DANGER are "error" results, WARNING "warning", and anything else "note".
+func WriteSARIF(w io.Writer, name string, version string, diags []*Diagnostic) error {
+	run := &sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: name, Version: version, InformationUri: "https://github.com/boynton/smithy"}},
+		Results: make([]*sarifResult, 0, len(diags)),
+	}
+	rules := make(map[string]bool, 0)
+	for _, d := range diags {
+		if d.Id != "" && !rules[d.Id] {
+			rules[d.Id] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, &sarifRule{Id: d.Id})
+		}
+		result := &sarifResult{RuleId: d.Id, Level: diagnosticLevels[d.Severity], Message: sarifMessage{Text: d.Message}}
+		if result.Level == "" {
+			result.Level = "note"
+		}
+		loc := &sarifLocation{}
+		if d.File != "" {
+			loc.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifact{Uri: sarifUri(d.File)}}
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+		}
+		if d.ShapeId != "" {
+			loc.LogicalLocations = []*sarifLogical{{FullyQualifiedName: d.ShapeId}}
+		}
+		if loc.PhysicalLocation != nil || loc.LogicalLocations != nil {
+			result.Locations = []*sarifLocation{loc}
+		}
+		run.Results = append(run.Results, result)
+	}
+	log := &sarifLog{Version: "2.1.0", Schema: "https://json.schemastore.org/sarif-2.1.0.json", Runs: []*sarifRun{run}}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// sarifUri returns the file as a URI, relative to the root of the repository if the path is relative.
+func sarifUri(file string) string {
+	if filepath.IsAbs(file) {
+		return "file://" + filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(file)
+}
+
+// WriteGitHubAnnotations writes the diagnostics as GitHub Actions workflow commands, i.e.
+// "::error file=model.smithy,line=3,col=5,title=Target::Target not found", one per line, so that a workflow step shows
+// them as annotations on the files. ERROR and DANGER are errors, WARNING warnings, and anything else notices.
+func WriteGitHubAnnotations(w io.Writer, diags []*Diagnostic) error {
+	for _, d := range diags {
+		level := diagnosticLevels[d.Severity]
+		if level == "" {
+			level = "notice"
+		}
+		var props []string
+		if d.File != "" {
+			props = append(props, "file="+githubEscapeProperty(d.File))
+			if d.Line > 0 {
+				props = append(props, fmt.Sprintf("line=%d", d.Line))
+			}
+			if d.Column > 0 {
+				props = append(props, fmt.Sprintf("col=%d", d.Column))
+			}
+		}
+		if d.Id != "" {
+			props = append(props, "title="+githubEscapeProperty(d.Id))
+		}
+		msg := d.Message
+		if d.ShapeId != "" {
+			msg = d.ShapeId + ": " + msg
+		}
+		cmd := "::" + level
+		if len(props) > 0 {
+			cmd = cmd + " " + strings.Join(props, ",")
+		}
+		_, err := fmt.Fprintf(w, "%s::%s\n", cmd, githubEscapeData(msg))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func githubEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+
+	"github.com/boynton/data"
+)
+
+type DiagramGenerator struct {
+	BaseGenerator
+}
+
+// Generate a class diagram of the shape relationships in the model. The "format" option selects "plantuml" (the
+// default) or "mermaid".
+func (gen *DiagramGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	format := config.GetString("format")
+	fname := "model.puml"
+	switch format {
+	case "", "plantuml":
+		format = "plantuml"
+	case "mermaid":
+		fname = "model.mmd"
+	default:
+		return fmt.Errorf("Unsupported diagram format: %q", format)
+	}
+	w := &DiagramWriter{
+		ast:     ast,
+		mermaid: format == "mermaid",
+	}
+	return gen.Emit(w.Diagram(), fname, "")
+}
+
+type DiagramWriter struct {
+	buf     bytes.Buffer
+	writer  *bufio.Writer
+	ast     *AST
+	mermaid bool
+}
+
+func (w *DiagramWriter) Emit(format string, args ...interface{}) {
+	w.writer.WriteString(fmt.Sprintf(format, args...))
+}
+
+func (w *DiagramWriter) Diagram() string {
+	w.buf.Reset()
+	w.writer = bufio.NewWriter(&w.buf)
+	if w.mermaid {
+		w.Emit("classDiagram\n")
+	} else {
+		w.Emit("@startuml\n")
+	}
+	for _, k := range w.ast.Shapes.Keys() {
+		w.EmitClass(StripNamespace(k), w.ast.GetShape(k))
+	}
+	for _, k := range w.ast.Shapes.Keys() {
+		w.EmitRelations(StripNamespace(k), w.ast.GetShape(k))
+	}
+	if !w.mermaid {
+		w.Emit("@enduml\n")
+	}
+	w.writer.Flush()
+	return w.buf.String()
+}
+
+func (w *DiagramWriter) EmitClass(name string, shape *Shape) {
+	var fields []string
+	switch shape.Type {
+	case "structure", "union":
+		for _, k := range shape.Members.Keys() {
+			fields = append(fields, fmt.Sprintf("%s: %s", k, StripNamespace(shape.Members.Get(k).Target)))
+		}
+	case "enum", "intEnum":
+		fields = shape.Members.Keys()
+	case "list", "set":
+		fields = append(fields, "member: "+StripNamespace(shape.Member.Target))
+	case "map":
+		fields = append(fields, "key: "+StripNamespace(shape.Key.Target), "value: "+StripNamespace(shape.Value.Target))
+	case "service":
+		if shape.Version != "" {
+			fields = append(fields, "version: "+shape.Version)
+		}
+	case "resource":
+		for _, k := range sortedIdentifierNames(shape.Identifiers) {
+			fields = append(fields, fmt.Sprintf("%s: %s", k, StripNamespace(shape.Identifiers[k].Target)))
+		}
+		for _, k := range sortedIdentifierNames(shape.Properties) {
+			fields = append(fields, fmt.Sprintf("%s: %s", k, StripNamespace(shape.Properties[k].Target)))
+		}
+	}
+	if w.mermaid {
+		w.Emit("    class %s {\n", name)
+		w.Emit("        <<%s>>\n", shape.Type)
+		for _, f := range fields {
+			w.Emit("        %s\n", f)
+		}
+		w.Emit("    }\n")
+	} else {
+		w.Emit("class %s <<%s>> {\n", name, shape.Type)
+		for _, f := range fields {
+			w.Emit("    %s\n", f)
+		}
+		w.Emit("}\n")
+	}
+}
+
+func (w *DiagramWriter) EmitRelation(from string, ref *ShapeRef, label string, dependency bool) {
+	if ref != nil {
+		w.emitRelation(from, ref.Target, label, dependency)
+	}
+}
+
+func (w *DiagramWriter) emitRelation(from string, target string, label string, dependency bool) {
+	if w.ast.GetShape(target) == nil {
+		return //prelude shapes and unresolved references are not drawn
+	}
+	arrow := "-->"
+	if dependency {
+		arrow = "..>"
+	}
+	indent := ""
+	if w.mermaid {
+		indent = IndentAmount
+	}
+	w.Emit("%s%s %s %s : %s\n", indent, from, arrow, StripNamespace(target), label)
+}
+
+func (w *DiagramWriter) EmitRelations(name string, shape *Shape) {
+	for _, m := range shape.Mixins {
+		w.EmitRelation(name, m, "mixin", true)
+	}
+	switch shape.Type {
+	case "structure", "union":
+		for _, k := range shape.Members.Keys() {
+			w.emitRelation(name, shape.Members.Get(k).Target, k, false)
+		}
+	case "list", "set":
+		w.emitRelation(name, shape.Member.Target, "member", false)
+	case "map":
+		w.emitRelation(name, shape.Key.Target, "key", false)
+		w.emitRelation(name, shape.Value.Target, "value", false)
+	case "operation":
+		w.EmitRelation(name, shape.Input, "input", false)
+		w.EmitRelation(name, shape.Output, "output", false)
+		for _, e := range shape.Errors {
+			w.EmitRelation(name, e, "error", true)
+		}
+	case "service":
+		for _, o := range shape.Operations {
+			w.EmitRelation(name, o, "operation", false)
+		}
+		for _, r := range shape.Resources {
+			w.EmitRelation(name, r, "resource", false)
+		}
+	case "resource":
+		for _, k := range sortedIdentifierNames(shape.Identifiers) {
+			w.EmitRelation(name, shape.Identifiers[k], k, false)
+		}
+		for _, k := range sortedIdentifierNames(shape.Properties) {
+			w.EmitRelation(name, shape.Properties[k], k, false)
+		}
+		w.EmitRelation(name, shape.Create, "create", false)
+		w.EmitRelation(name, shape.Put, "put", false)
+		w.EmitRelation(name, shape.Read, "read", false)
+		w.EmitRelation(name, shape.Update, "update", false)
+		w.EmitRelation(name, shape.Delete, "delete", false)
+		w.EmitRelation(name, shape.List, "list", false)
+		for _, o := range shape.Operations {
+			w.EmitRelation(name, o, "operation", false)
+		}
+		for _, o := range shape.CollectionOperations {
+			w.EmitRelation(name, o, "collectionOperation", false)
+		}
+		for _, r := range shape.Resources {
+			w.EmitRelation(name, r, "resource", false)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// Change is a difference between two versions of a model. Changes that will break existing clients or servers have
+// ERROR severity, and changes that may break them have DANGER severity.
+type Change struct {
+	Kind     string      `json:"kind"` //i.e. "AddedShape", "RemovedMember", "ChangedTrait"
+	Severity Severity    `json:"severity"`
+	ShapeId  string      `json:"shapeId,omitempty"` //the shape or member that changed
+	Trait    string      `json:"trait,omitempty"`   //the trait that changed, for trait changes
+	Old      interface{} `json:"old,omitempty"`
+	New      interface{} `json:"new,omitempty"`
+	Message  string      `json:"message"`
+}
+
+func (c *Change) String() string {
+	return fmt.Sprintf("[%s] %s %s: %s", c.Severity, c.Kind, c.ShapeId, c.Message)
+}
+
+// Breaking returns true if the change will, or may, break existing clients or servers.
+func (c *Change) Breaking() bool {
+	return c.Severity == SeverityError || c.Severity == SeverityDanger
+}
+
+// ChangeSet is the result of comparing two versions of a model.
+type ChangeSet struct {
+	Changes []*Change
+}
+
+// Breaking returns the changes that will, or may, break existing clients or servers.
+func (cs *ChangeSet) Breaking() []*Change {
+	var result []*Change
+	for _, c := range cs.Changes {
+		if c.Breaking() {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (cs *ChangeSet) String() string {
+	var lines []string
+	for _, c := range cs.Changes {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff compares two versions of a model, returning the shapes added and removed, and the changes to the types, members,
+// bindings, and traits of the shapes in both. Members are compared including those from mixins, so moving members into
+// a mixin is not reported as a change to them.
+func Diff(old, new *AST) *ChangeSet {
+	d := &differ{old: old, new: new, changes: &ChangeSet{}}
+	d.diffMetadata()
+	var ids []string
+	seen := make(map[string]bool, 0)
+	for _, ast := range []*AST{old, new} {
+		if ast.Shapes != nil {
+			for _, id := range ast.Shapes.Keys() {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	for _, id := range ids {
+		d.diffShape(id, old.GetShape(id), new.GetShape(id))
+	}
+	return d.changes
+}
+
+type differ struct {
+	old     *AST
+	new     *AST
+	changes *ChangeSet
+}
+
+func (d *differ) change(kind string, severity Severity, id string, format string, args ...interface{}) *Change {
+	c := &Change{Kind: kind, Severity: severity, ShapeId: id, Message: fmt.Sprintf(format, args...)}
+	d.changes.Changes = append(d.changes.Changes, c)
+	return c
+}
+
+func (d *differ) diffMetadata() {
+	keys := func(o *data.Object) []string {
+		if o == nil {
+			return nil
+		}
+		return o.Keys()
+	}
+	for _, k := range keys(d.old.Metadata) {
+		if !d.new.Metadata.Has(k) {
+			d.change("RemovedMetadata", SeverityWarning, "", "Metadata %q was removed", k)
+		} else if !nodeEqual(d.old.Metadata.Get(k), d.new.Metadata.Get(k)) {
+			d.change("ChangedMetadata", SeverityWarning, "", "Metadata %q was changed", k)
+		}
+	}
+	for _, k := range keys(d.new.Metadata) {
+		if !d.old.Metadata.Has(k) {
+			d.change("AddedMetadata", SeverityNote, "", "Metadata %q was added", k)
+		}
+	}
+}
+
+func (d *differ) diffShape(id string, oldShape, newShape *Shape) {
+	switch {
+	case oldShape == nil:
+		d.change("AddedShape", SeverityNote, id, "Added %s", newShape.Type)
+		return
+	case newShape == nil:
+		d.change("RemovedShape", SeverityError, id, "Removed %s", oldShape.Type)
+		return
+	case oldShape.Type != newShape.Type:
+		d.change("ChangedShapeType", SeverityError, id, "The type changed from %s to %s", oldShape.Type, newShape.Type)
+		return
+	}
+	d.diffTraits(id, oldShape.Traits, newShape.Traits)
+	d.diffRefs(id, "mixin", oldShape.Mixins, newShape.Mixins, SeverityNote, SeverityNote)
+	switch oldShape.Type {
+	case "structure", "union", "enum", "intEnum":
+		d.diffMembers(id, oldShape, newShape)
+	case "list", "set":
+		d.diffMember(id+"$member", oldShape.Member, newShape.Member)
+	case "map":
+		d.diffMember(id+"$key", oldShape.Key, newShape.Key)
+		d.diffMember(id+"$value", oldShape.Value, newShape.Value)
+	case "service":
+		if oldShape.Version != newShape.Version {
+			c := d.change("ChangedServiceVersion", SeverityNote, id, "The version changed from %q to %q", oldShape.Version, newShape.Version)
+			c.Old, c.New = oldShape.Version, newShape.Version
+		}
+		d.diffRefs(id, "operation", oldShape.Operations, newShape.Operations, SeverityError, SeverityNote)
+		d.diffRefs(id, "resource", oldShape.Resources, newShape.Resources, SeverityError, SeverityNote)
+		d.diffRefs(id, "error", oldShape.Errors, newShape.Errors, SeverityWarning, SeverityWarning)
+	case "operation":
+		d.diffRef(id, "input", oldShape.Input, newShape.Input)
+		d.diffRef(id, "output", oldShape.Output, newShape.Output)
+		d.diffRefs(id, "error", oldShape.Errors, newShape.Errors, SeverityWarning, SeverityWarning)
+	case "resource":
+		d.diffRefMap(id, "identifier", oldShape.Identifiers, newShape.Identifiers)
+		d.diffRefMap(id, "property", oldShape.Properties, newShape.Properties)
+		d.diffRef(id, "create", oldShape.Create, newShape.Create)
+		d.diffRef(id, "put", oldShape.Put, newShape.Put)
+		d.diffRef(id, "read", oldShape.Read, newShape.Read)
+		d.diffRef(id, "update", oldShape.Update, newShape.Update)
+		d.diffRef(id, "delete", oldShape.Delete, newShape.Delete)
+		d.diffRef(id, "list", oldShape.List, newShape.List)
+		d.diffRefs(id, "operation", oldShape.Operations, newShape.Operations, SeverityError, SeverityNote)
+		d.diffRefs(id, "collection operation", oldShape.CollectionOperations, newShape.CollectionOperations, SeverityError, SeverityNote)
+		d.diffRefs(id, "resource", oldShape.Resources, newShape.Resources, SeverityError, SeverityNote)
+	}
+}
+
+func (d *differ) diffMembers(id string, oldShape, newShape *Shape) {
+	oldMembers := d.old.allMembers(oldShape)
+	newMembers := d.new.allMembers(newShape)
+	for _, k := range oldMembers.Keys() {
+		mid := id + "$" + k
+		if newMembers.Get(k) == nil {
+			d.change("RemovedMember", SeverityError, mid, "Removed member %q", k)
+		} else {
+			d.diffMember(mid, oldMembers.Get(k), newMembers.Get(k))
+		}
+	}
+	for _, k := range newMembers.Keys() {
+		if oldMembers.Get(k) != nil {
+			continue
+		}
+		mem := newMembers.Get(k)
+		if mem.Traits.Has("smithy.api#required") && !mem.Traits.Has("smithy.api#default") {
+			d.change("AddedRequiredMember", SeverityError, id+"$"+k, "Added member %q, which is required and has no default", k)
+		} else {
+			d.change("AddedMember", SeverityNote, id+"$"+k, "Added member %q", k)
+		}
+	}
+}
+
+func (d *differ) diffMember(id string, oldMember, newMember *Member) {
+	if oldMember == nil || newMember == nil {
+		return
+	}
+	if oldMember.Target != newMember.Target {
+		c := d.change("ChangedMemberTarget", SeverityError, id, "The target changed from %s to %s", oldMember.Target, newMember.Target)
+		c.Old, c.New = oldMember.Target, newMember.Target
+	}
+	d.diffTraits(id, oldMember.Traits, newMember.Traits)
+}
+
+func (d *differ) diffRef(id string, what string, oldRef, newRef *ShapeRef) {
+	kind := changeKind(what)
+	switch {
+	case oldRef == nil && newRef == nil:
+	case oldRef == nil:
+		d.change("Added"+kind, SeverityNote, id, "Added %s %s", what, newRef.Target)
+	case newRef == nil:
+		d.change("Removed"+kind, SeverityError, id, "Removed %s %s", what, oldRef.Target)
+	case oldRef.Target != newRef.Target:
+		c := d.change("Changed"+kind, SeverityError, id, "The %s changed from %s to %s", what, oldRef.Target, newRef.Target)
+		c.Old, c.New = oldRef.Target, newRef.Target
+	}
+}
+
+func (d *differ) diffRefs(id string, what string, oldRefs, newRefs []*ShapeRef, removed, added Severity) {
+	kind := changeKind(what)
+	targets := func(refs []*ShapeRef) []string {
+		var lst []string
+		for _, ref := range refs {
+			lst = append(lst, ref.Target)
+		}
+		return lst
+	}
+	oldTargets := targets(oldRefs)
+	newTargets := targets(newRefs)
+	for _, t := range oldTargets {
+		if !containsString(newTargets, t) {
+			d.change("Removed"+kind, removed, id, "Removed %s %s", what, t)
+		}
+	}
+	for _, t := range newTargets {
+		if !containsString(oldTargets, t) {
+			d.change("Added"+kind, added, id, "Added %s %s", what, t)
+		}
+	}
+}
+
+func (d *differ) diffRefMap(id string, what string, oldRefs, newRefs map[string]*ShapeRef) {
+	kind := changeKind(what)
+	for _, k := range sortedIdentifierNames(oldRefs) {
+		oldRef, newRef := oldRefs[k], newRefs[k]
+		if newRef == nil {
+			d.change("Removed"+kind, SeverityError, id, "Removed %s %q", what, k)
+		} else if oldRef.Target != newRef.Target {
+			c := d.change("Changed"+kind, SeverityError, id, "The %s %q changed from %s to %s", what, k, oldRef.Target, newRef.Target)
+			c.Old, c.New = oldRef.Target, newRef.Target
+		}
+	}
+	for _, k := range sortedIdentifierNames(newRefs) {
+		if _, ok := oldRefs[k]; !ok {
+			d.change("Added"+kind, SeverityNote, id, "Added %s %q", what, k)
+		}
+	}
+}
+
+// changeKind returns the words capitalized and joined, i.e. "CollectionOperation" for "collection operation".
+func changeKind(what string) string {
+	var kind string
+	for _, word := range strings.Fields(what) {
+		kind += Capitalize(word)
+	}
+	return kind
+}
+
+// protocolTraits change what is sent over the wire, so any change to them is breaking.
+var protocolTraits = []string{"smithy.api#http", "smithy.api#httpError", "smithy.api#httpHeader", "smithy.api#httpLabel",
+	"smithy.api#httpPayload", "smithy.api#httpPrefixHeaders", "smithy.api#httpQuery", "smithy.api#httpQueryParams",
+	"smithy.api#httpResponseCode", "smithy.api#jsonName", "smithy.api#xmlName", "smithy.api#xmlAttribute",
+	"smithy.api#xmlFlattened", "smithy.api#xmlNamespace", "smithy.api#timestampFormat", "smithy.api#mediaType",
+	"smithy.api#error", "smithy.api#streaming", "smithy.api#eventPayload", "smithy.api#eventHeader",
+	"smithy.api#enumValue"}
+
+func (d *differ) diffTraits(id string, oldTraits, newTraits *data.Object) {
+	for _, tid := range oldTraits.Keys() {
+		if !newTraits.Has(tid) {
+			c := d.change("RemovedTrait", d.removedTraitSeverity(tid), id, "Removed trait @%s", d.traitName(tid))
+			c.Trait, c.Old = tid, oldTraits.Get(tid)
+		} else if oldVal, newVal := oldTraits.Get(tid), newTraits.Get(tid); !nodeEqual(oldVal, newVal) {
+			c := d.change("ChangedTrait", d.changedTraitSeverity(tid, oldVal, newVal), id, "Changed trait @%s", d.traitName(tid))
+			c.Trait, c.Old, c.New = tid, oldVal, newVal
+		}
+	}
+	for _, tid := range newTraits.Keys() {
+		if !oldTraits.Has(tid) {
+			c := d.change("AddedTrait", d.addedTraitSeverity(tid), id, "Added trait @%s", d.traitName(tid))
+			c.Trait, c.New = tid, newTraits.Get(tid)
+		}
+	}
+}
+
+func (d *differ) traitName(tid string) string {
+	if strings.HasPrefix(tid, "smithy.api#") {
+		return StripNamespace(tid)
+	}
+	return tid
+}
+
+func (d *differ) addedTraitSeverity(tid string) Severity {
+	switch {
+	case containsString(protocolTraits, tid):
+		return SeverityError
+	case tid == "smithy.api#required":
+		return SeverityError
+	case tid == "smithy.api#range" || tid == "smithy.api#length" || tid == "smithy.api#pattern":
+		return SeverityError
+	case tid == "smithy.api#deprecated":
+		return SeverityWarning
+	}
+	return SeverityNote
+}
+
+func (d *differ) removedTraitSeverity(tid string) Severity {
+	switch {
+	case containsString(protocolTraits, tid):
+		return SeverityError
+	case tid == "smithy.api#idempotent" || tid == "smithy.api#readonly" || tid == "smithy.api#paginated":
+		return SeverityDanger
+	case tid == "smithy.api#required" || tid == "smithy.api#default":
+		return SeverityDanger
+	case tid == "smithy.api#documentation" || tid == "smithy.api#deprecated":
+		return SeverityNote
+	}
+	return SeverityWarning
+}
+
+func (d *differ) changedTraitSeverity(tid string, oldVal, newVal interface{}) Severity {
+	switch {
+	case containsString(protocolTraits, tid):
+		return SeverityError
+	case tid == "smithy.api#range" || tid == "smithy.api#length":
+		if boundsNarrowed(oldVal, newVal) {
+			return SeverityError
+		}
+		return SeverityNote
+	case tid == "smithy.api#enum":
+		if enumValuesRemoved(oldVal, newVal) {
+			return SeverityError
+		}
+		return SeverityNote
+	case tid == "smithy.api#default" || tid == "smithy.api#paginated":
+		return SeverityDanger
+	case tid == "smithy.api#pattern":
+		return SeverityWarning
+	case tid == "smithy.api#documentation" || tid == "smithy.api#deprecated" || tid == "smithy.api#examples":
+		return SeverityNote
+	}
+	return SeverityWarning
+}
+
+// boundsNarrowed returns true if the min of a @range or @length trait was raised or added, or the max lowered or added.
+func boundsNarrowed(oldVal, newVal interface{}) bool {
+	oldBounds, newBounds := data.AsMap(oldVal), data.AsMap(newVal)
+	bound := func(m map[string]interface{}, k string) *big.Float {
+		return decimalNode(m[k])
+	}
+	oldMin, newMin := bound(oldBounds, "min"), bound(newBounds, "min")
+	oldMax, newMax := bound(oldBounds, "max"), bound(newBounds, "max")
+	if newMin != nil && (oldMin == nil || newMin.Cmp(oldMin) > 0) {
+		return true
+	}
+	if newMax != nil && (oldMax == nil || newMax.Cmp(oldMax) < 0) {
+		return true
+	}
+	return false
+}
+
+// decimalNode returns the number as a big.Float parsed from its json.Number decimal string, as normalizeNode gives it,
+// so that bounds that differ only beyond the precision of a float64 still compare as different. It returns nil if the
+// value is not a number.
+func decimalNode(v interface{}) *big.Float {
+	num, ok := normalizeNode(v).(json.Number)
+	if !ok {
+		return nil
+	}
+	f, _, err := big.ParseFloat(num.String(), 10, data.DecimalPrecision, big.ToNearestEven)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// enumValuesRemoved returns true if any value of the old @enum trait is missing from the new one.
+func enumValuesRemoved(oldVal, newVal interface{}) bool {
+	values := func(v interface{}) []string {
+		var lst []string
+		for _, item := range data.AsArray(v) {
+			lst = append(lst, data.AsString(data.AsMap(item)["value"]))
+		}
+		return lst
+	}
+	newValues := values(newVal)
+	for _, v := range values(oldVal) {
+		if !containsString(newValues, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeEqual compares two node values structurally, by their canonical JSON form, ignoring the order of object keys.
+func nodeEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(canonicalNode(a), canonicalNode(b))
+}
+
+// normalizeNode converts objects to maps and numbers to json.Number decimal strings, so that values that were parsed
+// or coerced differently compare as equal.
+func normalizeNode(v interface{}) interface{} {
+	switch n := v.(type) {
+	case *data.Object, map[string]interface{}:
+		m := make(map[string]interface{}, 0)
+		for k, item := range data.AsMap(n) {
+			m[k] = normalizeNode(item)
+		}
+		return m
+	case []interface{}:
+		lst := make([]interface{}, 0, len(n))
+		for _, item := range n {
+			lst = append(lst, normalizeNode(item))
+		}
+		return lst
+	case *string:
+		return *n
+	}
+	if num := nodeNumber(v); num != nil {
+		if _, isString := v.(string); !isString {
+			return json.Number(num.String())
+		}
+	}
+	return v
+}
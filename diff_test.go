@@ -0,0 +1,126 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// the changes between two versions of the shapes of a model, as "Kind SEVERITY shapeId"
+var diffTests = []struct {
+	name     string
+	old      string
+	new      string
+	expected []string
+	breaking bool
+}{
+	{"no change",
+		"string S", "string S",
+		nil, false},
+	{"added shape",
+		"string S", "string S\nstring T",
+		[]string{"AddedShape NOTE test#T"}, false},
+	{"removed shape",
+		"string S\nstring T", "string S",
+		[]string{"RemovedShape ERROR test#T"}, true},
+	{"changed type",
+		"string S", "integer S",
+		[]string{"ChangedShapeType ERROR test#S"}, true},
+	{"added optional member",
+		"structure S {\n a: String\n}", "structure S {\n a: String\n b: String\n}",
+		[]string{"AddedMember NOTE test#S$b"}, false},
+	{"added required member",
+		"structure S {\n a: String\n}", "structure S {\n a: String\n @required\n b: String\n}",
+		[]string{"AddedRequiredMember ERROR test#S$b"}, true},
+	{"added required member with a default",
+		"structure S {\n a: String\n}", "structure S {\n a: String\n @required\n b: String = \"x\"\n}",
+		[]string{"AddedMember NOTE test#S$b"}, false},
+	{"removed member",
+		"structure S {\n a: String\n b: String\n}", "structure S {\n a: String\n}",
+		[]string{"RemovedMember ERROR test#S$b"}, true},
+	{"changed member target",
+		"structure S {\n a: String\n}", "structure S {\n a: Integer\n}",
+		[]string{"ChangedMemberTarget ERROR test#S$a"}, true},
+	{"removed required",
+		"structure S {\n @required\n a: String\n}", "structure S {\n a: String\n}",
+		[]string{"RemovedTrait DANGER test#S$a"}, true},
+	{"added documentation",
+		"string S", "/// About S\nstring S",
+		[]string{"AddedTrait NOTE test#S"}, false},
+	{"added deprecated",
+		"string S", "@deprecated\nstring S",
+		[]string{"AddedTrait WARNING test#S"}, false},
+	{"widened length",
+		"@length(min: 2, max: 5)\nstring S", "@length(min: 1, max: 10)\nstring S",
+		[]string{"ChangedTrait NOTE test#S"}, false},
+	{"narrowed range",
+		"@range(min: 1, max: 10)\ninteger S", "@range(min: 1, max: 9)\ninteger S",
+		[]string{"ChangedTrait ERROR test#S"}, true},
+	{"narrowed range by a fraction",
+		"@range(max: 9007199254740993)\nlong S", "@range(max: 9007199254740992)\nlong S",
+		[]string{"ChangedTrait ERROR test#S"}, true},
+	{"added length",
+		"string S", "@length(max: 10)\nstring S",
+		[]string{"AddedTrait ERROR test#S"}, true},
+	{"changed http binding",
+		"structure S {\n @httpHeader(\"x-a\")\n a: String\n}", "structure S {\n @httpHeader(\"x-b\")\n a: String\n}",
+		[]string{"ChangedTrait ERROR test#S$a"}, true},
+	{"added enum value",
+		"enum E {\n A\n}", "enum E {\n A\n B\n}",
+		[]string{"AddedMember NOTE test#E$B"}, false},
+	{"removed enum value",
+		"enum E {\n A\n B\n}", "enum E {\n A\n}",
+		[]string{"RemovedMember ERROR test#E$B"}, true},
+	{"added operation",
+		"service Svc {\n version: \"1\"\n}\noperation Op {}", "service Svc {\n version: \"1\"\n operations: [Op]\n}\noperation Op {}",
+		[]string{"AddedOperation NOTE test#Svc"}, false},
+	{"removed operation",
+		"service Svc {\n version: \"1\"\n operations: [Op]\n}\noperation Op {}", "service Svc {\n version: \"1\"\n}\noperation Op {}",
+		[]string{"RemovedOperation ERROR test#Svc"}, true},
+	{"changed service version",
+		"service Svc {\n version: \"1\"\n}", "service Svc {\n version: \"2\"\n}",
+		[]string{"ChangedServiceVersion NOTE test#Svc"}, false},
+	{"removed readonly",
+		"@readonly\noperation Op {}", "operation Op {}",
+		[]string{"RemovedTrait DANGER test#Op"}, true},
+}
+
+func TestDiff(t *testing.T) {
+	parse := func(shapes string) *AST {
+		ast, err := ParseString("$version: \"2\"\nnamespace test\n"+shapes+"\n", "test.smithy")
+		if err != nil {
+			t.Fatalf("Cannot parse %q: %v", shapes, err)
+		}
+		return ast
+	}
+	for _, tc := range diffTests {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := Diff(parse(tc.old), parse(tc.new))
+			var got []string
+			for _, c := range changes.Changes {
+				got = append(got, fmt.Sprintf("%s %s %s", c.Kind, c.Severity, c.ShapeId))
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+			if breaking := len(changes.Breaking()) > 0; breaking != tc.breaking {
+				t.Errorf("Expected breaking to be %v", tc.breaking)
+			}
+		})
+	}
+}
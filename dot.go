@@ -0,0 +1,101 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+type DotGenerator struct {
+	BaseGenerator
+}
+
+// Generate a Graphviz graph of shape dependencies. The "root" option restricts the graph to the closure of the
+// given service or operation shape ID. Trait applications are drawn as dashed edges, and omitted entirely with
+// the "notraits" option.
+func (gen *DotGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	s, err := gen.ToDot(ast, config.GetString("root"), !config.GetBool("notraits"))
+	if err != nil {
+		return err
+	}
+	return gen.Emit(s, "model.dot", "")
+}
+
+func (gen *DotGenerator) ToDot(ast *AST, root string, withTraits bool) (string, error) {
+	included := make(map[string]bool, 0)
+	if root != "" {
+		if ast.GetShape(root) == nil {
+			return "", fmt.Errorf("Root shape not defined: %s", root)
+		}
+		ast.noteDependencies(included, root)
+	} else {
+		for _, k := range ast.Shapes.Keys() {
+			ast.noteDependencies(included, k)
+		}
+	}
+	var buf bytes.Buffer
+	writer := bufio.NewWriter(&buf)
+	writer.WriteString("digraph model {\n")
+	writer.WriteString("    rankdir=LR;\n")
+	writer.WriteString("    node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, k := range ast.Shapes.Keys() {
+		if !included[k] {
+			continue
+		}
+		shape := ast.GetShape(k)
+		writer.WriteString(fmt.Sprintf("    %s [label=\"%s\\n<%s>\"];\n", dotId(k), StripNamespace(k), shape.Type))
+	}
+	for _, k := range ast.Shapes.Keys() {
+		if !included[k] {
+			continue
+		}
+		shape := ast.GetShape(k)
+		traits := make(map[string]bool, 0)
+		for _, tk := range shape.Traits.Keys() {
+			traits[tk] = true
+		}
+		emitted := make(map[string]bool, 0)
+		for _, dep := range shape.Dependencies() {
+			if !included[dep] || ast.GetShape(dep) == nil || emitted[dep] {
+				continue
+			}
+			emitted[dep] = true
+			if traits[dep] {
+				if withTraits {
+					writer.WriteString(fmt.Sprintf("    %s -> %s [style=dashed];\n", dotId(k), dotId(dep)))
+				}
+			} else {
+				writer.WriteString(fmt.Sprintf("    %s -> %s;\n", dotId(k), dotId(dep)))
+			}
+		}
+	}
+	writer.WriteString("}\n")
+	writer.Flush()
+	return buf.String(), nil
+}
+
+func dotId(id string) string {
+	return fmt.Sprintf("%q", strings.ReplaceAll(id, "\"", ""))
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"github.com/boynton/data"
+)
+
+// EffectiveTraits returns the traits that the shape has once its mixins and any pending "apply" statements are taken
+// into account: the traits inherited from its mixins (other than @mixin and their localTraits), in the order of the
+// mixins, then its own traits, which take precedence, then the applied ones. The result is a new object, or nil if
+// the shape is not defined or has no traits.
+func (ast *AST) EffectiveTraits(shapeId string) *data.Object {
+	return ast.effectiveTraits(shapeId, make(map[string]bool, 0))
+}
+
+func (ast *AST) effectiveTraits(shapeId string, visited map[string]bool) *data.Object {
+	shape := ast.GetShape(shapeId)
+	if shape == nil || visited[shapeId] {
+		return nil
+	}
+	visited[shapeId] = true //only to guard against cycles, a mixin may be reached more than once
+	defer delete(visited, shapeId)
+	var traits *data.Object
+	for _, ref := range shape.Mixins {
+		mixin := ast.GetShape(ref.Target)
+		if mixin == nil {
+			continue
+		}
+		local := data.AsObject(mixin.Traits.Get("smithy.api#mixin")).GetStringArray("localTraits")
+		inherited := ast.effectiveTraits(ref.Target, visited)
+		for _, k := range inherited.Keys() {
+			if k != "smithy.api#mixin" && !isLocalTrait(k, local) {
+				if traits == nil {
+					traits = data.NewObject()
+				}
+				traits.Put(k, inherited.Get(k))
+			}
+		}
+	}
+	traits = mergeTraits(traits, shape.Traits)
+	return mergeTraits(traits, ast.applied.GetObject(shapeId))
+}
+
+// EffectiveMemberTraits returns the traits that the member of the shape has once its mixins and any pending "apply"
+// statements are taken into account: the traits of the member of that name in its mixins, then its own traits, then
+// the applied ones. The member need not be redeclared by the shape. The result is a new object, or nil if there are
+// no traits.
+func (ast *AST) EffectiveMemberTraits(shapeId string, member string) *data.Object {
+	return ast.effectiveMemberTraits(shapeId, member, make(map[string]bool, 0))
+}
+
+func (ast *AST) effectiveMemberTraits(shapeId string, member string, visited map[string]bool) *data.Object {
+	shape := ast.GetShape(shapeId)
+	if shape == nil || visited[shapeId] {
+		return nil
+	}
+	visited[shapeId] = true
+	defer delete(visited, shapeId)
+	var traits *data.Object
+	for _, ref := range shape.Mixins {
+		traits = mergeTraits(traits, ast.effectiveMemberTraits(ref.Target, member, visited))
+	}
+	mid := string(ShapeID(shapeId).WithMember(member))
+	if mem := ast.getMember(mid); mem != nil {
+		traits = mergeTraits(traits, mem.Traits)
+	}
+	return mergeTraits(traits, ast.applied.GetObject(mid))
+}
+
+// mergeTraits adds the additional traits to the traits, which may be nil, replacing those with the same id, and
+// returns the result. Unlike withTrait, null values (i.e. @default(null)) are kept.
+func mergeTraits(traits *data.Object, more *data.Object) *data.Object {
+	for _, k := range more.Keys() {
+		if traits == nil {
+			traits = data.NewObject()
+		}
+		traits.Put(k, more.Get(k))
+	}
+	return traits
+}
+
+// applyTraits adds the traits of an "apply" statement to the shape or member with the id, replacing any it has with
+// the same id. If the shape or member is not defined, they are kept until it is, i.e. by Merge.
+func (ast *AST) applyTraits(id string, traits *data.Object) {
+	ast.addApplied(id, traits)
+	ast.resolveApplied(id)
+}
+
+// addApplied adds the traits of an "apply" statement to those pending for the shape or member with the id, without
+// applying them to it.
+func (ast *AST) addApplied(id string, traits *data.Object) {
+	if traits.Length() == 0 {
+		return
+	}
+	if ast.applied == nil {
+		ast.applied = data.NewObject()
+	}
+	ast.applied.Put(id, mergeTraits(ast.applied.GetObject(id), traits))
+}
+
+// overrideMixinMember redeclares the member with the id in its shape, with the target it has in a mixin of the shape,
+// so that traits can be applied to it, returning nil if no mixin has the member.
+func (ast *AST) overrideMixinMember(id string) *Member {
+	sid := ShapeID(id)
+	shape := ast.GetShape(string(sid.Shape()))
+	if shape == nil {
+		return nil
+	}
+	inherited := ast.mixinMember(string(sid.Shape()), sid.Member(), make(map[string]bool, 0))
+	if inherited == nil {
+		return nil
+	}
+	mem := &Member{Target: inherited.Target}
+	switch {
+	case shape.Type == "list" || shape.Type == "set" || shape.Type == "map":
+		switch sid.Member() {
+		case "member":
+			shape.Member = mem
+		case "key":
+			shape.Key = mem
+		case "value":
+			shape.Value = mem
+		}
+	default:
+		if shape.Members == nil {
+			shape.Members = NewMembers()
+		}
+		shape.Members.Put(sid.Member(), mem)
+	}
+	return mem
+}
+
+// mixinMember returns the member with the name in the mixins of the shape, or their mixins, or nil if there is none.
+func (ast *AST) mixinMember(shapeId string, member string, visited map[string]bool) *Member {
+	shape := ast.GetShape(shapeId)
+	if shape == nil || visited[shapeId] {
+		return nil
+	}
+	visited[shapeId] = true
+	for _, ref := range shape.Mixins {
+		if mem := ast.getMember(string(ShapeID(ref.Target).WithMember(member))); mem != nil {
+			return mem
+		}
+		if mem := ast.mixinMember(ref.Target, member, visited); mem != nil {
+			return mem
+		}
+	}
+	return nil
+}
+
+// resolveApplied adds the pending applied traits for the id to its shape or member, if it is defined.
+func (ast *AST) resolveApplied(id string) {
+	traits := ast.applied.GetObject(id)
+	if traits == nil {
+		return
+	}
+	if ShapeID(id).Member() != "" {
+		mem := ast.getMember(id)
+		if mem == nil {
+			mem = ast.overrideMixinMember(id)
+			if mem == nil {
+				return
+			}
+		}
+		mem.Traits = mergeTraits(mem.Traits, traits)
+	} else {
+		shape := ast.GetShape(id)
+		if shape == nil {
+			return
+		}
+		shape.Traits = mergeTraits(shape.Traits, traits)
+	}
+	ast.applied = withoutTrait(ast.applied, id)
+	ast.ResetIndex()
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Equal returns true if the models are structurally the same: they have the same Smithy version, metadata and
+// shapes, ignoring the order of keys and how they were written, i.e. "2" and "2.0" are the same version, and 1 and
+// 1.0 are the same number. Source locations and warnings are not compared.
+func (ast *AST) Equal(other *AST) bool {
+	if ast == nil || other == nil {
+		return ast == other
+	}
+	return reflect.DeepEqual(ast.canonical(), other.canonical())
+}
+
+// Fingerprint returns a hash of the model that is the same for models that are Equal, as a hex string. It can be
+// used to tell if generated artifacts are up to date with the model.
+func (ast *AST) Fingerprint() string {
+	b, _ := json.Marshal(ast.canonical()) //marshaling sorts the keys of maps, so the result is stable
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonical returns the JSON AST of the model as plain maps, lists and decimal numbers, with a normalized version.
+func (ast *AST) canonical() interface{} {
+	m := map[string]interface{}{
+		"smithy": fmt.Sprintf("%d.0", ast.AssemblyVersion()),
+	}
+	if ast.Metadata.Length() > 0 {
+		m["metadata"] = canonicalNode(ast.Metadata)
+	}
+	if ast.Shapes.Length() > 0 {
+		m["shapes"] = canonicalNode(ast.Shapes)
+	}
+	return m
+}
+
+// canonicalNode returns the JSON form of the value as plain maps, lists and numbers as json.Number decimal strings, so
+// that values that were parsed or coerced differently compare as equal with reflect.DeepEqual, and numbers that
+// differ only beyond the precision of a float64 do not.
+func canonicalNode(v interface{}) interface{} {
+	b, err := json.Marshal(normalizeNode(v))
+	if err != nil {
+		return v
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var n interface{}
+	if err := dec.Decode(&n); err != nil {
+		return v
+	}
+	return n
+}
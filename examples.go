@@ -0,0 +1,60 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+
+	"github.com/boynton/data"
+)
+
+type ExamplesGenerator struct {
+	BaseGenerator
+}
+
+// Generate synthesized JSON example requests and responses for every operation in the model, as a pair of
+// fixture files per operation, i.e. "GetItem-request.json" and "GetItem-response.json".
+func (gen *ExamplesGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	for _, k := range ast.Shapes.Keys() {
+		shape := ast.GetShape(k)
+		if shape.Type != "operation" {
+			continue
+		}
+		name := StripNamespace(k)
+		if shape.Input != nil {
+			err = gen.emitExample(ast.SynthesizeValue(shape.Input.Target), name+"-request.json")
+			if err != nil {
+				return err
+			}
+		}
+		if shape.Output != nil {
+			err = gen.emitExample(ast.SynthesizeValue(shape.Output.Target), name+"-response.json")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (gen *ExamplesGenerator) emitExample(value interface{}, fname string) error {
+	sep := fmt.Sprintf("\n// ===== File(%q)\n\n", fname)
+	return gen.Emit(data.Pretty(value), fname, sep)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+
+	"github.com/boynton/data"
+)
+
+// ExamplesValidator checks every entry of the @examples trait of each operation: the input and output documents must
+// conform to the operation's input and output structures, and an error must be one of the operation's errors, with
+// content that conforms to it.
+var ExamplesValidator = ValidatorFunc(validateExamples)
+
+func validateExamples(ast *AST) []*ValidationEvent {
+	v := &modelValidator{ast: ast}
+	if ast.Shapes != nil {
+		for _, id := range ast.Shapes.Keys() {
+			shape := ast.GetShape(id)
+			if shape.Type == "operation" && shape.Traits.Has("smithy.api#examples") {
+				v.checkExamples(id, shape)
+			}
+		}
+	}
+	return v.events
+}
+
+func (v *modelValidator) checkExamples(id string, op *Shape) {
+	var examples []interface{}
+	switch lst := op.Traits.Get("smithy.api#examples").(type) {
+	case []interface{}:
+		examples = lst
+	case []*data.Object:
+		for _, ex := range lst {
+			examples = append(examples, ex)
+		}
+	default:
+		v.event("Examples", SeverityError, id, "The @examples trait must be a list")
+		return
+	}
+	for i, item := range examples {
+		ex := data.AsMap(item)
+		if ex == nil {
+			v.event("Examples", SeverityError, id, "Example %d is not an object", i)
+			continue
+		}
+		title := data.AsString(ex["title"])
+		if title == "" {
+			title = fmt.Sprintf("%d", i)
+		}
+		if input, ok := ex["input"]; ok {
+			v.checkExampleDocument(id, title, "input", op.Input, input)
+		}
+		if output, ok := ex["output"]; ok {
+			v.checkExampleDocument(id, title, "output", op.Output, output)
+		}
+		if e, ok := ex["error"]; ok {
+			em := data.AsMap(e)
+			target := data.AsString(em["shapeId"])
+			var ref *ShapeRef
+			for _, r := range op.Errors {
+				if r.Target == target {
+					ref = r
+				}
+			}
+			if ref == nil {
+				v.event("Examples", SeverityError, id, "Example %q has an error that is not an error of the operation: %s", title, target)
+				continue
+			}
+			if _, ok := ex["output"]; ok {
+				v.event("Examples", SeverityError, id, "Example %q cannot have both an output and an error", title)
+			}
+			v.checkExampleDocument(id, title, "error", ref, em["content"])
+		}
+	}
+}
+
+func (v *modelValidator) checkExampleDocument(id string, title string, which string, ref *ShapeRef, doc interface{}) {
+	if ref == nil {
+		v.event("Examples", SeverityError, id, "Example %q has an %s, but the operation has none", title, which)
+		return
+	}
+	shape := v.ast.nodeShape(ref.Target)
+	if shape == nil {
+		return //undefined targets are reported by the model validator
+	}
+	if _, err := v.ast.coerceNode("", shape, copyNode(doc)); err != nil {
+		v.event("Examples", SeverityError, id, "Example %q has a bad %s: %v", title, which, err)
+	}
+}
+
+// copyNode returns a deep copy of a node value, so that it can be coerced without changing the original.
+func copyNode(v interface{}) interface{} {
+	switch n := v.(type) {
+	case []interface{}:
+		lst := make([]interface{}, 0, len(n))
+		for _, item := range n {
+			lst = append(lst, copyNode(item))
+		}
+		return lst
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(n))
+		for k, item := range n {
+			m[k] = copyNode(item)
+		}
+		return m
+	case *data.Object:
+		obj := data.NewObject()
+		for _, k := range n.Keys() {
+			obj.Put(k, copyNode(n.Get(k)))
+		}
+		return obj
+	}
+	return v
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// ExecRequest is what an ExecGenerator writes to the stdin of its command, as JSON.
+type ExecRequest struct {
+	Model  *AST         `json:"model"`
+	Config *data.Object `json:"config"`
+}
+
+// ExecFile is a file generated by the command of an ExecGenerator. The command writes one JSON object per file to
+// its stdout, as it generates them.
+type ExecFile struct {
+	Path    string `json:"path"` //relative to the output directory
+	Content string `json:"content"`
+}
+
+// ExecGenerator is a generator implemented by another program, so generators can be written in any language. The
+// program is run with the ExecRequest on its stdin, and writes a stream of ExecFile objects to its stdout. Its
+// stderr is passed through, and a nonzero exit status fails the generation.
+type ExecGenerator struct {
+	BaseGenerator
+	Command string
+	Args    []string
+}
+
+// Generate runs the command, and writes the files it generates to the output directory, creating subdirectories as
+// needed, or to stdout if there is no output directory.
+func (gen *ExecGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	req, err := json.Marshal(&ExecRequest{Model: ast, Config: config})
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(gen.Context, gen.Command, gen.Args...)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	err = gen.emitFiles(stdout)
+	if err != nil {
+		io.Copy(io.Discard, stdout)
+	}
+	werr := cmd.Wait()
+	if err != nil {
+		return err
+	}
+	if werr != nil {
+		if gen.Context.Err() != nil {
+			return gen.Context.Err()
+		}
+		return fmt.Errorf("Generator %s failed: %v", gen.Command, werr)
+	}
+	return nil
+}
+
+func (gen *ExecGenerator) emitFiles(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var f ExecFile
+		err := dec.Decode(&f)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Bad output from generator %s: %v", gen.Command, err)
+		}
+		fpath := filepath.Clean(f.Path)
+		if f.Path == "" || filepath.IsAbs(fpath) || fpath == ".." || strings.HasPrefix(fpath, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("Bad file path from generator %s: %q", gen.Command, f.Path)
+		}
+		if gen.OutDir != "" && gen.Sink == nil && !gen.DryRun {
+			err = os.MkdirAll(filepath.Dir(filepath.Join(gen.OutDir, fpath)), 0755)
+			if err != nil {
+				return err
+			}
+		}
+		err = gen.Emit(f.Content, fpath, fmt.Sprintf("\n// ===== File(%q)\n\n", fpath))
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/boynton/data"
+)
+
+// FormatFile returns the IDL file at the path rewritten in canonical style. See Format.
+func FormatFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return Format(string(b), path)
+}
+
+// Format returns the IDL source rewritten in canonical style: the same layout, indentation and spacing as the IDL
+// generator, with the documentation of each shape and member first and its other traits sorted by id. Comments are
+// kept, with the shape or member they are next to, and the header comments of the file at its top. A source whose
+// canonical form does not parse to the same model, or loses a comment, is an error. The name is used in errors.
+func Format(src string, name string) (string, error) {
+	cst, err := ParseCST(src, name)
+	if err != nil {
+		return "", err
+	}
+	ast := cst.AST
+	ns := cst.Namespace
+	if nss := ast.Namespaces(); len(nss) > 0 {
+		ns = nss[0]
+	}
+	ast.Walk(&traitSorter{})
+	out := ast.IDLWithOptions(ns, &IdlOptions{CST: cst})
+	formatted, err := ParseCST(out, name)
+	if err != nil {
+		return "", fmt.Errorf("%s: Cannot format, the result does not parse: %v", name, err)
+	}
+	if changes := Diff(ast, formatted.AST).Changes; len(changes) > 0 {
+		return "", fmt.Errorf("%s: Cannot format, the result would change the model: %s", name, changes[0])
+	}
+	if id := changedApplied(ast, formatted.AST); id != "" {
+		return "", fmt.Errorf("%s: Cannot format, the result would change the traits applied to %s", name, id)
+	}
+	if lost := lostComments(cst.Comments(), formatted.Comments()); lost != nil {
+		return "", fmt.Errorf("%s:%d:%d: Cannot format, the comment would be lost: %s", name, lost.Line, lost.Column, lost)
+	}
+	return out, nil
+}
+
+// changedApplied returns the id of the first shape or member whose traits applied with apply statements differ between
+// the models, or "" if they are the same.
+func changedApplied(ast, formatted *AST) string {
+	for _, id := range ast.applied.Keys() {
+		if !nodeEqual(ast.applied.Get(id), formatted.applied.Get(id)) {
+			return id
+		}
+	}
+	for _, id := range formatted.applied.Keys() {
+		if !ast.applied.Has(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// lostComments returns the first of the comments that is not in the formatted ones, or nil if they are all there.
+func lostComments(comments, formatted []*Comment) *Comment {
+	count := make(map[string]int, 0)
+	for _, c := range formatted {
+		count[c.String()]++
+	}
+	for _, c := range comments {
+		if count[c.String()] == 0 {
+			return c
+		}
+		count[c.String()]--
+	}
+	return nil
+}
+
+// traitSorter puts the traits of every shape and member in order of their ids, which is the canonical order.
+type traitSorter struct {
+	BaseShapeVisitor
+}
+
+func (v *traitSorter) PreShape(id string, shape *Shape) error {
+	shape.Traits = sortTraits(shape.Traits)
+	return nil
+}
+
+func (v *traitSorter) VisitMember(shapeId string, name string, member *Member) error {
+	member.Traits = sortTraits(member.Traits)
+	return nil
+}
+
+func sortTraits(traits *data.Object) *data.Object {
+	if traits == nil {
+		return nil
+	}
+	keys := append([]string(nil), traits.Keys()...)
+	sort.Strings(keys)
+	sorted := data.NewObject()
+	for _, k := range keys {
+		sorted.Put(k, traits.Get(k))
+	}
+	return sorted
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the tests with the current output")
+
+// TestFormatGolden formats each testdata/format/*.smithy file, and compares the result with the .golden file next to
+// it. Formatting the golden file must not change it either.
+func TestFormatGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/format/*.smithy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			formatted, err := FormatFile(path)
+			if err != nil {
+				t.Fatalf("Cannot format: %v", err)
+			}
+			golden := strings.TrimSuffix(path, ".smithy") + ".golden"
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, []byte(formatted), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if formatted != string(expected) {
+				t.Errorf("The formatted file does not match %s:\n%s", golden, formatted)
+			}
+			again, err := Format(formatted, golden)
+			if err != nil {
+				t.Fatalf("Cannot format the formatted file: %v", err)
+			}
+			if again != formatted {
+				t.Errorf("Formatting the formatted file changed it:\n%s", again)
+			}
+		})
+	}
+}
@@ -18,10 +18,19 @@ package smithy
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/boynton/data"
 )
@@ -30,23 +39,176 @@ type Generator interface {
 	Generate(ast *AST, config *data.Object) error
 }
 
+// GeneratorFactory returns a new instance of a generator, since generators keep state while generating.
+type GeneratorFactory func() Generator
+
+var generators = map[string]GeneratorFactory{
+	"ast":           func() Generator { return new(AstGenerator) },
+	"idl":           func() Generator { return new(IdlGenerator) },
+	"sadl":          func() Generator { return new(SadlGenerator) },
+	"openapi":       func() Generator { return new(OpenApiGenerator) },
+	"jsonschema":    func() Generator { return new(JsonSchemaGenerator) },
+	"ts":            func() Generator { return new(TypeScriptGenerator) },
+	"diagram":       func() Generator { return new(DiagramGenerator) },
+	"dot":           func() Generator { return new(DotGenerator) },
+	"asyncapi":      func() Generator { return new(AsyncApiGenerator) },
+	"mock":          func() Generator { return new(MockGenerator) },
+	"examples":      func() Generator { return new(ExamplesGenerator) },
+	"python":        func() Generator { return new(PythonGenerator) },
+	"report":        func() Generator { return new(ReportGenerator) },
+	"protocoltests": func() Generator { return new(ProtocolTestGenerator) },
+	"gotraits":      func() Generator { return new(GoTraitsGenerator) },
+	"html":          func() Generator { return new(HtmlGenerator) },
+}
+var generatorsLock sync.Mutex
+
+// RegisterGenerator makes the generator available by name, i.e. to the -g flag of the smithy tool and to the plugins
+// of a build config, replacing any existing generator with the name.
+func RegisterGenerator(name string, factory GeneratorFactory) {
+	generatorsLock.Lock()
+	defer generatorsLock.Unlock()
+	generators[name] = factory
+}
+
+// LookupGenerator returns a new instance of the generator with the name, or nil if there is none. A name of the form
+// "exec:program" is an ExecGenerator that runs the program.
+func LookupGenerator(name string) Generator {
+	if strings.HasPrefix(name, "exec:") {
+		return &ExecGenerator{Command: name[len("exec:"):]}
+	}
+	generatorsLock.Lock()
+	defer generatorsLock.Unlock()
+	if factory, ok := generators[name]; ok {
+		return factory()
+	}
+	return nil
+}
+
+// GeneratorNames returns the names of the registered generators, sorted.
+func GeneratorNames() []string {
+	generatorsLock.Lock()
+	defer generatorsLock.Unlock()
+	var names []string
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type BaseGenerator struct {
 	Config         *data.Object
 	OutDir         string
 	ForceOverwrite bool
+	DryRun         bool            //if true, files are only recorded in the manifest, and nothing is written
+	Sink           OutputSink      //set by SetSink: if not nil, files are written to it, rather than to OutDir or stdout
+	Context        context.Context //set by GenerateContext: emitting a file fails with its error once it is cancelled
+	Logger         Logger          //set by SetLogger, or one that discards everything
 	buf            bytes.Buffer
 	file           *os.File
 	writer         *bufio.Writer
 	Err            error
+	files          []*GeneratedFile
+}
+
+// ContextSetter is implemented by generators that can be cancelled, as those based on BaseGenerator are.
+type ContextSetter interface {
+	SetContext(ctx context.Context)
+}
+
+// SinkSetter is implemented by generators that can write their files to an OutputSink, as those based on
+// BaseGenerator can.
+type SinkSetter interface {
+	SetSink(sink OutputSink)
+}
+
+// LoggerSetter is implemented by generators that can log their progress, as those based on BaseGenerator can.
+type LoggerSetter interface {
+	SetLogger(logger Logger)
+}
+
+// GenerateContext generates the model as the generator's Generate does, stopping with the error of the context if it
+// is cancelled, or its deadline passes, when the generator is a ContextSetter.
+func GenerateContext(ctx context.Context, gen Generator, ast *AST, config *data.Object) error {
+	if cs, ok := gen.(ContextSetter); ok {
+		cs.SetContext(ctx)
+	}
+	return gen.Generate(ast, config)
+}
+
+// GeneratedFile is an entry in the manifest of the files that a generator wrote, or would write.
+type GeneratedFile struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// SetLogger sets the logger of the generator. It is kept by Configure.
+func (gen *BaseGenerator) SetLogger(logger Logger) {
+	gen.Logger = logger
+}
+
+// ManifestReporter is implemented by generators that can report the files they emitted, as those based on
+// BaseGenerator do.
+type ManifestReporter interface {
+	Manifest() []*GeneratedFile
 }
 
 func (gen *BaseGenerator) Configure(conf *data.Object) error {
 	gen.Config = conf
 	gen.OutDir = conf.GetString("outdir")
 	gen.ForceOverwrite = conf.GetBool("force")
+	gen.DryRun = conf.GetBool("dryRun")
+	if gen.Context == nil {
+		gen.Context = context.Background()
+	}
+	gen.Logger = orNopLogger(gen.Logger)
+	gen.files = nil
 	return nil
 }
 
+// SetContext sets the context that stops the generator once it is cancelled. It is kept by Configure.
+func (gen *BaseGenerator) SetContext(ctx context.Context) {
+	gen.Context = ctx
+}
+
+// SetSink sets the sink that the files are written to, rather than to the output directory or stdout. It is kept by
+// Configure.
+func (gen *BaseGenerator) SetSink(sink OutputSink) {
+	gen.Sink = sink
+}
+
+// Manifest returns the files emitted by the generator, in order. When there is no output directory, the paths are
+// the names the files would have had.
+func (gen *BaseGenerator) Manifest() []*GeneratedFile {
+	return gen.files
+}
+
+// TargetModel returns the model converted to the IDL version given by the "targetVersion" option, or to version 2 if
+// there is none, and with "sort=alpha", sorted (see AST.Sorted) so that regenerating it makes minimal diffs.
+func (gen *BaseGenerator) TargetModel(ast *AST) (*AST, error) {
+	version := 2
+	if v := gen.Config.Get("targetVersion"); v != nil {
+		var err error
+		version, err = strconv.Atoi(strings.TrimSuffix(fmt.Sprint(v), ".0"))
+		if err != nil {
+			return nil, fmt.Errorf("Bad targetVersion: %v", v)
+		}
+	}
+	ast, err := ast.WithVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	switch order := gen.Config.GetString("sort"); order {
+	case "":
+		return ast, nil
+	case "alpha":
+		return ast.Sorted(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported sort option: %q", order)
+	}
+}
+
 func (gen *BaseGenerator) FileExists(path string) bool {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return false
@@ -78,54 +240,283 @@ func (gen *BaseGenerator) WriteFile(path string, content string) error {
 }
 
 func (gen *BaseGenerator) Emit(text string, filename string, separator string) error {
-	if gen.OutDir == "" {
+	return gen.EmitTo(filename, separator, func(w io.Writer) error {
+		_, err := io.WriteString(w, text)
+		return err
+	})
+}
+
+// EmitTo emits the file as Emit does, but the content is written incrementally by the write function, so that large
+// output need not be held in memory. Only a Sink, which takes the content whole, gets it buffered.
+func (gen *BaseGenerator) EmitTo(filename string, separator string, write func(w io.Writer) error) error {
+	if gen.Context != nil && gen.Context.Err() != nil {
+		return gen.Context.Err()
+	}
+	fpath := filename
+	if gen.OutDir != "" && gen.Sink == nil {
+		fpath = filepath.Join(gen.OutDir, filename)
+	}
+	sum := &manifestWriter{hash: sha256.New()}
+	var err error
+	switch {
+	case gen.DryRun:
+		if gen.Sink == nil && gen.OutDir != "" && !gen.ForceOverwrite && gen.FileExists(fpath) {
+			return fmt.Errorf("[%s already exists, not overwriting]", fpath)
+		}
+		err = write(sum)
+	case gen.Sink != nil:
+		var buf bytes.Buffer
+		err = write(io.MultiWriter(&buf, sum))
+		if err == nil {
+			err = gen.Sink.WriteFile(filepath.ToSlash(fpath), buf.Bytes())
+		}
+	case gen.OutDir == "":
 		if separator != "" {
 			fmt.Print(separator)
 		}
-		fmt.Print(text)
-	} else {
-		fpath := filepath.Join(gen.OutDir, filename)
-		err := gen.WriteFile(fpath, text)
-		if err != nil {
-			return err
-		}
+		err = gen.writeTo(os.Stdout, sum, write)
+	default:
+		err = gen.createFile(fpath, sum, write)
+	}
+	if err != nil {
+		return err
 	}
+	gen.files = append(gen.files, &GeneratedFile{Path: fpath, Size: sum.size, Sha256: hex.EncodeToString(sum.hash.Sum(nil))})
 	return nil
 }
 
+func (gen *BaseGenerator) createFile(path string, sum io.Writer, write func(w io.Writer) error) error {
+	if gen.Err != nil {
+		return gen.Err
+	}
+	if !gen.ForceOverwrite && gen.FileExists(path) {
+		return fmt.Errorf("[%s already exists, not overwriting]", path)
+	}
+	gen.Err = gen.replaceFile(path, sum, write)
+	return gen.Err
+}
+
+// replaceFile writes the file to a temporary file in the same directory, which replaces it only once it has all been
+// written, so that a failure leaves no partial file behind.
+func (gen *BaseGenerator) replaceFile(path string, sum io.Writer, write func(w io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".generate-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = gen.writeTo(tmp, sum, write)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (gen *BaseGenerator) writeTo(out io.Writer, sum io.Writer, write func(w io.Writer) error) error {
+	writer := bufio.NewWriter(out)
+	err := write(io.MultiWriter(writer, sum))
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// manifestWriter computes the size and hash of a generated file, for its manifest entry, as it is written.
+type manifestWriter struct {
+	hash hash.Hash
+	size int
+}
+
+func (w *manifestWriter) Write(p []byte) (int, error) {
+	w.size += len(p)
+	return w.hash.Write(p)
+}
+
 type AstGenerator struct {
 	BaseGenerator
 }
 
+// Generate the JSON AST for the model. The "format" option may be "yaml" to produce the equivalent YAML instead, and
+// the "targetVersion" option (1 or 2, 2 by default) converts the model to that version of Smithy first. For publishing
+// the AST as an artifact, "compact" leaves the whitespace out of the JSON, "sort=alpha" sorts it, "strip-sources"
+// removes the "source:" lines that older versions added to the documentation for source annotations, and
+// "exclude-prelude" leaves out any smithy.api shapes.
 func (gen *AstGenerator) Generate(ast *AST, config *data.Object) error {
 	err := gen.Configure(config)
 	if err != nil {
 		return err
 	}
-	text := data.Pretty(ast)
-	return gen.Emit(text, "model.json", "")
+	ast, err = gen.TargetModel(ast)
+	if err != nil {
+		return err
+	}
+	if config.GetBool("strip-sources") || config.GetBool("exclude-prelude") {
+		ast = gen.publishedModel(ast)
+	}
+	switch format := config.GetString("format"); format {
+	case "", "json":
+		if config.GetBool("compact") {
+			return gen.EmitTo("model.json", "", ast.WriteCompactJSON)
+		}
+		return gen.EmitTo("model.json", "", ast.WriteJSON)
+	case "yaml":
+		text, err := ToYaml(ast)
+		if err != nil {
+			return err
+		}
+		return gen.Emit(text, "model.yaml", "")
+	default:
+		return fmt.Errorf("Unsupported AST format: %q", format)
+	}
+}
+
+// publishedModel returns a copy of the model without the prelude shapes or source annotations, as the options ask.
+func (gen *AstGenerator) publishedModel(ast *AST) *AST {
+	published := &AST{Smithy: ast.Smithy, Metadata: ast.Metadata, Shapes: NewShapes()}
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		if gen.Config.GetBool("exclude-prelude") && shapeIdNamespace(id) == "smithy.api" {
+			continue
+		}
+		if doc := shape.Traits.GetString("smithy.api#documentation"); doc != "" && gen.Config.GetBool("strip-sources") {
+			shape = shape.Clone()
+			if doc = stripSourceAnnotation(doc); doc != "" {
+				shape.Traits.Put("smithy.api#documentation", doc)
+			} else {
+				shape.Traits = withoutTrait(shape.Traits, "smithy.api#documentation")
+			}
+		}
+		published.Shapes.Put(id, shape)
+	}
+	return published
 }
 
 type IdlGenerator struct {
 	BaseGenerator
 }
 
+// Generate Smithy IDL for the model, a file per namespace. The "targetVersion" option (1 or 2, 2 by default) converts
+// the model to that version of Smithy first, and the "shapeIds" and "textblock-docs" options are how shape ids and
+// documentation are written (see IdlOptions). Metadata is not namespaced, so the "metadata" option says which files
+// get it: "first" (the default), "all", "none", or "file:<namespace>". With the "verify-roundtrip" option, the IDL is
+// parsed again and compared with the model before anything is emitted, failing if any shape, member or trait was lost
+// or changed in translation.
 func (gen *IdlGenerator) Generate(ast *AST, config *data.Object) error {
 	err := gen.Configure(config)
 	if err != nil {
 		return err
 	}
+	ast, err = gen.TargetModel(ast)
+	if err != nil {
+		return err
+	}
 	//generate one file per namespace. For outdir == "", concatenate with separator indicating intended filename
-	//fixme: preserve metadata. Smithy IDL is problematic for that, since metadata is not namespaced, and gets merged
-	//on assembly. Should each namespaced IDL get all metadata? none?
-	for _, ns := range ast.Namespaces() {
-		fname := gen.FileName(ns, ".smithy")
+	opts := &IdlOptions{
+		ShapeIds:      config.GetString("shapeIds"),
+		TextBlockDocs: config.GetBool("textblock-docs"),
+	}
+	err = checkShapeIdsOption(opts.ShapeIds)
+	if err != nil {
+		return err
+	}
+	namespaces := ast.Namespaces()
+	withMetadata, err := metadataPolicy(config.GetString("metadata"), namespaces)
+	if err != nil {
+		return err
+	}
+	nsOptions := func(ns string) *IdlOptions {
+		o := *opts
+		o.OmitMetadata = !withMetadata(ns)
+		return &o
+	}
+	if !config.GetBool("verify-roundtrip") {
+		//nothing needs the IDL as a whole, so it is written out as it is generated
+		for _, ns := range namespaces {
+			fname := gen.FileName(ns, ".smithy")
+			err := gen.EmitTo(fname, fmt.Sprintf("\n// ===== File(%q)\n\n", fname), func(out io.Writer) error {
+				return ast.WriteIDL(out, ns, nsOptions(ns))
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var fnames, sources []string
+	omitMetadata := true
+	for _, ns := range namespaces {
+		fnames = append(fnames, gen.FileName(ns, ".smithy"))
+		sources = append(sources, ast.IDLWithOptions(ns, nsOptions(ns)))
+		omitMetadata = omitMetadata && !withMetadata(ns)
+	}
+	err = verifyRoundTrip(ast, fnames, sources, omitMetadata)
+	if err != nil {
+		return err
+	}
+	for i, fname := range fnames {
 		sep := fmt.Sprintf("\n// ===== File(%q)\n\n", fname)
-		s := ast.IDL(ns)
-		err := gen.Emit(s, fname, sep)
+		err := gen.Emit(sources[i], fname, sep)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// metadataPolicy returns whether the IDL file for a namespace gets the metadata of the model, according to the
+// "metadata" option of the IdlGenerator.
+func metadataPolicy(policy string, namespaces []string) (func(ns string) bool, error) {
+	switch {
+	case policy == "all":
+		return func(ns string) bool { return true }, nil
+	case policy == "none":
+		return func(ns string) bool { return false }, nil
+	case policy == "" || policy == "first":
+		return func(ns string) bool { return len(namespaces) > 0 && ns == namespaces[0] }, nil
+	case strings.HasPrefix(policy, "file:"):
+		target := policy[len("file:"):]
+		if !containsString(namespaces, target) {
+			return nil, fmt.Errorf("No IDL file for the metadata, the model has no shapes in namespace %q", target)
+		}
+		return func(ns string) bool { return ns == target }, nil
+	}
+	return nil, fmt.Errorf("Unsupported metadata option: %q", policy)
+}
+
+func checkShapeIdsOption(shapeIds string) error {
+	switch shapeIds {
+	case "", "relative", "local", "absolute":
+		return nil
+	}
+	return fmt.Errorf("Unsupported shapeIds option: %q", shapeIds)
+}
+
+// verifyRoundTrip parses the IDL generated for the model, and compares the result with it, returning an error that
+// lists the differences, if there are any. The files are merged as they would be when assembled, metadata included,
+// unless no file has the metadata, in which case it is not compared.
+func verifyRoundTrip(ast *AST, fnames []string, sources []string, omitMetadata bool) error {
+	reparsed := &AST{Smithy: ast.Smithy}
+	for i, src := range sources {
+		parsed, err := ParseString(src, fnames[i])
+		if err != nil {
+			return fmt.Errorf("Round-trip verification failed, the generated IDL does not parse: %v", err)
+		}
+		err = reparsed.Merge(parsed)
+		if err != nil {
+			return fmt.Errorf("Round-trip verification failed: %v", err)
+		}
+	}
+	if omitMetadata {
+		reparsed.Metadata = ast.Metadata
+	}
+	changes := Diff(ast, reparsed)
+	if len(changes.Changes) > 0 {
+		return fmt.Errorf("Round-trip verification failed, the generated IDL differs from the model:\n%s", changes)
+	}
+	return nil
+}
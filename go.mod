@@ -3,3 +3,7 @@ module github.com/boynton/smithy
 go 1.17
 
 require github.com/boynton/data v0.0.1
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/jmespath/go-jmespath v0.4.0
@@ -0,0 +1,208 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+type GoTraitsGenerator struct {
+	BaseGenerator
+}
+
+// Generate a Go source file per namespace that defines traits. Each shape marked with @trait becomes a Go type with
+// JSON tags, along with a constant for its absolute id and Get/Put functions to read and write the trait on the
+// *data.Object that holds a shape's traits. Shapes in the same namespace that the traits refer to are also emitted.
+// The "package" option sets the Go package name, which defaults to the last component of the namespace.
+func (gen *GoTraitsGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	for _, ns := range ast.Namespaces() {
+		traits := goTraitShapes(ast, ns)
+		if len(traits) == 0 {
+			continue
+		}
+		fname := strings.ReplaceAll(ns, ".", "_") + "_traits.go"
+		sep := fmt.Sprintf("\n// ===== File(%q)\n\n", fname)
+		err := gen.Emit(gen.ToGoTraits(ast, ns, traits), fname, sep)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func goTraitShapes(ast *AST, ns string) []string {
+	var traits []string
+	for _, k := range ast.ShapesWithTrait("smithy.api#trait") {
+		if shapeIdNamespace(k) == ns {
+			traits = append(traits, k)
+		}
+	}
+	return traits
+}
+
+type GoTraitsWriter struct {
+	buf       bytes.Buffer
+	writer    *bufio.Writer
+	namespace string
+	ast       *AST
+	emitted   map[string]bool
+}
+
+func (w *GoTraitsWriter) Emit(format string, args ...interface{}) {
+	w.writer.WriteString(fmt.Sprintf(format, args...))
+}
+
+func (gen *GoTraitsGenerator) ToGoTraits(ast *AST, ns string, traits []string) string {
+	pkg := gen.Config.GetString("package")
+	if pkg == "" {
+		lst := strings.Split(ns, ".")
+		pkg = strings.ToLower(lst[len(lst)-1])
+	}
+	w := &GoTraitsWriter{
+		namespace: ns,
+		ast:       ast,
+		emitted:   make(map[string]bool, 0),
+	}
+	w.writer = bufio.NewWriter(&w.buf)
+	w.Emit("// Code generated by smithy from the %s namespace. DO NOT EDIT.\n\n", ns)
+	w.Emit("package %s\n\n", pkg)
+	w.Emit("import (\n\t\"encoding/json\"\n\n\t\"github.com/boynton/data\"\n)\n")
+	for _, id := range traits {
+		w.EmitTrait(id)
+	}
+	w.writer.Flush()
+	src, err := format.Source(w.buf.Bytes())
+	if err != nil {
+		return w.buf.String()
+	}
+	return string(src)
+}
+
+func (w *GoTraitsWriter) EmitTrait(id string) {
+	name := Capitalize(StripNamespace(id))
+	w.EmitType(id)
+	w.Emit("\nconst %sTraitId = %q\n", name, id)
+	w.Emit("\n// Get%sTrait returns the value of the %s trait, or nil if it is not present.\n", name, id)
+	w.Emit("func Get%sTrait(traits *data.Object) (*%s, error) {\n", name, name)
+	w.Emit("\tif !traits.Has(%sTraitId) {\n\t\treturn nil, nil\n\t}\n", name)
+	w.Emit("\tb, err := json.Marshal(traits.Get(%sTraitId))\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", name)
+	w.Emit("\tvar v %s\n\terr = json.Unmarshal(b, &v)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n\treturn &v, nil\n}\n", name)
+	w.Emit("\n// Put%sTrait sets the value of the %s trait.\n", name, id)
+	w.Emit("func Put%sTrait(traits *data.Object, v *%s) error {\n", name, name)
+	w.Emit("\tb, err := json.Marshal(v)\n\tif err != nil {\n\t\treturn err\n\t}\n")
+	w.Emit("\tvar raw interface{}\n\terr = json.Unmarshal(b, &raw)\n\tif err != nil {\n\t\treturn err\n\t}\n")
+	w.Emit("\tif m, ok := raw.(map[string]interface{}); ok {\n\t\traw = data.ObjectFromMap(m)\n\t}\n")
+	w.Emit("\ttraits.Put(%sTraitId, raw)\n\treturn nil\n}\n", name)
+}
+
+// EmitType emits the Go type for the shape, followed by any shapes in the same namespace that it refers to.
+func (w *GoTraitsWriter) EmitType(id string) {
+	if w.emitted[id] {
+		return
+	}
+	w.emitted[id] = true
+	shape := w.ast.GetShape(id)
+	name := Capitalize(StripNamespace(id))
+	w.Emit("\n")
+	if doc := shape.Traits.GetString("smithy.api#documentation"); doc != "" {
+		w.Emit("%s", FormatComment("", "// ", doc, 100, false))
+	}
+	var refs []string
+	switch shape.Type {
+	case "structure", "union":
+		w.Emit("type %s struct {\n", name)
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			omit := ",omitempty"
+			if shape.Type == "structure" && mem.Traits.Has("smithy.api#required") {
+				omit = ""
+			}
+			w.Emit("\t%s %s `json:\"%s%s\"`\n", Capitalize(k), w.fieldType(mem.Target), k, omit)
+			refs = append(refs, mem.Target)
+		}
+		w.Emit("}\n")
+	case "list", "set":
+		w.Emit("type %s []%s\n", name, w.typeRef(shape.Member.Target))
+		refs = append(refs, shape.Member.Target)
+	case "map":
+		w.Emit("type %s map[string]%s\n", name, w.typeRef(shape.Value.Target))
+		refs = append(refs, shape.Value.Target)
+	default:
+		w.Emit("type %s %s\n", name, goSimpleType(shape.Type))
+	}
+	for _, ref := range refs {
+		if shapeIdNamespace(ref) == w.namespace {
+			w.EmitType(ref)
+		}
+	}
+}
+
+// fieldType returns the type of a struct field, using pointers to structures so that optional values can be omitted.
+func (w *GoTraitsWriter) fieldType(target string) string {
+	t := w.typeRef(target)
+	if shape := w.ast.GetShape(target); shape != nil && (shape.Type == "structure" || shape.Type == "union") {
+		return "*" + t
+	}
+	return t
+}
+
+func (w *GoTraitsWriter) typeRef(target string) string {
+	ns := shapeIdNamespace(target)
+	if ns == "smithy.api" {
+		return goSimpleType(Uncapitalize(strings.TrimPrefix(StripNamespace(target), "Primitive")))
+	}
+	if ns != w.namespace {
+		return "interface{}"
+	}
+	return Capitalize(StripNamespace(target))
+}
+
+func goSimpleType(shapeType string) string {
+	switch shapeType {
+	case "string", "enum", "timestamp":
+		return "string"
+	case "boolean":
+		return "bool"
+	case "byte":
+		return "int8"
+	case "short":
+		return "int16"
+	case "integer", "intEnum":
+		return "int32"
+	case "long":
+		return "int64"
+	case "float":
+		return "float32"
+	case "double":
+		return "float64"
+	case "bigInteger", "bigDecimal":
+		return "*data.Decimal"
+	case "blob":
+		return "[]byte"
+	default:
+		return "interface{}"
+	}
+}
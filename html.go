@@ -0,0 +1,233 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+type HtmlGenerator struct {
+	BaseGenerator
+	ast *AST
+	sb  strings.Builder
+}
+
+// Generate browsable documentation for the model, as a single HTML page with a section for each shape, grouped by
+// namespace, and links between the shapes. The "title" option is the title of the page.
+func (gen *HtmlGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	title := config.GetString("title")
+	if title == "" {
+		title = "API Documentation"
+	}
+	return gen.Emit(gen.ToHtml(ast, title), "index.html", "")
+}
+
+// the order that the kinds of shapes are listed in, within a namespace
+var htmlShapeTypeOrder = map[string]int{"service": 0, "resource": 1, "operation": 2}
+
+// ToHtml returns the documentation page for the model.
+func (gen *HtmlGenerator) ToHtml(ast *AST, title string) string {
+	gen.ast = ast
+	gen.sb.Reset()
+	byNamespace := make(map[string][]string, 0)
+	for _, id := range ast.Shapes.Keys() {
+		ns := shapeIdNamespace(id)
+		if ns != "smithy.api" {
+			byNamespace[ns] = append(byNamespace[ns], id)
+		}
+	}
+	var namespaces []string
+	for ns, ids := range byNamespace {
+		namespaces = append(namespaces, ns)
+		sort.SliceStable(ids, func(i, j int) bool {
+			return gen.typeOrder(ids[i]) < gen.typeOrder(ids[j])
+		})
+	}
+	sort.Strings(namespaces)
+	gen.printf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	gen.printf("<style>\n%s</style>\n</head>\n<body>\n", htmlStyle)
+	gen.printf("<nav>\n<h1>%s</h1>\n", html.EscapeString(title))
+	for _, ns := range namespaces {
+		gen.printf("<h3>%s</h3>\n<ul>\n", html.EscapeString(ns))
+		for _, id := range byNamespace[ns] {
+			gen.printf("<li>%s <span class=\"type\">%s</span></li>\n", gen.link(id), ast.GetShape(id).Type)
+		}
+		gen.printf("</ul>\n")
+	}
+	gen.printf("</nav>\n<main>\n")
+	for _, ns := range namespaces {
+		gen.printf("<h2 class=\"namespace\">%s</h2>\n", html.EscapeString(ns))
+		for _, id := range byNamespace[ns] {
+			gen.shapeSection(id)
+		}
+	}
+	gen.printf("</main>\n</body>\n</html>\n")
+	return gen.sb.String()
+}
+
+func (gen *HtmlGenerator) typeOrder(id string) int {
+	if n, ok := htmlShapeTypeOrder[gen.ast.GetShape(id).Type]; ok {
+		return n
+	}
+	return len(htmlShapeTypeOrder)
+}
+
+func (gen *HtmlGenerator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&gen.sb, format, args...)
+}
+
+func (gen *HtmlGenerator) shapeSection(id string) {
+	shape := gen.ast.GetShape(id)
+	gen.printf("<section id=\"%s\">\n<h3>%s <span class=\"type\">%s</span></h3>\n", htmlAnchor(id), html.EscapeString(StripNamespace(id)), shape.Type)
+	gen.docs(shape.Traits)
+	gen.traits(shape.Traits)
+	if len(shape.Mixins) > 0 {
+		gen.refs("Mixins", shape.Mixins)
+	}
+	switch shape.Type {
+	case "service":
+		if shape.Version != "" {
+			gen.printf("<p><b>Version:</b> %s</p>\n", html.EscapeString(shape.Version))
+		}
+		gen.refs("Operations", shape.Operations)
+		gen.refs("Resources", shape.Resources)
+	case "resource":
+		var names []string
+		for name := range shape.Identifiers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			gen.printf("<p><b>Identifier %s:</b> %s</p>\n", html.EscapeString(name), gen.link(shape.Identifiers[name].Target))
+		}
+		for _, op := range []struct {
+			name string
+			ref  *ShapeRef
+		}{{"Create", shape.Create}, {"Put", shape.Put}, {"Read", shape.Read}, {"Update", shape.Update}, {"Delete", shape.Delete}, {"List", shape.List}} {
+			if op.ref != nil {
+				gen.printf("<p><b>%s:</b> %s</p>\n", op.name, gen.link(op.ref.Target))
+			}
+		}
+		gen.refs("Operations", shape.Operations)
+		gen.refs("Collection operations", shape.CollectionOperations)
+		gen.refs("Resources", shape.Resources)
+	case "operation":
+		if shape.Input != nil {
+			gen.printf("<p><b>Input:</b> %s</p>\n", gen.link(shape.Input.Target))
+		}
+		if shape.Output != nil {
+			gen.printf("<p><b>Output:</b> %s</p>\n", gen.link(shape.Output.Target))
+		}
+		gen.refs("Errors", shape.Errors)
+	}
+	mids := memberIds(id, shape)
+	if len(mids) > 0 {
+		gen.printf("<table>\n<tr><th>Member</th><th>Target</th><th>Description</th></tr>\n")
+		for _, mid := range mids {
+			member := gen.ast.getMember(mid)
+			gen.printf("<tr id=\"%s\"><td><code>%s</code></td><td>%s</td><td>", htmlAnchor(mid), html.EscapeString(ShapeID(mid).Member()), gen.link(member.Target))
+			gen.docs(member.Traits)
+			gen.traits(member.Traits)
+			gen.printf("</td></tr>\n")
+		}
+		gen.printf("</table>\n")
+	}
+	gen.printf("</section>\n")
+}
+
+func (gen *HtmlGenerator) docs(traits *data.Object) {
+	doc := strings.TrimSpace(traits.GetString("smithy.api#documentation"))
+	if doc == "" {
+		return
+	}
+	for _, para := range strings.Split(doc, "\n\n") {
+		gen.printf("<p>%s</p>\n", html.EscapeString(para))
+	}
+}
+
+func (gen *HtmlGenerator) traits(traits *data.Object) {
+	var items []string
+	for _, tid := range traits.Keys() {
+		if tid == "smithy.api#documentation" {
+			continue
+		}
+		s := "@" + StripNamespace(tid)
+		if v := traits.Get(tid); !isEmptyNode(v) {
+			s = s + " " + data.Json(v)
+		}
+		items = append(items, "<code>"+html.EscapeString(s)+"</code>")
+	}
+	if len(items) > 0 {
+		gen.printf("<p class=\"traits\">%s</p>\n", strings.Join(items, " "))
+	}
+}
+
+func (gen *HtmlGenerator) refs(label string, refs []*ShapeRef) {
+	if len(refs) == 0 {
+		return
+	}
+	var links []string
+	for _, ref := range refs {
+		links = append(links, gen.link(ref.Target))
+	}
+	gen.printf("<p><b>%s:</b> %s</p>\n", label, strings.Join(links, ", "))
+}
+
+// link returns a link to the shape if it is documented on the page, otherwise just its name.
+func (gen *HtmlGenerator) link(id string) string {
+	name := html.EscapeString(StripNamespace(id))
+	if gen.ast.GetShape(id) == nil || shapeIdNamespace(id) == "smithy.api" {
+		return "<code>" + name + "</code>"
+	}
+	return fmt.Sprintf("<a href=\"#%s\"><code>%s</code></a>", htmlAnchor(id), name)
+}
+
+func htmlAnchor(id string) string {
+	return html.EscapeString(strings.NewReplacer("#", "-", "$", "-").Replace(id))
+}
+
+// isEmptyNode returns true for the values of annotation traits, i.e. @required, which are empty objects.
+func isEmptyNode(v interface{}) bool {
+	switch n := v.(type) {
+	case nil:
+		return true
+	case *data.Object:
+		return n.Length() == 0
+	case map[string]interface{}:
+		return len(n) == 0
+	}
+	return false
+}
+
+const htmlStyle = `body { font-family: sans-serif; margin: 0; display: flex; }
+nav { width: 18em; height: 100vh; overflow-y: auto; position: sticky; top: 0; padding: 0 1em; background: #f4f4f4; }
+nav ul { list-style: none; padding-left: 0.5em; }
+main { flex: 1; padding: 0 2em; max-width: 60em; }
+section { border-top: 1px solid #ddd; padding-bottom: 1em; }
+.type { color: #888; font-size: 0.8em; font-weight: normal; }
+.traits code { background: #eef; margin-right: 0.5em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; vertical-align: top; border-bottom: 1px solid #eee; padding: 0.3em; }
+`
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// HttpTrait is the value of the @http trait, as DecodeNode decodes it.
+type HttpTrait struct {
+	Method string `json:"method"`
+	Uri    string `json:"uri"`
+	Code   int    `json:"code,omitempty"`
+}
+
+// HttpBindingValidator checks the HTTP bindings of every operation with the @http trait: the labels in the uri must
+// match the @httpLabel members of the input, there may be only one @httpPayload member in the input and output, and
+// members bound to headers, query parameters and labels must target the kinds of shapes that can be serialized there.
+var HttpBindingValidator = ValidatorFunc(validateHttpBindings)
+
+func validateHttpBindings(ast *AST) []*ValidationEvent {
+	v := &modelValidator{ast: ast}
+	for _, id := range ast.ShapesWithTrait("smithy.api#http") {
+		if shape := ast.GetShape(id); shape != nil && shape.Type == "operation" {
+			v.checkHttpOperation(id, shape)
+		}
+	}
+	return v.events
+}
+
+func (v *modelValidator) checkHttpOperation(id string, op *Shape) {
+	http := data.AsMap(op.Traits.Get("smithy.api#http"))
+	method := data.AsString(http["method"])
+	uri := data.AsString(http["uri"])
+	if method == "" || !strings.HasPrefix(uri, "/") {
+		v.event("HttpBinding", SeverityError, id, "The @http trait must have a method and a uri that starts with '/'")
+		return
+	}
+	labels := httpUriLabels(uri)
+	var input, output *Members
+	if op.Input != nil {
+		if shape := v.ast.GetShape(op.Input.Target); shape != nil {
+			input = v.ast.allMembers(shape)
+		}
+	}
+	if op.Output != nil {
+		if shape := v.ast.GetShape(op.Output.Target); shape != nil {
+			output = v.ast.allMembers(shape)
+		}
+	}
+	bound := make(map[string]bool, 0)
+	payloads := 0
+	unbound := 0
+	headers := make(map[string]string, 0)
+	if input != nil {
+		for _, k := range input.Keys() {
+			mem := input.Get(k)
+			memId := op.Input.Target + "$" + k
+			switch {
+			case mem.Traits.Has("smithy.api#httpLabel"):
+				greedy, ok := labels[k]
+				if !ok {
+					v.event("HttpBinding.Label", SeverityError, memId, "The @httpLabel member %q has no label in the uri of %s: %s", k, id, uri)
+				} else if greedy && !v.targetsType(mem, "string") {
+					v.event("HttpBinding.Label", SeverityError, memId, "The greedy label {%s+} must be bound to a member that targets a string", k)
+				}
+				if !mem.Traits.Has("smithy.api#required") {
+					v.event("HttpBinding.Label", SeverityError, memId, "The @httpLabel member %q must be @required", k)
+				}
+				if !v.targetsSimpleType(mem) {
+					v.event("HttpBinding.Label", SeverityError, memId, "The @httpLabel member %q must target a simple type", k)
+				}
+				bound[k] = true
+			case mem.Traits.Has("smithy.api#httpQuery"):
+				if !v.targetsSimpleType(mem) && !v.targetsListOfSimpleType(mem) {
+					v.event("HttpBinding.Query", SeverityError, memId, "The @httpQuery member %q must target a simple type, or a list of them", k)
+				}
+			case mem.Traits.Has("smithy.api#httpQueryParams"):
+				if !v.targetsType(mem, "map") {
+					v.event("HttpBinding.Query", SeverityError, memId, "The @httpQueryParams member %q must target a map", k)
+				}
+			case mem.Traits.Has("smithy.api#httpHeader"):
+				v.checkHttpHeader(memId, k, mem, headers)
+			case mem.Traits.Has("smithy.api#httpPrefixHeaders"):
+				if !v.targetsType(mem, "map") {
+					v.event("HttpBinding.Header", SeverityError, memId, "The @httpPrefixHeaders member %q must target a map", k)
+				}
+			case mem.Traits.Has("smithy.api#httpPayload"):
+				payloads++
+			case mem.Traits.Has("smithy.api#httpResponseCode"):
+				v.event("HttpBinding.ResponseCode", SeverityError, memId, "The @httpResponseCode trait can only be used in an operation output")
+			default:
+				unbound++
+			}
+		}
+	}
+	for _, label := range sortedLabelNames(labels) {
+		if !bound[label] {
+			v.event("HttpBinding.Label", SeverityError, id, "The label {%s} in the uri has no @httpLabel member in the input", label)
+		}
+	}
+	v.checkHttpPayload(id, "input", payloads, unbound)
+	if payloads+unbound > 0 && (method == "GET" || method == "HEAD" || method == "DELETE" || method == "OPTIONS" || method == "TRACE") {
+		v.event("HttpBinding.Method", SeverityWarning, id, "The %s operation has an input payload, which the method is not expected to have", method)
+	}
+	if output != nil {
+		payloads, unbound = 0, 0
+		headers = make(map[string]string, 0)
+		for _, k := range output.Keys() {
+			mem := output.Get(k)
+			memId := op.Output.Target + "$" + k
+			switch {
+			case mem.Traits.Has("smithy.api#httpHeader"):
+				v.checkHttpHeader(memId, k, mem, headers)
+			case mem.Traits.Has("smithy.api#httpPrefixHeaders"):
+				if !v.targetsType(mem, "map") {
+					v.event("HttpBinding.Header", SeverityError, memId, "The @httpPrefixHeaders member %q must target a map", k)
+				}
+			case mem.Traits.Has("smithy.api#httpResponseCode"):
+				if !v.targetsType(mem, "integer") {
+					v.event("HttpBinding.ResponseCode", SeverityError, memId, "The @httpResponseCode member %q must target an integer", k)
+				}
+			case mem.Traits.Has("smithy.api#httpPayload"):
+				payloads++
+			case mem.Traits.Has("smithy.api#httpLabel"), mem.Traits.Has("smithy.api#httpQuery"), mem.Traits.Has("smithy.api#httpQueryParams"):
+				v.event("HttpBinding", SeverityError, memId, "Labels and query parameters can only be bound in an operation input")
+			default:
+				unbound++
+			}
+		}
+		v.checkHttpPayload(id, "output", payloads, unbound)
+	}
+}
+
+func (v *modelValidator) checkHttpHeader(memId, name string, mem *Member, headers map[string]string) {
+	header := data.AsString(mem.Traits.Get("smithy.api#httpHeader"))
+	if header == "" {
+		v.event("HttpBinding.Header", SeverityError, memId, "The @httpHeader trait of member %q must name the header", name)
+	} else if other, ok := headers[strings.ToLower(header)]; ok {
+		v.event("HttpBinding.Header", SeverityError, memId, "The header %q is bound to both %q and %q", header, other, name)
+	} else {
+		headers[strings.ToLower(header)] = name
+	}
+	if !v.targetsSimpleType(mem) && !v.targetsListOfSimpleType(mem) {
+		v.event("HttpBinding.Header", SeverityError, memId, "The @httpHeader member %q must target a simple type, or a list of them", name)
+	}
+}
+
+func (v *modelValidator) checkHttpPayload(id string, which string, payloads, unbound int) {
+	if payloads > 1 {
+		v.event("HttpBinding.Payload", SeverityError, id, "More than one @httpPayload member in the %s of the operation", which)
+	} else if payloads == 1 && unbound > 0 {
+		v.event("HttpBinding.Payload", SeverityError, id, "The %s of the operation has an @httpPayload member, so all other members must be bound to the HTTP message", which)
+	}
+}
+
+func (v *modelValidator) targetsType(mem *Member, types ...string) bool {
+	return containsString(types, v.shapeType(mem.Target))
+}
+
+func (v *modelValidator) targetsSimpleType(mem *Member) bool {
+	return v.targetsType(mem, "string", "enum", "boolean", "timestamp", "byte", "short", "integer", "long", "float", "double", "bigInteger", "bigDecimal", "intEnum")
+}
+
+func (v *modelValidator) targetsListOfSimpleType(mem *Member) bool {
+	if shape := v.ast.GetShape(mem.Target); shape != nil && (shape.Type == "list" || shape.Type == "set") && shape.Member != nil {
+		return v.targetsSimpleType(shape.Member)
+	}
+	return false
+}
+
+// httpUriLabels returns the labels in the path of the uri, and whether each is greedy, i.e. "{key+}".
+func httpUriLabels(uri string) map[string]bool {
+	labels := make(map[string]bool, 0)
+	path := strings.SplitN(uri, "?", 2)[0]
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			label := segment[1 : len(segment)-1]
+			greedy := strings.HasSuffix(label, "+")
+			labels[strings.TrimSuffix(label, "+")] = greedy
+		}
+	}
+	return labels
+}
+
+func sortedLabelNames(labels map[string]bool) []string {
+	var names []string
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// Reference is a relationship from a shape or member to another shape or member. The relationships are those of the
+// Smithy selector language ("member", "input", "output", "error", "operation", "resource", "collectionOperation",
+// "create", "read", "update", "delete", "list", "put", "identifier", "property", "mixin" and "trait"), plus "target",
+// from a member to the shape it targets.
+type Reference struct {
+	From         string
+	To           string
+	Relationship string
+}
+
+// Index is a snapshot of the relationships between the shapes of a model. It is not updated if the model changes.
+type Index struct {
+	ast      *AST
+	ids      []string
+	from     map[string][]*Reference
+	to       map[string][]*Reference
+	bound    map[string][]string
+	closures map[string][]string
+	services map[string][]string
+	traits   map[string][]string
+}
+
+// Index returns an index of the references between the shapes and members of the model, built in a single pass. The
+// closures of all the services are computed up front, so ServicesOf is a lookup, as are ReferencesTo and BoundTo.
+func (ast *AST) Index() *Index {
+	idx := newIndex(ast)
+	if ast.Shapes == nil {
+		return idx
+	}
+	for _, id := range ast.Shapes.Keys() {
+		idx.indexShape(id, ast.GetShape(id))
+	}
+	for _, id := range ast.Shapes.Keys() {
+		if ast.GetShape(id).Type == "service" {
+			for _, sid := range idx.Closure(id) {
+				idx.services[sid] = append(idx.services[sid], id)
+			}
+		}
+	}
+	return idx
+}
+
+func newIndex(ast *AST) *Index {
+	return &Index{
+		ast:      ast,
+		ids:      make([]string, 0),
+		from:     make(map[string][]*Reference, 0),
+		to:       make(map[string][]*Reference, 0),
+		bound:    make(map[string][]string, 0),
+		closures: make(map[string][]string, 0),
+		services: make(map[string][]string, 0),
+		traits:   make(map[string][]string, 0),
+	}
+}
+
+// indexShape adds the references from the shape and its members.
+func (idx *Index) indexShape(id string, shape *Shape) {
+	idx.ids = append(idx.ids, id)
+	idx.addShape(id, shape)
+	for _, mid := range memberIds(id, shape) {
+		mem := idx.ast.getMember(mid)
+		if mem == nil {
+			//a member without a definition, in a malformed model, has nothing to index
+			continue
+		}
+		idx.ids = append(idx.ids, mid)
+		idx.add(mid, mem.Target, "target")
+		idx.addTraits(mid, mem.Traits)
+	}
+}
+
+func (idx *Index) add(from, to, rel string) {
+	ref := &Reference{From: from, To: to, Relationship: rel}
+	idx.from[from] = append(idx.from[from], ref)
+	idx.to[to] = append(idx.to[to], ref)
+	switch rel {
+	case "operation", "resource", "collectionOperation", "create", "read", "update", "delete", "list", "put":
+		if !containsString(idx.bound[to], from) {
+			idx.bound[to] = append(idx.bound[to], from)
+		}
+	}
+}
+
+func (idx *Index) addRef(from string, ref *ShapeRef, rel string) {
+	if ref != nil {
+		idx.add(from, ref.Target, rel)
+	}
+}
+
+func (idx *Index) addRefs(from string, refs []*ShapeRef, rel string) {
+	for _, ref := range refs {
+		idx.add(from, ref.Target, rel)
+	}
+}
+
+func (idx *Index) addShape(id string, shape *Shape) {
+	for _, mid := range memberIds(id, shape) {
+		idx.add(id, mid, "member")
+	}
+	idx.addRefs(id, shape.Mixins, "mixin")
+	idx.addRef(id, shape.Input, "input")
+	idx.addRef(id, shape.Output, "output")
+	idx.addRefs(id, shape.Errors, "error")
+	idx.addRefs(id, shape.Operations, "operation")
+	idx.addRefs(id, shape.CollectionOperations, "collectionOperation")
+	idx.addRefs(id, shape.Resources, "resource")
+	idx.addRef(id, shape.Create, "create")
+	idx.addRef(id, shape.Put, "put")
+	idx.addRef(id, shape.Read, "read")
+	idx.addRef(id, shape.Update, "update")
+	idx.addRef(id, shape.Delete, "delete")
+	idx.addRef(id, shape.List, "list")
+	for _, k := range sortedIdentifierNames(shape.Identifiers) {
+		idx.addRef(id, shape.Identifiers[k], "identifier")
+	}
+	for _, k := range sortedIdentifierNames(shape.Properties) {
+		idx.addRef(id, shape.Properties[k], "property")
+	}
+	idx.addTraits(id, shape.Traits)
+}
+
+// addTraits adds references to the definitions of the applied traits that are in the model, and notes the shape or
+// member as having each of the traits.
+func (idx *Index) addTraits(id string, traits *data.Object) {
+	for _, tid := range traits.Keys() {
+		idx.traits[tid] = append(idx.traits[tid], id)
+		if idx.ast.GetShape(tid) != nil {
+			idx.add(id, tid, "trait")
+		}
+	}
+}
+
+// Ids returns the ids of every shape in the model, each followed by the ids of its members.
+func (idx *Index) Ids() []string {
+	return idx.ids
+}
+
+// ReferencesFrom returns the references from the shape or member to other shapes and members.
+func (idx *Index) ReferencesFrom(id string) []*Reference {
+	return idx.from[id]
+}
+
+// ReferencesTo returns the references to the shape or member from other shapes and members.
+func (idx *Index) ReferencesTo(id string) []*Reference {
+	return idx.to[id]
+}
+
+// Referrers returns the ids of the shapes that refer to the shape, directly or through one of their members.
+func (idx *Index) Referrers(id string) []string {
+	var result []string
+	seen := map[string]bool{id: true}
+	for _, ref := range idx.to[id] {
+		from := strings.SplitN(ref.From, "$", 2)[0]
+		if !seen[from] {
+			seen[from] = true
+			result = append(result, from)
+		}
+	}
+	return result
+}
+
+// BoundTo returns the ids of the services and resources that the operation or resource is bound to.
+func (idx *Index) BoundTo(id string) []string {
+	return idx.bound[id]
+}
+
+// Closure returns the ids of the shapes in the model reachable from the shape, including itself, through any
+// relationship. Prelude shapes are not included. The result is computed once and remembered.
+func (idx *Index) Closure(id string) []string {
+	if closure, ok := idx.closures[id]; ok {
+		return closure
+	}
+	closure := idx.closure(id, false)
+	idx.closures[id] = closure
+	return closure
+}
+
+// ShapeClosure returns the same ids as the Closure of the model's index, but it looks up only the shapes in the
+// closure, and indexes them as it goes, so that it is cheap for a model loaded with LoadASTLazy.
+func (ast *AST) ShapeClosure(id string) []string {
+	return newIndex(ast).closure(id, true)
+}
+
+// closure returns the ids of the shapes reachable from the shape, in breadth first order. If indexing is true, the
+// references from each shape are added to the index when it is reached, rather than being there already.
+func (idx *Index) closure(id string, indexing bool) []string {
+	closure := make([]string, 0)
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if shape := idx.ast.GetShape(next); shape != nil {
+			closure = append(closure, next)
+			if indexing {
+				idx.indexShape(next, shape)
+			}
+		}
+		for _, ref := range idx.from[next] {
+			if !seen[ref.To] {
+				seen[ref.To] = true
+				queue = append(queue, ref.To)
+			}
+		}
+	}
+	return closure
+}
+
+// ShapesWithTrait returns the ids of the shapes and members that have the trait, in the order of Ids.
+func (idx *Index) ShapesWithTrait(traitId string) []string {
+	return idx.traits[traitId]
+}
+
+// ServicesOf returns the ids of the services whose closure includes the shape.
+func (idx *Index) ServicesOf(id string) []string {
+	return idx.services[id]
+}
+
+// memberIds returns the ids of the members of the shape, including the "member" of a list and the "key" and "value"
+// of a map.
+func memberIds(id string, shape *Shape) []string {
+	var ids []string
+	if shape.Members != nil {
+		for _, k := range shape.Members.Keys() {
+			ids = append(ids, id+"$"+k)
+		}
+	}
+	for i, mem := range []*Member{shape.Member, shape.Key, shape.Value} {
+		if mem != nil {
+			ids = append(ids, id+"$"+[]string{"member", "key", "value"}[i])
+		}
+	}
+	return ids
+}
+
+// ShapesWithTrait returns the ids of the shapes and members of the model that have the trait, i.e.
+// "smithy.api#error", in order. It uses an index of the model that is built on first use, and kept until the model
+// is changed with PutShape, Merge, a filter or a transform.
+func (ast *AST) ShapesWithTrait(traitId string) []string {
+	return ast.indexed().ShapesWithTrait(traitId)
+}
+
+// ResetIndex discards the index used by ShapesWithTrait. It only needs to be called after changing shapes or their
+// traits in place.
+func (ast *AST) ResetIndex() {
+	ast.index = nil
+}
+
+func (ast *AST) indexed() *Index {
+	if ast.index == nil {
+		ast.index = ast.Index()
+	}
+	return ast.index
+}
+
+// UnreferencedShapes returns the ids of the shapes that are not in the closure of any service or resource, in order.
+// Prelude shapes are not included.
+func (ast *AST) UnreferencedShapes() []string {
+	return ast.unreferencedShapes(false)
+}
+
+// unreferencedShapes returns the shapes that are not in the closure of a service or resource, or, if keepTraits is
+// true, of a trait definition.
+func (ast *AST) unreferencedShapes(keepTraits bool) []string {
+	if ast.Shapes == nil {
+		return nil
+	}
+	idx := ast.indexed()
+	used := make(map[string]bool, 0)
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		if shape.Type == "service" || shape.Type == "resource" || (keepTraits && shape.Traits.Has("smithy.api#trait")) {
+			for _, sid := range idx.Closure(id) {
+				used[sid] = true
+			}
+		}
+	}
+	var result []string
+	for _, id := range ast.Shapes.Keys() {
+		if !used[id] && !strings.HasPrefix(id, "smithy.api#") {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+const JsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+type JsonSchemaGenerator struct {
+	BaseGenerator
+}
+
+// Generate JSON Schema documents for the data shapes in the model. By default one file per namespace is produced,
+// with each shape under "$defs". The "bundle" option produces a single "schema.json" with all shapes instead, named by
+// their namespace and name, i.e. "example.weather.City", so that shapes of different namespaces do not collide.
+func (gen *JsonSchemaGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	if config.GetBool("bundle") {
+		fname := "schema.json"
+		doc := gen.ToJsonSchema(ast, "", fname, func(id string) string {
+			return "#/$defs/" + schemaName(id)
+		})
+		return gen.Emit(data.Pretty(doc), fname, "")
+	}
+	for _, ns := range ast.Namespaces() {
+		fname := gen.FileName(ns, ".schema.json")
+		sep := fmt.Sprintf("\n// ===== File(%q)\n\n", fname)
+		doc := gen.ToJsonSchema(ast, ns, fname, func(id string) string {
+			refNs := shapeIdNamespace(id)
+			if refNs == ns {
+				return "#/$defs/" + StripNamespace(id)
+			}
+			return gen.FileName(refNs, ".schema.json") + "#/$defs/" + StripNamespace(id)
+		})
+		err := gen.Emit(data.Pretty(doc), fname, sep)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Produce a JSON Schema document for the shapes in the given namespace, or all namespaces if ns is empty, in which
+// case the shapes are named by schemaName.
+func (gen *JsonSchemaGenerator) ToJsonSchema(ast *AST, ns string, id string, refs schemaRef) *data.Object {
+	defs := data.NewObject()
+	for _, k := range ast.Shapes.Keys() {
+		if ns != "" && shapeIdNamespace(k) != ns {
+			continue
+		}
+		shape := ast.GetShape(k)
+		switch shape.Type {
+		case "service", "resource", "operation":
+			//not data shapes
+		default:
+			name := StripNamespace(k)
+			if ns == "" {
+				name = schemaName(k)
+			}
+			defs.Put(name, openApiShapeSchema(ast, shape, refs))
+		}
+	}
+	doc := data.NewObject()
+	doc.Put("$schema", JsonSchemaDialect)
+	doc.Put("$id", id)
+	doc.Put("$defs", defs)
+	return doc
+}
+
+// schemaName returns the name of the schema of a shape in a document with the shapes of more than one namespace, as a
+// JSON Schema bundle or OpenAPI components: its namespace and name joined with a dot, which is unique, since a shape
+// name cannot contain a dot, and needs no escaping in a JSON pointer.
+func schemaName(id string) string {
+	return strings.Replace(id, "#", ".", 1)
+}
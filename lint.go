@@ -0,0 +1,187 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// LintRule is a check of the style of a model, as opposed to its correctness. The events it reports have the Id of
+// the rule, and its Severity unless the LintConfig says otherwise.
+type LintRule struct {
+	Id          string
+	Description string
+	Severity    Severity
+	Check       func(ast *AST, rule *LintRule) []*ValidationEvent
+}
+
+// Event returns an event reported by the rule about the shape or member with the id.
+func (rule *LintRule) Event(ast *AST, shapeId string, format string, args ...interface{}) *ValidationEvent {
+	return NewValidationEvent(ast, rule.Id, rule.Severity, shapeId, format, args...)
+}
+
+// LintConfig configures the lint rules. Each entry of Rules maps the id of a rule to the severity of the events it
+// reports, or to "off" to disable it. In a smithy-build.json file it is the "lint" property.
+type LintConfig struct {
+	Rules map[string]string `json:"rules,omitempty"`
+}
+
+var builtinLintRules = []*LintRule{
+	{"MissingDocumentation", "Shapes that are not @private, @input or @output should have documentation", SeverityNote, lintMissingDocumentation},
+	{"ShapeName", "Shape names should be PascalCase, except for traits", SeverityWarning, lintShapeName},
+	{"MissingHttp", "Operations should have an @http binding", SeverityWarning, lintMissingHttp},
+	{"UnusedShape", "Shapes should be connected to a service, resource or trait definition", SeverityNote, lintUnusedShape},
+}
+
+var registeredLintRules []*LintRule
+var lintRulesLock sync.Mutex
+
+// RegisterLintRule adds a rule that is run by Lint, in addition to the built in ones.
+func RegisterLintRule(rule *LintRule) {
+	lintRulesLock.Lock()
+	defer lintRulesLock.Unlock()
+	registeredLintRules = append(registeredLintRules, rule)
+}
+
+// LintRules returns the built in lint rules followed by the registered ones.
+func LintRules() []*LintRule {
+	lintRulesLock.Lock()
+	defer lintRulesLock.Unlock()
+	result := append([]*LintRule{}, builtinLintRules...)
+	return append(result, registeredLintRules...)
+}
+
+// Lint runs the lint rules on the model, with the severities given by the config, which may be nil. Only the shapes
+// outside the smithy and aws namespaces are checked. As with validation, events matched by the "suppressions"
+// metadata of the model have their severity changed to SUPPRESSED. An error is returned if the config names a rule
+// that does not exist, or a severity that is not valid.
+func (ast *AST) Lint(config *LintConfig) ([]*ValidationEvent, error) {
+	rules := LintRules()
+	severities := make(map[string]string, 0)
+	if config != nil {
+		for id, sev := range config.Rules {
+			found := false
+			for _, rule := range rules {
+				if rule.Id == id {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("Unknown lint rule: %q", id)
+			}
+			switch Severity(sev) {
+			case SeverityNote, SeverityWarning, SeverityDanger, SeverityError, "off":
+			default:
+				return nil, fmt.Errorf("Bad severity for lint rule %s: %q", id, sev)
+			}
+			severities[id] = sev
+		}
+	}
+	var events []*ValidationEvent
+	for _, rule := range rules {
+		if sev, ok := severities[rule.Id]; ok {
+			if sev == "off" {
+				continue
+			}
+			configured := *rule
+			configured.Severity = Severity(sev)
+			rule = &configured
+		}
+		events = append(events, rule.Check(ast, rule)...)
+	}
+	return ast.applySuppressions(events), nil
+}
+
+// lintedShapeIds returns the ids of the shapes that lint rules check, in order.
+func lintedShapeIds(ast *AST) []string {
+	var ids []string
+	if ast.Shapes != nil {
+		for _, id := range ast.Shapes.Keys() {
+			if !strings.HasPrefix(id, "smithy.") && !strings.HasPrefix(id, "aws.") {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func lintMissingDocumentation(ast *AST, rule *LintRule) []*ValidationEvent {
+	var events []*ValidationEvent
+	for _, id := range lintedShapeIds(ast) {
+		shape := ast.GetShape(id)
+		if shape.Traits.Has("smithy.api#private") || shape.Traits.Has("smithy.api#documentation") {
+			continue
+		}
+		if shape.Traits.Has("smithy.api#input") || shape.Traits.Has("smithy.api#output") {
+			continue
+		}
+		events = append(events, rule.Event(ast, id, "The %s has no documentation", shape.Type))
+	}
+	return events
+}
+
+var pascalCase = regexp.MustCompile("^[A-Z][A-Za-z0-9]*$")
+
+func lintShapeName(ast *AST, rule *LintRule) []*ValidationEvent {
+	var events []*ValidationEvent
+	for _, id := range lintedShapeIds(ast) {
+		if ast.GetShape(id).Traits.Has("smithy.api#trait") {
+			continue
+		}
+		if name := StripNamespace(id); !pascalCase.MatchString(name) {
+			events = append(events, rule.Event(ast, id, "The shape name %q is not PascalCase", name))
+		}
+	}
+	return events
+}
+
+func lintMissingHttp(ast *AST, rule *LintRule) []*ValidationEvent {
+	var events []*ValidationEvent
+	for _, id := range lintedShapeIds(ast) {
+		shape := ast.GetShape(id)
+		if shape.Type == "operation" && !shape.Traits.Has("smithy.api#http") {
+			events = append(events, rule.Event(ast, id, "The operation has no @http trait"))
+		}
+	}
+	return events
+}
+
+// lintUnusedShape reports the shapes that are not in the closure of a service, resource or trait definition. A model
+// with no services is taken to be a library of shapes, and nothing is reported for it.
+func lintUnusedShape(ast *AST, rule *LintRule) []*ValidationEvent {
+	var events []*ValidationEvent
+	linted := make(map[string]bool, 0)
+	hasService := false
+	for _, id := range lintedShapeIds(ast) {
+		linted[id] = true
+		if ast.GetShape(id).Type == "service" {
+			hasService = true
+		}
+	}
+	if !hasService {
+		return events
+	}
+	for _, id := range ast.unreferencedShapes(true) {
+		if linted[id] {
+			events = append(events, rule.Event(ast, id, "The %s is not used by any service, resource or trait", ast.GetShape(id).Type))
+		}
+	}
+	return events
+}
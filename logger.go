@@ -0,0 +1,94 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Logger is where parsers, assemblers and generators report what they are doing, and problems that do not stop them.
+// The arguments after the message are alternating keys and values. The methods are those of *slog.Logger, so one can
+// be used as a Logger. Nothing is logged unless a Logger is given.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// orNopLogger returns the logger, or one that discards everything if it is nil.
+func orNopLogger(l Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return l
+}
+
+// NewWriterLogger returns a Logger that writes a line to w for each message, i.e. "INFO Loaded path=model.smithy",
+// for programs that do not use slog. Debug messages are only written if debug is true.
+func NewWriterLogger(w io.Writer, debug bool) Logger {
+	return &writerLogger{w: w, debug: debug}
+}
+
+type writerLogger struct {
+	w     io.Writer
+	debug bool
+	lock  sync.Mutex
+}
+
+func (l *writerLogger) Debug(msg string, args ...interface{}) {
+	if l.debug {
+		l.log("DEBUG", msg, args)
+	}
+}
+
+func (l *writerLogger) Info(msg string, args ...interface{}) {
+	l.log("INFO", msg, args)
+}
+
+func (l *writerLogger) Warn(msg string, args ...interface{}) {
+	l.log("WARN", msg, args)
+}
+
+func (l *writerLogger) Error(msg string, args ...interface{}) {
+	l.log("ERROR", msg, args)
+}
+
+func (l *writerLogger) log(level string, msg string, args []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(level + " " + msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&sb, " %v", args[i])
+		}
+	}
+	sb.WriteString("\n")
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	io.WriteString(l.w, sb.String())
+}
@@ -17,26 +17,9 @@ package smithy
 
 import (
 	"bytes"
-	"fmt"
 	"strings"
 )
 
-var Verbose bool
-
-func Debug(args ...interface{}) {
-	if Verbose {
-		max := len(args) - 1
-		for i := 0; i < max; i++ {
-			fmt.Print(str(args[i]))
-		}
-		fmt.Println(str(args[max]))
-	}
-}
-
-func str(arg interface{}) string {
-	return fmt.Sprintf("%v", arg)
-}
-
 func Capitalize(s string) string {
 	if s == "" {
 		return s
@@ -61,7 +44,6 @@ func IsSymbolChar(ch rune, first bool) bool {
 	return IsDigit(ch) || ch == '_'
 }
 
-/*
 func IsSymbol(s string) bool {
 	if s == "" {
 		return false
@@ -73,7 +55,6 @@ func IsSymbol(s string) bool {
 	}
 	return true
 }
-*/
 
 func IsWhitespace(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n'
@@ -0,0 +1,198 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// The MockGenerator doesn't generate files, it serves the operations of the model over HTTP, responding with the
+// output of the operation's first @examples entry, or a synthesized output if no example is present.
+// The "addr" option specifies the listen address (default ":8080"). It serves until its Context is cancelled, as it is
+// by GenerateContext, and then returns the error of the context.
+type MockGenerator struct {
+	BaseGenerator
+	routes []*mockRoute
+}
+
+type mockRoute struct {
+	method   string
+	segments []string
+	name     string
+	shape    *Shape
+}
+
+func (gen *MockGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	handler, err := gen.Handler(ast)
+	if err != nil {
+		return err
+	}
+	addr := config.GetString("addr")
+	if addr == "" {
+		addr = ":8080"
+	}
+	server := &http.Server{Addr: addr, Handler: handler}
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-gen.Context.Done():
+			server.Shutdown(context.Background())
+		case <-stopped:
+		}
+	}()
+	gen.Logger.Info("Mock server listening", "addr", addr)
+	err = server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return gen.Context.Err()
+	}
+	return err
+}
+
+// Handler returns an http.Handler that answers requests for the operations of the model
+func (gen *MockGenerator) Handler(ast *AST) (http.Handler, error) {
+	gen.Logger = orNopLogger(gen.Logger)
+	gen.routes = nil
+	for _, k := range ast.Shapes.Keys() {
+		shape := ast.GetShape(k)
+		if shape.Type != "operation" {
+			continue
+		}
+		httpTrait := shape.Traits.GetObject("smithy.api#http")
+		if httpTrait == nil {
+			continue
+		}
+		uri := httpTrait.GetString("uri")
+		if i := strings.Index(uri, "?"); i >= 0 {
+			uri = uri[:i]
+		}
+		gen.routes = append(gen.routes, &mockRoute{
+			method:   strings.ToUpper(httpTrait.GetString("method")),
+			segments: splitPath(uri),
+			name:     k,
+			shape:    shape,
+		})
+	}
+	if len(gen.routes) == 0 {
+		return nil, fmt.Errorf("No operations with the @http trait in the model")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gen.serve(ast, w, r)
+	}), nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func (route *mockRoute) matches(method string, segments []string) bool {
+	if method != route.method {
+		return false
+	}
+	for i, seg := range route.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "+}") {
+			return len(segments) > i
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(seg, "{") && seg != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(route.segments)
+}
+
+func (gen *MockGenerator) serve(ast *AST, w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	for _, route := range gen.routes {
+		if route.matches(r.Method, segments) {
+			gen.Logger.Debug("Mock request", "method", r.Method, "path", r.URL.Path, "operation", route.name)
+			gen.respond(ast, route, w)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, "{\"message\": %q}\n", "No operation matches "+r.Method+" "+r.URL.Path)
+}
+
+func (gen *MockGenerator) respond(ast *AST, route *mockRoute, w http.ResponseWriter) {
+	shape := route.shape
+	status := data.AsInt(ObjectGetPath(shape.Traits, "smithy.api#http.code"))
+	if status == 0 {
+		status = 200
+	}
+	var output *data.Object
+	for _, ex := range shape.Traits.GetArray("smithy.api#examples") {
+		if eo := data.AsObject(ex); eo.Has("output") {
+			output = eo.GetObject("output")
+			break
+		}
+	}
+	if shape.Output == nil {
+		w.WriteHeader(status)
+		return
+	}
+	outShape := ast.GetShape(shape.Output.Target)
+	if outShape == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if output == nil {
+		output = data.AsObject(ast.SynthesizeValue(shape.Output.Target))
+	}
+	var body interface{}
+	bodyMembers := data.NewObject()
+	for _, k := range outShape.Members.Keys() {
+		mem := outShape.Members.Get(k)
+		v := output.Get(k)
+		if mem.Traits.Has("smithy.api#httpPayload") {
+			body = v
+		} else if header := mem.Traits.GetString("smithy.api#httpHeader"); header != "" {
+			if s := data.AsString(v); s != "" {
+				w.Header().Set(header, s)
+			} else if v != nil {
+				w.Header().Set(header, fmt.Sprint(v))
+			}
+		} else if mem.Traits.Has("smithy.api#httpResponseCode") {
+			if n := data.AsInt(v); n != 0 {
+				status = n
+			}
+		} else if v != nil {
+			bodyMembers.Put(k, v)
+		}
+	}
+	if body == nil && bodyMembers.Length() > 0 {
+		body = bodyMembers
+	}
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprint(w, data.Pretty(body))
+}
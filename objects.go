@@ -0,0 +1,426 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// The data.Object type only adds and replaces keys, so these change one in place, keeping the order of the others,
+// as the same methods of Shapes and Members do. They are used for traits, which are data.Objects. Since the order of a
+// data.Object cannot be changed but by rebuilding it, each of these costs O(n) in its keys, unlike Shapes.Delete.
+
+// ObjectDelete removes the key from the object, returning false if it was not there.
+func ObjectDelete(obj *data.Object, key string) bool {
+	if !obj.Has(key) {
+		return false
+	}
+	rebuildObject(obj, removeKey(obj.Keys(), key), nil)
+	return true
+}
+
+// ObjectRename changes a key of the object, keeping its position, returning false if the old key is not there or the
+// new key already is.
+func ObjectRename(obj *data.Object, oldKey string, newKey string) bool {
+	if !obj.Has(oldKey) || obj.Has(newKey) {
+		return false
+	}
+	rebuildObject(obj, renameKey(obj.Keys(), oldKey, newKey), map[string]string{newKey: oldKey})
+	return true
+}
+
+// ObjectInsertBefore puts the value in the object immediately before the mark key, moving it there if the key is
+// already present, and returns the object. If the mark is not a key, the value is put at the end, as Put does. If the
+// object is nil, as the traits of a shape without any are, a new one is returned.
+func ObjectInsertBefore(obj *data.Object, mark string, key string, val interface{}) *data.Object {
+	return insertObjectKey(obj, mark, key, val, false)
+}
+
+// ObjectInsertAfter puts the value in the object immediately after the mark key, as ObjectInsertBefore does.
+func ObjectInsertAfter(obj *data.Object, mark string, key string, val interface{}) *data.Object {
+	return insertObjectKey(obj, mark, key, val, true)
+}
+
+func insertObjectKey(obj *data.Object, mark string, key string, val interface{}, after bool) *data.Object {
+	if obj == nil {
+		obj = data.NewObject()
+	}
+	obj.Put(key, val)
+	rebuildObject(obj, insertKey(obj.Keys(), mark, key, after), nil)
+	return obj
+}
+
+// rebuildObject replaces the content of the object with its values in the order of the keys, those of renamed keys
+// being found under their old keys.
+func rebuildObject(obj *data.Object, keys []string, renamed map[string]string) {
+	result := data.NewObject()
+	for _, k := range keys {
+		from := k
+		if old, ok := renamed[k]; ok {
+			from = old
+		}
+		result.Put(k, obj.Get(from))
+	}
+	*obj = *result
+}
+
+// ObjectMergeStrategy says how ObjectMerge combines a key that is in both objects with different values.
+type ObjectMergeStrategy struct {
+	Deep        bool //values that are objects in both are merged recursively, rather than being a conflict
+	ConcatLists bool //values that are lists in both are concatenated, rather than being a conflict
+	// Conflict is called with the path (i.e. "a.b") of any other key with different values, and returns whether the
+	// existing value is kept, or an error to stop the merge. If it is nil, the new value replaces the existing one.
+	Conflict func(path string) (bool, error)
+}
+
+// ObjectMerge merges the keys of the other object into the object, in place, keeping the order of the existing keys
+// and adding new ones at the end, and returns it. If the object is nil, as the traits of a shape without any are, a new
+// one is returned, unless the other object is empty too. A key in both is resolved by the strategy, which may be nil
+// to replace the values, unless its values are the same. Nested objects that are merged are copied first, so that
+// values shared with the other object, or with another model, are not changed.
+func ObjectMerge(obj *data.Object, other *data.Object, strategy *ObjectMergeStrategy) (*data.Object, error) {
+	if strategy == nil {
+		strategy = &ObjectMergeStrategy{}
+	}
+	if obj == nil {
+		if other.Length() == 0 {
+			return nil, nil
+		}
+		obj = data.NewObject()
+	}
+	return obj, mergeObjects(obj, other, strategy, "")
+}
+
+func mergeObjects(obj *data.Object, other *data.Object, strategy *ObjectMergeStrategy, prefix string) error {
+	for _, k := range other.Keys() {
+		v := other.Get(k)
+		if !obj.Has(k) {
+			obj.Put(k, v)
+			continue
+		}
+		prev := obj.Get(k)
+		l1, ok1 := prev.([]interface{})
+		l2, ok2 := v.([]interface{})
+		if strategy.ConcatLists && ok1 && ok2 {
+			obj.Put(k, append(append([]interface{}{}, l1...), l2...))
+			continue
+		}
+		if nodeEqual(prev, v) {
+			continue
+		}
+		path := prefix + k
+		if strategy.Deep && isObjectNode(prev) && isObjectNode(v) {
+			merged := cloneObject(data.AsObject(prev))
+			err := mergeObjects(merged, data.AsObject(v), strategy, path+".")
+			if err != nil {
+				return err
+			}
+			obj.Put(k, merged)
+			continue
+		}
+		if strategy.Conflict != nil {
+			keep, err := strategy.Conflict(path)
+			if err != nil {
+				return err
+			}
+			if keep {
+				continue
+			}
+		}
+		obj.Put(k, v)
+	}
+	return nil
+}
+
+func isObjectNode(v interface{}) bool {
+	switch v.(type) {
+	case *data.Object, map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+// ObjectGetPath returns the value at the path in the object, or nil if there is none. A path is a sequence of keys
+// separated by dots, and list indexes in brackets, i.e. "a.b[2].c". A key with a "#" in it may be a shape id, whose
+// namespace has dots but whose name does not, so "smithy.api#paginated.inputToken" is the inputToken of the paginated
+// trait (see parsePath). Any key may be quoted in brackets, i.e. `["a.b"]`.
+func ObjectGetPath(obj *data.Object, path string) interface{} {
+	segments, err := parsePath(path, obj)
+	if err != nil {
+		return nil
+	}
+	var node interface{} = obj
+	for _, seg := range segments {
+		node = pathChild(node, seg)
+	}
+	return node
+}
+
+// pathChild returns the value of the key (a string) or list index (an int) in the node, or nil if there is none.
+func pathChild(node interface{}, seg interface{}) interface{} {
+	switch s := seg.(type) {
+	case string:
+		switch n := node.(type) {
+		case *data.Object:
+			if n != nil {
+				return n.Get(s)
+			}
+		case map[string]interface{}:
+			return n[s]
+		}
+	case int:
+		if lst, ok := node.([]interface{}); ok && s < len(lst) {
+			return lst[s]
+		}
+	}
+	return nil
+}
+
+// ObjectPutPath sets the value at the path in the object, as ObjectGetPath finds it, creating the objects on the way
+// that are missing, and returns the object. If it is nil, as the traits of a shape without any are, a new one is
+// returned. A list index may be that of an existing element, or the length of the list to add one to it.
+func ObjectPutPath(obj *data.Object, path string, val interface{}) (*data.Object, error) {
+	segments, err := parsePath(path, obj)
+	if err != nil {
+		return obj, err
+	}
+	if _, ok := segments[0].(string); !ok {
+		return obj, fmt.Errorf("Path does not start with a key: %q", path)
+	}
+	var node interface{}
+	if obj != nil {
+		node = obj
+	}
+	result, err := putPath(node, segments, val, path)
+	if err != nil {
+		return obj, err
+	}
+	return result.(*data.Object), nil
+}
+
+// putPath returns the node with the value put at the path of the segments, which may be a new node.
+func putPath(node interface{}, segments []interface{}, val interface{}, path string) (interface{}, error) {
+	if len(segments) == 0 {
+		return val, nil
+	}
+	switch s := segments[0].(type) {
+	case string:
+		switch n := node.(type) {
+		case nil:
+			obj := data.NewObject()
+			child, err := putPath(nil, segments[1:], val, path)
+			obj.Put(s, child)
+			return obj, err
+		case *data.Object:
+			child, err := putPath(n.Get(s), segments[1:], val, path)
+			if err == nil {
+				n.Put(s, child)
+			}
+			return n, err
+		case map[string]interface{}:
+			child, err := putPath(n[s], segments[1:], val, path)
+			if err == nil {
+				n[s] = child
+			}
+			return n, err
+		}
+		return nil, fmt.Errorf("Not an object at %q in path %q", s, path)
+	default:
+		lst, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, fmt.Errorf("Not a list at [%d] in path %q", s, path)
+		}
+		i := s.(int)
+		if i > len(lst) {
+			return nil, fmt.Errorf("List index out of range at [%d] in path %q", i, path)
+		}
+		var prev interface{}
+		if i < len(lst) {
+			prev = lst[i]
+		}
+		child, err := putPath(prev, segments[1:], val, path)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(lst) {
+			return append(lst, child), nil
+		}
+		lst[i] = child
+		return lst, nil
+	}
+}
+
+// parsePath returns the keys (strings) and list indexes (ints) of the path, which is that of a value in the root node.
+// A key with a "#" before the next dot is a shape id if the node it is in has that key, or else if the key before the
+// next dot is not there either, and the shape id is the first key of the path, or its namespace has no dots. So
+// "a.ns#T" is the key "ns#T" in "a" if the root has "a", but not "a.ns#T".
+func parsePath(path string, root interface{}) ([]interface{}, error) {
+	var segments []interface{}
+	node := root
+	i := 0
+	for i < len(path) {
+		var seg interface{}
+		switch {
+		case path[i] == '[':
+			end := strings.Index(path[i:], "]")
+			if end < 0 {
+				return nil, fmt.Errorf("Unclosed bracket in path: %q", path)
+			}
+			inner := path[i+1 : i+end]
+			if strings.HasPrefix(inner, "\"") {
+				if end = strings.Index(path[i:], "\"]"); end < 1 {
+					return nil, fmt.Errorf("Unclosed quote in path: %q", path)
+				}
+				seg = path[i+2 : i+end]
+				end++
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("Bad list index in path: %q", path)
+				}
+				seg = n
+			}
+			i += end + 1
+		case path[i] == '.' && len(segments) > 0 && i+1 < len(path) && path[i+1] != '.' && path[i+1] != '[':
+			i++
+			continue
+		case path[i] == '.':
+			return nil, fmt.Errorf("Bad path: %q", path)
+		default:
+			end := pathKeyEnd(path, i, len(segments) == 0, node)
+			seg = path[i:end]
+			i = end
+		}
+		segments = append(segments, seg)
+		node = pathChild(node, seg)
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("Empty path")
+	}
+	return segments, nil
+}
+
+// pathKeyEnd returns the end of the unquoted key at i in the path, in the node, as parsePath describes.
+func pathKeyEnd(path string, i int, first bool, node interface{}) int {
+	plain := indexAnyFrom(path, i, ".[")
+	hash := strings.Index(path[i:], "#")
+	if hash < 0 || strings.Contains(path[i:i+hash], "[") {
+		return plain
+	}
+	id := indexAnyFrom(path, i+hash, ".[")
+	switch {
+	case id == plain, pathChild(node, path[i:id]) != nil:
+		return id
+	case pathChild(node, path[i:plain]) != nil:
+		return plain
+	case first:
+		return id
+	}
+	return plain
+}
+
+// indexAnyFrom returns the index of the first of the chars in s at or after i, or the length of s if there is none.
+func indexAnyFrom(s string, i int, chars string) int {
+	if n := strings.IndexAny(s[i:], chars); n >= 0 {
+		return i + n
+	}
+	return len(s)
+}
+
+// ObjectDecode decodes the object into the value that out points to, i.e. a struct, as encoding/json would decode the
+// object's JSON, so that the json tags of the struct fields name their keys. Trait values can be decoded this way into
+// types like HttpTrait.
+func ObjectDecode(obj *data.Object, out interface{}) error {
+	return DecodeNode(obj, out)
+}
+
+// DecodeNode decodes a node value, i.e. a trait value that may be a *data.Object or a map, as ObjectDecode does.
+func DecodeNode(node interface{}, out interface{}) error {
+	raw, err := json.Marshal(node)
+	if err == nil {
+		err = json.Unmarshal(raw, out)
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot decode value: %v", err)
+	}
+	return nil
+}
+
+// DecodeObjectJSON decodes a JSON object as the IDL parser would produce it, unlike the UnmarshalJSON of data.Object:
+// numbers are *data.Decimal, so that large longs and the digits of decimals are exact, and nested objects are
+// *data.Object, so that the order of their keys is kept.
+func DecodeObjectJSON(raw []byte) (*data.Object, error) {
+	v, err := decodeJSONNode(raw)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(*data.Object)
+	if !ok {
+		return nil, fmt.Errorf("Not a JSON object")
+	}
+	return obj, nil
+}
+
+func decodeJSONNode(raw []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return decodeJSONValue(dec)
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			lst := make([]interface{}, 0)
+			for dec.More() {
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				lst = append(lst, v)
+			}
+			_, err = dec.Token()
+			return lst, err
+		}
+		obj := data.NewObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.Put(key.(string), v)
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Number:
+		return data.ParseDecimal(string(t))
+	}
+	return tok, nil
+}
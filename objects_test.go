@@ -0,0 +1,132 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/boynton/data"
+)
+
+const pathTestJSON = `{
+  "a": {"b": [{"c": 1}, {"c": 2}]},
+  "a.b": "dotted",
+  "smithy.api#paginated": {"inputToken": "next"},
+  "x": {"ns#T": "in x", "my.ns#T": "dotted in x"}
+}`
+
+// paths in pathTestJSON, the keys and list indexes they parse to, and the values they find
+var pathTests = []struct {
+	path     string
+	segments []interface{}
+	value    interface{}
+}{
+	{"a.b[1].c", []interface{}{"a", "b", 1, "c"}, "2"},
+	{"a.b[2]", []interface{}{"a", "b", 2}, nil},
+	{`["a.b"]`, []interface{}{"a.b"}, "dotted"},
+	{"smithy.api#paginated.inputToken", []interface{}{"smithy.api#paginated", "inputToken"}, "next"},
+	{"x.ns#T", []interface{}{"x", "ns#T"}, "in x"},
+	{"x.my.ns#T", []interface{}{"x", "my.ns#T"}, "dotted in x"},
+	{"missing.b", []interface{}{"missing", "b"}, nil},
+	{"a.b.c", []interface{}{"a", "b", "c"}, nil},
+}
+
+// paths that do not parse
+var badPaths = []string{"", ".a", "a.", "a..b", "a[", "a[x]", "a[-1]", `a["b]`}
+
+func TestObjectPath(t *testing.T) {
+	obj, err := DecodeObjectJSON([]byte(pathTestJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range pathTests {
+		t.Run(tc.path, func(t *testing.T) {
+			segments, err := parsePath(tc.path, obj)
+			if err != nil {
+				t.Fatalf("Cannot parse path: %v", err)
+			}
+			if !reflect.DeepEqual(segments, tc.segments) {
+				t.Errorf("Expected segments %v, got %v", tc.segments, segments)
+			}
+			val := ObjectGetPath(obj, tc.path)
+			if tc.value == nil {
+				if val != nil {
+					t.Errorf("Expected no value, got %v", val)
+				}
+			} else if fmt.Sprint(val) != tc.value {
+				t.Errorf("Expected %v, got %v", tc.value, val)
+			}
+		})
+	}
+	for _, path := range badPaths {
+		if _, err := parsePath(path, obj); err == nil {
+			t.Errorf("Expected an error for path %q", path)
+		}
+		if val := ObjectGetPath(obj, path); val != nil {
+			t.Errorf("Expected no value for path %q, got %v", path, val)
+		}
+	}
+}
+
+// puts to paths of pathTestJSON, and the JSON of the object after each, or the error it should produce
+var putPathTests = []struct {
+	path     string
+	val      interface{}
+	expected string
+	error    string
+}{
+	{"a.b[0].c", 3, `{"a":{"b":[{"c":3},{"c":2}]}}`, ""},
+	{"a.b[2]", "new", `{"a":{"b":[{"c":1},{"c":2},"new"]}}`, ""},
+	{"a.d.e", true, `{"a":{"b":[{"c":1},{"c":2}],"d":{"e":true}}}`, ""},
+	{`["a.b"]`, "x", `{"a":{"b":[{"c":1},{"c":2}]},"a.b":"x"}`, ""},
+	{"smithy.api#paginated.pageSize", "size", `{"a":{"b":[{"c":1},{"c":2}]},"smithy.api#paginated":{"pageSize":"size"}}`, ""},
+	{"a.b[3]", 1, "", "List index out of range"},
+	{"a.b.c", 1, "", "Not an object"},
+	{"a[0]", 1, "", "Not a list"},
+	{"[0]", 1, "", "Path does not start with a key"},
+	{"a..b", 1, "", "Bad path"},
+}
+
+func TestObjectPutPath(t *testing.T) {
+	for _, tc := range putPathTests {
+		t.Run(tc.path, func(t *testing.T) {
+			obj, err := DecodeObjectJSON([]byte(`{"a": {"b": [{"c": 1}, {"c": 2}]}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := ObjectPutPath(obj, tc.path, tc.val)
+			if tc.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.error) {
+					t.Errorf("Expected an error containing %q, got %v", tc.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Cannot put: %v", err)
+			}
+			if s := data.Json(result); s != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, s)
+			}
+		})
+	}
+	obj, err := ObjectPutPath(nil, "smithy.api#length.max", 10)
+	if err != nil || data.Json(obj) != `{"smithy.api#length":{"max":10}}` {
+		t.Errorf("Cannot put a value in a nil object: %v %s", err, data.Json(obj))
+	}
+}
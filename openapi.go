@@ -0,0 +1,484 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+const OpenApiVersion = "3.0.3"
+
+type OpenApiGenerator struct {
+	BaseGenerator
+}
+
+func (gen *OpenApiGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	doc, err := gen.ToOpenApi(ast)
+	if err != nil {
+		return err
+	}
+	return gen.Emit(data.Pretty(doc), "openapi.json", "")
+}
+
+// Produce an OpenAPI 3.0 document for the model. Only operations with the @http trait are represented as paths,
+// all other non-service shapes become component schemas.
+func (gen *OpenApiGenerator) ToOpenApi(ast *AST) (*data.Object, error) {
+	_, serviceName, serviceVersion := ast.NamespaceAndServiceVersion()
+	title := gen.Config.GetString("title")
+	if title == "" {
+		title = serviceName
+	}
+	if title == "" {
+		title = "Untitled"
+	}
+	if serviceVersion == "" {
+		serviceVersion = UnspecifiedVersion
+	}
+	info := data.NewObject()
+	info.Put("title", title)
+	info.Put("version", serviceVersion)
+	for _, k := range ast.Shapes.Keys() {
+		shape := ast.GetShape(k)
+		if shape.Type == "service" {
+			if doc := shape.Traits.GetString("smithy.api#documentation"); doc != "" {
+				info.Put("description", doc)
+			}
+			break
+		}
+	}
+	doc := data.NewObject()
+	doc.Put("openapi", OpenApiVersion)
+	doc.Put("info", info)
+	paths := data.NewObject()
+	schemas := data.NewObject()
+	for _, k := range ast.Shapes.Keys() {
+		shape := ast.GetShape(k)
+		switch shape.Type {
+		case "operation":
+			err := gen.addOperation(ast, paths, k, shape)
+			if err != nil {
+				return nil, err
+			}
+		case "service", "resource":
+			//no schema equivalent
+		default:
+			schemas.Put(schemaName(k), openApiShapeSchema(ast, shape, openApiSchemaRef))
+		}
+	}
+	doc.Put("paths", paths)
+	if schemas.Length() > 0 {
+		components := data.NewObject()
+		components.Put("schemas", schemas)
+		doc.Put("components", components)
+	}
+	return doc, nil
+}
+
+func (gen *OpenApiGenerator) addOperation(ast *AST, paths *data.Object, id string, shape *Shape) error {
+	httpTrait := shape.Traits.GetObject("smithy.api#http")
+	if httpTrait == nil {
+		return nil
+	}
+	method := strings.ToLower(httpTrait.GetString("method"))
+	uri := httpTrait.GetString("uri")
+	if method == "" || uri == "" {
+		return fmt.Errorf("Operation has an incomplete @http trait: %s", id)
+	}
+	if i := strings.Index(uri, "?"); i >= 0 {
+		uri = uri[:i] //literal query params are not representable in OpenAPI paths
+	}
+	uri = strings.ReplaceAll(uri, "+}", "}") //a greedy label is an ordinary path parameter in OpenAPI
+	op := data.NewObject()
+	op.Put("operationId", Uncapitalize(StripNamespace(id)))
+	if doc := shape.Traits.GetString("smithy.api#documentation"); doc != "" {
+		op.Put("description", doc)
+	}
+	if tags := shape.Traits.GetStringArray("smithy.api#tags"); tags != nil {
+		op.Put("tags", tags)
+	}
+	if shape.Traits.Has("smithy.api#deprecated") {
+		op.Put("deprecated", true)
+	}
+	if shape.Input != nil {
+		inShape := ast.GetShape(shape.Input.Target)
+		if inShape == nil {
+			return fmt.Errorf("Undefined shape: %s", shape.Input.Target)
+		}
+		var params []interface{}
+		body := data.NewObject()
+		var bodyRequired []string
+		var payload *Member
+		for _, k := range inShape.Members.Keys() {
+			mem := inShape.Members.Get(k)
+			if mem.Traits.Has("smithy.api#httpPayload") {
+				payload = mem
+			} else if mem.Traits.Has("smithy.api#httpLabel") {
+				params = append(params, openApiParameter(ast, k, "path", mem, true))
+			} else if name := mem.Traits.GetString("smithy.api#httpQuery"); name != "" {
+				params = append(params, openApiParameter(ast, name, "query", mem, mem.Traits.Has("smithy.api#required")))
+			} else if name := mem.Traits.GetString("smithy.api#httpHeader"); name != "" {
+				params = append(params, openApiParameter(ast, name, "header", mem, mem.Traits.Has("smithy.api#required")))
+			} else if mem.Traits.Has("smithy.api#httpQueryParams") || mem.Traits.Has("smithy.api#httpPrefixHeaders") {
+				//not representable as individual parameters
+			} else {
+				body.Put(k, openApiMemberSchema(ast, mem, openApiSchemaRef))
+				if mem.Traits.Has("smithy.api#required") {
+					bodyRequired = append(bodyRequired, k)
+				}
+			}
+		}
+		if len(params) > 0 {
+			op.Put("parameters", params)
+		}
+		if payload != nil {
+			op.Put("requestBody", openApiBody(openApiMemberSchema(ast, payload, openApiSchemaRef), payload.Traits.Has("smithy.api#required")))
+		} else if body.Length() > 0 {
+			op.Put("requestBody", openApiBody(openApiObjectSchema(body, bodyRequired), len(bodyRequired) > 0))
+		}
+	}
+	responses := data.NewObject()
+	code := httpTrait.GetInt("code")
+	if code == 0 {
+		code = 200
+	}
+	resp := data.NewObject()
+	resp.Put("description", "Successful response")
+	if shape.Output != nil {
+		outShape := ast.GetShape(shape.Output.Target)
+		if outShape == nil {
+			return fmt.Errorf("Undefined shape: %s", shape.Output.Target)
+		}
+		headers := data.NewObject()
+		body := data.NewObject()
+		var bodyRequired []string
+		var payload *Member
+		for _, k := range outShape.Members.Keys() {
+			mem := outShape.Members.Get(k)
+			if mem.Traits.Has("smithy.api#httpPayload") {
+				payload = mem
+			} else if name := mem.Traits.GetString("smithy.api#httpHeader"); name != "" {
+				header := data.NewObject()
+				header.Put("schema", openApiMemberSchema(ast, mem, openApiSchemaRef))
+				headers.Put(name, header)
+			} else if mem.Traits.Has("smithy.api#httpResponseCode") || mem.Traits.Has("smithy.api#httpPrefixHeaders") {
+				//not part of the body
+			} else {
+				body.Put(k, openApiMemberSchema(ast, mem, openApiSchemaRef))
+				if mem.Traits.Has("smithy.api#required") {
+					bodyRequired = append(bodyRequired, k)
+				}
+			}
+		}
+		if headers.Length() > 0 {
+			resp.Put("headers", headers)
+		}
+		if payload != nil {
+			resp.Put("content", openApiContent(openApiMemberSchema(ast, payload, openApiSchemaRef)))
+		} else if body.Length() > 0 {
+			resp.Put("content", openApiContent(openApiObjectSchema(body, bodyRequired)))
+		}
+	}
+	responses.Put(fmt.Sprint(code), resp)
+	//errors with the same status are alternatives of a single response
+	var statuses []string
+	errSchemas := make(map[string][]*data.Object)
+	errNames := make(map[string][]string)
+	errDescs := make(map[string]string)
+	for _, e := range shape.Errors {
+		errShape := ast.GetShape(e.Target)
+		if errShape == nil {
+			return fmt.Errorf("Undefined shape: %s", e.Target)
+		}
+		status := errShape.Traits.GetInt("smithy.api#httpError")
+		if status == 0 {
+			if errShape.Traits.GetString("smithy.api#error") == "client" {
+				status = 400
+			} else {
+				status = 500
+			}
+		}
+		key := fmt.Sprint(status)
+		if _, ok := errSchemas[key]; !ok {
+			statuses = append(statuses, key)
+			errDescs[key] = errShape.Traits.GetString("smithy.api#documentation")
+		}
+		errSchemas[key] = append(errSchemas[key], openApiRef(e.Target, openApiSchemaRef))
+		errNames[key] = append(errNames[key], StripNamespace(e.Target))
+	}
+	for _, key := range statuses {
+		schema := errSchemas[key][0]
+		desc := errDescs[key]
+		if len(errSchemas[key]) > 1 {
+			schema = data.NewObject()
+			schema.Put("oneOf", errSchemas[key])
+			desc = strings.Join(errNames[key], " or ")
+		} else if desc == "" {
+			desc = errNames[key][0]
+		}
+		eresp := data.NewObject()
+		eresp.Put("description", desc)
+		eresp.Put("content", openApiContent(schema))
+		responses.Put(key, eresp)
+	}
+	op.Put("responses", responses)
+	pathItem := paths.GetObject(uri)
+	if pathItem == nil {
+		pathItem = data.NewObject()
+		paths.Put(uri, pathItem)
+	}
+	pathItem.Put(method, op)
+	return nil
+}
+
+// a schemaRef function produces the JSON reference URI for a shape ID
+type schemaRef func(id string) string
+
+func openApiSchemaRef(id string) string {
+	return "#/components/schemas/" + schemaName(id)
+}
+
+func openApiParameter(ast *AST, name, in string, mem *Member, required bool) *data.Object {
+	param := data.NewObject()
+	param.Put("name", name)
+	param.Put("in", in)
+	if doc := mem.Traits.GetString("smithy.api#documentation"); doc != "" {
+		param.Put("description", doc)
+	}
+	if required {
+		param.Put("required", true)
+	}
+	param.Put("schema", openApiMemberSchema(ast, mem, openApiSchemaRef))
+	return param
+}
+
+func openApiContent(schema *data.Object) *data.Object {
+	media := data.NewObject()
+	media.Put("schema", schema)
+	content := data.NewObject()
+	content.Put("application/json", media)
+	return content
+}
+
+func openApiBody(schema *data.Object, required bool) *data.Object {
+	body := data.NewObject()
+	body.Put("content", openApiContent(schema))
+	if required {
+		body.Put("required", true)
+	}
+	return body
+}
+
+func openApiObjectSchema(properties *data.Object, required []string) *data.Object {
+	schema := data.NewObject()
+	schema.Put("type", "object")
+	schema.Put("properties", properties)
+	if len(required) > 0 {
+		schema.Put("required", required)
+	}
+	return schema
+}
+
+func openApiRef(target string, refs schemaRef) *data.Object {
+	if schema := openApiPreludeSchema(target); schema != nil {
+		return schema
+	}
+	ref := data.NewObject()
+	ref.Put("$ref", refs(target))
+	return ref
+}
+
+func openApiPreludeSchema(target string) *data.Object {
+	schema := data.NewObject()
+	switch target {
+	case "smithy.api#String":
+		schema.Put("type", "string")
+	case "smithy.api#Boolean", "smithy.api#PrimitiveBoolean":
+		schema.Put("type", "boolean")
+	case "smithy.api#Byte", "smithy.api#Short", "smithy.api#PrimitiveByte", "smithy.api#PrimitiveShort":
+		schema.Put("type", "integer")
+	case "smithy.api#Integer", "smithy.api#PrimitiveInteger":
+		schema.Put("type", "integer")
+		schema.Put("format", "int32")
+	case "smithy.api#Long", "smithy.api#PrimitiveLong":
+		schema.Put("type", "integer")
+		schema.Put("format", "int64")
+	case "smithy.api#BigInteger":
+		schema.Put("type", "integer")
+	case "smithy.api#Float", "smithy.api#PrimitiveFloat":
+		schema.Put("type", "number")
+		schema.Put("format", "float")
+	case "smithy.api#Double", "smithy.api#PrimitiveDouble":
+		schema.Put("type", "number")
+		schema.Put("format", "double")
+	case "smithy.api#BigDecimal":
+		schema.Put("type", "number")
+	case "smithy.api#Timestamp":
+		schema.Put("type", "string")
+		schema.Put("format", "date-time")
+	case "smithy.api#Blob":
+		schema.Put("type", "string")
+		schema.Put("format", "byte")
+	case "smithy.api#Document":
+		//any value
+	default:
+		return nil
+	}
+	return schema
+}
+
+// the schema for a member is a reference to its target, with the member's documentation and any constraint traits
+// on the member applied when the target is a simple prelude type.
+func openApiMemberSchema(ast *AST, mem *Member, refs schemaRef) *data.Object {
+	schema := openApiRef(mem.Target, refs)
+	if !schema.Has("$ref") {
+		openApiConstraints(schema, mem.Traits)
+		if doc := mem.Traits.GetString("smithy.api#documentation"); doc != "" {
+			schema.Put("description", doc)
+		}
+	}
+	return schema
+}
+
+func openApiConstraints(schema *data.Object, traits *data.Object) {
+	if traits == nil {
+		return
+	}
+	if pat := traits.GetString("smithy.api#pattern"); pat != "" {
+		schema.Put("pattern", pat)
+	}
+	if l := traits.GetObject("smithy.api#length"); l != nil {
+		minKey, maxKey := "minLength", "maxLength"
+		switch schema.GetString("type") {
+		case "array":
+			minKey, maxKey = "minItems", "maxItems"
+		case "object":
+			minKey, maxKey = "minProperties", "maxProperties"
+		}
+		if l.Has("min") {
+			schema.Put(minKey, l.GetInt("min"))
+		}
+		if l.Has("max") {
+			schema.Put(maxKey, l.GetInt("max"))
+		}
+	}
+	if r := traits.GetObject("smithy.api#range"); r != nil {
+		if r.Has("min") {
+			schema.Put("minimum", r.Get("min"))
+		}
+		if r.Has("max") {
+			schema.Put("maximum", r.Get("max"))
+		}
+	}
+}
+
+func openApiShapeSchema(ast *AST, shape *Shape, refs schemaRef) *data.Object {
+	schema := data.NewObject()
+	switch shape.Type {
+	case "string":
+		schema.Put("type", "string")
+		if items := shape.Traits.GetArray("smithy.api#enum"); items != nil {
+			var values []string
+			for _, item := range items {
+				values = append(values, data.AsObject(item).GetString("value"))
+			}
+			schema.Put("enum", values)
+		}
+	case "enum":
+		schema.Put("type", "string")
+		var values []string
+		for _, k := range shape.Members.Keys() {
+			v := shape.Members.Get(k).Traits.GetString("smithy.api#enumValue")
+			if v == "" {
+				v = k
+			}
+			values = append(values, v)
+		}
+		schema.Put("enum", values)
+	case "intEnum":
+		schema.Put("type", "integer")
+		var values []int
+		for _, k := range shape.Members.Keys() {
+			values = append(values, shape.Members.Get(k).Traits.GetInt("smithy.api#enumValue"))
+		}
+		schema.Put("enum", values)
+	case "boolean":
+		schema.Put("type", "boolean")
+	case "byte", "short", "integer", "long", "bigInteger":
+		schema.Put("type", "integer")
+		switch shape.Type {
+		case "integer":
+			schema.Put("format", "int32")
+		case "long":
+			schema.Put("format", "int64")
+		}
+	case "float", "double", "bigDecimal":
+		schema.Put("type", "number")
+		switch shape.Type {
+		case "float", "double":
+			schema.Put("format", shape.Type)
+		}
+	case "timestamp":
+		schema.Put("type", "string")
+		schema.Put("format", "date-time")
+	case "blob":
+		schema.Put("type", "string")
+		schema.Put("format", "byte")
+	case "document":
+		//any value
+	case "list", "set":
+		schema.Put("type", "array")
+		schema.Put("items", openApiMemberSchema(ast, shape.Member, refs))
+		if shape.Type == "set" || shape.Traits.Has("smithy.api#uniqueItems") {
+			schema.Put("uniqueItems", true)
+		}
+	case "map":
+		schema.Put("type", "object")
+		schema.Put("additionalProperties", openApiMemberSchema(ast, shape.Value, refs))
+	case "structure", "union":
+		schema.Put("type", "object")
+		props := data.NewObject()
+		var required []string
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			props.Put(k, openApiMemberSchema(ast, mem, refs))
+			if mem.Traits.Has("smithy.api#required") {
+				required = append(required, k)
+			}
+		}
+		schema.Put("properties", props)
+		if shape.Type == "union" {
+			schema.Put("minProperties", 1)
+			schema.Put("maxProperties", 1)
+		} else if len(required) > 0 {
+			schema.Put("required", required)
+		}
+	}
+	openApiConstraints(schema, shape.Traits)
+	if doc := shape.Traits.GetString("smithy.api#documentation"); doc != "" {
+		schema.Put("description", doc)
+	}
+	if shape.Traits.Has("smithy.api#deprecated") {
+		schema.Put("deprecated", true)
+	}
+	return schema
+}
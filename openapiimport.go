@@ -0,0 +1,557 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+var openApiMethods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+// LoadOpenAPI reads an OpenAPI 3 document, in either YAML or JSON, and converts it to a Smithy AST. The paths become
+// operations of a single service, and the component schemas become shapes. The namespace is derived from the title.
+func LoadOpenAPI(path string) (*AST, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read OpenAPI file: %v", err)
+	}
+	return loadOpenAPIBytes(path, b)
+}
+
+func loadOpenAPIBytes(path string, b []byte) (*AST, error) {
+	doc, err := openApiDocument(path, b)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Has("swagger") {
+		return nil, fmt.Errorf("Swagger 2.0 is not supported, only OpenAPI 3: %s", path)
+	}
+	if !doc.Has("openapi") {
+		return nil, fmt.Errorf("Not an OpenAPI document: %s", path)
+	}
+	return ImportOpenApi(doc)
+}
+
+// IsOpenAPI returns true if the file is a YAML or JSON file with a top level "openapi" or "swagger" field.
+func IsOpenAPI(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		b, err := ioutil.ReadFile(path)
+		return err == nil && isOpenAPIDocument(b)
+	}
+	return false
+}
+
+// isOpenAPIDocument returns true if the YAML or JSON content has a top level "openapi" or "swagger" field.
+func isOpenAPIDocument(b []byte) bool {
+	return hasTopLevelKey(b, "openapi", "swagger")
+}
+
+func openApiDocument(path string, b []byte) (*data.Object, error) {
+	raw, err := decodeYaml(b)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse OpenAPI file: %v", err)
+	}
+	doc, ok := raw.(*data.Object)
+	if !ok {
+		return nil, fmt.Errorf("Not an OpenAPI document: %s", path)
+	}
+	return doc, nil
+}
+
+type openApiImporter struct {
+	ast     *AST
+	ns      string
+	doc     *data.Object
+	service *Shape
+}
+
+// ImportOpenApi converts an OpenAPI 3 document to a Smithy AST.
+func ImportOpenApi(doc *data.Object) (*AST, error) {
+	info := doc.GetObject("info")
+	title := info.GetString("title")
+	imp := &openApiImporter{
+		ast: &AST{Smithy: "2"},
+		ns:  openApiNamespace(title),
+		doc: doc,
+	}
+	serviceName := openApiSymbol(title, true)
+	if serviceName == "" {
+		serviceName = "Service"
+	}
+	version := info.GetString("version")
+	if version == "" {
+		version = UnspecifiedVersion
+	}
+	imp.service = &Shape{
+		Type:    "service",
+		Version: version,
+		Traits:  withTrait(nil, "smithy.api#documentation", nonEmpty(info.GetString("description"))),
+	}
+	imp.ast.PutShape(imp.ns+"#"+serviceName, imp.service)
+	schemas := doc.GetObject("components").GetObject("schemas")
+	for _, name := range schemas.Keys() {
+		imp.defineShape(openApiSymbol(name, true), schemas.GetObject(name))
+	}
+	paths := doc.GetObject("paths")
+	for _, path := range paths.Keys() {
+		item := imp.resolve(paths.GetObject(path))
+		for _, method := range item.Keys() {
+			if !containsString(openApiMethods, method) {
+				continue
+			}
+			err := imp.importOperation(path, method, item, imp.resolve(item.GetObject(method)))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return imp.ast, nil
+}
+
+func nonEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func openApiNamespace(title string) string {
+	var parts []string
+	for _, word := range openApiWords(title) {
+		parts = append(parts, strings.ToLower(word))
+	}
+	ns := strings.Join(parts, "")
+	if ns == "" || !IsLetter(rune(ns[0])) {
+		return UnspecifiedNamespace
+	}
+	return ns
+}
+
+func openApiWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !(IsLetter(r) || IsDigit(r))
+	})
+}
+
+// openApiSymbol converts an arbitrary name to a valid Smithy identifier, in camel case.
+func openApiSymbol(s string, capitalized bool) string {
+	var b strings.Builder
+	for i, word := range openApiWords(s) {
+		if i > 0 || capitalized {
+			word = Capitalize(word)
+		} else {
+			word = Uncapitalize(word)
+		}
+		b.WriteString(word)
+	}
+	sym := b.String()
+	if sym != "" && IsDigit(rune(sym[0])) {
+		sym = "_" + sym
+	}
+	return sym
+}
+
+// enumMemberName converts an enum value to a valid enum member name, i.e. "sold-out" becomes "SOLD_OUT". Values
+// that are already valid identifiers are kept as they are.
+func enumMemberName(s string) string {
+	if IsSymbol(s) {
+		return s
+	}
+	name := strings.ToUpper(strings.Join(openApiWords(s), "_"))
+	if name != "" && IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
+
+// resolve follows a local "$ref" to the object it refers to, i.e. "#/components/parameters/Limit".
+func (imp *openApiImporter) resolve(obj *data.Object) *data.Object {
+	ref := obj.GetString("$ref")
+	if !strings.HasPrefix(ref, "#/") {
+		return obj
+	}
+	target := imp.doc
+	for _, name := range strings.Split(ref[2:], "/") {
+		target = target.GetObject(name)
+	}
+	return target
+}
+
+func (imp *openApiImporter) refTarget(ref string) string {
+	return imp.ns + "#" + openApiSymbol(ref[strings.LastIndex(ref, "/")+1:], true)
+}
+
+func (imp *openApiImporter) defineShape(name string, schema *data.Object) string {
+	id := imp.ns + "#" + name
+	shape := &Shape{}
+	imp.ast.PutShape(id, shape)
+	imp.initShape(name, shape, schema)
+	return id
+}
+
+func (imp *openApiImporter) initShape(name string, shape *Shape, schema *data.Object) {
+	shape.Traits = withTrait(nil, "smithy.api#documentation", nonEmpty(schema.GetString("description")))
+	if schema.GetBool("deprecated") {
+		shape.Traits = withTrait(shape.Traits, "smithy.api#deprecated", data.NewObject())
+	}
+	switch openApiSchemaType(schema) {
+	case "object":
+		if ap := schema.GetObject("additionalProperties"); ap != nil && !schema.Has("properties") {
+			shape.Type = "map"
+			shape.Key = &Member{Target: "smithy.api#String"}
+			shape.Value = &Member{Target: imp.memberTarget(name+"Value", ap)}
+			return
+		}
+		shape.Type = "structure"
+		shape.Members = NewMembers()
+		imp.addProperties(name, shape, schema)
+	case "array":
+		shape.Type = "list"
+		shape.Member = &Member{Target: imp.memberTarget(name+"Item", schema.GetObject("items"))}
+		shape.Traits = imp.withConstraints(shape.Traits, schema)
+	case "union":
+		shape.Type = "union"
+		shape.Members = NewMembers()
+		for _, v := range openApiAlternatives(schema) {
+			alt := data.AsObject(v)
+			if ref := alt.GetString("$ref"); ref != "" {
+				target := imp.refTarget(ref)
+				shape.Members.Put(Uncapitalize(StripNamespace(target)), &Member{Target: target})
+			}
+		}
+	case "string":
+		if enum := schema.GetArray("enum"); enum != nil {
+			shape.Type = "enum"
+			shape.Members = NewMembers()
+			for _, v := range enum {
+				s := fmt.Sprint(data.AsString(v))
+				mname := enumMemberName(s)
+				if mname == "" || shape.Members.Get(mname) != nil {
+					continue
+				}
+				var mtraits *data.Object
+				if mname != s {
+					mtraits = withTrait(mtraits, "smithy.api#enumValue", s)
+				}
+				shape.Members.Put(mname, &Member{Target: "smithy.api#Unit", Traits: mtraits})
+			}
+			return
+		}
+		shape.Type = Uncapitalize(openApiPreludeType("string", schema.GetString("format")))
+		shape.Traits = imp.withConstraints(shape.Traits, schema)
+	case "integer", "number":
+		shape.Type = Uncapitalize(openApiPreludeType(openApiSchemaType(schema), schema.GetString("format")))
+		shape.Traits = imp.withConstraints(shape.Traits, schema)
+	case "boolean":
+		shape.Type = "boolean"
+	default:
+		shape.Type = "document"
+	}
+}
+
+// addProperties adds the properties of an object schema, including those of any allOf parts, as structure members.
+func (imp *openApiImporter) addProperties(name string, shape *Shape, schema *data.Object) {
+	for _, part := range schema.GetArray("allOf") {
+		imp.addProperties(name, shape, imp.resolve(data.AsObject(part)))
+	}
+	required := schema.GetStringArray("required")
+	props := schema.GetObject("properties")
+	for _, pname := range props.Keys() {
+		prop := props.GetObject(pname)
+		mname := openApiSymbol(pname, false)
+		var traits *data.Object
+		traits = withTrait(traits, "smithy.api#documentation", nonEmpty(prop.GetString("description")))
+		if containsString(required, pname) {
+			traits = withTrait(traits, "smithy.api#required", data.NewObject())
+		}
+		if mname != pname {
+			traits = withTrait(traits, "smithy.api#jsonName", pname)
+		}
+		shape.Members.Put(mname, &Member{
+			Target: imp.memberTarget(name+Capitalize(mname), prop),
+			Traits: traits,
+		})
+	}
+}
+
+// memberTarget returns the target for a member with the given schema. Unconstrained primitives target the prelude
+// shapes, references target the referenced shape, and anything else is defined as a new shape with the given name.
+func (imp *openApiImporter) memberTarget(name string, schema *data.Object) string {
+	if schema == nil {
+		return "smithy.api#Document"
+	}
+	if ref := schema.GetString("$ref"); ref != "" {
+		return imp.refTarget(ref)
+	}
+	switch t := openApiSchemaType(schema); t {
+	case "string", "integer", "number", "boolean":
+		constrained := false
+		for _, k := range []string{"enum", "pattern", "minLength", "maxLength", "minimum", "maximum"} {
+			constrained = constrained || schema.Has(k)
+		}
+		if !constrained {
+			return "smithy.api#" + openApiPreludeType(t, schema.GetString("format"))
+		}
+	case "":
+		return "smithy.api#Document"
+	}
+	return imp.defineShape(name, schema)
+}
+
+func openApiSchemaType(schema *data.Object) string {
+	if t := schema.GetString("type"); t != "" {
+		return t
+	}
+	if schema.Has("properties") || schema.Has("allOf") || schema.Has("additionalProperties") {
+		return "object"
+	}
+	if openApiAlternatives(schema) != nil {
+		return "union"
+	}
+	return ""
+}
+
+func openApiAlternatives(schema *data.Object) []interface{} {
+	if alts := schema.GetArray("oneOf"); alts != nil {
+		return alts
+	}
+	return schema.GetArray("anyOf")
+}
+
+func openApiPreludeType(schemaType, format string) string {
+	switch schemaType {
+	case "string":
+		switch format {
+		case "date-time", "date":
+			return "Timestamp"
+		case "byte", "binary":
+			return "Blob"
+		}
+		return "String"
+	case "integer":
+		if format == "int64" {
+			return "Long"
+		}
+		return "Integer"
+	case "number":
+		if format == "float" {
+			return "Float"
+		}
+		return "Double"
+	case "boolean":
+		return "Boolean"
+	}
+	return "Document"
+}
+
+func (imp *openApiImporter) withConstraints(traits *data.Object, schema *data.Object) *data.Object {
+	length := data.NewObject()
+	if schema.Has("minLength") || schema.Has("minItems") {
+		length.Put("min", schema.GetInt("minLength")+schema.GetInt("minItems"))
+	}
+	if schema.Has("maxLength") || schema.Has("maxItems") {
+		length.Put("max", schema.GetInt("maxLength")+schema.GetInt("maxItems"))
+	}
+	if length.Length() > 0 {
+		traits = withTrait(traits, "smithy.api#length", length)
+	}
+	rng := data.NewObject()
+	if schema.Has("minimum") {
+		rng.Put("min", data.AsDecimal(schema.Get("minimum")))
+	}
+	if schema.Has("maximum") {
+		rng.Put("max", data.AsDecimal(schema.Get("maximum")))
+	}
+	if rng.Length() > 0 {
+		traits = withTrait(traits, "smithy.api#range", rng)
+	}
+	return withTrait(traits, "smithy.api#pattern", nonEmpty(schema.GetString("pattern")))
+}
+
+func (imp *openApiImporter) importOperation(path, method string, item, op *data.Object) error {
+	name := openApiSymbol(op.GetString("operationId"), true)
+	if name == "" {
+		name = openApiSymbol(method+" "+path, true)
+	}
+	id := imp.ns + "#" + name
+	if imp.ast.GetShape(id) != nil {
+		return fmt.Errorf("Duplicate operation name in OpenAPI document: %s", name)
+	}
+	shape := &Shape{Type: "operation"}
+	imp.ast.PutShape(id, shape)
+	imp.service.Operations = append(imp.service.Operations, &ShapeRef{Target: id})
+	doc := op.GetString("description")
+	if doc == "" {
+		doc = op.GetString("summary")
+	}
+	shape.Traits = withTrait(nil, "smithy.api#documentation", nonEmpty(doc))
+	switch method {
+	case "get", "head":
+		shape.Traits = withTrait(shape.Traits, "smithy.api#readonly", data.NewObject())
+	case "put", "delete":
+		shape.Traits = withTrait(shape.Traits, "smithy.api#idempotent", data.NewObject())
+	}
+	if op.GetBool("deprecated") {
+		shape.Traits = withTrait(shape.Traits, "smithy.api#deprecated", data.NewObject())
+	}
+	if tags := op.GetStringArray("tags"); len(tags) > 0 {
+		shape.Traits = withTrait(shape.Traits, "smithy.api#tags", tags)
+	}
+	code := 200
+	responses := op.GetObject("responses")
+	for _, k := range responses.Keys() {
+		if strings.HasPrefix(k, "2") {
+			code, _ = strconv.Atoi(k)
+			shape.Output = imp.importOutput(name, imp.resolve(responses.GetObject(k)))
+			break
+		}
+	}
+	http := data.NewObject()
+	http.Put("method", strings.ToUpper(method))
+	http.Put("uri", path)
+	http.Put("code", code)
+	shape.Traits = withTrait(shape.Traits, "smithy.api#http", http)
+	shape.Input = imp.importInput(name, item, op)
+	for _, k := range responses.Keys() {
+		status, err := strconv.Atoi(k)
+		if err != nil || status < 400 {
+			continue
+		}
+		if target := imp.importError(name, status, imp.resolve(responses.GetObject(k))); target != "" {
+			shape.Errors = append(shape.Errors, &ShapeRef{Target: target})
+		}
+	}
+	return nil
+}
+
+func (imp *openApiImporter) importInput(opName string, item, op *data.Object) *ShapeRef {
+	input := &Shape{Type: "structure", Members: NewMembers()}
+	var params []interface{}
+	params = append(params, item.GetArray("parameters")...)
+	params = append(params, op.GetArray("parameters")...)
+	for _, p := range params {
+		param := imp.resolve(data.AsObject(p))
+		pname := param.GetString("name")
+		mname := openApiSymbol(pname, false)
+		var traits *data.Object
+		traits = withTrait(traits, "smithy.api#documentation", nonEmpty(param.GetString("description")))
+		switch param.GetString("in") {
+		case "path":
+			traits = withTrait(traits, "smithy.api#httpLabel", data.NewObject())
+			traits = withTrait(traits, "smithy.api#required", data.NewObject())
+		case "query":
+			traits = withTrait(traits, "smithy.api#httpQuery", pname)
+		case "header":
+			traits = withTrait(traits, "smithy.api#httpHeader", pname)
+		default:
+			continue
+		}
+		if param.GetBool("required") {
+			traits = withTrait(traits, "smithy.api#required", data.NewObject())
+		}
+		input.Members.Put(mname, &Member{
+			Target: imp.memberTarget(opName+Capitalize(mname), param.GetObject("schema")),
+			Traits: traits,
+		})
+	}
+	if body := op.GetObject("requestBody"); body != nil {
+		body = imp.resolve(body)
+		if schema := openApiContentSchema(body.GetObject("content")); schema != nil {
+			traits := withTrait(nil, "smithy.api#httpPayload", data.NewObject())
+			if body.GetBool("required") {
+				traits = withTrait(traits, "smithy.api#required", data.NewObject())
+			}
+			input.Members.Put("body", &Member{
+				Target: imp.memberTarget(opName+"Body", schema),
+				Traits: traits,
+			})
+		}
+	}
+	if input.Members.Length() == 0 {
+		return nil
+	}
+	id := imp.ns + "#" + opName + "Input"
+	imp.ast.PutShape(id, input)
+	return &ShapeRef{Target: id}
+}
+
+func (imp *openApiImporter) importOutput(opName string, response *data.Object) *ShapeRef {
+	output := &Shape{Type: "structure", Members: NewMembers()}
+	headers := response.GetObject("headers")
+	for _, hname := range headers.Keys() {
+		header := imp.resolve(headers.GetObject(hname))
+		mname := openApiSymbol(hname, false)
+		traits := withTrait(nil, "smithy.api#httpHeader", hname)
+		traits = withTrait(traits, "smithy.api#documentation", nonEmpty(header.GetString("description")))
+		output.Members.Put(mname, &Member{
+			Target: imp.memberTarget(opName+Capitalize(mname), header.GetObject("schema")),
+			Traits: traits,
+		})
+	}
+	if schema := openApiContentSchema(response.GetObject("content")); schema != nil {
+		output.Members.Put("body", &Member{
+			Target: imp.memberTarget(opName+"ResponseBody", schema),
+			Traits: withTrait(nil, "smithy.api#httpPayload", data.NewObject()),
+		})
+	}
+	if output.Members.Length() == 0 {
+		return nil
+	}
+	id := imp.ns + "#" + opName + "Output"
+	imp.ast.PutShape(id, output)
+	return &ShapeRef{Target: id}
+}
+
+// importError marks the structure used by an error response with the @error and @httpError traits. Responses
+// without a structure body are not represented.
+func (imp *openApiImporter) importError(opName string, status int, response *data.Object) string {
+	schema := openApiContentSchema(response.GetObject("content"))
+	if schema == nil {
+		return ""
+	}
+	target := imp.memberTarget(fmt.Sprintf("%s%dError", opName, status), schema)
+	shape := imp.ast.GetShape(target)
+	if shape == nil || shape.Type != "structure" {
+		return ""
+	}
+	if !shape.Traits.Has("smithy.api#error") {
+		kind := "client"
+		if status >= 500 {
+			kind = "server"
+		}
+		shape.Traits = withTrait(shape.Traits, "smithy.api#error", kind)
+		shape.Traits = withTrait(shape.Traits, "smithy.api#httpError", int32(status))
+	}
+	return target
+}
+
+// openApiContentSchema returns the schema of the JSON media type in the content, or else of the first media type.
+func openApiContentSchema(content *data.Object) *data.Object {
+	if content.Length() == 0 {
+		return nil
+	}
+	if media := content.GetObject("application/json"); media != nil {
+		return media.GetObject("schema")
+	}
+	return content.GetObject(content.Keys()[0]).GetObject("schema")
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+	"testing"
+)
+
+// OpenAPI documents and the Smithy IDL of the models they should import as
+var openApiTests = []struct {
+	name    string
+	openapi string
+	idl     string
+}{
+	{"empty", `{"openapi": "3.0.0", "info": {"title": "x", "version": "1"}, "paths": {}}`, `$version: "2"
+namespace x
+service X {
+    version: "1"
+}
+`},
+	{"schemas", `openapi: 3.0.0
+info:
+  title: Pet Store
+  version: "1.0"
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id]
+      properties:
+        id:
+          type: string
+          pattern: "^[0-9]+$"
+        age:
+          type: integer
+          format: int64
+          minimum: 0
+        status:
+          type: string
+          enum: [available, sold-out]
+        tags:
+          type: array
+          maxItems: 5
+          items:
+            type: string
+        attrs:
+          type: object
+          additionalProperties:
+            type: number
+            format: double
+`, `$version: "2"
+namespace petstore
+service PetStore {
+    version: "1.0"
+}
+structure Pet {
+    @required
+    id: PetId
+    age: PetAge
+    status: PetStatus
+    tags: PetTags
+    attrs: PetAttrs
+}
+@pattern("^[0-9]+$")
+string PetId
+@range(min: 0)
+long PetAge
+enum PetStatus {
+    available
+    SOLD_OUT = "sold-out"
+}
+@length(max: 5)
+list PetTags {
+    member: String
+}
+map PetAttrs {
+    key: String
+    value: Double
+}
+`},
+	{"paths", `openapi: 3.0.0
+info:
+  title: Pet Store
+  version: "1.0"
+  description: Pets for sale
+paths:
+  /pets/{petId}:
+    get:
+      operationId: getPet
+      parameters:
+        - name: petId
+          in: path
+          required: true
+          schema:
+            type: string
+        - name: verbose
+          in: query
+          schema:
+            type: boolean
+        - name: X-Trace
+          in: header
+          schema:
+            type: string
+      responses:
+        "200":
+          description: the pet
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+        "404":
+          description: not found
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Error"
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+      responses:
+        "201":
+          description: created
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id:
+          type: string
+    Error:
+      type: object
+      properties:
+        message:
+          type: string
+`, `$version: "2"
+namespace petstore
+/// Pets for sale
+service PetStore {
+    version: "1.0"
+    operations: [GetPet, PostPets]
+}
+@readonly
+@http(method: "GET", uri: "/pets/{petId}", code: 200)
+operation GetPet {
+    input: GetPetInput
+    output: GetPetOutput
+    errors: [Error]
+}
+structure GetPetInput {
+    @httpLabel
+    @required
+    petId: String
+    @httpQuery("verbose")
+    verbose: Boolean
+    @httpHeader("X-Trace")
+    xTrace: String
+}
+structure GetPetOutput {
+    @httpPayload
+    body: Pet
+}
+@http(method: "POST", uri: "/pets", code: 201)
+operation PostPets {
+    input: PostPetsInput
+}
+structure PostPetsInput {
+    @httpPayload
+    body: Pet
+}
+structure Pet {
+    id: String
+}
+@error("client")
+@httpError(404)
+structure Error {
+    message: String
+}
+`},
+}
+
+func TestOpenApi(t *testing.T) {
+	for _, tc := range openApiTests {
+		t.Run(tc.name, func(t *testing.T) {
+			if !isOpenAPIDocument([]byte(tc.openapi)) {
+				t.Errorf("Not recognized as an OpenAPI document")
+			}
+			ast, err := loadOpenAPIBytes("test.yaml", []byte(tc.openapi))
+			if err != nil {
+				t.Fatalf("Cannot import the OpenAPI document: %v", err)
+			}
+			expected, err := ParseString(tc.idl, "test.smithy")
+			if err != nil {
+				t.Fatalf("Cannot parse the expected IDL: %v", err)
+			}
+			if !ast.Equal(expected) {
+				t.Errorf("The OpenAPI document does not import as expected:\n%s", ast.IDL(ast.Namespaces()[0]))
+			}
+		})
+	}
+}
+
+// documents that cannot be imported, and the errors they should produce
+var badOpenApiTests = []struct {
+	name  string
+	doc   string
+	error string
+}{
+	{"swagger", `{"swagger": "2.0", "info": {"title": "x"}}`, "Swagger 2.0 is not supported"},
+	{"not openapi", `{"info": {"title": "x"}}`, "Not an OpenAPI document"},
+	{"not an object", `[1, 2]`, "Not an OpenAPI document"},
+	{"bad yaml", "openapi: [3.0.0\n", "Cannot parse OpenAPI file"},
+}
+
+func TestBadOpenApi(t *testing.T) {
+	for _, tc := range badOpenApiTests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadOpenAPIBytes("test.yaml", []byte(tc.doc))
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.error) {
+				t.Errorf("Expected an error containing %q, got %q", tc.error, err.Error())
+			}
+		})
+	}
+}
@@ -16,7 +16,10 @@ limitations under the License.
 package smithy
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"strconv"
@@ -25,24 +28,138 @@ import (
 	"github.com/boynton/data"
 )
 
-var AnnotateSources bool = false
+const sourceAnnotationPrefix = "source: "
 
-func Parse(path string) (*AST, error) {
-	b, err := ioutil.ReadFile(path)
+// stripSourceAnnotation returns the documentation without the "source: path" line that source annotations were once
+// added to it as.
+func stripSourceAnnotation(doc string) string {
+	n := strings.LastIndex(doc, "\n") + 1
+	if !strings.HasPrefix(doc[n:], sourceAnnotationPrefix) {
+		return doc
+	}
+	return strings.TrimSuffix(doc[:n], "\n")
+}
+
+// ParserOption configures how a model is parsed.
+type ParserOption func(*parserOptions)
+
+type parserOptions struct {
+	fsys         fs.FS
+	strictTraits bool
+	ctx          context.Context
+	logger       Logger
+	annotate     bool
+	keepApplied  bool
+}
+
+// WithFS reads model files from the given filesystem, i.e. an embed.FS, rather than the OS filesystem.
+func WithFS(fsys fs.FS) ParserOption {
+	return func(o *parserOptions) {
+		o.fsys = fsys
+	}
+}
+
+// WithStrictTraits makes it an error to apply a trait that is not defined, as AST.CheckTraitsDefined checks, rather
+// than a warning when the model is validated. Only the file being parsed is checked, so this is for models in a single
+// file: for those in several, use the StrictTraits of the Assembler.
+func WithStrictTraits() ParserOption {
+	return func(o *parserOptions) {
+		o.strictTraits = true
+	}
+}
+
+// WithContext stops the parse with the error of the context, if it is cancelled or its deadline passes before the
+// parse is done.
+func WithContext(ctx context.Context) ParserOption {
+	return func(o *parserOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithSourceAnnotations annotates each shape with the path of its source file, relative to the working directory, which
+// AST.SourceAnnotation returns.
+func WithSourceAnnotations() ParserOption {
+	return func(o *parserOptions) {
+		o.annotate = true
+	}
+}
+
+// WithLogger reports the progress and problems of the parse to the logger.
+func WithLogger(logger Logger) ParserOption {
+	return func(o *parserOptions) {
+		o.logger = logger
+	}
+}
+
+// withAppliedKept keeps the traits of apply statements pending on the AST, as if the shapes they apply to were not
+// defined, so that the statements can be written back as they were, rather than folded into the shapes.
+func withAppliedKept() ParserOption {
+	return func(o *parserOptions) {
+		o.keepApplied = true
+	}
+}
+
+func newParserOptions(opts []ParserOption) *parserOptions {
+	o := &parserOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func Parse(path string, opts ...ParserOption) (*AST, error) {
+	o := newParserOptions(opts)
+	var f io.ReadCloser
+	var err error
+	if o.fsys != nil {
+		f, err = o.fsys.Open(path)
+	} else {
+		f, err = os.Open(path)
+	}
 	if err != nil {
 		return nil, err
 	}
-	src := string(b)
+	defer f.Close()
+	return ParseReader(f, path, opts...)
+}
+
+// ParseFS parses the IDL file at the path in the filesystem.
+func ParseFS(fsys fs.FS, path string, opts ...ParserOption) (*AST, error) {
+	return Parse(path, append(opts, WithFS(fsys))...)
+}
+
+// ParseReader parses the IDL read from r. The name is used as the file name in errors and source locations.
+func ParseReader(r io.Reader, name string, opts ...ParserOption) (*AST, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(string(b), name, opts...)
+}
+
+// ParseString parses the IDL source text. The name is used as the file name in errors and source locations.
+func ParseString(src string, name string, opts ...ParserOption) (*AST, error) {
+	o := newParserOptions(opts)
 	p := &Parser{
-		scanner: NewScanner(strings.NewReader(src)),
-		path:    path,
-		source:  src,
+		scanner:     newStringScanner(src),
+		path:        name,
+		source:      src,
+		ctx:         o.ctx,
+		logger:      orNopLogger(o.logger),
+		annotate:    o.annotate,
+		keepApplied: o.keepApplied,
 	}
 	p.wd, _ = os.Getwd()
-	err = p.Parse()
+	err := p.Parse()
 	if err != nil {
 		return nil, err
 	}
+	if o.strictTraits {
+		err = p.ast.CheckTraitsDefined()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return p.ast, nil
 }
 
@@ -59,7 +176,33 @@ type Parser struct {
 	currentComment string
 	use            map[string]string //maps short name to fully qualified name (typically another namespace)
 	wd             string
-	version        int //1 or 2
+	version        int    //1 or 2
+	shapeToken     *Token //the first token of the shape statement being parsed
+	inputSuffix    string
+	outputSuffix   string
+	elided         []*elidedMember
+	applied        []*appliedTraits
+	preludeTraits  map[string]bool //unqualified trait names that were taken to be prelude traits
+	ctx            context.Context //checked before each statement, if set
+	logger         Logger
+	annotate       bool //see WithSourceAnnotations
+	keepApplied    bool //see withAppliedKept
+}
+
+// elidedMember is a member declared as "$name", whose target is resolved from the bound resource or the mixins of its
+// structure once the whole file has been parsed.
+type elidedMember struct {
+	name     string
+	member   *Member
+	resource string
+	mixins   []*ShapeRef
+	tok      *Token
+}
+
+// appliedTraits are the traits of an "apply" statement for the shape or member with the id.
+type appliedTraits struct {
+	id     string
+	traits *data.Object
 }
 
 func (p *Parser) Parse() error {
@@ -68,6 +211,8 @@ func (p *Parser) Parse() error {
 	p.ast = &AST{
 		Smithy: "2",
 	}
+	p.inputSuffix = "Input"
+	p.outputSuffix = "Output"
 	for {
 		var err error
 		tok := p.GetToken()
@@ -76,6 +221,10 @@ func (p *Parser) Parse() error {
 		}
 		switch tok.Type {
 		case SYMBOL:
+			if p.ctx != nil && p.ctx.Err() != nil {
+				return p.ctx.Err()
+			}
+			p.shapeToken = tok
 			switch tok.Text {
 			case "namespace":
 				if traits != nil {
@@ -90,6 +239,7 @@ func (p *Parser) Parse() error {
 			case "service":
 				traits, comment = withCommentTrait(traits, comment)
 				err = p.parseService(traits)
+				traits = nil
 			case "blob", "document":
 				err = p.Error(fmt.Sprintf("Shape NYI: %s", tok.Text))
 			case "byte", "short", "integer", "long", "float", "double", "bigInteger", "bigDecimal", "string", "timestamp", "boolean":
@@ -111,6 +261,7 @@ func (p *Parser) Parse() error {
 			case "set":
 				p.Warning("Deprecated shape: set")
 				traits, comment = withCommentTrait(traits, comment)
+				traits = withTrait(traits, "smithy.api#uniqueItems", data.NewObject())
 				err = p.parseList(traits)
 				traits = nil
 			case "list":
@@ -137,12 +288,14 @@ func (p *Parser) Parse() error {
 						p.use = make(map[string]string, 0)
 					}
 					p.use[shortName] = use
+					p.ast.addUse(p.namespace, use)
 				}
 			case "apply":
-				//to do: parse straight to a "target" shape, then apply it later during assembly?
 				var ftype string
 				ftype, err = p.expectShapeId()
-				//ftype, err = p.expectTarget()
+				if err != nil {
+					return err
+				}
 				tok := p.GetToken()
 				if tok == nil {
 					return p.SyntaxError()
@@ -150,11 +303,15 @@ func (p *Parser) Parse() error {
 				if tok.Type != AT {
 					return p.SyntaxError()
 				}
-				//to do: support apply on shape members
-				if shape := p.ast.GetShape(p.ensureNamespaced(ftype)); shape != nil {
-					t, e := p.parseTrait(shape.Traits)
-					err = e
-					shape.Traits = t
+				var t *data.Object
+				t, err = p.parseTrait(nil)
+				if err == nil {
+					//applied once the whole file has been parsed, as the shape may be defined later
+					id := ShapeID(p.ensureNamespaced(string(ShapeID(ftype).Shape())))
+					if mem := ShapeID(ftype).Member(); mem != "" {
+						id = id.WithMember(mem)
+					}
+					p.applied = append(p.applied, &appliedTraits{id: string(id), traits: t})
 				}
 			default:
 				err = p.Error(fmt.Sprintf("Unknown shape: %s", tok.Text))
@@ -183,15 +340,26 @@ func (p *Parser) Parse() error {
 			case "version":
 				if s, ok := v.(*string); ok {
 					if strings.HasPrefix(*s, "1") {
+						p.ast.Smithy = "1.0"
 						p.version = 1
 					} else if strings.HasPrefix(*s, "2") {
 						p.ast.Smithy = "2"
 						p.version = 2
 					} else {
-						return fmt.Errorf("Unsupported version: %s\n", *s)
+						return p.Error(fmt.Sprintf("Unsupported version: %s", *s))
 					}
 				} else {
-					return fmt.Errorf("Bad control statement (only version 1 or 1.0 is supported): $%s: %v\n", variable, v)
+					return p.Error(fmt.Sprintf("Bad control statement (only version 1 or 1.0 is supported): $%s: %v", variable, v))
+				}
+			case "operationInputSuffix", "operationOutputSuffix":
+				s, ok := v.(*string)
+				if !ok || *s == "" {
+					return p.Error(fmt.Sprintf("Bad control statement, expected a string: $%s", variable))
+				}
+				if variable == "operationInputSuffix" {
+					p.inputSuffix = *s
+				} else {
+					p.outputSuffix = *s
 				}
 			}
 		case SEMICOLON, NEWLINE:
@@ -203,9 +371,63 @@ func (p *Parser) Parse() error {
 			return err
 		}
 	}
+	err := p.resolveElidedMembers()
+	if err != nil {
+		return err
+	}
+	p.resolveLocalTraits()
+	for _, a := range p.applied {
+		if p.keepApplied {
+			p.ast.addApplied(a.id, a.traits)
+		} else {
+			p.ast.applyTraits(a.id, a.traits)
+		}
+	}
+	p.applied = nil
+	return nil
+}
+
+func (p *Parser) resolveElidedMembers() error {
+	for _, e := range p.elided {
+		if e.resource != "" {
+			if rez := p.ast.GetShape(e.resource); rez != nil {
+				if ref, ok := rez.Identifiers[e.name]; ok {
+					e.member.Target = ref.Target
+					continue
+				}
+				if ref, ok := rez.Properties[e.name]; ok {
+					e.member.Target = ref.Target
+					continue
+				}
+			}
+		}
+		if target := p.mixinMemberTarget(e.mixins, e.name); target != "" {
+			e.member.Target = target
+			continue
+		}
+		p.lastToken = e.tok
+		return p.Error(fmt.Sprintf("Cannot resolve the target of elided member $%s", e.name))
+	}
+	p.elided = nil
 	return nil
 }
 
+func (p *Parser) mixinMemberTarget(mixins []*ShapeRef, name string) string {
+	for _, ref := range mixins {
+		if mixin := p.ast.GetShape(ref.Target); mixin != nil {
+			if mixin.Members != nil {
+				if mem := mixin.Members.Get(name); mem != nil {
+					return mem.Target
+				}
+			}
+			if target := p.mixinMemberTarget(mixin.Mixins, name); target != "" {
+				return target
+			}
+		}
+	}
+	return ""
+}
+
 func (p *Parser) UngetToken() {
 	p.ungottenToken = p.lastToken
 	p.lastToken = p.prevLastToken
@@ -257,12 +479,12 @@ func (p *Parser) expect(toktype TokenType) error {
 func (p *Parser) expectText() (string, error) {
 	tok := p.GetToken()
 	if tok == nil {
-		return "", fmt.Errorf("Unexpected end of file")
+		return "", p.EndOfFileError()
 	}
 	if tok.IsText() {
 		return tok.Text, nil
 	}
-	return "", fmt.Errorf("Expected symbol or string, found %v", tok.Type)
+	return "", p.Error(fmt.Sprintf("Expected symbol or string, found %v", tok.Type))
 }
 
 func (p *Parser) assertIdentifier(tok *Token) (string, error) {
@@ -431,17 +653,51 @@ func (p *Parser) MergeComment(comment1 string, comment2 string) string {
 	return comment1 + "\n" + TrimSpace(comment2)
 }
 
+// ParseError is the error returned for a problem in a model file, positioned at the token where it was found.
+type ParseError struct {
+	File    string
+	Line    int
+	Column  int
+	Token   *Token
+	Message string
+
+	source   string //of the file, for the surrounding lines
+	annotate bool   //print the message with the surrounding source lines, which are only formatted when printed
+}
+
+func (e *ParseError) Error() string {
+	if e.annotate {
+		return fmt.Sprintf("*** %s\n", FormattedAnnotation(e.File, e.source, "", e.Message, e.Token, RED, 5))
+	}
+	if e.Token != nil {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Message)
+}
+
 func (p *Parser) Error(msg string) error {
-	Debug("*** error, last token:", p.lastToken)
-	return fmt.Errorf("*** %s\n", FormattedAnnotation(p.path, p.source, "", msg, p.lastToken, RED, 5))
+	p.logger.Debug("Parse error", "file", p.path, "token", p.lastToken, "message", msg)
+	err := &ParseError{
+		File:     p.path,
+		Token:    p.lastToken,
+		Message:  msg,
+		source:   p.source,
+		annotate: true,
+	}
+	if p.lastToken != nil {
+		err.Line = p.lastToken.Line
+		err.Column = p.lastToken.Start
+	}
+	return err
 }
 
 func (p *Parser) SyntaxError() error {
 	return p.Error("Syntax error")
 }
 
+// Warning records a warning at the current token. The warnings are available from the resulting AST.
 func (p *Parser) Warning(msg string) {
-	fmt.Fprintf(os.Stderr, "[WARNING]: %s\n", FormattedAnnotation(p.path, p.source, "", msg, p.lastToken, RED, 5))
+	p.ast.AddWarning(SeverityWarning, p.location(p.lastToken), msg)
 }
 
 func (p *Parser) EndOfFileError() error {
@@ -449,11 +705,20 @@ func (p *Parser) EndOfFileError() error {
 }
 
 func (p *Parser) parseMetadata() error {
-	key, err := p.ExpectIdentifier()
-	if err != nil {
-		return err
+	tok := p.GetToken()
+	if tok == nil {
+		return p.EndOfFileError()
 	}
-	err = p.expect(EQUALS)
+	key := tok.Text //keys that are not identifiers are quoted
+	if tok.Type != STRING {
+		p.UngetToken()
+		ident, err := p.ExpectIdentifier()
+		if err != nil {
+			return err
+		}
+		key = ident
+	}
+	err := p.expect(EQUALS)
 	if err != nil {
 		return err
 	}
@@ -544,10 +809,16 @@ func (p *Parser) expectShapeId() (string, error) {
 		ns = ns + txt
 	}
 	for {
+		prev := p.lastToken
 		tok := p.GetToken()
 		if tok == nil {
 			break
 		}
+		if tok.Type == DOLLAR && !adjacentTokens(prev, tok) {
+			//a member id cannot contain whitespace, so this starts something else, i.e. an elided member
+			p.UngetToken()
+			break
+		}
 		if tok.Type == HASH {
 			if ns == "" {
 				ns = ident
@@ -585,6 +856,17 @@ func (p *Parser) expectShapeId() (string, error) {
 	return ident, nil
 }
 
+func (p *Parser) location(tok *Token) *SourceLocation {
+	if tok == nil {
+		return nil
+	}
+	return &SourceLocation{File: p.path, Line: tok.Line, Column: tok.Start}
+}
+
+func adjacentTokens(prev, tok *Token) bool {
+	return prev != nil && prev.Line == tok.Line && prev.Start+len([]rune(prev.Text)) == tok.Start
+}
+
 func (p *Parser) parseNamespace(comment string) error {
 	//	p.schema.Comment = p.MergeComment(p.schema.Comment, comment)
 	if p.namespace != "" {
@@ -600,9 +882,11 @@ func (p *Parser) addShapeDefinition(name string, shape *Shape) error {
 	if tmp := p.ast.GetShape(id); tmp != nil {
 		return p.Error(fmt.Sprintf("Duplicate shape: %q", id))
 	}
-	if AnnotateSources {
-		rpath := p.relativePath(p.path)
-		shape.Traits, _ = withCommentTrait(shape.Traits, "source: "+rpath)
+	if shape.location == nil {
+		shape.location = p.location(p.shapeToken)
+	}
+	if p.annotate {
+		shape.source = p.relativePath(p.path)
 	}
 	p.ast.PutShape(id, shape)
 	return nil
@@ -613,47 +897,11 @@ func (p *Parser) parseSimpleTypeDef(typeName string, traits *data.Object) error
 	if err != nil {
 		return err
 	}
-	enumItems := traits.GetArray("smithy.api#enum")
-	if enumItems != nil {
-		//convert to enum shape
-		var tr *data.Object
-		for _, k := range traits.Keys() {
-			if k != "smithy.api#enum" {
-				tr = withTrait(tr, k, traits.Get(k))
-			}
-		}
-		enumShapeName := "enum"
-		if typeName == "integer" {
-			enumShapeName = "intEnum"
-		}
-		shape := &Shape{
-			Type:   enumShapeName,
-			Traits: tr,
-		}
-		mems := NewMembers()
-		for _, e := range enumItems {
-			var mtraits *data.Object
-			d := data.AsObject(e)
-			name := d.GetString("name") //optional
-			if enumShapeName == "intEnum" {
-				ivalue := d.GetInt("value") //required
-				mtraits = withTrait(mtraits, "smithy.api#enumValue", ivalue)
-			} else {
-				svalue := d.GetString("value") //required
-				if name == "" {
-					name = svalue
-					svalue = ""
-				}
-				if svalue != "" {
-					mtraits = withTrait(mtraits, "smithy.api#enumValue", svalue)
-				}
-			}
-			mems.Put(name, &Member{
-				Target: "smithy.api#Unit",
-				Traits: mtraits,
-			})
+	if traits.Has("smithy.api#enum") {
+		shape, err := enumShape(typeName, traits)
+		if err != nil {
+			return p.Error(err.Error())
 		}
-		shape.Members = mems
 		return p.addShapeDefinition(tname, shape)
 	}
 	shape := &Shape{
@@ -670,6 +918,26 @@ func (p *Parser) parseSimpleTypeDef(typeName string, traits *data.Object) error
 	return p.addShapeDefinition(tname, shape)
 }
 
+// optionalResourceBinding parses the "for Resource" clause of a structure, returning the resource id.
+func (p *Parser) optionalResourceBinding() (string, error) {
+	tok := p.GetToken()
+	if tok == nil {
+		return "", nil
+	}
+	if tok.Type == SYMBOL && tok.Text == "for" {
+		if p.version < 2 {
+			return "", p.SyntaxError()
+		}
+		rez, err := p.expectShapeId()
+		if err != nil {
+			return "", err
+		}
+		return p.ensureNamespaced(rez), nil
+	}
+	p.UngetToken()
+	return "", nil
+}
+
 func (p *Parser) optionalMixins() ([]string, error) {
 	tok := p.GetToken()
 	if tok == nil {
@@ -717,6 +985,7 @@ func (p *Parser) parseList(traits *data.Object) error {
 		Traits: traits,
 	}
 	var mtraits *data.Object
+	comment := ""
 	for {
 		tok := p.GetToken()
 		if tok == nil {
@@ -743,18 +1012,24 @@ func (p *Parser) parseList(traits *data.Object) error {
 				return p.SyntaxError()
 			}
 
-			ftype, err := p.ExpectIdentifier()
+			ftype, err := p.expectShapeId()
 			if err != nil {
 				return err
 			}
 			err = p.ignore(COMMA)
+			mtraits, comment = withCommentTrait(mtraits, comment)
 			shape.Member = &Member{
-				Target: p.ensureNamespaced(ftype),
-				Traits: mtraits,
+				Target:   p.ensureNamespaced(ftype),
+				Traits:   mtraits,
+				location: p.location(tok),
 			}
 			if shape.Member.Target == p.ensureNamespaced(name) {
 				return p.Error(fmt.Sprintf("Directly recursive type references not allowed: %s", ftype))
 			}
+		} else if tok.Type == LINE_COMMENT {
+			if strings.HasPrefix(tok.Text, "/") { //a triple slash means doc comment
+				comment = p.MergeComment(comment, tok.Text[1:])
+			}
 		} else {
 			return p.SyntaxError()
 		}
@@ -782,6 +1057,7 @@ func (p *Parser) parseMap(sname string, traits *data.Object) error {
 		Traits: traits,
 	}
 	var mtraits *data.Object
+	comment := ""
 	for {
 		tok := p.GetToken()
 		if tok == nil {
@@ -804,15 +1080,17 @@ func (p *Parser) parseMap(sname string, traits *data.Object) error {
 			if err != nil {
 				return err
 			}
-			ftype, err := p.ExpectIdentifier()
+			ftype, err := p.expectShapeId()
 			if err != nil {
 				return err
 			}
 			err = p.ignore(COMMA)
+			mtraits, comment = withCommentTrait(mtraits, comment)
 			if fname == "key" {
 				shape.Key = &Member{
-					Target: p.ensureNamespaced(ftype),
-					Traits: mtraits,
+					Target:   p.ensureNamespaced(ftype),
+					Traits:   mtraits,
+					location: p.location(tok),
 				}
 				if shape.Key.Target == p.ensureNamespaced(name) {
 					return p.Error(fmt.Sprintf("Directly recursive type references not allowed: %s", ftype))
@@ -820,8 +1098,9 @@ func (p *Parser) parseMap(sname string, traits *data.Object) error {
 				mtraits = nil
 			} else if fname == "value" {
 				shape.Value = &Member{
-					Target: p.ensureNamespaced(ftype),
-					Traits: mtraits,
+					Target:   p.ensureNamespaced(ftype),
+					Traits:   mtraits,
+					location: p.location(tok),
 				}
 				if shape.Value.Target == p.ensureNamespaced(name) {
 					return p.Error(fmt.Sprintf("Directly recursive type references not allowed: %s", ftype))
@@ -830,6 +1109,10 @@ func (p *Parser) parseMap(sname string, traits *data.Object) error {
 			} else {
 				return p.SyntaxError()
 			}
+		} else if tok.Type == LINE_COMMENT {
+			if strings.HasPrefix(tok.Text, "/") { //a triple slash means doc comment
+				comment = p.MergeComment(comment, tok.Text[1:])
+			}
 		} else {
 			return p.SyntaxError()
 		}
@@ -848,6 +1131,10 @@ func (p *Parser) parseStructureBody(traits *data.Object) (*Shape, error) {
 		Type:   "structure",
 		Traits: traits,
 	}
+	resource, err := p.optionalResourceBinding()
+	if err != nil {
+		return nil, err
+	}
 	mixins, err := p.optionalMixins()
 	if err != nil {
 		return nil, err
@@ -881,8 +1168,33 @@ func (p *Parser) parseStructureBody(traits *data.Object) (*Shape, error) {
 			if err != nil {
 				return nil, err
 			}
+		} else if tok.Type == DOLLAR {
+			loc := p.location(tok)
+			fname, err := p.ExpectIdentifier()
+			if err != nil {
+				return nil, err
+			}
+			err = p.ignore(COMMA)
+			if comment != "" {
+				mtraits, comment = withCommentTrait(mtraits, comment)
+				comment = ""
+			}
+			mem := &Member{
+				Traits:   mtraits,
+				location: loc,
+			}
+			p.elided = append(p.elided, &elidedMember{
+				name:     fname,
+				member:   mem,
+				resource: resource,
+				mixins:   shape.Mixins,
+				tok:      p.lastToken,
+			})
+			mems.Put(fname, mem)
+			mtraits = nil
 		} else if tok.Type == SYMBOL {
 			fname := tok.Text
+			loc := p.location(tok)
 			err = p.expect(COLON)
 			if err != nil {
 				return nil, err
@@ -891,14 +1203,25 @@ func (p *Parser) parseStructureBody(traits *data.Object) (*Shape, error) {
 			if err != nil {
 				return nil, err
 			}
+			tok = p.GetToken()
+			if tok != nil && tok.Type == EQUALS {
+				val, err := p.parseLiteralValue()
+				if err != nil {
+					return nil, err
+				}
+				mtraits = withDefaultTrait(mtraits, val)
+			} else if tok != nil {
+				p.UngetToken()
+			}
 			err = p.ignore(COMMA)
 			if comment != "" {
 				mtraits, comment = withCommentTrait(mtraits, comment)
 				comment = ""
 			}
 			mems.Put(fname, &Member{
-				Target: p.ensureNamespaced(ftype),
-				Traits: mtraits,
+				Target:   p.ensureNamespaced(ftype),
+				Traits:   mtraits,
+				location: loc,
 			})
 			mtraits = nil
 		} else if tok.Type == LINE_COMMENT {
@@ -943,6 +1266,7 @@ func (p *Parser) parseUnion(traits *data.Object) error {
 	}
 	mems := NewMembers()
 	var mtraits *data.Object
+	comment := ""
 	for {
 		tok := p.GetToken()
 		if tok == nil {
@@ -971,11 +1295,17 @@ func (p *Parser) parseUnion(traits *data.Object) error {
 				return err
 			}
 			err = p.ignore(COMMA)
+			mtraits, comment = withCommentTrait(mtraits, comment)
 			mems.Put(fname, &Member{
-				Target: p.ensureNamespaced(ftype),
-				Traits: mtraits,
+				Target:   p.ensureNamespaced(ftype),
+				Traits:   mtraits,
+				location: p.location(tok),
 			})
 			mtraits = nil
+		} else if tok.Type == LINE_COMMENT {
+			if strings.HasPrefix(tok.Text, "/") { //a triple slash means doc comment
+				comment = p.MergeComment(comment, tok.Text[1:])
+			}
 		} else {
 			return p.SyntaxError()
 		}
@@ -1025,6 +1355,7 @@ func (p *Parser) parseEnum(traits *data.Object, intEnum bool) error {
 			}
 		} else if tok.Type == SYMBOL {
 			fname := tok.Text
+			loc := p.location(tok)
 			tok = p.GetToken()
 			if tok == nil {
 				return p.EndOfFileError()
@@ -1051,8 +1382,9 @@ func (p *Parser) parseEnum(traits *data.Object, intEnum bool) error {
 			err = p.ignore(COMMA)
 			mtraits, comment = withCommentTrait(mtraits, comment)
 			mems.Put(fname, &Member{
-				Target: "smithy.api#Unit",
-				Traits: mtraits,
+				Target:   "smithy.api#Unit",
+				Traits:   mtraits,
+				location: loc,
 			})
 			mtraits = nil
 			comment = ""
@@ -1105,6 +1437,7 @@ func (p *Parser) parseOperation(traits *data.Object) error {
 		if err != nil {
 			return err
 		}
+		loc := p.location(p.lastToken)
 		err = p.expect(COLON)
 		if err != nil {
 			return err
@@ -1116,18 +1449,7 @@ func (p *Parser) parseOperation(traits *data.Object) error {
 				return p.EndOfFileError()
 			}
 			if tok.Type == EQUALS {
-				if p.version < 2 {
-					err = p.SyntaxError()
-				} else {
-					traits = data.ObjectFromMap(map[string]interface{}{"smithy.api#input": data.NewObject()})
-					body, err := p.parseStructureBody(traits)
-					if err != nil {
-						return err
-					}
-					inName := name + "Input"
-					shape.Input = &ShapeRef{Target: p.ensureNamespaced(inName)}
-					p.addShapeDefinition(inName, body)
-				}
+				shape.Input, err = p.parseInlineStructure(name+p.inputSuffix, "smithy.api#input", loc)
 			} else {
 				p.UngetToken()
 				shape.Input, err = p.expectShapeRef()
@@ -1138,18 +1460,7 @@ func (p *Parser) parseOperation(traits *data.Object) error {
 				return p.EndOfFileError()
 			}
 			if tok.Type == EQUALS {
-				if p.version < 2 {
-					err = p.SyntaxError()
-				} else {
-					traits = data.ObjectFromMap(map[string]interface{}{"smithy.api#output": data.NewObject()})
-					body, err := p.parseStructureBody(traits)
-					if err != nil {
-						return err
-					}
-					outName := name + "Output"
-					shape.Output = &ShapeRef{Target: p.ensureNamespaced(outName)}
-					p.addShapeDefinition(outName, body)
-				}
+				shape.Output, err = p.parseInlineStructure(name+p.outputSuffix, "smithy.api#output", loc)
 			} else {
 				p.UngetToken()
 				shape.Output, err = p.expectShapeRef()
@@ -1167,6 +1478,48 @@ func (p *Parser) parseOperation(traits *data.Object) error {
 	return p.addShapeDefinition(name, shape)
 }
 
+// parseInlineStructure parses the structure following "input :=" or "output :=", which may be preceded by traits,
+// and defines it with the given name.
+func (p *Parser) parseInlineStructure(name string, traitId string, loc *SourceLocation) (*ShapeRef, error) {
+	if p.version < 2 {
+		return nil, p.SyntaxError()
+	}
+	traits := data.NewObject()
+	traits.Put(traitId, data.NewObject())
+	comment := ""
+	for {
+		tok := p.GetToken()
+		if tok == nil {
+			return nil, p.EndOfFileError()
+		}
+		if tok.Type == AT {
+			var err error
+			traits, err = p.parseTrait(traits)
+			if err != nil {
+				return nil, err
+			}
+		} else if tok.Type == LINE_COMMENT {
+			if strings.HasPrefix(tok.Text, "/") {
+				comment = p.MergeComment(comment, tok.Text[1:])
+			}
+		} else if tok.Type != NEWLINE {
+			p.UngetToken()
+			break
+		}
+	}
+	traits, _ = withCommentTrait(traits, comment)
+	body, err := p.parseStructureBody(traits)
+	if err != nil {
+		return nil, err
+	}
+	body.location = loc
+	err = p.addShapeDefinition(name, body)
+	if err != nil {
+		return nil, err
+	}
+	return &ShapeRef{Target: p.ensureNamespaced(name)}, nil
+}
+
 func (p *Parser) parseService(traits *data.Object) error {
 	name, err := p.ExpectIdentifier()
 	if err != nil {
@@ -1194,6 +1547,9 @@ func (p *Parser) parseService(traits *data.Object) error {
 		if tok.Type == CLOSE_BRACE {
 			break
 		}
+		if tok.Type == LINE_COMMENT {
+			continue
+		}
 		if tok.Type != COLON {
 			p.UngetToken()
 		}
@@ -1271,6 +1627,8 @@ func (p *Parser) parseResource(traits *data.Object) error {
 		switch fname {
 		case "identifiers":
 			shape.Identifiers, err = p.expectNamedShapeRefs()
+		case "properties":
+			shape.Properties, err = p.expectNamedShapeRefs()
 		case "create":
 			shape.Create, err = p.expectShapeRef()
 		case "put":
@@ -1282,12 +1640,12 @@ func (p *Parser) parseResource(traits *data.Object) error {
 		case "delete":
 			shape.Delete, err = p.expectShapeRef()
 		case "list":
-			shape.Delete, err = p.expectShapeRef()
+			shape.List, err = p.expectShapeRef()
 		case "operations":
 			shape.Operations, err = p.expectShapeRefs()
 		case "collectionOperations":
 			shape.CollectionOperations, err = p.expectShapeRefs()
-		case "Resources":
+		case "resources":
 			shape.Resources, err = p.expectShapeRefs()
 		default:
 			return p.SyntaxError()
@@ -1314,10 +1672,32 @@ func IsPreludeType(name string) bool {
 	return false
 }
 
+// IsPreludeTrait returns true if the name is the unqualified name of a trait defined in the smithy.api prelude
+func IsPreludeTrait(name string) bool {
+	switch name {
+	case "addedDefault", "auth", "authDefinition", "box", "clientOptional", "cors", "default", "deprecated",
+		"documentation", "endpoint", "enum", "enumValue", "error", "eventHeader", "eventPayload", "examples",
+		"externalDocumentation", "hostLabel", "http", "httpApiKeyAuth", "httpBasicAuth", "httpBearerAuth",
+		"httpChecksumRequired", "httpDigestAuth", "httpError", "httpHeader", "httpLabel", "httpPayload",
+		"httpPrefixHeaders", "httpQuery", "httpQueryParams", "httpResponseCode", "idRef", "idempotencyToken",
+		"idempotent", "input", "internal", "jsonName", "length", "mediaType", "mixin", "nestedProperties",
+		"noReplace", "notProperty", "optionalAuth", "output", "paginated", "pattern", "private", "property",
+		"protocolDefinition", "range", "readonly", "recommended", "references", "requestCompression", "required",
+		"requiresLength", "resourceIdentifier", "retryable", "sensitive", "since", "sparse", "streaming",
+		"suppress", "tags", "timestampFormat", "title", "trait", "uniqueItems", "unitType", "unstable",
+		"xmlAttribute", "xmlFlattened", "xmlName", "xmlNamespace":
+		return true
+	}
+	return false
+}
+
 func (p *Parser) ensureNamespaced(name string) string {
 	if IsPreludeType(name) {
 		return "smithy.api#" + name
 	}
+	if p.version < 2 && strings.HasPrefix(name, "Primitive") && containsString(primitiveTypes, name[len("Primitive"):]) {
+		return "smithy.api#" + name
+	}
 	if strings.Index(name, "#") < 0 {
 		if full, ok := p.use[name]; ok {
 			return full
@@ -1343,22 +1723,35 @@ func (p *Parser) expectNamedShapeRefs() (map[string]*ShapeRef, error) {
 }
 
 func (p *Parser) expectShapeRefs() ([]*ShapeRef, error) {
-	targets, err := p.ExpectIdentifierArray()
+	err := p.expect(OPEN_BRACKET)
 	if err != nil {
 		return nil, err
 	}
 	var refs []*ShapeRef
-	for _, target := range targets {
-		ref := &ShapeRef{
-			Target: p.ensureNamespaced(target),
+	for {
+		tok := p.GetToken()
+		if tok == nil {
+			return nil, p.EndOfFileError()
+		}
+		if tok.Type == CLOSE_BRACKET {
+			break
+		}
+		if tok.Type == SYMBOL {
+			p.UngetToken()
+			ref, err := p.expectShapeRef()
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, ref)
+		} else if tok.Type != COMMA && tok.Type != NEWLINE && tok.Type != LINE_COMMENT {
+			return nil, p.SyntaxError()
 		}
-		refs = append(refs, ref)
 	}
 	return refs, nil
 }
 
 func (p *Parser) expectShapeRef() (*ShapeRef, error) {
-	tname, err := p.ExpectIdentifier()
+	tname, err := p.expectShapeId()
 	if err != nil {
 		return nil, err
 	}
@@ -1388,13 +1781,27 @@ func (p *Parser) parseTraitArgs() (*data.Object, interface{}, error) {
 			if tok.Type == LINE_COMMENT {
 				continue
 			}
-			if tok.Type == SYMBOL {
-				p.ignore(COLON)
+			if tok.Type == SYMBOL || tok.Type == STRING {
+				key := tok
+				if next := p.GetToken(); next == nil || next.Type != COLON {
+					//not a key, so the whole argument is a single value, i.e. @owner("lee") or @flag(true)
+					p.UngetToken()
+					literal, err = p.parseLiteral(key)
+					if err != nil {
+						return nil, nil, err
+					}
+					continue
+				}
 				val, err := p.parseLiteralValue()
 				if err != nil {
 					return nil, nil, err
 				}
-				args = withTrait(args, tok.Text, val)
+				args = withTrait(args, key.Text, val)
+			} else if tok.Type == NUMBER || tok.Type == OPEN_BRACE {
+				literal, err = p.parseLiteral(tok)
+				if err != nil {
+					return nil, nil, err
+				}
 			} else if tok.Type == OPEN_BRACKET {
 				literal, err = p.parseLiteralArray()
 				if err != nil {
@@ -1417,8 +1824,16 @@ func (p *Parser) parseTrait(traits *data.Object) (*data.Object, error) {
 	if err != nil {
 		return traits, err
 	}
+	if _, ok := p.use[tname]; !ok && IsPreludeTrait(tname) {
+		//taken to be the prelude trait, unless the namespace defines one of the name, which is only known once the
+		//whole file is parsed
+		if p.preludeTraits == nil {
+			p.preludeTraits = make(map[string]bool, 0)
+		}
+		p.preludeTraits[tname] = true
+	}
 	switch tname {
-	case "idempotent", "required", "httpLabel", "httpPayload", "readonly", "box", "sensitive", "input", "output", "httpResponseCode":
+	case "idempotent", "required", "httpLabel", "httpPayload", "readonly", "box", "sensitive", "input", "output", "httpResponseCode", "notProperty":
 		return withTrait(traits, "smithy.api#"+tname, data.NewObject()), nil
 	case "documentation":
 		err := p.expect(OPEN_PAREN)
@@ -1433,9 +1848,9 @@ func (p *Parser) parseTrait(traits *data.Object) (*data.Object, error) {
 		if err != nil {
 			return traits, err
 		}
-		traits, _ = withCommentTrait(traits, s)
-		return traits, nil
-	case "httpQuery", "httpHeader", "error", "pattern", "title", "timestampFormat", "enumValue": //strings
+		//unlike a doc comment, the value of the trait is kept exactly as written
+		return withTrait(traits, "smithy.api#documentation", s), nil
+	case "httpQuery", "httpHeader", "error", "pattern", "title", "timestampFormat", "enumValue", "jsonName", "xmlName", "mediaType": //strings
 		err := p.expect(OPEN_PAREN)
 		if err != nil {
 			return traits, err
@@ -1449,6 +1864,20 @@ func (p *Parser) parseTrait(traits *data.Object) (*data.Object, error) {
 			return traits, err
 		}
 		return withTrait(traits, "smithy.api#"+tname, s), nil
+	case "default":
+		err := p.expect(OPEN_PAREN)
+		if err != nil {
+			return traits, err
+		}
+		val, err := p.parseLiteralValue()
+		if err != nil {
+			return traits, err
+		}
+		err = p.expect(CLOSE_PAREN)
+		if err != nil {
+			return traits, err
+		}
+		return withDefaultTrait(traits, val), nil
 	case "tags":
 		_, tags, err := p.parseTraitArgs()
 		return withTrait(traits, "smithy.api#tags", tags), err
@@ -1534,6 +1963,9 @@ func (p *Parser) parseTrait(traits *data.Object) (*data.Object, error) {
 			return traits, err
 		}
 		tid := p.ensureNamespaced(tname)
+		if _, ok := p.use[tname]; !ok && IsPreludeTrait(tname) {
+			tid = "smithy.api#" + tname
+		}
 		if lit != nil {
 			return withTrait(traits, tid, lit), nil
 		}
@@ -1541,6 +1973,35 @@ func (p *Parser) parseTrait(traits *data.Object) (*data.Object, error) {
 	}
 }
 
+// resolveLocalTraits changes the traits that were taken to be prelude traits to those of the namespace of the file, if
+// it defines traits of the same names, since a relative shape id resolves to a use statement, then the namespace, and
+// only then the prelude.
+func (p *Parser) resolveLocalTraits() {
+	for name := range p.preludeTraits {
+		local := p.namespace + "#" + name
+		if def := p.ast.GetShape(local); def == nil || !def.Traits.Has("smithy.api#trait") {
+			continue
+		}
+		rename := func(traits *data.Object) {
+			if traits != nil {
+				ObjectRename(traits, "smithy.api#"+name, local)
+			}
+		}
+		for _, id := range p.ast.Shapes.Keys() {
+			shape := p.ast.GetShape(id)
+			rename(shape.Traits)
+			for _, mid := range memberIds(id, shape) {
+				if mem := p.ast.getMember(mid); mem != nil {
+					rename(mem.Traits)
+				}
+			}
+		}
+		for _, a := range p.applied {
+			rename(a.traits)
+		}
+	}
+}
+
 func withTrait(traits *data.Object, key string, val interface{}) *data.Object {
 	if val != nil {
 		if traits == nil {
@@ -1551,6 +2012,15 @@ func withTrait(traits *data.Object, key string, val interface{}) *data.Object {
 	return traits
 }
 
+// withDefaultTrait is like withTrait, but keeps a null value, since @default(null) is meaningful.
+func withDefaultTrait(traits *data.Object, val interface{}) *data.Object {
+	if traits == nil {
+		traits = data.NewObject()
+	}
+	traits.Put("smithy.api#default", val)
+	return traits
+}
+
 func withCommentTrait(traits *data.Object, val string) (*data.Object, string) {
 	if val != "" {
 		val = TrimSpace(val)
@@ -1572,7 +2042,6 @@ func (p *Parser) parseLiteral(tok *Token) (interface{}, error) {
 	case SYMBOL:
 		return p.parseLiteralSymbol(tok)
 	case STRING:
-		//todo: string blocks, i.e. triple-quoted strings
 		return p.parseLiteralString(tok)
 	case NUMBER:
 		return p.parseLiteralNumber(tok)
@@ -1610,7 +2079,7 @@ func (p *Parser) parseLiteralNumber(tok *Token) (interface{}, error) {
 }
 
 func (p *Parser) parseLiteralArray() (interface{}, error) {
-	var ary []interface{}
+	ary := make([]interface{}, 0)
 	for {
 		tok := p.GetToken()
 		if tok == nil {
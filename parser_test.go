@@ -0,0 +1,61 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+	"testing"
+)
+
+// @enum traits that cannot be converted to enum shapes, and the parse error each one gets
+var badEnumTraits = []struct {
+	shape string
+	err   string
+}{
+	{`@enum(["A"]) string S`, "not an object"},
+	{`@enum([{name: "A"}]) string S`, "must be a non-empty string"},
+	{`@enum([{value: "a"}, {value: ""}]) string S`, "definition 1: its value must be a non-empty string"},
+	{`@enum([{value: "x", name: "X"}]) integer S`, "must be a number"},
+	{`@enum([{value: 3}]) integer S`, "must have names"},
+}
+
+func TestBadEnumTrait(t *testing.T) {
+	for _, tc := range badEnumTraits {
+		t.Run(tc.shape, func(t *testing.T) {
+			_, err := ParseString("$version: \"1.0\"\nnamespace test\n"+tc.shape+"\n", "test.smithy")
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if perr, ok := err.(*ParseError); !ok || !strings.Contains(perr.Message, tc.err) {
+				t.Errorf("Expected a parse error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestBadEnumTraitValidation(t *testing.T) {
+	ast, err := LoadASTBytes([]byte(`{"smithy": "1.0", "shapes": {"test#S": {"type": "string", "traits": {"smithy.api#enum": ["A"]}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast.UpgradeToV2()
+	if shape := ast.GetShape("test#S"); shape.Type != "string" {
+		t.Errorf("Expected the shape with a bad @enum trait to be left a string, but it is a %s", shape.Type)
+	}
+	if err := ast.Validate(); err == nil || !strings.Contains(err.Error(), "not an object") {
+		t.Errorf("Expected a validation error for the bad @enum trait, got %v", err)
+	}
+}
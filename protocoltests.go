@@ -0,0 +1,257 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+const HttpRequestTestsTrait = "smithy.test#httpRequestTests"
+const HttpResponseTestsTrait = "smithy.test#httpResponseTests"
+
+type ProtocolTestGenerator struct {
+	BaseGenerator
+}
+
+// Generate a Go test file per namespace from the smithy.test#httpRequestTests and smithy.test#httpResponseTests
+// traits on operations. The tests run against a live endpoint, taken from the SMITHY_TEST_ENDPOINT environment
+// variable when the tests run, or else the "endpoint" option given here. Request test cases are sent as-is and must
+// not be rejected. Response test cases send the operation's request, with URI labels filled in from the test params,
+// then check the status code, headers, and body. The "package" option sets the Go package name, which defaults to
+// the last component of the namespace.
+func (gen *ProtocolTestGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	for _, ns := range ast.Namespaces() {
+		if !gen.hasTests(ast, ns) {
+			continue
+		}
+		fname := strings.ReplaceAll(ns, ".", "_") + "_protocol_test.go"
+		sep := fmt.Sprintf("\n// ===== File(%q)\n\n", fname)
+		err := gen.Emit(gen.ToGoTests(ast, ns), fname, sep)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gen *ProtocolTestGenerator) hasTests(ast *AST, ns string) bool {
+	for _, k := range ast.Shapes.Keys() {
+		if shapeIdNamespace(k) == ns {
+			traits := ast.GetShape(k).Traits
+			if traits.Has(HttpRequestTestsTrait) || traits.Has(HttpResponseTestsTrait) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type protocolTestWriter struct {
+	buf    bytes.Buffer
+	writer *bufio.Writer
+	names  map[string]int
+}
+
+func (w *protocolTestWriter) Emit(format string, args ...interface{}) {
+	w.writer.WriteString(fmt.Sprintf(format, args...))
+}
+
+func (gen *ProtocolTestGenerator) ToGoTests(ast *AST, ns string) string {
+	pkg := gen.Config.GetString("package")
+	if pkg == "" {
+		lst := strings.Split(ns, ".")
+		pkg = strings.ToLower(lst[len(lst)-1])
+	}
+	endpoint := gen.Config.GetString("endpoint")
+	if endpoint == "" {
+		endpoint = "http://localhost:8080"
+	}
+	w := &protocolTestWriter{names: make(map[string]int, 0)}
+	w.writer = bufio.NewWriter(&w.buf)
+	w.Emit("// Code generated by smithy from the %s namespace. DO NOT EDIT.\n\n", ns)
+	w.Emit("package %s\n\n", pkg)
+	w.Emit("import (\n\t\"encoding/json\"\n\t\"io\"\n\t\"net/http\"\n\t\"os\"\n\t\"reflect\"\n\t\"strings\"\n\t\"testing\"\n)\n")
+	w.Emit(protocolTestSupport, endpoint)
+	for _, k := range ast.Shapes.Keys() {
+		if shapeIdNamespace(k) != ns {
+			continue
+		}
+		shape := ast.GetShape(k)
+		if shape.Type != "operation" {
+			continue
+		}
+		for _, tc := range shape.Traits.GetArray(HttpRequestTestsTrait) {
+			w.emitRequestTest(StripNamespace(k), data.AsObject(tc))
+		}
+		for _, tc := range shape.Traits.GetArray(HttpResponseTestsTrait) {
+			w.emitResponseTest(ast, StripNamespace(k), shape, data.AsObject(tc))
+		}
+	}
+	w.writer.Flush()
+	return w.buf.String()
+}
+
+const protocolTestSupport = `
+func testEndpoint() string {
+	if e := os.Getenv("SMITHY_TEST_ENDPOINT"); e != "" {
+		return strings.TrimRight(e, "/")
+	}
+	return %q
+}
+
+func doTestRequest(t *testing.T, method, uri string, query []string, headers map[string]string, body string) *http.Response {
+	url := testEndpoint() + uri
+	if len(query) > 0 {
+		sep := "?"
+		if strings.Contains(url, "?") {
+			sep = "&"
+		}
+		url = url + sep + strings.Join(query, "&")
+	}
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("cannot create request: %%v", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %%v", err)
+	}
+	return resp
+}
+
+func checkTestJson(t *testing.T, expected string, actual []byte) {
+	var e, a interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatalf("bad expected body: %%v", err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatalf("response body is not JSON: %%v", err)
+	}
+	if !reflect.DeepEqual(e, a) {
+		t.Errorf("body mismatch, expected %%s, got %%s", expected, string(actual))
+	}
+}
+`
+
+func (w *protocolTestWriter) testName(opName, kind, id string) string {
+	var b strings.Builder
+	for _, r := range id {
+		if IsSymbolChar(r, false) {
+			b.WriteRune(r)
+		}
+	}
+	name := "Test" + opName + kind + "_" + b.String()
+	if n := w.names[name]; n > 0 {
+		w.names[name] = n + 1
+		return fmt.Sprintf("%s_%d", name, n)
+	}
+	w.names[name] = 1
+	return name
+}
+
+func sortedStringMap(obj *data.Object) string {
+	var keys []string
+	for _, k := range obj.Keys() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var lst []string
+	for _, k := range keys {
+		lst = append(lst, fmt.Sprintf("%q: %q", k, obj.GetString(k)))
+	}
+	return "map[string]string{" + strings.Join(lst, ", ") + "}"
+}
+
+func (w *protocolTestWriter) emitRequestTest(opName string, tc *data.Object) {
+	w.Emit("\nfunc %s(t *testing.T) {\n", w.testName(opName, "Request", tc.GetString("id")))
+	if doc := tc.GetString("documentation"); doc != "" {
+		w.Emit("\t// %s\n", strings.ReplaceAll(doc, "\n", " "))
+	}
+	var query []string
+	for _, q := range tc.GetStringArray("queryParams") {
+		query = append(query, fmt.Sprintf("%q", q))
+	}
+	w.Emit("\tresp := doTestRequest(t, %q, %q, []string{%s}, %s, %q)\n", tc.GetString("method"), tc.GetString("uri"),
+		strings.Join(query, ", "), sortedStringMap(tc.GetObject("headers")), tc.GetString("body"))
+	w.Emit("\tdefer resp.Body.Close()\n")
+	w.Emit("\tif resp.StatusCode >= 400 {\n\t\tt.Errorf(\"request rejected with status %%d\", resp.StatusCode)\n\t}\n")
+	w.Emit("}\n")
+}
+
+func (w *protocolTestWriter) emitResponseTest(ast *AST, opName string, shape *Shape, tc *data.Object) {
+	w.Emit("\nfunc %s(t *testing.T) {\n", w.testName(opName, "Response", tc.GetString("id")))
+	if doc := tc.GetString("documentation"); doc != "" {
+		w.Emit("\t// %s\n", strings.ReplaceAll(doc, "\n", " "))
+	}
+	httpTrait := shape.Traits.GetObject("smithy.api#http")
+	if httpTrait == nil {
+		w.Emit("\tt.Skip(\"operation has no @http trait\")\n}\n")
+		return
+	}
+	params := tc.GetObject("params")
+	uri := httpTrait.GetString("uri")
+	for _, seg := range splitPath(uri) {
+		if strings.HasPrefix(seg, "{") {
+			label := strings.TrimSuffix(strings.Trim(seg, "{}"), "+")
+			if !params.Has(label) {
+				w.Emit("\tt.Skip(\"no value for URI label %s in the test params\")\n}\n", label)
+				return
+			}
+			uri = strings.ReplaceAll(uri, seg, protocolTestParam(params.Get(label)))
+		}
+	}
+	w.Emit("\tresp := doTestRequest(t, %q, %q, nil, nil, \"\")\n", httpTrait.GetString("method"), uri)
+	w.Emit("\tdefer resp.Body.Close()\n")
+	w.Emit("\tif resp.StatusCode != %d {\n\t\tt.Fatalf(\"expected status %d, got %%d\", resp.StatusCode)\n\t}\n", tc.GetInt("code"), tc.GetInt("code"))
+	headers := tc.GetObject("headers")
+	if headers.Length() > 0 {
+		w.Emit("\tfor k, v := range %s {\n", sortedStringMap(headers))
+		w.Emit("\t\tif resp.Header.Get(k) != v {\n\t\t\tt.Errorf(\"header %%s: expected %%q, got %%q\", k, v, resp.Header.Get(k))\n\t\t}\n\t}\n")
+	}
+	if body := tc.GetString("body"); body != "" && strings.Contains(tc.GetString("bodyMediaType"), "json") {
+		w.Emit("\tbody, err := io.ReadAll(resp.Body)\n\tif err != nil {\n\t\tt.Fatal(err)\n\t}\n")
+		w.Emit("\tcheckTestJson(t, %q, body)\n", body)
+	}
+	w.Emit("}\n")
+}
+
+func protocolTestParam(v interface{}) string {
+	switch p := v.(type) {
+	case *string:
+		return *p
+	case *data.Decimal:
+		return p.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+type PythonGenerator struct {
+	BaseGenerator
+}
+
+// Generate Python dataclasses for the data shapes in the model, one module per namespace. The "pydantic" option
+// produces pydantic models instead.
+func (gen *PythonGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	for _, ns := range ast.Namespaces() {
+		fname := pythonModule(ns) + ".py"
+		sep := fmt.Sprintf("\n# ===== File(%q)\n\n", fname)
+		s := gen.ToPython(ast, ns)
+		err := gen.Emit(s, fname, sep)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type PythonWriter struct {
+	buf       bytes.Buffer
+	writer    *bufio.Writer
+	namespace string
+	ast       *AST
+	pydantic  bool
+}
+
+func (gen *PythonGenerator) ToPython(ast *AST, ns string) string {
+	w := &PythonWriter{
+		namespace: ns,
+		ast:       ast,
+		pydantic:  gen.Config.GetBool("pydantic"),
+	}
+	w.buf.Reset()
+	w.writer = bufio.NewWriter(&w.buf)
+	w.Emit("# Generated from smithy source, namespace %s\n", ns)
+	w.Emit("from __future__ import annotations\n\n")
+	if w.pydantic {
+		w.Emit("from pydantic import BaseModel\n")
+	} else {
+		w.Emit("from dataclasses import dataclass\n")
+	}
+	w.Emit("from datetime import datetime\n")
+	w.Emit("from decimal import Decimal\n")
+	w.Emit("from enum import Enum, IntEnum\n")
+	w.Emit("from typing import Any, Dict, List, Optional\n")
+	nss, imports := externalShapeRefs(ast, ns)
+	if len(nss) > 0 {
+		w.Emit("\n")
+	}
+	for _, other := range nss {
+		w.Emit("from %s import %s\n", pythonModule(other), strings.Join(imports[other], ", "))
+	}
+	for _, k := range ast.Shapes.Keys() {
+		if shapeIdNamespace(k) == ns {
+			w.EmitShape(StripNamespace(k), ast.GetShape(k))
+		}
+	}
+	w.writer.Flush()
+	return w.buf.String()
+}
+
+func (w *PythonWriter) Emit(format string, args ...interface{}) {
+	w.writer.WriteString(fmt.Sprintf(format, args...))
+}
+
+func (w *PythonWriter) EmitShape(name string, shape *Shape) {
+	switch shape.Type {
+	case "service", "resource", "operation":
+		return
+	case "structure", "union":
+		w.EmitStructureShape(name, shape)
+	case "enum", "intEnum":
+		w.EmitEnumShape(name, shape)
+	case "list", "set":
+		w.Emit("\n\n%s = List[%s]\n", name, w.typeRef(shape.Member.Target, true))
+	case "map":
+		w.Emit("\n\n%s = Dict[str, %s]\n", name, w.typeRef(shape.Value.Target, true))
+	default:
+		w.Emit("\n\n%s = %s\n", name, pythonSimpleType(shape.Type))
+	}
+}
+
+func (w *PythonWriter) EmitDocstring(traits *data.Object, indent string) {
+	doc := traits.GetString("smithy.api#documentation")
+	if doc == "" {
+		return
+	}
+	doc = strings.ReplaceAll(doc, "\"\"\"", "\\\"\\\"\\\"")
+	lines := strings.Split(doc, "\n")
+	if len(lines) == 1 {
+		w.Emit("%s\"\"\"%s\"\"\"\n", indent, doc)
+		return
+	}
+	w.Emit("%s\"\"\"\n", indent)
+	for _, line := range lines {
+		w.Emit("%s\n", TrimRightSpace(indent+line))
+	}
+	w.Emit("%s\"\"\"\n", indent)
+}
+
+// Structure members are emitted with the required members first, since dataclass fields without defaults must
+// precede those with defaults. Union members are all optional, exactly one of them is expected to be set.
+func (w *PythonWriter) EmitStructureShape(name string, shape *Shape) {
+	if w.pydantic {
+		w.Emit("\n\nclass %s(BaseModel):\n", name)
+	} else {
+		w.Emit("\n\n@dataclass\nclass %s:\n", name)
+	}
+	w.EmitDocstring(shape.Traits, IndentAmount)
+	var required, optional []string
+	for _, k := range shape.Members.Keys() {
+		if shape.Type == "structure" && shape.Members.Get(k).Traits.Has("smithy.api#required") {
+			required = append(required, k)
+		} else {
+			optional = append(optional, k)
+		}
+	}
+	for _, k := range required {
+		w.Emit("%s%s: %s\n", IndentAmount, pythonIdentifier(k), w.typeRef(shape.Members.Get(k).Target, false))
+	}
+	for _, k := range optional {
+		w.Emit("%s%s: Optional[%s] = None\n", IndentAmount, pythonIdentifier(k), w.typeRef(shape.Members.Get(k).Target, false))
+	}
+	if shape.Members.Length() == 0 && shape.Traits.GetString("smithy.api#documentation") == "" {
+		w.Emit("%spass\n", IndentAmount)
+	}
+}
+
+func (w *PythonWriter) EmitEnumShape(name string, shape *Shape) {
+	if shape.Type == "intEnum" {
+		w.Emit("\n\nclass %s(IntEnum):\n", name)
+	} else {
+		w.Emit("\n\nclass %s(str, Enum):\n", name)
+	}
+	w.EmitDocstring(shape.Traits, IndentAmount)
+	for _, k := range shape.Members.Keys() {
+		v := shape.Members.Get(k).Traits.Get("smithy.api#enumValue")
+		if shape.Type == "intEnum" {
+			w.Emit("%s%s = %d\n", IndentAmount, pythonIdentifier(k), data.AsInt(v))
+		} else {
+			s := data.AsString(v)
+			if s == "" {
+				s = k
+			}
+			w.Emit("%s%s = %q\n", IndentAmount, pythonIdentifier(k), s)
+		}
+	}
+	if shape.Members.Length() == 0 {
+		w.Emit("%spass\n", IndentAmount)
+	}
+}
+
+// typeRef returns the python type for the target. Type aliases are evaluated at import time, so references to
+// model shapes must be quoted there to allow forward references.
+func (w *PythonWriter) typeRef(target string, quoted bool) string {
+	if shapeIdNamespace(target) == "smithy.api" {
+		switch StripNamespace(target) {
+		case "String":
+			return "str"
+		case "Boolean", "PrimitiveBoolean":
+			return "bool"
+		case "Float", "Double", "PrimitiveFloat", "PrimitiveDouble":
+			return "float"
+		case "BigDecimal":
+			return "Decimal"
+		case "Timestamp":
+			return "datetime"
+		case "Blob":
+			return "bytes"
+		case "Document", "Unit":
+			return "Any"
+		default:
+			return "int"
+		}
+	}
+	if quoted {
+		return fmt.Sprintf("%q", StripNamespace(target))
+	}
+	return StripNamespace(target)
+}
+
+// pythonModule returns the name of the module generated for the namespace.
+func pythonModule(ns string) string {
+	return strings.ReplaceAll(ns, ".", "_")
+}
+
+func pythonSimpleType(shapeType string) string {
+	switch shapeType {
+	case "string":
+		return "str"
+	case "boolean":
+		return "bool"
+	case "float", "double":
+		return "float"
+	case "bigDecimal":
+		return "Decimal"
+	case "timestamp":
+		return "datetime"
+	case "blob":
+		return "bytes"
+	case "document":
+		return "Any"
+	default:
+		return "int"
+	}
+}
+
+func pythonIdentifier(name string) string {
+	switch name {
+	case "False", "None", "True", "and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del",
+		"elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal",
+		"not", "or", "pass", "raise", "return", "try", "while", "with", "yield":
+		return name + "_"
+	}
+	return name
+}
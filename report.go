@@ -0,0 +1,121 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+
+	"github.com/boynton/data"
+)
+
+type ReportGenerator struct {
+	BaseGenerator
+}
+
+// Generate a summary of the model for governance purposes: the number of shapes of each type, the number of
+// operations per service, and the shapes that are undocumented or deprecated. The "format" option selects
+// "csv" (the default) or "json".
+func (gen *ReportGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	report := gen.Report(ast)
+	switch format := config.GetString("format"); format {
+	case "", "csv":
+		s, err := gen.ToCsv(report)
+		if err != nil {
+			return err
+		}
+		return gen.Emit(s, "report.csv", "")
+	case "json":
+		return gen.Emit(data.Pretty(report), "report.json", "")
+	default:
+		return fmt.Errorf("Unsupported report format: %q", format)
+	}
+}
+
+func (gen *ReportGenerator) Report(ast *AST) *data.Object {
+	counts := make(map[string]int, 0)
+	services := data.NewObject()
+	var undocumented []string
+	deprecated := data.NewObject()
+	for _, k := range ast.Shapes.Keys() {
+		shape := ast.GetShape(k)
+		counts[shape.Type] = counts[shape.Type] + 1
+		if !shape.Traits.Has("smithy.api#documentation") {
+			undocumented = append(undocumented, k)
+		}
+		if shape.Traits.Has("smithy.api#deprecated") {
+			deprecated.Put(k, data.AsString(ObjectGetPath(shape.Traits, "smithy.api#deprecated.message")))
+		}
+		if shape.Type == "service" {
+			included := make(map[string]bool, 0)
+			ast.noteDependencies(included, k)
+			n := 0
+			for id := range included {
+				if s := ast.GetShape(id); s != nil && s.Type == "operation" {
+					n++
+				}
+			}
+			services.Put(k, n)
+		}
+	}
+	var types []string
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	shapeTypes := data.NewObject()
+	for _, t := range types {
+		shapeTypes.Put(t, counts[t])
+	}
+	report := data.NewObject()
+	report.Put("shapeTypes", shapeTypes)
+	report.Put("services", services)
+	if undocumented == nil {
+		undocumented = make([]string, 0)
+	}
+	report.Put("undocumented", undocumented)
+	report.Put("deprecated", deprecated)
+	return report
+}
+
+func (gen *ReportGenerator) ToCsv(report *data.Object) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"section", "name", "value"})
+	shapeTypes := report.GetObject("shapeTypes")
+	for _, k := range shapeTypes.Keys() {
+		w.Write([]string{"shapeType", k, fmt.Sprint(shapeTypes.Get(k))})
+	}
+	services := report.GetObject("services")
+	for _, k := range services.Keys() {
+		w.Write([]string{"serviceOperations", k, fmt.Sprint(services.Get(k))})
+	}
+	for _, k := range report.Get("undocumented").([]string) {
+		w.Write([]string{"undocumented", k, ""})
+	}
+	deprecated := report.GetObject("deprecated")
+	for _, k := range deprecated.Keys() {
+		w.Write([]string{"deprecated", k, deprecated.GetString(k)})
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
@@ -17,8 +17,8 @@ package smithy
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/boynton/data"
@@ -28,11 +28,18 @@ type SadlGenerator struct {
 	BaseGenerator
 }
 
+// Generate SADL for the namespace given by the "namespace" option. SADL has no use statements, so shape ids are
+// written without their namespaces, unless the "shapeIds" option is "local", to strip only that namespace, or
+// "absolute".
 func (gen *SadlGenerator) Generate(ast *AST, config *data.Object) error {
 	err := gen.Configure(config)
 	if err != nil {
 		return err
 	}
+	err = checkShapeIdsOption(config.GetString("shapeIds"))
+	if err != nil {
+		return err
+	}
 	ns := config.GetString("namespace")
 	fbase := ns
 	if fbase == "" {
@@ -43,25 +50,30 @@ func (gen *SadlGenerator) Generate(ast *AST, config *data.Object) error {
 	if err != nil {
 		return err
 	}
-	s := gen.ToSadl(ns, ast)
-	return gen.Emit(s, fname, "")
+	return gen.EmitTo(fname, "", func(out io.Writer) error {
+		return gen.WriteSadl(out, ns, ast)
+	})
 }
 
 func (gen *SadlGenerator) Validate(ns string, ast *AST) error {
+	for _, ev := range HttpBindingValidator.Validate(ast) {
+		if ev.failed() {
+			return fmt.Errorf("%s", ev.Message)
+		}
+	}
 	for _, nsk := range ast.Shapes.Keys() {
 		shape := ast.GetShape(nsk)
 		if shape == nil {
 			return fmt.Errorf("Undefined shape: %s\n", nsk)
 		}
-		lst := strings.Split(nsk, "#")
-		k := lst[1]
+		id := ShapeID(nsk)
 		if shape.Type == "operation" {
-			err := gen.validateOperation(lst[0], k, shape, ast)
+			err := gen.validateOperation(id.Namespace(), id.Name(), shape, ast)
 			if err != nil {
 				return err
 			}
 		} else {
-			err := gen.validateType(lst[0], k, shape, ast)
+			err := gen.validateType(id.Namespace(), id.Name(), shape, ast)
 			if err != nil {
 				return err
 			}
@@ -73,7 +85,9 @@ func (gen *SadlGenerator) Validate(ns string, ast *AST) error {
 func (gen *SadlGenerator) validateType(ns, n string, shape *Shape, ast *AST) error {
 	switch shape.Type {
 	case "intEnum":
-		return fmt.Errorf("intEnum not supported by SADL: %s#%s", ns, n)
+		if !gen.Config.GetBool("downgrade-intEnum") {
+			return fmt.Errorf("intEnum not supported by SADL: %s#%s (the downgrade-intEnum option makes it an Int32)", ns, n)
+		}
 	}
 	return nil
 }
@@ -97,16 +111,12 @@ func (gen *SadlGenerator) validateOperation(ns, n string, shape *Shape, ast *AST
 			v := inShape.Members.Get(k)
 			if v.Traits != nil {
 				if v.Traits.Has("smithy.api#httpPayload") {
-					if inputPayload {
-						return fmt.Errorf("More than one @httpPayload specified in the input for operation %s", fullName)
-					}
 					inputPayload = true
 					isPayload = true
 				} else if v.Traits.Has("smithy.api#httpHeader") {
 					//check header value
 					isHeader = true
 				} else if v.Traits.Has("smithy.api#httpLabel") {
-					//check that label is present in path template
 					isLabel = true
 				} else if v.Traits.Has("smithy.api#httpQuery") {
 					isQuery = true
@@ -138,9 +148,6 @@ func (gen *SadlGenerator) validateOperation(ns, n string, shape *Shape, ast *AST
 			v := outShape.Members.Get(k)
 			if v.Traits != nil {
 				if v.Traits.Has("smithy.api#httpPayload") {
-					if outputPayload {
-						return fmt.Errorf("More than one @httpPayload specified in output for operation %s", fullName)
-					}
 					outputPayload = true
 				} else if v.Traits.Has("smithy.api#httpResponseCode") {
 					//
@@ -163,23 +170,34 @@ func (gen *SadlGenerator) validateOperation(ns, n string, shape *Shape, ast *AST
 }
 
 type SadlWriter struct {
-	buf       bytes.Buffer
 	writer    *bufio.Writer
 	namespace string
 	name      string
 	ast       *AST
 	config    *data.Object
+	shapeIds  string
+	logger    Logger
 }
 
 func (gen *SadlGenerator) ToSadl(ns string, ast *AST) string {
+	var sb strings.Builder
+	gen.WriteSadl(&sb, ns, ast)
+	return sb.String()
+}
+
+// WriteSadl writes the SADL for the namespace to the writer as it is generated, rather than building it in memory
+// first, as ToSadl does.
+func (gen *SadlGenerator) WriteSadl(out io.Writer, ns string, ast *AST) error {
 	w := &SadlWriter{
 		namespace: ns,
 		ast:       ast,
 		config:    gen.Config,
+		shapeIds:  gen.Config.GetString("shapeIds"),
+		logger:    orNopLogger(gen.Logger),
 	}
 	emitted := make(map[string]bool, 0)
 
-	w.Begin()
+	w.Begin(out)
 	w.Emit("/* Generated from smithy source */\n")
 	if ns != "" {
 		w.Emit("\nnamespace %s\n", ns)
@@ -190,24 +208,21 @@ func (gen *SadlGenerator) ToSadl(ns string, ast *AST) string {
 	w.Emit("\n")
 
 	for _, nsk := range ast.Shapes.Keys() {
-		lst := strings.Split(nsk, "#")
 		shape := ast.GetShape(nsk)
-		k := lst[1]
+		k := ShapeID(nsk).Name()
 		if shape.Type == "operation" {
 			w.EmitShape(k, shape)
 			emitted[k] = true
 			if shape.Input != nil {
 				it := w.shapeRefToTypeRef(shape.Input.Target)
-				lst := strings.Split(it, "#")
-				ki := lst[1]
+				ki := ShapeID(it).Name()
 				if vi := ast.GetShape(it); vi != nil {
 					emitted[ki] = true
 				}
 			}
 			if shape.Output != nil {
 				ot := w.shapeRefToTypeRef(shape.Output.Target)
-				lst := strings.Split(ot, "#")
-				ko := lst[1]
+				ko := ShapeID(ot).Name()
 				if vo := ast.GetShape(ot); vo != nil {
 					emitted[ko] = true
 				}
@@ -215,8 +230,7 @@ func (gen *SadlGenerator) ToSadl(ns string, ast *AST) string {
 		}
 	}
 	for _, nsk := range ast.Shapes.Keys() {
-		lst := strings.Split(nsk, "#")
-		k := lst[1]
+		k := ShapeID(nsk).Name()
 		if !emitted[k] {
 			w.EmitShape(k, ast.GetShape(nsk))
 		}
@@ -234,9 +248,8 @@ func (gen *SadlGenerator) ToSadl(ns string, ast *AST) string {
 	return w.End()
 }
 
-func (w *SadlWriter) Begin() {
-	w.buf.Reset()
-	w.writer = bufio.NewWriter(&w.buf)
+func (w *SadlWriter) Begin(out io.Writer) {
+	w.writer = bufio.NewWriter(out)
 }
 
 func (w *SadlWriter) Emit(format string, args ...interface{}) {
@@ -284,8 +297,10 @@ func (w *SadlWriter) EmitShape(name string, shape *Shape) {
 		w.EmitUnionShape(name, shape)
 	case "enum":
 		w.EmitEnumShape(name, shape)
+	case "intenum":
+		w.EmitIntEnumShape(name, shape)
 	case "resource":
-		//no equivalent in SADL at the moment
+		w.EmitResourceShape(name, shape)
 	case "operation":
 		w.EmitOperationShape(name, shape, opts)
 	default:
@@ -298,6 +313,9 @@ func (w *SadlWriter) EmitShapeComment(shape *Shape) {
 	if comment != "" {
 		w.Emit(FormatComment("", "// ", comment, 100, true))
 	}
+	if shape.source != "" {
+		w.Emit("// %s%s\n", sourceAnnotationPrefix, shape.source)
+	}
 }
 
 func (w *SadlWriter) EmitEnumShape(name string, shape *Shape) {
@@ -311,6 +329,92 @@ func (w *SadlWriter) EmitEnumShape(name string, shape *Shape) {
 	w.Emit("}\n")
 }
 
+// EmitResourceShape emits the resource, which SADL has no equivalent for, as a comment. The http operations bound to
+// it are annotated with it when the "annotate" option is set (see resourceAnnotations).
+func (w *SadlWriter) EmitResourceShape(name string, shape *Shape) {
+	w.EmitShapeComment(shape)
+	w.Emit("// resource %s\n", name)
+	refs := func(label string, named map[string]*ShapeRef) {
+		if len(named) > 0 {
+			var lst []string
+			for _, k := range sortedIdentifierNames(named) {
+				lst = append(lst, k+" "+w.stripNamespace(w.shapeRefToTypeRef(named[k].Target)))
+			}
+			w.Emit("//   %s: %s\n", label, strings.Join(lst, ", "))
+		}
+	}
+	refs("identifiers", shape.Identifiers)
+	refs("properties", shape.Properties)
+	for _, b := range resourceBindings(shape) {
+		w.Emit("//   %s: %s\n", b.lifecycle, w.stripNamespace(b.target))
+	}
+	for _, r := range shape.Resources {
+		w.Emit("//   resource: %s\n", w.stripNamespace(r.Target))
+	}
+}
+
+type resourceBinding struct {
+	lifecycle string //create, put, read, update, delete, list, or operation or collectionOperation
+	target    string
+}
+
+func resourceBindings(shape *Shape) []resourceBinding {
+	var bindings []resourceBinding
+	for _, b := range []struct {
+		lifecycle string
+		ref       *ShapeRef
+	}{{"create", shape.Create}, {"put", shape.Put}, {"read", shape.Read}, {"update", shape.Update}, {"delete", shape.Delete}, {"list", shape.List}} {
+		if b.ref != nil {
+			bindings = append(bindings, resourceBinding{b.lifecycle, b.ref.Target})
+		}
+	}
+	for _, ref := range shape.Operations {
+		bindings = append(bindings, resourceBinding{"operation", ref.Target})
+	}
+	for _, ref := range shape.CollectionOperations {
+		bindings = append(bindings, resourceBinding{"collectionOperation", ref.Target})
+	}
+	return bindings
+}
+
+// resourceAnnotations returns the annotations for an operation bound to a resource: the resource, how the operation
+// is bound to it, and its identifiers, i.e. x_resource="Thing", x_lifecycle="read", x_identifiers="id=String".
+func (w *SadlWriter) resourceAnnotations(opName string) []string {
+	for _, id := range w.ast.Shapes.Keys() {
+		shape := w.ast.GetShape(id)
+		if shape.Type != "resource" {
+			continue
+		}
+		for _, b := range resourceBindings(shape) {
+			if ShapeID(b.target).Name() != opName {
+				continue
+			}
+			opts := []string{fmt.Sprintf("x_resource=%q", ShapeID(id).Name()), fmt.Sprintf("x_lifecycle=%q", b.lifecycle)}
+			if len(shape.Identifiers) > 0 {
+				var lst []string
+				for _, k := range sortedIdentifierNames(shape.Identifiers) {
+					lst = append(lst, k+"="+w.stripNamespace(w.shapeRefToTypeRef(shape.Identifiers[k].Target)))
+				}
+				opts = append(opts, fmt.Sprintf("x_identifiers=%q", strings.Join(lst, ",")))
+			}
+			return opts
+		}
+	}
+	return nil
+}
+
+// EmitIntEnumShape emits the intEnum, which SADL does not have, as an Int32 with its values in an x_enum annotation,
+// i.e. x_enum="LOW=1,HIGH=10".
+func (w *SadlWriter) EmitIntEnumShape(name string, shape *Shape) {
+	w.EmitShapeComment(shape)
+	var values []string
+	for _, k := range shape.Members.Keys() {
+		v := shape.Members.Get(k).Traits.Get("smithy.api#enumValue")
+		values = append(values, fmt.Sprintf("%s=%d", k, data.AsInt(v)))
+	}
+	w.Emit("type %s Int32%s\n", name, w.annotationString([]string{fmt.Sprintf("x_enum=%q", strings.Join(values, ","))}))
+}
+
 func (w *SadlWriter) EmitBooleanShape(name string, shape *Shape) {
 	opt := ""
 	w.EmitShapeComment(shape)
@@ -428,6 +532,9 @@ func (w *SadlWriter) EmitOperationShape(name string, shape *Shape, opts []string
 	}
 
 	opts = append(opts, fmt.Sprintf("operation=%s", Uncapitalize(name)))
+	if w.config.GetBool("annotate") {
+		opts = append(opts, w.resourceAnnotations(name)...)
+	}
 	sopts := "(" + strings.Join(opts, ", ") + ")"
 	queryParams := ""
 	var inShape *Shape
@@ -524,8 +631,8 @@ func (w *SadlWriter) EmitOperationShape(name string, shape *Shape, opts []string
 		for _, errType := range shape.Errors {
 			errShape := w.ast.GetShape(errType.Target)
 			if errShape == nil {
-				fmt.Println(data.Pretty(errType))
-				panic("whoops, no error?")
+				w.logger.Warn("Undefined error shape, not written", "operation", name, "error", errType.Target)
+				continue
 			}
 			errCode := errShape.Traits.GetInt("smithy.api#httpError")
 			if errCode != 0 {
@@ -547,17 +654,17 @@ func (w *SadlWriter) EmitExample(shape *Shape, obj *data.Object) {
 		er := obj.GetObject("error")
 		respType := w.stripNamespace(er.GetString("shapeId"))
 		w.Emit("\nexample %s (name=%s) ", respType, opName)
-		w.Emit(data.Pretty(er.GetObject("error")))
-	} else {
+		w.Emit(data.Pretty(er.GetObject("content")))
+	} else if obj.Has("output") {
 		respType := w.stripNamespace(shape.Output.Target)
 		w.Emit("\nexample %s (name=%s) ", respType, opName)
 		w.Emit(data.Pretty(obj.GetObject("output")))
 	}
 }
 
-func (w *SadlWriter) End() string {
-	w.writer.Flush()
-	return w.buf.String()
+// End flushes the output, returning the first error there was in writing it.
+func (w *SadlWriter) End() error {
+	return w.writer.Flush()
 }
 
 /*
@@ -578,26 +685,14 @@ func (w *SadlWriter) End() string {
 */
 
 func (w *SadlWriter) stripNamespace(id string) string {
-	//fixme: just totally ignore it for now
-	n := strings.Index(id, "#")
-	if n < 0 {
+	ns := ShapeID(id).Namespace()
+	switch {
+	case !strings.Contains(id, "#") || w.shapeIds == "absolute":
 		return id
-	} else {
-		return id[n+1:]
-	}
-	/*
-		match := w.namespace + "#"
-		if strings.HasPrefix(id, match) {
-			return id[len(match):]
-		}
-		if strings.HasPrefix(id, "smithy.api") {
-			n := strings.Index(id, "#")
-			if n >= 0 {
-				return id[n+1:]
-			}
-		}
+	case w.shapeIds == "local" && ns != w.namespace:
 		return id
-	*/
+	}
+	return strings.TrimPrefix(id, ns+"#")
 }
 
 func (w *SadlWriter) formatBlockComment(indent string, comment string) {
@@ -0,0 +1,750 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// ParseSadl reads a SADL file into a Smithy AST. Types become shapes, and http actions become operations of a
+// single service, named by the "name" directive, or else after the namespace.
+func ParseSadl(path string) (*AST, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSadlString(path, string(b))
+}
+
+func parseSadlString(path string, src string) (*AST, error) {
+	p := &SadlParser{
+		Parser: Parser{
+			scanner: newStringScanner(src),
+			path:    path,
+			source:  src,
+			logger:  orNopLogger(nil),
+		},
+		aliases: make(map[string]*sadlAlias, 0),
+		errors:  make(map[string]int, 0),
+	}
+	p.wd, _ = os.Getwd()
+	err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return p.ast, nil
+}
+
+type SadlParser struct {
+	Parser
+	serviceName string
+	version     string
+	base        string
+	operations  []*ShapeRef
+	aliases     map[string]*sadlAlias //types defined as another user-defined type
+	errors      map[string]int        //the HTTP status of each type used in an "except" clause
+	examples    []*sadlExample
+}
+
+type sadlAlias struct {
+	target string
+	traits *data.Object
+}
+
+type sadlExample struct {
+	target string
+	name   string
+	value  interface{}
+}
+
+func (p *SadlParser) Parse() error {
+	var comment string
+	p.ast = &AST{
+		Smithy: "2",
+	}
+	for {
+		var err error
+		tok := p.GetToken()
+		if tok == nil {
+			break
+		}
+		switch tok.Type {
+		case SYMBOL:
+			switch tok.Text {
+			case "name":
+				p.serviceName, err = p.ExpectIdentifier()
+			case "namespace":
+				p.namespace, err = p.expectNamespacedIdentifier()
+			case "version":
+				p.version, err = p.expectText()
+				if err != nil {
+					p.UngetToken()
+					var n *data.Decimal
+					n, err = p.ExpectNumber()
+					if err == nil {
+						p.version = n.String()
+					}
+				}
+			case "base":
+				p.base, err = p.ExpectString()
+			case "type":
+				err = p.parseTypeDef(comment)
+			case "http":
+				err = p.parseHttp(comment)
+			case "example":
+				err = p.parseExample()
+			default:
+				err = p.Error(fmt.Sprintf("Unsupported SADL statement: %s", tok.Text))
+			}
+			comment = ""
+		case LINE_COMMENT:
+			comment = p.MergeComment(comment, tok.Text)
+		case SEMICOLON, NEWLINE:
+			/* ignore */
+		default:
+			return p.SyntaxError()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return p.finish()
+}
+
+func (p *SadlParser) id(name string) string {
+	ns := p.namespace
+	if ns == "" {
+		ns = UnspecifiedNamespace
+	}
+	return ns + "#" + name
+}
+
+// peek returns the next token without consuming it, or nil at the end of the file.
+func (p *SadlParser) peek() *Token {
+	tok := p.GetToken()
+	if tok != nil {
+		p.UngetToken()
+	}
+	return tok
+}
+
+func (p *SadlParser) parseTypeDef(comment string) error {
+	name, err := p.ExpectIdentifier()
+	if err != nil {
+		return err
+	}
+	if p.ast.GetShape(p.id(name)) != nil {
+		return p.Error(fmt.Sprintf("Duplicate type: %s", name))
+	}
+	_, _, err = p.parseTypeSpec(name, true, comment)
+	return err
+}
+
+// parseTypeSpec parses a type reference along with its parameters, options, and body. Definitions, and inline types
+// that need a shape of their own, are defined with the given name. For inline types, the options are returned so
+// that the caller can apply them to the member.
+func (p *SadlParser) parseTypeSpec(name string, define bool, comment string) (string, *data.Object, error) {
+	base, err := p.ExpectIdentifier()
+	if err != nil {
+		return "", nil, err
+	}
+	var params []string
+	if tok := p.peek(); tok != nil && tok.Type == OPEN_ANGLE {
+		p.GetToken()
+		suffixes := []string{"Item", "Value"}
+		if base == "Map" {
+			suffixes = []string{"Key", "Value"}
+		}
+		for {
+			suffix := suffixes[len(params)%2]
+			param, _, err := p.parseTypeSpec(name+suffix, false, "")
+			if err != nil {
+				return "", nil, err
+			}
+			params = append(params, param)
+			tok := p.GetToken()
+			if tok == nil {
+				return "", nil, p.EndOfFileError()
+			}
+			if tok.Type == CLOSE_ANGLE {
+				break
+			}
+			if tok.Type != COMMA {
+				return "", nil, p.SyntaxError()
+			}
+		}
+	}
+	opts := data.NewObject()
+	if tok := p.peek(); tok != nil && tok.Type == OPEN_PAREN {
+		p.GetToken()
+		opts, err = p.parseOptions()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	shape := &Shape{}
+	switch base {
+	case "Struct", "Union", "Enum":
+		shape.Type = map[string]string{"Struct": "structure", "Union": "union", "Enum": "enum"}[base]
+		shape.Members = NewMembers()
+		p.ast.PutShape(p.id(name), shape) //before any inline member types
+		if tok := p.peek(); tok != nil && tok.Type == OPEN_BRACE {
+			p.GetToken()
+			err = p.parseMembers(name, shape)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+	case "Array", "List":
+		shape.Type = "list"
+		shape.Member = &Member{Target: "smithy.api#Document"}
+		if len(params) > 0 {
+			shape.Member.Target = params[0]
+		}
+	case "Map":
+		if len(params) != 2 {
+			return "", nil, p.Error("Map requires key and value types")
+		}
+		shape.Type = "map"
+		shape.Key = &Member{Target: params[0]}
+		shape.Value = &Member{Target: params[1]}
+	default:
+		prim := sadlPreludeType(base)
+		if prim == "" {
+			target := p.id(base)
+			if !define {
+				return target, opts, nil
+			}
+			traits, _ := withCommentTrait(nil, comment)
+			p.aliases[p.id(name)] = &sadlAlias{target: target, traits: sadlTraits(traits, opts)}
+			p.ast.PutShape(p.id(name), shape)
+			return p.id(name), nil, nil
+		}
+		if values := opts.GetArray("values"); values != nil && prim == "String" {
+			shape.Type = "enum"
+			shape.Members = NewMembers()
+			for _, v := range values {
+				s := data.AsString(v)
+				var mtraits *data.Object
+				mname := enumMemberName(s)
+				if mname != s {
+					mtraits = withTrait(mtraits, "smithy.api#enumValue", s)
+				}
+				shape.Members.Put(mname, &Member{Target: "smithy.api#Unit", Traits: mtraits})
+			}
+		} else if !define {
+			return "smithy.api#" + prim, opts, nil
+		} else {
+			shape.Type = Uncapitalize(prim)
+		}
+	}
+	id := p.id(name)
+	shape.Traits, _ = withCommentTrait(nil, comment)
+	p.ast.PutShape(id, shape)
+	if define {
+		shape.Traits = sadlTraits(shape.Traits, opts)
+		return id, nil, nil
+	}
+	return id, opts, nil
+}
+
+func sadlPreludeType(name string) string {
+	switch name {
+	case "Bool":
+		return "Boolean"
+	case "Int8":
+		return "Byte"
+	case "Int16":
+		return "Short"
+	case "Int32":
+		return "Integer"
+	case "Int64":
+		return "Long"
+	case "Float32":
+		return "Float"
+	case "Float64":
+		return "Double"
+	case "Decimal":
+		return "BigDecimal"
+	case "Bytes":
+		return "Blob"
+	case "String", "UUID":
+		return "String"
+	case "Timestamp":
+		return "Timestamp"
+	case "Any":
+		return "Document"
+	}
+	return ""
+}
+
+// parseOptions parses a parenthesized option list, i.e. "(required, min=0, pattern="[a-z]+")". Options without
+// a value are true.
+func (p *SadlParser) parseOptions() (*data.Object, error) {
+	opts := data.NewObject()
+	for {
+		tok := p.GetToken()
+		if tok == nil {
+			return nil, p.EndOfFileError()
+		}
+		switch tok.Type {
+		case CLOSE_PAREN:
+			return opts, nil
+		case COMMA, NEWLINE:
+			continue
+		case SYMBOL:
+			key := tok.Text
+			if next := p.peek(); next == nil || next.Type != EQUALS {
+				opts.Put(key, true)
+				continue
+			}
+			p.GetToken()
+			val := p.GetToken()
+			if val == nil {
+				return nil, p.EndOfFileError()
+			}
+			if val.Type == SYMBOL && val.Text != "true" && val.Text != "false" && val.Text != "null" {
+				opts.Put(key, val.Text)
+				continue
+			}
+			v, err := p.parseLiteral(val)
+			if err != nil {
+				return nil, err
+			}
+			opts.Put(key, v)
+		default:
+			return nil, p.SyntaxError()
+		}
+	}
+}
+
+// sadlTraits converts the SADL options that have a Smithy equivalent to traits. Others are ignored.
+func sadlTraits(traits *data.Object, opts *data.Object) *data.Object {
+	for _, k := range opts.Keys() {
+		v := opts.Get(k)
+		switch k {
+		case "required":
+			traits = withTrait(traits, "smithy.api#required", data.NewObject())
+		case "min", "max":
+			rng := traits.GetObject("smithy.api#range")
+			if rng == nil {
+				rng = data.NewObject()
+			}
+			rng.Put(k, data.AsDecimal(v))
+			traits = withTrait(traits, "smithy.api#range", rng)
+		case "minsize", "maxsize":
+			length := traits.GetObject("smithy.api#length")
+			if length == nil {
+				length = data.NewObject()
+			}
+			length.Put(strings.TrimSuffix(k, "size"), data.AsInt(v))
+			traits = withTrait(traits, "smithy.api#length", length)
+		case "pattern":
+			traits = withTrait(traits, "smithy.api#pattern", data.AsString(v))
+		case "x_deprecated":
+			dep := data.NewObject()
+			if msg := data.AsString(v); msg != "" {
+				dep.Put("message", msg)
+			}
+			traits = withTrait(traits, "smithy.api#deprecated", dep)
+		case "x_tags":
+			traits = withTrait(traits, "smithy.api#tags", strings.Split(data.AsString(v), ","))
+		case "x_timestampFormat":
+			traits = withTrait(traits, "smithy.api#timestampFormat", data.AsString(v))
+		}
+	}
+	return traits
+}
+
+// parseMembers parses the body of a Struct, Union, or Enum. Union variants may be given as just a type name, in
+// which case the variant is named after the type.
+func (p *SadlParser) parseMembers(name string, shape *Shape) error {
+	comment := ""
+	for {
+		tok := p.GetToken()
+		if tok == nil {
+			return p.EndOfFileError()
+		}
+		switch tok.Type {
+		case CLOSE_BRACE:
+			return nil
+		case NEWLINE, COMMA:
+			continue
+		case LINE_COMMENT:
+			comment = p.MergeComment(comment, tok.Text)
+			continue
+		case SYMBOL:
+		default:
+			return p.SyntaxError()
+		}
+		mname := tok.Text
+		target := "smithy.api#Unit"
+		opts := data.NewObject()
+		var err error
+		if shape.Type == "enum" {
+			if next := p.peek(); next != nil && next.Type == OPEN_PAREN {
+				p.GetToken()
+				_, err = p.parseOptions()
+			}
+		} else if next := p.peek(); shape.Type == "union" && (next == nil || next.Type != SYMBOL) {
+			target = p.id(mname)
+			if prim := sadlPreludeType(mname); prim != "" {
+				target = "smithy.api#" + prim
+			}
+			mname = Uncapitalize(mname)
+		} else {
+			target, opts, err = p.parseTypeSpec(name+Capitalize(mname), false, "")
+		}
+		if err != nil {
+			return err
+		}
+		comment = p.trailingComment(comment)
+		traits, _ := withCommentTrait(nil, comment)
+		shape.Members.Put(mname, &Member{
+			Target: target,
+			Traits: sadlTraits(traits, opts),
+		})
+		comment = ""
+	}
+}
+
+// trailingComment merges a comment at the end of the current line, if any.
+func (p *SadlParser) trailingComment(comment string) string {
+	if tok := p.peek(); tok != nil && tok.Type == LINE_COMMENT {
+		p.GetToken()
+		return p.MergeComment(comment, tok.Text)
+	}
+	return comment
+}
+
+// parseHttp parses an http action, i.e. `http GET "/items/{id}?limit={limit}" (operation=getItem) { ... }`. Inputs
+// that are neither path labels, query parameters, nor headers are the payload.
+func (p *SadlParser) parseHttp(comment string) error {
+	method, err := p.ExpectIdentifier()
+	if err != nil {
+		return err
+	}
+	method = strings.ToUpper(method)
+	uri, err := p.ExpectString()
+	if err != nil {
+		return err
+	}
+	opts := data.NewObject()
+	if tok := p.peek(); tok != nil && tok.Type == OPEN_PAREN {
+		p.GetToken()
+		opts, err = p.parseOptions()
+		if err != nil {
+			return err
+		}
+	}
+	err = p.expect(OPEN_BRACE)
+	if err != nil {
+		return err
+	}
+	path := uri
+	query := make(map[string]string, 0)
+	if i := strings.Index(uri, "?"); i >= 0 {
+		path = uri[:i]
+		for _, q := range strings.Split(uri[i+1:], "&") {
+			kv := strings.SplitN(q, "=", 2)
+			if len(kv) == 2 {
+				query[strings.Trim(kv[1], "{}")] = kv[0]
+			}
+		}
+	}
+	name := Capitalize(data.AsString(opts.Get("operation")))
+	if name == "" {
+		name = openApiSymbol(strings.ToLower(method)+" "+path, true)
+	}
+	id := p.id(name)
+	if p.ast.GetShape(id) != nil {
+		return p.Error(fmt.Sprintf("Duplicate operation: %s", name))
+	}
+	shape := &Shape{Type: "operation"}
+	shape.Traits, _ = withCommentTrait(nil, comment)
+	switch method {
+	case "GET", "HEAD":
+		shape.Traits = withTrait(shape.Traits, "smithy.api#readonly", data.NewObject())
+	case "PUT", "DELETE":
+		shape.Traits = withTrait(shape.Traits, "smithy.api#idempotent", data.NewObject())
+	}
+	shape.Traits = sadlTraits(shape.Traits, opts)
+	p.ast.PutShape(id, shape)
+	p.operations = append(p.operations, &ShapeRef{Target: id})
+	input := &Shape{Type: "structure", Members: NewMembers()}
+	var output *Shape
+	code := 200
+	comment = ""
+	for {
+		tok := p.GetToken()
+		if tok == nil {
+			return p.EndOfFileError()
+		}
+		switch tok.Type {
+		case CLOSE_BRACE:
+			http := data.NewObject()
+			http.Put("method", method)
+			http.Put("uri", p.base+path)
+			http.Put("code", code)
+			shape.Traits = withTrait(shape.Traits, "smithy.api#http", http)
+			if input.Members.Length() > 0 {
+				p.ast.PutShape(id+"Input", input)
+				shape.Input = &ShapeRef{Target: id + "Input"}
+			}
+			if output != nil && output.Members.Length() > 0 {
+				p.ast.PutShape(id+"Output", output)
+				shape.Output = &ShapeRef{Target: id + "Output"}
+			}
+			return nil
+		case NEWLINE, COMMA:
+			continue
+		case LINE_COMMENT:
+			comment = p.MergeComment(comment, tok.Text)
+			continue
+		case SYMBOL:
+		default:
+			return p.SyntaxError()
+		}
+		switch tok.Text {
+		case "expect":
+			code, err = p.ExpectInt()
+			if err != nil {
+				return err
+			}
+			output = &Shape{Type: "structure", Members: NewMembers()}
+			if next := p.peek(); next != nil && next.Type == OPEN_BRACE {
+				p.GetToken()
+				err = p.parseHttpMembers(name+"Output", output)
+			}
+		case "except":
+			var status int
+			status, err = p.ExpectInt()
+			if err != nil {
+				return err
+			}
+			var etype string
+			etype, err = p.ExpectIdentifier()
+			if err != nil {
+				return err
+			}
+			p.errors[p.id(etype)] = status
+			shape.Errors = append(shape.Errors, &ShapeRef{Target: p.id(etype)})
+			if next := p.peek(); next != nil && next.Type == OPEN_BRACE {
+				p.GetToken()
+				err = p.parseHttpMembers(name+etype, &Shape{Members: NewMembers()}) //headers are not represented
+			}
+		default:
+			p.UngetToken()
+			err = p.parseHttpMember(name, input, path, query, comment)
+		}
+		if err != nil {
+			return err
+		}
+		comment = ""
+	}
+}
+
+func (p *SadlParser) parseHttpMembers(name string, shape *Shape) error {
+	comment := ""
+	for {
+		tok := p.GetToken()
+		if tok == nil {
+			return p.EndOfFileError()
+		}
+		switch tok.Type {
+		case CLOSE_BRACE:
+			return nil
+		case NEWLINE, COMMA:
+		case LINE_COMMENT:
+			comment = p.MergeComment(comment, tok.Text)
+		case SYMBOL:
+			p.UngetToken()
+			err := p.parseHttpMember(name, shape, "", nil, comment)
+			if err != nil {
+				return err
+			}
+			comment = ""
+		default:
+			return p.SyntaxError()
+		}
+	}
+}
+
+func (p *SadlParser) parseHttpMember(name string, shape *Shape, path string, query map[string]string, comment string) error {
+	mname, err := p.ExpectIdentifier()
+	if err != nil {
+		return err
+	}
+	target, opts, err := p.parseTypeSpec(name+Capitalize(mname), false, "")
+	if err != nil {
+		return err
+	}
+	comment = p.trailingComment(comment)
+	traits, _ := withCommentTrait(nil, comment)
+	if strings.Contains(path, "{"+mname+"}") || strings.Contains(path, "{"+mname+"+}") {
+		traits = withTrait(traits, "smithy.api#httpLabel", data.NewObject())
+		traits = withTrait(traits, "smithy.api#required", data.NewObject())
+	} else if q, ok := query[mname]; ok {
+		traits = withTrait(traits, "smithy.api#httpQuery", q)
+	} else if h := data.AsString(opts.Get("header")); h != "" {
+		traits = withTrait(traits, "smithy.api#httpHeader", h)
+	} else {
+		traits = withTrait(traits, "smithy.api#httpPayload", data.NewObject())
+	}
+	shape.Members.Put(mname, &Member{
+		Target: target,
+		Traits: sadlTraits(traits, opts),
+	})
+	return nil
+}
+
+// parseExample parses an example, i.e. `example GetItemOutput (name=ex1) { ... }`. Examples of operation inputs,
+// outputs, and errors are attached to the operation's @examples trait, paired up by name.
+func (p *SadlParser) parseExample() error {
+	tname, err := p.ExpectIdentifier()
+	if err != nil {
+		return err
+	}
+	opts := data.NewObject()
+	if tok := p.peek(); tok != nil && tok.Type == OPEN_PAREN {
+		p.GetToken()
+		opts, err = p.parseOptions()
+		if err != nil {
+			return err
+		}
+	}
+	val, err := p.parseLiteralValue()
+	if err != nil {
+		return err
+	}
+	p.examples = append(p.examples, &sadlExample{target: p.id(tname), name: data.AsString(opts.Get("name")), value: val})
+	return nil
+}
+
+// finish resolves type aliases, marks the error types, attaches examples, and defines the service.
+func (p *SadlParser) finish() error {
+	for id := range p.aliases {
+		err := p.resolveAlias(id, make(map[string]bool, 0))
+		if err != nil {
+			return err
+		}
+	}
+	for id, status := range p.errors {
+		shape := p.ast.GetShape(id)
+		if shape == nil || shape.Type != "structure" {
+			return fmt.Errorf("Error type is not a Struct: %s", id)
+		}
+		kind := "client"
+		if status >= 500 {
+			kind = "server"
+		}
+		shape.Traits = withTrait(shape.Traits, "smithy.api#error", kind)
+		shape.Traits = withTrait(shape.Traits, "smithy.api#httpError", int32(status))
+	}
+	for _, ref := range p.operations {
+		p.attachExamples(p.ast.GetShape(ref.Target))
+	}
+	if len(p.operations) > 0 {
+		name := p.serviceName
+		if name == "" {
+			lst := strings.Split(shapeIdNamespace(p.id("")), ".")
+			name = lst[len(lst)-1]
+		}
+		version := p.version
+		if version == "" {
+			version = UnspecifiedVersion
+		}
+		p.ast.PutShape(p.id(Capitalize(name)), &Shape{
+			Type:       "service",
+			Version:    version,
+			Operations: p.operations,
+		})
+	}
+	return nil
+}
+
+func (p *SadlParser) resolveAlias(id string, visiting map[string]bool) error {
+	alias := p.aliases[id]
+	if alias == nil {
+		return nil
+	}
+	if visiting[id] {
+		return fmt.Errorf("Circular type definition: %s", id)
+	}
+	visiting[id] = true
+	err := p.resolveAlias(alias.target, visiting)
+	if err != nil {
+		return err
+	}
+	src := p.ast.GetShape(alias.target)
+	if src == nil {
+		return fmt.Errorf("Undefined type: %s", alias.target)
+	}
+	shape := p.ast.GetShape(id)
+	*shape = *src
+	shape.Traits = nil
+	for _, k := range src.Traits.Keys() {
+		shape.Traits = withTrait(shape.Traits, k, src.Traits.Get(k))
+	}
+	for _, k := range alias.traits.Keys() {
+		shape.Traits = withTrait(shape.Traits, k, alias.traits.Get(k))
+	}
+	delete(p.aliases, id)
+	return nil
+}
+
+func (p *SadlParser) attachExamples(op *Shape) {
+	var examples []interface{}
+	byName := make(map[string]*data.Object, 0)
+	for _, ex := range p.examples {
+		key := ""
+		var val interface{} = ex.value
+		if op.Input != nil && ex.target == op.Input.Target {
+			key = "input"
+		} else if op.Output != nil && ex.target == op.Output.Target {
+			key = "output"
+		} else {
+			for _, e := range op.Errors {
+				if e.Target == ex.target {
+					key = "error"
+					errval := data.NewObject()
+					errval.Put("shapeId", ex.target)
+					errval.Put("content", ex.value)
+					val = errval
+				}
+			}
+		}
+		if key == "" {
+			continue
+		}
+		example := byName[ex.name]
+		if example == nil {
+			example = data.NewObject()
+			example.Put("title", ex.name)
+			byName[ex.name] = example
+			examples = append(examples, example)
+		}
+		example.Put(key, val)
+	}
+	if len(examples) > 0 {
+		op.Traits = withTrait(op.Traits, "smithy.api#examples", examples)
+	}
+}
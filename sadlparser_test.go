@@ -0,0 +1,225 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+	"testing"
+)
+
+// SADL sources and the Smithy IDL of the models they should import as
+var sadlTests = []struct {
+	name string
+	sadl string
+	idl  string
+}{
+	{"struct", `namespace test
+// A thing
+type Item Struct {
+    id String (required, pattern="^[a-z]+$") // the id
+    count Int32 (min=0, max=100)
+    tags Array<String> (maxsize=10)
+    attrs Map<String,Int64>
+}
+`, `$version: "2"
+namespace test
+/// A thing
+structure Item {
+    /// the id
+    @required
+    @pattern("^[a-z]+$")
+    id: String
+    @range(min: 0, max: 100)
+    count: Integer
+    @length(max: 10)
+    tags: ItemTags
+    attrs: ItemAttrs
+}
+list ItemTags {
+    member: String
+}
+map ItemAttrs {
+    key: String
+    value: Long
+}
+`},
+	{"enums", `namespace test
+type Color String (values=["RED","dark-blue"])
+type Kind Enum {
+    A
+    B
+}
+`, `$version: "2"
+namespace test
+enum Color {
+    RED
+    DARK_BLUE = "dark-blue"
+}
+enum Kind {
+    A
+    B
+}
+`},
+	{"union", `namespace test
+type Value Union {
+    String
+    Int32
+    item Item
+}
+type Item Struct {
+    id String
+}
+`, `$version: "2"
+namespace test
+union Value {
+    string: String
+    int32: Integer
+    item: Item
+}
+structure Item {
+    id: String
+}
+`},
+	{"alias", `namespace test
+type Name String (maxsize=20)
+type OldName Name (x_deprecated="use Name")
+`, `$version: "2"
+namespace test
+@length(max: 20)
+string Name
+@length(max: 20)
+@deprecated(message: "use Name")
+string OldName
+`},
+	{"http", `namespace test
+name Shop
+version "1.2"
+base "/v1"
+type Item Struct {
+    id String
+}
+type NotFound Struct {
+    message String
+}
+http GET "/items/{id}?limit={max}" (operation=getItem) {
+    id String
+    max Int32
+    etag String (header="If-None-Match")
+    expect 200 {
+        item Item
+    }
+    except 404 NotFound
+}
+http PUT "/items/{id}" {
+    id String
+    item Item
+    expect 204
+}
+`, `$version: "2"
+namespace test
+service Shop {
+    version: "1.2"
+    operations: [GetItem, PutItemsId]
+}
+@readonly
+@http(method: "GET", uri: "/v1/items/{id}", code: 200)
+operation GetItem {
+    input: GetItemInput
+    output: GetItemOutput
+    errors: [NotFound]
+}
+structure GetItemInput {
+    @httpLabel
+    @required
+    id: String
+    @httpQuery("limit")
+    max: Integer
+    @httpHeader("If-None-Match")
+    etag: String
+}
+structure GetItemOutput {
+    @httpPayload
+    item: Item
+}
+@idempotent
+@http(method: "PUT", uri: "/v1/items/{id}", code: 204)
+operation PutItemsId {
+    input: PutItemsIdInput
+}
+structure PutItemsIdInput {
+    @httpLabel
+    @required
+    id: String
+    @httpPayload
+    item: Item
+}
+structure Item {
+    id: String
+}
+@error("client")
+@httpError(404)
+structure NotFound {
+    message: String
+}
+`},
+}
+
+func TestSadl(t *testing.T) {
+	for _, tc := range sadlTests {
+		t.Run(tc.name, func(t *testing.T) {
+			ast, err := parseSadlString("test.sadl", tc.sadl)
+			if err != nil {
+				t.Fatalf("Cannot parse the SADL: %v", err)
+			}
+			expected, err := ParseString(tc.idl, "test.smithy")
+			if err != nil {
+				t.Fatalf("Cannot parse the expected IDL: %v", err)
+			}
+			if !ast.Equal(expected) {
+				t.Errorf("The SADL does not import as expected:\n%s", ast.IDL("test"))
+			}
+		})
+	}
+}
+
+// SADL sources that cannot be imported, and the errors they should produce
+var badSadlTests = []struct {
+	name  string
+	sadl  string
+	error string
+}{
+	{"unsupported statement", "namespace test\naction Foo\n", "Unsupported SADL statement: action"},
+	{"duplicate type", "namespace test\ntype A String\ntype A Int32\n", "Duplicate type: A"},
+	{"map parameters", "namespace test\ntype M Map<String>\n", "Map requires key and value types"},
+	{"undefined type", "namespace test\ntype A B\n", "Undefined type: test#B"},
+	{"circular alias", "namespace test\ntype A B\ntype B A\n", "Circular type definition"},
+	{"error type", "namespace test\nhttp GET \"/\" {\n    except 404 String\n}\n", "Error type is not a Struct"},
+	{"unterminated struct", "namespace test\ntype A Struct {\n    id String\n", "Unexpected end of file"},
+}
+
+func TestBadSadl(t *testing.T) {
+	for _, tc := range badSadlTests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseSadlString("test.sadl", tc.sadl)
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.error) {
+				t.Errorf("Expected an error containing %q, got %q", tc.error, err.Error())
+			}
+		})
+	}
+}
@@ -16,18 +16,20 @@ limitations under the License.
 package smithy
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"path"
 	"strings"
+	"unicode/utf8"
 )
 
+// TokenType is the kind of a Token. The values are stable: new kinds are only added at the end.
 type TokenType int
 
 const (
-	UNDEFINED TokenType = iota
+	UNDEFINED TokenType = iota //an error: the Text of the token describes it
 	EOF
 	LINE_COMMENT
 	BLOCK_COMMENT
@@ -61,11 +63,17 @@ const (
 	BANG
 )
 
+// Token is a token of Smithy IDL source, as returned by the Scanner. The Text of a STRING is its value, with the
+// escapes interpreted, and that of a LINE_COMMENT or BLOCK_COMMENT is its content without the delimiters, so a
+// documentation comment is a LINE_COMMENT starting with "/". The Offset and End of a token are the byte offsets of its
+// first character and just past its last in the source, which can be sliced with them for the token as written.
 type Token struct {
-	Type  TokenType
-	Text  string
-	Line  int
-	Start int
+	Type   TokenType
+	Text   string
+	Line   int //the line of the first character, from 1
+	Start  int //the column of the first character, in runes, from 1
+	Offset int
+	End    int
 }
 
 func (tokenType TokenType) String() string {
@@ -154,25 +162,69 @@ func (tok Token) IsNumeric() bool {
 	return tok.Type == NUMBER
 }
 
+// IsDocComment returns true for a documentation ("///") comment.
+func (tok Token) IsDocComment() bool {
+	return tok.Type == LINE_COMMENT && strings.HasPrefix(tok.Text, "/")
+}
+
+// IsError returns true for the UNDEFINED token returned for malformed input, i.e. an unterminated string, whose Text
+// is the description of the problem.
+func (tok Token) IsError() bool {
+	return tok.Type == UNDEFINED
+}
+
 var eof = rune(0)
 
+// Scanner tokenizes Smithy IDL without parsing it, for tools such as syntax highlighters. Whitespace other than
+// newlines, and carriage returns, are skipped. Malformed input produces an UNDEFINED token, after which scanning
+// continues with the next character.
 type Scanner struct {
-	r          *bufio.Reader
+	src        string //the whole input: the text of most tokens is a substring of it, so scanning them does not copy
 	line       int
 	column     int
 	prevColumn int
-	atEOL      bool
+	offset     int
+	lastSize   int
+	buf        []byte //reused for the text of strings, which have escapes to interpret
 }
 
+// NewScanner returns a Scanner for the IDL read from r, which is read completely first.
 func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{r: bufio.NewReader(r), line: 1, column: 0}
+	var src []byte
+	if r != nil {
+		src, _ = ioutil.ReadAll(r)
+	}
+	return newStringScanner(string(src))
+}
+
+func newStringScanner(src string) *Scanner {
+	return &Scanner{src: src, line: 1, column: 0}
+}
+
+// Tokenize returns all of the tokens of the source, up to but not including the EOF token.
+func Tokenize(src string) []Token {
+	tokens := make([]Token, 0, len(src)/4) //about the number of tokens of typical IDL, so the slice rarely grows
+	s := newStringScanner(src)
+	for {
+		tok := s.Scan()
+		if tok.Type == EOF {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
 }
 
 func (s *Scanner) read() rune {
-	ch, _, err := s.r.ReadRune()
-	if err != nil {
+	if s.offset >= len(s.src) {
+		s.lastSize = 0
 		return eof
 	}
+	ch, size := rune(s.src[s.offset]), 1
+	if ch >= utf8.RuneSelf {
+		ch, size = utf8.DecodeRuneInString(s.src[s.offset:])
+	}
+	s.offset += size
+	s.lastSize = size
 	if ch == '\n' {
 		s.line = s.line + 1
 		s.prevColumn = s.column + 1
@@ -184,17 +236,35 @@ func (s *Scanner) read() rune {
 }
 
 func (s *Scanner) unread(ch rune) {
+	if ch == eof {
+		return
+	}
+	s.offset -= s.lastSize
 	if ch == '\n' {
 		s.column = s.prevColumn - 1
 		s.line = s.line - 1
 	} else {
 		s.column = s.column - 1
 	}
-	s.r.UnreadRune()
+}
+
+// text returns the source from the start of the token to the current position.
+func (s *Scanner) text(tok Token) string {
+	return s.src[tok.Offset:s.offset]
+}
+
+// appendRune is utf8.AppendRune, which is not in the Go version this module requires.
+func appendRune(buf []byte, ch rune) []byte {
+	if ch < utf8.RuneSelf {
+		return append(buf, byte(ch))
+	}
+	var tmp [utf8.UTFMax]byte
+	n := utf8.EncodeRune(tmp[:], ch)
+	return append(buf, tmp[:n]...)
 }
 
 func (s *Scanner) startToken(tokenType TokenType) Token {
-	return Token{Type: tokenType, Text: "", Line: s.line, Start: s.column}
+	return Token{Type: tokenType, Text: "", Line: s.line, Start: s.column, Offset: s.offset - s.lastSize}
 }
 
 func (tok Token) finish(text string) Token {
@@ -207,7 +277,14 @@ func (tok Token) undefined(text string) Token {
 	return tok.finish(text)
 }
 
+// Scan returns the next token, or an EOF token at the end of the input.
 func (s *Scanner) Scan() Token {
+	tok := s.scan()
+	tok.End = s.offset
+	return tok
+}
+
+func (s *Scanner) scan() Token {
 	for {
 		ch := s.read()
 		if !IsWhitespace(ch) {
@@ -226,33 +303,23 @@ func (s *Scanner) Scan() Token {
 				return s.scanPunct(ch)
 			}
 		} else if ch == '\n' {
-			return Token{Type: NEWLINE, Text: "\n", Line: s.line - 1, Start: s.prevColumn}
+			return Token{Type: NEWLINE, Text: "\n", Line: s.line - 1, Start: s.prevColumn, Offset: s.offset - 1}
 		}
 	}
 }
 
 func (s *Scanner) scanSymbol(firstChar rune) Token {
-	var buf bytes.Buffer
-	buf.WriteRune(firstChar)
 	tok := s.startToken(SYMBOL)
-
-	for {
-		ch := s.read()
-		if ch == eof {
-			break
-		} else if !IsSymbolChar(ch, false) {
-			s.unread(ch)
-			break
-		} else {
-			buf.WriteRune(ch)
-		}
+	//symbol characters are ASCII, and not newlines, so they are skipped without decoding them
+	for s.offset < len(s.src) && IsSymbolChar(rune(s.src[s.offset]), false) {
+		s.offset++
+		s.column++
+		s.lastSize = 1
 	}
-	return tok.finish(buf.String())
+	return tok.finish(s.text(tok))
 }
 
 func (s *Scanner) scanNumber(firstDigit rune) Token {
-	var buf bytes.Buffer
-	buf.WriteRune(firstDigit)
 	tok := s.startToken(NUMBER)
 	gotDecimal := false
 	for {
@@ -261,20 +328,17 @@ func (s *Scanner) scanNumber(firstDigit rune) Token {
 			break
 		} else if !IsDigit(ch) {
 			if ch == '.' {
-				buf.WriteRune(ch)
 				if gotDecimal {
-					return tok.undefined(buf.String())
+					return tok.undefined("Malformed number: " + s.text(tok))
 				}
 				gotDecimal = true
 			} else {
 				s.unread(ch)
 				break
 			}
-		} else {
-			buf.WriteRune(ch)
 		}
 	}
-	return tok.finish(buf.String())
+	return tok.finish(s.text(tok))
 }
 
 func (s *Scanner) scanComment() Token {
@@ -282,7 +346,6 @@ func (s *Scanner) scanComment() Token {
 	ch := s.read()
 	if ch != eof {
 		if ch == '/' {
-			var buf bytes.Buffer
 			for {
 				ch = s.read()
 				if ch == eof {
@@ -292,32 +355,21 @@ func (s *Scanner) scanComment() Token {
 					s.unread(ch)
 					break
 				}
-				buf.WriteRune(ch)
 			}
-			return tok.finish(buf.String())
+			return tok.finish(s.text(tok)[2:])
 		}
 		if ch == '*' {
-			var nextToLast bool
 			tok.Type = BLOCK_COMMENT
-			var buf bytes.Buffer
+			prev := rune(0)
 			for {
 				if ch = s.read(); ch == eof {
 					return tok.undefined("Unterminated block comment")
 				}
-				if nextToLast {
-					if ch == '/' {
-						return tok.finish(buf.String())
-					}
-					buf.WriteRune('*')
-					buf.WriteRune(ch)
-					nextToLast = false
-				} else {
-					if ch == '*' {
-						nextToLast = true
-					} else {
-						buf.WriteRune(ch)
-					}
+				if prev == '*' && ch == '/' {
+					text := s.text(tok)
+					return tok.finish(text[2 : len(text)-2])
 				}
+				prev = ch
 			}
 		}
 	}
@@ -326,9 +378,10 @@ func (s *Scanner) scanComment() Token {
 }
 
 func (s *Scanner) scanString() Token {
-	escape := false
+	escape, escaped := false, false
 	potentialTextBlock := true
-	var buf bytes.Buffer
+	buf := s.buf[:0]
+	defer func() { s.buf = buf[:0] }()
 	tok := s.startToken(STRING)
 	for {
 		ch := s.read()
@@ -338,16 +391,16 @@ func (s *Scanner) scanString() Token {
 		if escape {
 			switch ch {
 			case 'n':
-				buf.WriteRune('\n')
+				buf = appendRune(buf, '\n')
 				ch = '\n'
 			case 'r':
-				buf.WriteRune('\r')
+				buf = appendRune(buf, '\r')
 			case 't':
-				buf.WriteRune('\t')
+				buf = appendRune(buf, '\t')
 			case '"':
-				buf.WriteRune(ch)
+				buf = appendRune(buf, ch)
 			case '\\':
-				buf.WriteRune(ch)
+				buf = appendRune(buf, ch)
 			case 'u':
 				c1 := s.read()
 				c2 := s.read()
@@ -364,9 +417,9 @@ func (s *Scanner) scanString() Token {
 				if h1 > 15 || h2 > 15 || h3 > 15 || h4 > 15 {
 					return tok.undefined("Unicode escape must contain 4 hex digits")
 				}
-				buf.WriteRune(h1<<12 + h2<<8 + h3<<4 + h4)
+				buf = appendRune(buf, h1<<12+h2<<8+h3<<4+h4)
 			default:
-				buf.WriteRune(ch)
+				buf = appendRune(buf, ch)
 				return tok.undefined("Bad escape char in string: \\" + string(ch))
 			}
 			escape = false
@@ -384,13 +437,17 @@ func (s *Scanner) scanString() Token {
 				}
 				potentialTextBlock = false
 			}
-			return tok.finish(buf.String())
+			if !escaped {
+				return tok.finish(s.src[tok.Offset+1 : s.offset-1])
+			}
+			return tok.finish(string(buf))
 		case '\\':
-			escape = true
+			escape, escaped = true, true
 		default:
-			buf.WriteRune(ch)
+			buf = appendRune(buf, ch)
 			escape = false
 		}
+		potentialTextBlock = false
 	}
 }
 
@@ -404,106 +461,135 @@ func (s *Scanner) scanTextBlock(tok Token) Token {
 		if ch == '\n' {
 			break
 		}
-		if !IsWhitespace(ch) {
+		if !IsWhitespace(ch) && ch != '\r' {
 			return tok.undefined("Expected newline to start the text block, encountered '" + string(ch) + "'")
 		}
 	}
+	//collect the raw content first: escapes are interpreted only after the incidental whitespace is removed
 	escape := false
 	quoteCount := 0
-	var buf bytes.Buffer
+	buf := s.buf[:0]
+	defer func() { s.buf = buf[:0] }()
 	for {
 		ch := s.read()
 		if ch == eof {
-			return tok.undefined("Unterminated string")
+			return tok.undefined("Unterminated text block")
+		}
+		if ch == '\r' {
+			continue
 		}
 		if escape {
-			switch ch {
-			case 'n':
-				buf.WriteRune('\n')
-				ch = '\n'
-			case 'r':
-				buf.WriteRune('\r')
-			case 't':
-				buf.WriteRune('\t')
-			case '"':
-				buf.WriteRune(ch)
-			case '\\':
-				buf.WriteRune(ch)
-			case 'u':
-				c1 := s.read()
-				c2 := s.read()
-				c3 := s.read()
-				c4 := s.read()
-				if c1 == eof || c2 == eof || c3 == eof || c4 == eof {
-					return tok.undefined("Unterminated string")
-				}
-				//handle unicode char
-				h1 := hexDigit(c1)
-				h2 := hexDigit(c2)
-				h3 := hexDigit(c3)
-				h4 := hexDigit(c4)
-				if h1 > 15 || h2 > 15 || h3 > 15 || h4 > 15 {
-					return tok.undefined("Unicode escape must contain 4 hex digits")
-				}
-				buf.WriteRune(h1<<24 + h2<<16 + h3<<8 + h4)
-			default:
-				buf.WriteRune(ch)
-				return tok.undefined("Bad escape char in string: \\" + string(ch))
-			}
+			buf = appendRune(buf, ch)
 			escape = false
 			continue
 		}
-		switch ch {
-		case '"':
-			switch quoteCount {
-			case 2:
-				return tok.finish(stripCommonPrefix(buf.String()))
-			case 1, 0:
-				quoteCount++
+		if ch == '"' {
+			quoteCount++
+			if quoteCount == 3 {
+				break
 			}
-		case '\\':
+			continue
+		}
+		for ; quoteCount > 0; quoteCount-- {
+			buf = appendRune(buf, '"')
+		}
+		if ch == '\\' {
 			escape = true
-			quoteCount = 0
-		default:
-			buf.WriteRune(ch)
-			escape = false
-			quoteCount = 0
 		}
+		buf = appendRune(buf, ch)
+	}
+	text, err := unescapeText(stripIncidentalWhitespace(string(buf)))
+	if err != nil {
+		return tok.undefined(err.Error())
 	}
+	return tok.finish(text)
 }
 
-func stripCommonPrefix(s string) string {
+// stripIncidentalWhitespace removes the indentation common to the lines of a text block, and the trailing whitespace
+// of every line. Whitespace-only lines do not count towards the common indentation, except for the last line, whose
+// indentation is that of the closing delimiter.
+func stripIncidentalWhitespace(s string) string {
 	lines := strings.Split(s, "\n")
-	noPrefix := 1000
-	minWhitespace := noPrefix
-	for _, l := range lines {
-		j := 0
-		for _, ch := range l {
-			if IsWhitespace(ch) {
-				j++
-			} else {
-				break
-			}
+	minWhitespace := -1
+	for i, l := range lines {
+		n := leadingWhitespace(l)
+		if n == len(l) && i < len(lines)-1 {
+			continue
 		}
-		if j < minWhitespace && j < len(l) {
-			minWhitespace = j
+		if minWhitespace < 0 || n < minWhitespace {
+			minWhitespace = n
 		}
 	}
-	if minWhitespace != noPrefix {
-		if minWhitespace > 0 {
-			ss := ""
-			for i, l := range lines {
-				if i > 0 {
-					ss = ss + "\n"
-				}
-				if len(l) > minWhitespace {
-					ss = ss + l[minWhitespace:]
+	for i, l := range lines {
+		if len(l) > minWhitespace {
+			l = l[minWhitespace:]
+		} else {
+			l = ""
+		}
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func leadingWhitespace(s string) int {
+	n := 0
+	for n < len(s) && (s[n] == ' ' || s[n] == '\t') {
+		n++
+	}
+	return n
+}
+
+// unescapeText interprets the escape sequences in the content of a text block. An escaped newline is a line
+// continuation, and produces nothing.
+func unescapeText(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var buf bytes.Buffer
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if ch != '\\' {
+			buf.WriteRune(ch)
+			continue
+		}
+		i++
+		if i == len(runes) {
+			return "", fmt.Errorf("Unterminated escape in text block")
+		}
+		switch ch = runes[i]; ch {
+		case 'n':
+			buf.WriteRune('\n')
+		case 'r':
+			buf.WriteRune('\r')
+		case 't':
+			buf.WriteRune('\t')
+		case 'b':
+			buf.WriteRune('\b')
+		case 'f':
+			buf.WriteRune('\f')
+		case '"', '\'', '\\', '/':
+			buf.WriteRune(ch)
+		case '\n':
+		case 'u':
+			if i+4 >= len(runes) {
+				return "", fmt.Errorf("Unicode escape must contain 4 hex digits")
+			}
+			var r rune
+			for _, c := range runes[i+1 : i+5] {
+				h := hexDigit(c)
+				if h > 15 {
+					return "", fmt.Errorf("Unicode escape must contain 4 hex digits")
 				}
+				r = r<<4 + h
 			}
-			return ss
+			buf.WriteRune(r)
+			i += 4
+		default:
+			return "", fmt.Errorf("Bad escape char in text block: \\" + string(ch))
 		}
 	}
-	return s
+	return buf.String(), nil
 }
 
 func hexDigit(c rune) rune {
@@ -520,7 +606,7 @@ func hexDigit(c rune) rune {
 
 func (s *Scanner) scanPunct(ch rune) Token {
 	tok := s.startToken(UNDEFINED)
-	tok.Text = string(ch)
+	tok.Text = s.text(tok)
 	switch ch {
 	case eof:
 		tok.Type = EOF
@@ -575,6 +661,8 @@ func (s *Scanner) scanPunct(ch rune) Token {
 		tok.Type = TILDE
 	case '#':
 		tok.Type = HASH
+	default:
+		tok.Text = fmt.Sprintf("Unexpected character: %q", ch)
 	}
 	return tok
 }
@@ -614,9 +702,12 @@ func formattedAnnotation(filename string, source string, prefix string, msg stri
 					mid := l
 					right := ""
 					if tok.Start > 0 && toklen > 1 {
-						left = l[:tok.Start-1]
-						mid = l[tok.Start-1 : tok.Start-1+toklen]
-						right = l[tok.Start-1+toklen:]
+						//a token that spans lines, such as a text block, is highlighted to the end of its first line
+						begin := min(len(l), tok.Start-1)
+						end := min(len(l), begin+toklen)
+						left = l[:begin]
+						mid = l[begin:end]
+						right = l[end:]
 					}
 					tmp += fmt.Sprintf("%3d\t%v", i+begin+1, left)
 					tmp += fmt.Sprintf("%s%v%s", highlight, mid, restore)
@@ -0,0 +1,182 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+	"testing"
+)
+
+// text blocks and the strings they should scan to, with their incidental whitespace removed
+var textBlockTests = []struct {
+	name     string
+	src      string
+	expected string
+}{
+	{"indented", "\"\"\"\n    foo\n      bar\n    \"\"\"", "foo\n  bar\n"},
+	{"closing less indented", "\"\"\"\n    foo\n  \"\"\"", "  foo\n"},
+	{"closing on the last line", "\"\"\"\n    foo\n    bar\"\"\"", "foo\nbar"},
+	{"closing more indented", "\"\"\"\n  foo\n      \"\"\"", "foo\n"},
+	{"trailing whitespace", "\"\"\"\n  foo   \n  bar\t\n  \"\"\"", "foo\nbar\n"},
+	{"blank lines", "\"\"\"\n    foo\n\n  \n    bar\n    \"\"\"", "foo\n\n\nbar\n"},
+	{"quotes", "\"\"\"\n  say \"hi\" and \"\"\\\"\n  \"\"\"", "say \"hi\" and \"\"\"\n"},
+	{"escapes", "\"\"\"\n  a\\tb\\n\\u00e9\n  \"\"\"", "a\tb\né\n"},
+	{"line continuation", "\"\"\"\n  foo \\\n  bar\n  \"\"\"", "foo bar\n"},
+	{"empty", "\"\"\"\n\"\"\"", ""},
+}
+
+func TestTextBlock(t *testing.T) {
+	for _, tc := range textBlockTests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := Tokenize(tc.src)
+			if len(tokens) != 1 || tokens[0].Type != STRING {
+				t.Fatalf("Expected a single string token, got %v", tokens)
+			}
+			if tokens[0].Text != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, tokens[0].Text)
+			}
+		})
+	}
+}
+
+// text blocks that do not scan, and the start of the error each should produce
+var badTextBlockTests = []struct {
+	name  string
+	src   string
+	error string
+}{
+	{"no newline", "\"\"\"foo\"\"\"", "Expected newline to start the text block"},
+	{"unterminated", "\"\"\"\n  foo", "Unterminated text block"},
+	{"bad escape", "\"\"\"\n  \\q\n\"\"\"", "Bad escape char in text block"},
+}
+
+func TestBadTextBlock(t *testing.T) {
+	for _, tc := range badTextBlockTests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := Tokenize(tc.src)
+			if len(tokens) == 0 || tokens[0].Type != UNDEFINED {
+				t.Fatalf("Expected an error token, got %v", tokens)
+			}
+			if !strings.HasPrefix(tokens[0].Text, tc.error) {
+				t.Errorf("Expected an error starting with %q, got %q", tc.error, tokens[0].Text)
+			}
+		})
+	}
+}
+
+// sources and the tokens they should scan to
+var tokenizeTests = []struct {
+	name   string
+	src    string
+	tokens []Token
+}{
+	{"shape", "namespace a.b\n\n@http(uri: \"/a\")\nlist L { member: ns#T$m }\n", []Token{
+		{SYMBOL, "namespace", 1, 1, 0, 9},
+		{SYMBOL, "a", 1, 11, 10, 11},
+		{DOT, ".", 1, 12, 11, 12},
+		{SYMBOL, "b", 1, 13, 12, 13},
+		{NEWLINE, "\n", 1, 14, 13, 14},
+		{NEWLINE, "\n", 2, 1, 14, 15},
+		{AT, "@", 3, 1, 15, 16},
+		{SYMBOL, "http", 3, 2, 16, 20},
+		{OPEN_PAREN, "(", 3, 6, 20, 21},
+		{SYMBOL, "uri", 3, 7, 21, 24},
+		{COLON, ":", 3, 10, 24, 25},
+		{STRING, "/a", 3, 12, 26, 30},
+		{CLOSE_PAREN, ")", 3, 16, 30, 31},
+		{NEWLINE, "\n", 3, 17, 31, 32},
+		{SYMBOL, "list", 4, 1, 32, 36},
+		{SYMBOL, "L", 4, 6, 37, 38},
+		{OPEN_BRACE, "{", 4, 8, 39, 40},
+		{SYMBOL, "member", 4, 10, 41, 47},
+		{COLON, ":", 4, 16, 47, 48},
+		{SYMBOL, "ns", 4, 18, 49, 51},
+		{HASH, "#", 4, 20, 51, 52},
+		{SYMBOL, "T", 4, 21, 52, 53},
+		{DOLLAR, "$", 4, 22, 53, 54},
+		{SYMBOL, "m", 4, 23, 54, 55},
+		{CLOSE_BRACE, "}", 4, 25, 56, 57},
+		{NEWLINE, "\n", 4, 26, 57, 58},
+	}},
+	{"comments", "/** doc **/ a // c\n/// d\n", []Token{
+		{BLOCK_COMMENT, "* doc *", 1, 1, 0, 11},
+		{SYMBOL, "a", 1, 13, 12, 13},
+		{LINE_COMMENT, " c", 1, 15, 14, 18},
+		{NEWLINE, "\n", 1, 19, 18, 19},
+		{LINE_COMMENT, "/ d", 2, 1, 19, 24},
+		{NEWLINE, "\n", 2, 6, 24, 25},
+	}},
+	{"strings and numbers", "\"a\\tb\\u00e9\" \"é\" x 12 -3.5 0.25\r\n", []Token{
+		{STRING, "a\tbé", 1, 1, 0, 12},
+		{STRING, "é", 1, 14, 13, 17},
+		{SYMBOL, "x", 1, 18, 18, 19},
+		{NUMBER, "12", 1, 20, 20, 22},
+		{NUMBER, "-3.5", 1, 23, 23, 27},
+		{NUMBER, "0.25", 1, 28, 28, 32},
+		{NEWLINE, "\n", 1, 33, 33, 34},
+	}},
+	{"operators", "a = 1, [X] ?& *", []Token{
+		{SYMBOL, "a", 1, 1, 0, 1},
+		{EQUALS, "=", 1, 3, 2, 3},
+		{NUMBER, "1", 1, 5, 4, 5},
+		{COMMA, ",", 1, 6, 5, 6},
+		{OPEN_BRACKET, "[", 1, 8, 7, 8},
+		{SYMBOL, "X", 1, 9, 8, 9},
+		{CLOSE_BRACKET, "]", 1, 10, 9, 10},
+		{QUESTION, "?", 1, 12, 11, 12},
+		{AMPERSAND, "&", 1, 13, 12, 13},
+		{STAR, "*", 1, 15, 14, 15},
+	}},
+}
+
+func TestTokenize(t *testing.T) {
+	for _, tc := range tokenizeTests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := Tokenize(tc.src)
+			if len(tokens) != len(tc.tokens) {
+				t.Fatalf("Expected %d tokens, got %d: %v", len(tc.tokens), len(tokens), tokens)
+			}
+			for i, tok := range tokens {
+				if tok != tc.tokens[i] {
+					t.Errorf("Expected token %d to be %#v, got %#v", i, tc.tokens[i], tok)
+				}
+			}
+		})
+	}
+}
+
+// TestScanner checks that a Scanner reading the source produces the same tokens as Tokenize, and that the text of
+// symbols and numbers is the source they span.
+func TestScanner(t *testing.T) {
+	src := benchIDL("bench", 10)
+	tokens := Tokenize(src)
+	s := NewScanner(strings.NewReader(src))
+	for i := 0; ; i++ {
+		tok := s.Scan()
+		if tok.Type == EOF {
+			if i != len(tokens) {
+				t.Errorf("Expected %d tokens, got %d", len(tokens), i)
+			}
+			break
+		}
+		if i >= len(tokens) || tok != tokens[i] {
+			t.Fatalf("Token %d is %v, not as Tokenize returns it", i, tok)
+		}
+		if (tok.Type == SYMBOL || tok.Type == NUMBER) && tok.Text != src[tok.Offset:tok.End] {
+			t.Errorf("The text of %v is not its source %q", tok, src[tok.Offset:tok.End])
+		}
+	}
+}
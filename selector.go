@@ -0,0 +1,1015 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// Select returns the ids of the shapes and members in the model matched by the selector expression, in the order they
+// are found. See ParseSelector for the supported syntax.
+func (ast *AST) Select(expr string) ([]string, error) {
+	sel, err := ParseSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	return sel.Select(ast), nil
+}
+
+// Selector is a parsed Smithy selector expression.
+type Selector struct {
+	text  string
+	steps []selectorStep
+}
+
+func (sel *Selector) String() string {
+	return sel.text
+}
+
+// Select returns the ids of the shapes and members in the model matched by the selector. The selector starts with
+// every shape and member defined in the model. Prelude shapes are not included, but can be reached through
+// relationships, i.e. "member > string" matches smithy.api#String if a member targets it.
+func (sel *Selector) Select(ast *AST) []string {
+	return sel.SelectIndexed(ast.Index())
+}
+
+// SelectIndexed is like Select, but uses an existing index of the model.
+func (sel *Selector) SelectIndexed(idx *Index) []string {
+	ctx := &selectorContext{
+		ast:   idx.ast,
+		index: idx,
+		vars:  make(map[string][]string, 0),
+	}
+	return ctx.eval(sel.steps, idx.Ids())
+}
+
+// ParseSelector parses a selector in the Smithy selector language. Supported are shape type selectors ("*",
+// "structure", "number", "simpleType", "collection", "member", etc), attribute selectors on id, service, trait and var
+// with the =, !=, ^=, $=, *=, ?=, <, <=, >, >=, {=}, {!=}, {<} and {<<} comparators and the "i" flag, scoped attribute
+// selectors ("[@trait|range: @{min} > 1]"), the neighbor relationships ">", "~>", "<", "-[input, output]->" and
+// "<-[member]-", the :test, :is, :not, :topdown, :recursive, :in and :root functions, and variables ("$name(...)" and
+// "${name}").
+func ParseSelector(expr string) (*Selector, error) {
+	p := &selectorParser{text: expr, vars: make(map[string]bool, 0)}
+	steps, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		return nil, p.error("unexpected character '%c'", p.text[p.pos])
+	}
+	return &Selector{text: expr, steps: steps}, nil
+}
+
+type selectorStep func(ctx *selectorContext, in []string) []string
+
+// the relationships that can be named in a directed neighbor selector, i.e. "-[input]->"
+var selectorRelationships = []string{"member", "input", "output", "error", "operation", "resource", "collectionOperation",
+	"instanceOperation", "create", "read", "update", "delete", "list", "put", "identifier", "property", "bound", "mixin", "trait"}
+
+var selectorShapeTypes = []string{"*", "blob", "boolean", "document", "string", "byte", "short", "integer", "long", "float",
+	"double", "bigInteger", "bigDecimal", "timestamp", "member", "list", "set", "map", "structure", "union", "service",
+	"operation", "resource", "enum", "intEnum", "number", "simpleType", "collection"}
+
+//
+// evaluation
+//
+
+type selectorContext struct {
+	ast   *AST
+	index *Index
+	vars  map[string][]string
+}
+
+func (ctx *selectorContext) eval(steps []selectorStep, in []string) []string {
+	for _, step := range steps {
+		if len(in) == 0 {
+			break
+		}
+		in = step(ctx, in)
+	}
+	return in
+}
+
+func (ctx *selectorContext) shapeType(id string) string {
+	if strings.Index(id, "$") >= 0 {
+		if ctx.ast.getMember(id) != nil {
+			return "member"
+		}
+		return ""
+	}
+	if shape := ctx.ast.nodeShape(id); shape != nil {
+		return shape.Type
+	}
+	return ""
+}
+
+func (ctx *selectorContext) traits(id string) *data.Object {
+	if mem := ctx.ast.getMember(id); mem != nil {
+		return mem.Traits
+	}
+	if shape := ctx.ast.GetShape(id); shape != nil {
+		return shape.Traits
+	}
+	return nil
+}
+
+// neighbors returns the references from the shape to other shapes, including the "bound" relationships from
+// operations and resources to the services and resources they are bound to.
+func (ctx *selectorContext) neighbors(id string) []*Reference {
+	refs := ctx.index.ReferencesFrom(id)
+	if bound := ctx.index.BoundTo(id); len(bound) > 0 {
+		refs = append([]*Reference{}, refs...)
+		for _, b := range bound {
+			refs = append(refs, &Reference{From: id, To: b, Relationship: "bound"})
+		}
+	}
+	return refs
+}
+
+// relationshipMatches returns true if the reference is one of the named relationships. The "instanceOperation" and
+// "collectionOperation" relationships of a resource include its lifecycle operations.
+func (ctx *selectorContext) relationshipMatches(ref *Reference, rels []string) bool {
+	if containsString(rels, ref.Relationship) {
+		return true
+	}
+	switch ref.Relationship {
+	case "read", "update", "delete", "put":
+		return containsString(rels, "instanceOperation")
+	case "operation":
+		return containsString(rels, "instanceOperation") && ctx.shapeType(ref.From) == "resource"
+	case "create", "list":
+		return containsString(rels, "collectionOperation")
+	}
+	return false
+}
+
+func (ctx *selectorContext) attribute(id string, path []string) []interface{} {
+	if len(path) == 0 {
+		return nil
+	}
+	switch path[0] {
+	case "id":
+		if len(path) == 1 {
+			return []interface{}{id}
+		}
+		var v string
+		switch path[1] {
+		case "namespace":
+			v = shapeIdNamespace(id)
+		case "name":
+			v = StripNamespace(strings.SplitN(id, "$", 2)[0])
+		case "member":
+			if i := strings.Index(id, "$"); i >= 0 {
+				v = id[i+1:]
+			}
+		}
+		if v == "" {
+			return nil
+		}
+		return selectorNodePath([]interface{}{v}, path[2:])
+	case "service":
+		shape := ctx.ast.GetShape(id)
+		if shape == nil || shape.Type != "service" {
+			return nil
+		}
+		if len(path) == 1 {
+			return []interface{}{id}
+		}
+		switch path[1] {
+		case "id":
+			return ctx.attribute(id, append([]string{"id"}, path[2:]...))
+		case "version":
+			if shape.Version == "" {
+				return nil
+			}
+			return selectorNodePath([]interface{}{shape.Version}, path[2:])
+		}
+		return nil
+	case "trait":
+		traits := ctx.traits(id)
+		if traits == nil || traits.Length() == 0 {
+			return nil
+		}
+		if len(path) == 1 {
+			return []interface{}{traits}
+		}
+		if strings.HasPrefix(path[1], "(") {
+			return selectorNodePath([]interface{}{traits}, path[1:])
+		}
+		tid := path[1]
+		if strings.Index(tid, "#") < 0 {
+			tid = "smithy.api#" + tid
+		}
+		if !traits.Has(tid) {
+			return nil
+		}
+		return selectorNodePath([]interface{}{traits.Get(tid)}, path[2:])
+	case "var":
+		ids, ok := ctx.vars[path[1]]
+		if !ok {
+			return nil
+		}
+		var lst []interface{}
+		for _, v := range ids {
+			lst = append(lst, v)
+		}
+		return selectorNodePath([]interface{}{lst}, path[2:])
+	}
+	return nil
+}
+
+// selectorNodePath follows the path into node values, i.e. "min" or "(keys)". The result is empty if the path does not
+// exist.
+func selectorNodePath(vals []interface{}, path []string) []interface{} {
+	for _, seg := range path {
+		var next []interface{}
+		for _, v := range vals {
+			switch seg {
+			case "(keys)":
+				if m := selectorNodeMap(v); m != nil {
+					for _, k := range sortedKeys(m) {
+						next = append(next, k)
+					}
+				}
+			case "(values)":
+				if m := selectorNodeMap(v); m != nil {
+					for _, k := range sortedKeys(m) {
+						next = append(next, m[k])
+					}
+				} else if lst, ok := v.([]interface{}); ok {
+					next = append(next, lst...)
+				}
+			case "(length)":
+				if m := selectorNodeMap(v); m != nil {
+					next = append(next, len(m))
+				} else if lst, ok := v.([]interface{}); ok {
+					next = append(next, len(lst))
+				} else if s, ok := nodeString(v); ok {
+					next = append(next, len(s))
+				}
+			default:
+				if m := selectorNodeMap(v); m != nil {
+					if mv, ok := m[seg]; ok {
+						next = append(next, mv)
+					}
+				}
+			}
+		}
+		vals = next
+	}
+	return vals
+}
+
+func selectorNodeMap(v interface{}) map[string]interface{} {
+	switch v.(type) {
+	case *data.Object, map[string]interface{}:
+		return data.AsMap(v)
+	}
+	return nil
+}
+
+// selectorString returns the string form of a node value for comparison. Objects and arrays cannot be compared.
+func selectorString(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case string:
+		return n, true
+	case *string:
+		return *n, true
+	case bool:
+		return strconv.FormatBool(n), true
+	case int:
+		return strconv.Itoa(n), true
+	case int32, int64, float32, float64:
+		return fmt.Sprint(n), true
+	case *data.Decimal:
+		return n.String(), true
+	}
+	return "", false
+}
+
+type selectorComparison struct {
+	comparator      string
+	values          []string
+	scoped          []string //for scoped attribute selectors, values that are paths relative to the scope, i.e. "@{min}"
+	caseInsensitive bool
+}
+
+func (c *selectorComparison) matches(lhs []interface{}, rhs []string) bool {
+	if c.comparator == "?=" {
+		exists := len(lhs) > 0
+		for _, r := range rhs {
+			if r == strconv.FormatBool(exists) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(lhs) == 0 {
+		return false
+	}
+	var left []string
+	for _, v := range lhs {
+		if s, ok := selectorString(v); ok {
+			if c.caseInsensitive {
+				s = strings.ToLower(s)
+			}
+			left = append(left, s)
+		}
+	}
+	var right []string
+	for _, r := range rhs {
+		if c.caseInsensitive {
+			r = strings.ToLower(r)
+		}
+		right = append(right, r)
+	}
+	switch c.comparator {
+	case "{=}":
+		return selectorSubset(left, right) && selectorSubset(right, left)
+	case "{!=}":
+		return !(selectorSubset(left, right) && selectorSubset(right, left))
+	case "{<}":
+		return selectorSubset(left, right)
+	case "{<<}":
+		return selectorSubset(left, right) && !selectorSubset(right, left)
+	case "!=":
+		for _, l := range left {
+			if containsString(right, l) {
+				return false
+			}
+		}
+		return len(left) > 0
+	}
+	for _, l := range left {
+		for _, r := range right {
+			if selectorCompare(c.comparator, l, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func selectorCompare(comparator, l, r string) bool {
+	switch comparator {
+	case "=":
+		return l == r
+	case "^=":
+		return strings.HasPrefix(l, r)
+	case "$=":
+		return strings.HasSuffix(l, r)
+	case "*=":
+		return strings.Contains(l, r)
+	}
+	lf, err := strconv.ParseFloat(l, 64)
+	if err != nil {
+		return false
+	}
+	rf, err := strconv.ParseFloat(r, 64)
+	if err != nil {
+		return false
+	}
+	switch comparator {
+	case "<":
+		return lf < rf
+	case "<=":
+		return lf <= rf
+	case ">":
+		return lf > rf
+	case ">=":
+		return lf >= rf
+	}
+	return false
+}
+
+func selectorSubset(a, b []string) bool {
+	set := make(map[string]bool, len(b))
+	for _, s := range b {
+		set[s] = true
+	}
+	for _, s := range a {
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
+
+func selectorTypeMatches(sel string, t string) bool {
+	switch sel {
+	case "*":
+		return t != ""
+	case "number":
+		return containsString([]string{"byte", "short", "integer", "long", "float", "double", "bigInteger", "bigDecimal", "intEnum"}, t)
+	case "simpleType":
+		return containsString([]string{"blob", "boolean", "document", "string", "timestamp", "enum", "byte", "short", "integer", "long", "float", "double", "bigInteger", "bigDecimal", "intEnum"}, t)
+	case "collection":
+		return t == "list" || t == "set"
+	case "string":
+		return t == "string" || t == "enum"
+	case "integer":
+		return t == "integer" || t == "intEnum"
+	case "list":
+		return t == "list" || t == "set"
+	}
+	return sel == t
+}
+
+// selectorSet accumulates ids without duplicates, in the order they are added.
+type selectorSet struct {
+	ids  []string
+	seen map[string]bool
+}
+
+func newSelectorSet() *selectorSet {
+	return &selectorSet{ids: make([]string, 0), seen: make(map[string]bool, 0)}
+}
+
+func (s *selectorSet) add(id string) bool {
+	if s.seen[id] {
+		return false
+	}
+	s.seen[id] = true
+	s.ids = append(s.ids, id)
+	return true
+}
+
+func filterStep(keep func(ctx *selectorContext, id string) bool) selectorStep {
+	return func(ctx *selectorContext, in []string) []string {
+		out := make([]string, 0)
+		for _, id := range in {
+			if keep(ctx, id) {
+				out = append(out, id)
+			}
+		}
+		return out
+	}
+}
+
+func neighborStep(reverse bool, recursive bool, rels []string) selectorStep {
+	return func(ctx *selectorContext, in []string) []string {
+		out := newSelectorSet()
+		queue := append([]string{}, in...)
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			refs := ctx.neighbors(id)
+			if reverse {
+				refs = ctx.index.ReferencesTo(id)
+			}
+			for _, ref := range refs {
+				if rels == nil {
+					if ref.Relationship == "trait" {
+						continue
+					}
+				} else if !ctx.relationshipMatches(ref, rels) {
+					continue
+				}
+				next := ref.To
+				if reverse {
+					next = ref.From
+				}
+				if out.add(next) && recursive {
+					queue = append(queue, next)
+				}
+			}
+		}
+		return out.ids
+	}
+}
+
+//
+// parsing
+//
+
+type selectorParser struct {
+	text string
+	pos  int
+	vars map[string]bool
+}
+
+func (p *selectorParser) error(format string, args ...interface{}) error {
+	return fmt.Errorf("Bad selector %q at offset %d: %s", p.text, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *selectorParser) skipSpace() {
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			p.pos++
+		} else if strings.HasPrefix(p.text[p.pos:], "//") {
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+}
+
+func (p *selectorParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+func (p *selectorParser) accept(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.text[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) expect(s string) error {
+	if !p.accept(s) {
+		return p.error("expected %q", s)
+	}
+	return nil
+}
+
+func isSelectorIdentifierChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '.' || c == '#'
+}
+
+func (p *selectorParser) identifier() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.text) && isSelectorIdentifierChar(p.text[p.pos]) {
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+// parseSelector parses a sequence of selector expressions, up to the end of the text, or a ',' or ')' that ends a
+// function argument.
+func (p *selectorParser) parseSelector() ([]selectorStep, error) {
+	var steps []selectorStep
+	for {
+		p.skipSpace()
+		c := p.peek()
+		if c == 0 || c == ',' || c == ')' {
+			break
+		}
+		step, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, p.error("expected a selector")
+	}
+	return steps, nil
+}
+
+func (p *selectorParser) parseExpression() (selectorStep, error) {
+	switch {
+	case p.accept("*"):
+		return filterStep(func(ctx *selectorContext, id string) bool { return ctx.shapeType(id) != "" }), nil
+	case p.accept("["):
+		return p.parseAttribute()
+	case p.accept("~>"):
+		return neighborStep(false, true, nil), nil
+	case p.accept(">"):
+		return neighborStep(false, false, nil), nil
+	case p.accept("<-["):
+		rels, err := p.parseRelationships()
+		if err == nil {
+			err = p.expect("]-")
+		}
+		return neighborStep(true, false, rels), err
+	case p.accept("<"):
+		return neighborStep(true, false, nil), nil
+	case p.accept("-["):
+		rels, err := p.parseRelationships()
+		if err == nil {
+			err = p.expect("]->")
+		}
+		return neighborStep(false, false, rels), err
+	case p.accept(":"):
+		return p.parseFunction()
+	case p.accept("${"):
+		name := p.identifier()
+		if !p.vars[name] {
+			return nil, p.error("undefined variable %q", name)
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		return func(ctx *selectorContext, in []string) []string {
+			return ctx.vars[name]
+		}, nil
+	case p.accept("$"):
+		name := p.identifier()
+		if name == "" {
+			return nil, p.error("expected a variable name")
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		steps, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		p.vars[name] = true
+		return func(ctx *selectorContext, in []string) []string {
+			ctx.vars[name] = ctx.eval(steps, in)
+			return in
+		}, nil
+	}
+	name := p.identifier()
+	if name == "" {
+		return nil, p.error("unexpected character '%c'", p.peek())
+	}
+	if !containsString(selectorShapeTypes, name) {
+		return nil, p.error("unknown shape type %q", name)
+	}
+	return filterStep(func(ctx *selectorContext, id string) bool {
+		return selectorTypeMatches(name, ctx.shapeType(id))
+	}), nil
+}
+
+func (p *selectorParser) parseRelationships() ([]string, error) {
+	var rels []string
+	for {
+		rel := p.identifier()
+		if !containsString(selectorRelationships, rel) {
+			return nil, p.error("unknown relationship %q", rel)
+		}
+		rels = append(rels, rel)
+		if !p.accept(",") {
+			return rels, nil
+		}
+	}
+}
+
+func (p *selectorParser) parseFunction() (selectorStep, error) {
+	name := p.identifier()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args [][]selectorStep
+	for {
+		arg, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	matchesAny := func(ctx *selectorContext, id string) bool {
+		for _, arg := range args {
+			if len(ctx.eval(arg, []string{id})) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	switch name {
+	case "test":
+		return filterStep(matchesAny), nil
+	case "not":
+		return filterStep(func(ctx *selectorContext, id string) bool { return !matchesAny(ctx, id) }), nil
+	case "is", "each":
+		return func(ctx *selectorContext, in []string) []string {
+			out := newSelectorSet()
+			for _, arg := range args {
+				for _, id := range ctx.eval(arg, in) {
+					out.add(id)
+				}
+			}
+			return out.ids
+		}, nil
+	case "in":
+		if len(args) != 1 {
+			return nil, p.error(":in takes one argument")
+		}
+		return func(ctx *selectorContext, in []string) []string {
+			set := newSelectorSet()
+			for _, id := range ctx.eval(args[0], in) {
+				set.add(id)
+			}
+			return filterStep(func(ctx *selectorContext, id string) bool { return set.seen[id] })(ctx, in)
+		}, nil
+	case "root":
+		if len(args) != 1 {
+			return nil, p.error(":root takes one argument")
+		}
+		return func(ctx *selectorContext, in []string) []string {
+			return ctx.eval(args[0], ctx.index.Ids())
+		}, nil
+	case "recursive":
+		if len(args) != 1 {
+			return nil, p.error(":recursive takes one argument")
+		}
+		return func(ctx *selectorContext, in []string) []string {
+			out := newSelectorSet()
+			next := in
+			for len(next) > 0 {
+				var added []string
+				for _, id := range ctx.eval(args[0], next) {
+					if out.add(id) {
+						added = append(added, id)
+					}
+				}
+				next = added
+			}
+			return out.ids
+		}, nil
+	case "topdown":
+		if len(args) > 2 {
+			return nil, p.error(":topdown takes one or two arguments")
+		}
+		return filterStep(func(ctx *selectorContext, id string) bool {
+			return ctx.topdown(id, args, make(map[string]bool, 0))
+		}), nil
+	}
+	return nil, p.error("unknown function %q", name)
+}
+
+// topdown returns true if the shape, or the nearest shape that contains it or binds it, matches the first selector
+// before a shape matching the disqualifier (the optional second selector) is found.
+func (ctx *selectorContext) topdown(id string, args [][]selectorStep, visited map[string]bool) bool {
+	if visited[id] {
+		return false
+	}
+	visited[id] = true
+	if len(args) > 1 && len(ctx.eval(args[1], []string{id})) > 0 {
+		return false
+	}
+	if len(ctx.eval(args[0], []string{id})) > 0 {
+		return true
+	}
+	if i := strings.Index(id, "$"); i >= 0 {
+		return ctx.topdown(id[:i], args, visited)
+	}
+	for _, b := range ctx.index.BoundTo(id) {
+		if ctx.topdown(b, args, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *selectorParser) parseAttributePath() ([]string, error) {
+	var path []string
+	for {
+		p.skipSpace()
+		switch c := p.peek(); {
+		case c == '\'' || c == '"':
+			s, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, s)
+		case c == '(':
+			end := strings.Index(p.text[p.pos:], ")")
+			if end < 0 {
+				return nil, p.error("expected ')'")
+			}
+			fn := p.text[p.pos : p.pos+end+1]
+			if fn != "(keys)" && fn != "(values)" && fn != "(length)" {
+				return nil, p.error("unknown function %q", fn)
+			}
+			path = append(path, fn)
+			p.pos += end + 1
+		default:
+			seg := p.identifier()
+			if seg == "" {
+				return nil, p.error("expected an attribute")
+			}
+			path = append(path, seg)
+		}
+		if !p.accept("|") {
+			return path, nil
+		}
+	}
+}
+
+func (p *selectorParser) checkAttributePath(path []string) error {
+	switch path[0] {
+	case "id", "service", "trait":
+		return nil
+	case "var":
+		if len(path) < 2 || !p.vars[path[1]] {
+			return p.error("undefined variable in var attribute")
+		}
+		return nil
+	}
+	return p.error("unknown attribute %q", path[0])
+}
+
+func (p *selectorParser) parseQuoted() (string, error) {
+	quote := p.text[p.pos]
+	end := strings.IndexByte(p.text[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.error("unterminated string")
+	}
+	s := p.text[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+var selectorComparators = []string{"{<<}", "{!=}", "{=}", "{<}", "!=", "^=", "$=", "*=", "?=", "<=", ">=", "=", "<", ">"}
+
+func (p *selectorParser) parseComparison(scoped bool) (*selectorComparison, error) {
+	p.skipSpace()
+	c := &selectorComparison{}
+	for _, cmp := range selectorComparators {
+		if p.accept(cmp) {
+			c.comparator = cmp
+			break
+		}
+	}
+	if c.comparator == "" {
+		return nil, nil
+	}
+	for {
+		p.skipSpace()
+		switch ch := p.peek(); {
+		case ch == '\'' || ch == '"':
+			s, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, s)
+		case scoped && strings.HasPrefix(p.text[p.pos:], "@{"):
+			p.pos += 2
+			end := strings.Index(p.text[p.pos:], "}")
+			if end < 0 {
+				return nil, p.error("expected '}'")
+			}
+			c.scoped = append(c.scoped, p.text[p.pos:p.pos+end])
+			p.pos += end + 1
+		default:
+			start := p.pos
+			for p.pos < len(p.text) && (isSelectorIdentifierChar(p.text[p.pos]) || p.text[p.pos] == '-' || p.text[p.pos] == '$') {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.error("expected a value")
+			}
+			c.values = append(c.values, p.text[start:p.pos])
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.skipSpace()
+	if p.peek() == 'i' {
+		p.pos++
+		c.caseInsensitive = true
+	}
+	return c, nil
+}
+
+func (p *selectorParser) parseAttribute() (selectorStep, error) {
+	if p.accept("@") {
+		return p.parseScopedAttribute()
+	}
+	path, err := p.parseAttributePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkAttributePath(path); err != nil {
+		return nil, err
+	}
+	cmp, err := p.parseComparison(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return filterStep(func(ctx *selectorContext, id string) bool {
+		vals := ctx.attribute(id, path)
+		if cmp == nil {
+			return len(vals) > 0
+		}
+		return cmp.matches(vals, cmp.values)
+	}), nil
+}
+
+type scopedAssertion struct {
+	path []string
+	cmp  *selectorComparison
+}
+
+// parseScopedAttribute parses "[@path: @{subpath} comparator values && ...]", with the "[@" already consumed.
+func (p *selectorParser) parseScopedAttribute() (selectorStep, error) {
+	var scope []string
+	var err error
+	if !p.accept(":") {
+		scope, err = p.parseAttributePath()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.checkAttributePath(scope); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+	}
+	var assertions []*scopedAssertion
+	for {
+		if err := p.expect("@{"); err != nil {
+			return nil, err
+		}
+		end := strings.Index(p.text[p.pos:], "}")
+		if end < 0 {
+			return nil, p.error("expected '}'")
+		}
+		a := &scopedAssertion{path: selectorScopedPath(p.text[p.pos : p.pos+end])}
+		p.pos += end + 1
+		a.cmp, err = p.parseComparison(true)
+		if err != nil {
+			return nil, err
+		}
+		if a.cmp == nil {
+			return nil, p.error("expected a comparator")
+		}
+		assertions = append(assertions, a)
+		if !p.accept("&&") {
+			break
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return filterStep(func(ctx *selectorContext, id string) bool {
+		resolve := func(path []string) []interface{} {
+			return ctx.attribute(id, path)
+		}
+		if scope == nil {
+			return scopedMatches(assertions, resolve)
+		}
+		for _, v := range ctx.attribute(id, scope) {
+			val := v
+			if scopedMatches(assertions, func(path []string) []interface{} {
+				return selectorNodePath([]interface{}{val}, path)
+			}) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func selectorScopedPath(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	path := strings.Split(s, "|")
+	for i, seg := range path {
+		path[i] = strings.TrimSpace(seg)
+	}
+	return path
+}
+
+func scopedMatches(assertions []*scopedAssertion, resolve func(path []string) []interface{}) bool {
+	for _, a := range assertions {
+		rhs := a.cmp.values
+		for _, sp := range a.cmp.scoped {
+			for _, v := range resolve(selectorScopedPath(sp)) {
+				if s, ok := selectorString(v); ok {
+					rhs = append(rhs, s)
+				}
+			}
+		}
+		if !a.cmp.matches(resolve(a.path), rhs) {
+			return false
+		}
+	}
+	return true
+}
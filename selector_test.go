@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"reflect"
+	"testing"
+)
+
+// the model the selector tests are evaluated against
+const selectorIDL = `$version: "2"
+namespace test
+
+service Shop {
+    version: "1"
+    operations: [GetItem, PutItem]
+}
+
+@readonly
+@http(method: "GET", uri: "/items/{id}")
+operation GetItem {
+    input: GetItemInput
+    output: Item
+    errors: [NotFound]
+}
+
+@idempotent
+@http(method: "PUT", uri: "/items/{id}")
+operation PutItem {
+    input: Item
+}
+
+@input
+structure GetItemInput {
+    @required
+    @httpLabel
+    id: ItemId
+}
+
+structure Item {
+    @required
+    @httpLabel
+    id: ItemId
+
+    @length(min: 1, max: 10)
+    name: String
+
+    tags: TagList
+}
+
+@length(min: 3)
+@pattern("^[a-z]+$")
+string ItemId
+
+list TagList {
+    member: String
+}
+
+@error("client")
+structure NotFound {
+    message: String
+}
+`
+
+var selectorTests = []struct {
+	selector string
+	expected []string
+}{
+	{"service", []string{"test#Shop"}},
+	{"operation", []string{"test#GetItem", "test#PutItem"}},
+	{"list", []string{"test#TagList"}},
+	{"simpleType", []string{"test#ItemId"}},
+	{"[trait|readonly]", []string{"test#GetItem"}},
+	{"[trait|error = client]", []string{"test#NotFound"}},
+	{"operation:not([trait|readonly])", []string{"test#PutItem"}},
+	{"[id|name ^= Get]", []string{"test#GetItem", "test#GetItemInput", "test#GetItemInput$id"}},
+	{"structure[id|name $= 'input' i]", []string{"test#GetItemInput"}},
+	{"member[trait|required]", []string{"test#GetItemInput$id", "test#Item$id"}},
+	{"member > string", []string{"test#ItemId", "smithy.api#String"}},
+	{"operation -[input]-> structure", []string{"test#GetItemInput", "test#Item"}},
+	{"operation -[error]-> structure", []string{"test#NotFound"}},
+	{"structure :test(> member > list)", []string{"test#Item"}},
+	{"[@trait|length: @{min} > 2]", []string{"test#ItemId"}},
+	{"[trait|http|method = PUT]", []string{"test#PutItem"}},
+	{"service ~> operation[trait|idempotent]", []string{"test#PutItem"}},
+	{"[trait|length] :not(member)", []string{"test#ItemId"}},
+	{"structure :not(:test(< operation))", nil},
+	{"$ops(operation[trait|readonly]) structure :test(< ${ops})", []string{"test#GetItemInput", "test#Item", "test#NotFound"}},
+	{"structure :test(<-[input]- operation)", []string{"test#GetItemInput", "test#Item"}},
+}
+
+func TestSelector(t *testing.T) {
+	ast, err := ParseString(selectorIDL, "test.smithy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range selectorTests {
+		t.Run(tc.selector, func(t *testing.T) {
+			ids, err := ast.Select(tc.selector)
+			if err != nil {
+				t.Fatalf("Cannot evaluate: %v", err)
+			}
+			if len(ids)+len(tc.expected) > 0 && !reflect.DeepEqual(ids, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, ids)
+			}
+		})
+	}
+}
+
+func TestBadSelector(t *testing.T) {
+	for _, expr := range []string{"", "[trait|", ":not(", "[id = ]", "foo"} {
+		if _, err := ParseSelector(expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ShapeID is the id of a shape, i.e. "name.space#Shape", or of a member, i.e. "name.space#Shape$member". It is a
+// string, so ids can be converted to and from the string form that the rest of the API uses.
+type ShapeID string
+
+var shapeIDPattern = regexp.MustCompile(`^_*[A-Za-z][A-Za-z0-9_]*(\._*[A-Za-z][A-Za-z0-9_]*)*#_*[A-Za-z][A-Za-z0-9_]*(\$_*[A-Za-z][A-Za-z0-9_]*)?$`)
+var identifierPattern = regexp.MustCompile(`^_*[A-Za-z][A-Za-z0-9_]*$`)
+
+// isIdentifier returns true if the string can be written in IDL without quotes, i.e. as the key of an object.
+func isIdentifier(s string) bool {
+	return identifierPattern.MatchString(s)
+}
+
+// NewShapeID returns the id of the shape with the name in the namespace.
+func NewShapeID(namespace, name string) ShapeID {
+	return ShapeID(namespace + "#" + name)
+}
+
+// ParseShapeID returns the ShapeID for the string, or an error if it is not an absolute shape or member id.
+func ParseShapeID(s string) (ShapeID, error) {
+	id := ShapeID(s)
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Validate returns an error if the id is not an absolute shape or member id.
+func (id ShapeID) Validate() error {
+	if !shapeIDPattern.MatchString(string(id)) {
+		return fmt.Errorf("Invalid shape id: %q", string(id))
+	}
+	return nil
+}
+
+// Namespace returns the namespace of the id, or "" if it is relative.
+func (id ShapeID) Namespace() string {
+	if i := strings.Index(string(id), "#"); i >= 0 {
+		return string(id[:i])
+	}
+	return ""
+}
+
+// Name returns the name of the shape, without its namespace or member.
+func (id ShapeID) Name() string {
+	s := string(id)
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = s[i+1:]
+	}
+	if i := strings.Index(s, "$"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// Member returns the name of the member, or "" if the id is not a member id.
+func (id ShapeID) Member() string {
+	if i := strings.Index(string(id), "$"); i >= 0 {
+		return string(id[i+1:])
+	}
+	return ""
+}
+
+// Shape returns the id of the shape, without the member.
+func (id ShapeID) Shape() ShapeID {
+	if i := strings.Index(string(id), "$"); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// WithMember returns the id of the member of the shape with the name.
+func (id ShapeID) WithMember(member string) ShapeID {
+	return id.Shape() + ShapeID("$"+member)
+}
+
+func (id ShapeID) String() string {
+	return string(id)
+}
+
+// ShapeIDs returns the ids of the shapes of the model, in order.
+func (ast *AST) ShapeIDs() []ShapeID {
+	var ids []ShapeID
+	if ast.Shapes != nil {
+		for _, k := range ast.Shapes.Keys() {
+			ids = append(ids, ShapeID(k))
+		}
+	}
+	return ids
+}
+
+// ShapeByID returns the shape with the id, as GetShape does.
+func (ast *AST) ShapeByID(id ShapeID) *Shape {
+	return ast.GetShape(string(id))
+}
+
+// MemberByID returns the member with the id, or nil if it is not defined.
+func (ast *AST) MemberByID(id ShapeID) *Member {
+	return ast.getMember(string(id))
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// OutputSink receives the files emitted by a generator, instead of them being written to the output directory or
+// stdout. It is given to a generator with SetSink (see SinkSetter). The paths are relative, and use "/".
+type OutputSink interface {
+	WriteFile(path string, content []byte) error
+}
+
+// DirSink writes the files under a directory, creating subdirectories as needed, and replacing existing files.
+type DirSink struct {
+	Dir string
+}
+
+func (s *DirSink) WriteFile(path string, content []byte) error {
+	fpath := filepath.Join(s.Dir, filepath.FromSlash(path))
+	err := os.MkdirAll(filepath.Dir(fpath), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, content, 0644)
+}
+
+// ZipSink writes the files as the entries of a zip archive. Close must be called to finish the archive.
+type ZipSink struct {
+	writer *zip.Writer
+}
+
+func NewZipSink(w io.Writer) *ZipSink {
+	return &ZipSink{writer: zip.NewWriter(w)}
+}
+
+func (s *ZipSink) WriteFile(path string, content []byte) error {
+	w, err := s.writer.Create(filepath.ToSlash(path))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (s *ZipSink) Close() error {
+	return s.writer.Close()
+}
+
+// MemorySink keeps the files in memory, so they can be used by the caller, i.e. uploaded.
+type MemorySink struct {
+	Paths []string          //in the order they were first written
+	Files map[string][]byte //the content of each file, by path
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{Files: make(map[string][]byte, 0)}
+}
+
+func (s *MemorySink) WriteFile(path string, content []byte) error {
+	if _, ok := s.Files[path]; !ok {
+		s.Paths = append(s.Paths, path)
+	}
+	s.Files[path] = content
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"sort"
+
+	"github.com/boynton/data"
+)
+
+// Sorted returns a copy of the model with its shapes, metadata and traits, and the keys of the objects in their
+// values, in alphabetical order, so that generated output does not depend on the order the model was written in.
+// The order of members is meaningful, so it is kept.
+func (ast *AST) Sorted() *AST {
+	c := ast.Clone()
+	if c == nil {
+		return nil
+	}
+	c.Metadata = sortedObject(c.Metadata)
+	if c.Shapes != nil {
+		shapes := NewShapes()
+		for _, k := range sortedStrings(c.Shapes.Keys()) {
+			shape := c.GetShape(k)
+			shape.Traits = sortedObject(shape.Traits)
+			for _, mem := range []*Member{shape.Member, shape.Key, shape.Value} {
+				if mem != nil {
+					mem.Traits = sortedObject(mem.Traits)
+				}
+			}
+			for _, name := range shape.Members.Keys() {
+				mem := shape.Members.Get(name)
+				mem.Traits = sortedObject(mem.Traits)
+			}
+			shapes.Put(k, shape)
+		}
+		c.Shapes = shapes
+	}
+	return c
+}
+
+func sortedStrings(keys []string) []string {
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func sortedObject(obj *data.Object) *data.Object {
+	if obj == nil {
+		return nil
+	}
+	sorted := data.NewObject()
+	for _, k := range sortedStrings(obj.Keys()) {
+		sorted.Put(k, sortedNode(obj.Get(k)))
+	}
+	return sorted
+}
+
+// sortedNode sorts the keys of the objects in the value. Go maps are already written with their keys sorted.
+func sortedNode(v interface{}) interface{} {
+	switch n := v.(type) {
+	case *data.Object:
+		return sortedObject(n)
+	case map[string]interface{}:
+		for k, e := range n {
+			n[k] = sortedNode(e)
+		}
+	case []interface{}:
+		for i, e := range n {
+			n[i] = sortedNode(e)
+		}
+	case []map[string]interface{}:
+		for _, e := range n {
+			sortedNode(e)
+		}
+	case []*data.Object:
+		for i, e := range n {
+			n[i] = sortedObject(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"strings"
+)
+
+// Stats are counts of the shapes of a model, other than the prelude shapes.
+type Stats struct {
+	Shapes                int            `json:"shapes"`
+	Members               int            `json:"members"`
+	ShapeTypes            map[string]int `json:"shapeTypes"`            //the number of shapes of each type
+	Namespaces            map[string]int `json:"namespaces"`            //the number of shapes in each namespace
+	Documented            int            `json:"documented"`            //the number of shapes with documentation
+	DocumentationCoverage float64        `json:"documentationCoverage"` //the percentage of shapes with documentation
+	Traits                map[string]int `json:"traits"`                //the number of shapes and members with each trait
+}
+
+// Stats returns the counts of the shapes of the model by type and namespace, the documentation coverage, and how
+// many shapes and members have each trait.
+func (ast *AST) Stats() *Stats {
+	stats := &Stats{
+		ShapeTypes: make(map[string]int, 0),
+		Namespaces: make(map[string]int, 0),
+		Traits:     make(map[string]int, 0),
+	}
+	if ast.Shapes == nil {
+		return stats
+	}
+	for _, id := range ast.Shapes.Keys() {
+		if strings.HasPrefix(id, "smithy.api#") {
+			continue
+		}
+		shape := ast.GetShape(id)
+		stats.Shapes++
+		stats.ShapeTypes[shape.Type]++
+		stats.Namespaces[shapeIdNamespace(id)]++
+		if shape.Traits.Has("smithy.api#documentation") {
+			stats.Documented++
+		}
+		for _, tid := range shape.Traits.Keys() {
+			stats.Traits[tid]++
+		}
+		for _, mid := range memberIds(id, shape) {
+			stats.Members++
+			for _, tid := range ast.getMember(mid).Traits.Keys() {
+				stats.Traits[tid]++
+			}
+		}
+	}
+	if stats.Shapes > 0 {
+		stats.DocumentationCoverage = float64(stats.Documented) * 100 / float64(stats.Shapes)
+	}
+	return stats
+}
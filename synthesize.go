@@ -0,0 +1,250 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boynton/data"
+)
+
+// recursive shapes are only expanded to this depth when synthesizing values
+const MaxSynthesisDepth = 5
+
+// required members and the members of unions are expanded past MaxSynthesisDepth, but no further than this, so that
+// cycles through them end
+const maxRequiredSynthesisDepth = 2 * MaxSynthesisDepth
+
+// SynthesizeValue produces a plausible JSON value for the given shape ID, suitable for mock responses and
+// documentation examples. The @length, @range, and @pattern constraints of the shape are honored.
+func (ast *AST) SynthesizeValue(target string) interface{} {
+	return ast.synthesize(target, nil, 0)
+}
+
+// synthesize a value for the target. The traits are those of the referring member, if any, and take precedence
+// over the constraints of the target shape.
+func (ast *AST) synthesize(target string, traits *data.Object, depth int) interface{} {
+	switch target {
+	case "smithy.api#String":
+		return synthesizeString("string", traits)
+	case "smithy.api#Boolean", "smithy.api#PrimitiveBoolean":
+		return true
+	case "smithy.api#Byte", "smithy.api#Short", "smithy.api#Integer", "smithy.api#Long", "smithy.api#BigInteger",
+		"smithy.api#PrimitiveByte", "smithy.api#PrimitiveShort", "smithy.api#PrimitiveInteger", "smithy.api#PrimitiveLong":
+		return synthesizeNumber(1, true, traits)
+	case "smithy.api#Float", "smithy.api#Double", "smithy.api#BigDecimal", "smithy.api#PrimitiveFloat", "smithy.api#PrimitiveDouble":
+		return synthesizeNumber(1.5, false, traits)
+	case "smithy.api#Timestamp":
+		return "2021-01-01T00:00:00Z"
+	case "smithy.api#Blob":
+		return "ZXhhbXBsZQ=="
+	case "smithy.api#Document", "smithy.api#Unit":
+		return data.NewObject()
+	}
+	shape := ast.GetShape(target)
+	if shape == nil {
+		return nil
+	}
+	return ast.synthesizeShape(StripNamespace(target), shape, constraintTraits(traits, shape.Traits), depth)
+}
+
+// constraintTraits merges the constraint traits of a member over those of its target
+func constraintTraits(memberTraits, shapeTraits *data.Object) *data.Object {
+	merged := data.NewObject()
+	for _, k := range []string{"smithy.api#length", "smithy.api#range", "smithy.api#pattern"} {
+		if memberTraits.Has(k) {
+			merged.Put(k, memberTraits.Get(k))
+		} else if shapeTraits.Has(k) {
+			merged.Put(k, shapeTraits.Get(k))
+		}
+	}
+	return merged
+}
+
+func (ast *AST) synthesizeShape(name string, shape *Shape, traits *data.Object, depth int) interface{} {
+	switch shape.Type {
+	case "string":
+		if items := shape.Traits.GetArray("smithy.api#enum"); len(items) > 0 {
+			return data.AsObject(items[0]).GetString("value")
+		}
+		return synthesizeString(Uncapitalize(name), traits)
+	case "enum":
+		for _, k := range shape.Members.Keys() {
+			if v := shape.Members.Get(k).Traits.GetString("smithy.api#enumValue"); v != "" {
+				return v
+			}
+			return k
+		}
+		return ""
+	case "intEnum":
+		for _, k := range shape.Members.Keys() {
+			return shape.Members.Get(k).Traits.GetInt("smithy.api#enumValue")
+		}
+		return 0
+	case "boolean":
+		return true
+	case "byte", "short", "integer", "long", "bigInteger":
+		return synthesizeNumber(1, true, traits)
+	case "float", "double", "bigDecimal":
+		return synthesizeNumber(1.5, false, traits)
+	case "timestamp":
+		return "2021-01-01T00:00:00Z"
+	case "blob":
+		return "ZXhhbXBsZQ=="
+	case "document":
+		return data.NewObject()
+	case "list", "set":
+		items := make([]interface{}, 0)
+		count := 1
+		if l := traits.GetObject("smithy.api#length"); l != nil {
+			if l.Has("min") {
+				count = l.GetInt("min")
+			} else if l.Has("max") && l.GetInt("max") < 1 {
+				count = 0
+			}
+		}
+		if depth >= MaxSynthesisDepth && count > 0 {
+			count = 0
+		}
+		for i := 0; i < count; i++ {
+			items = append(items, ast.synthesize(shape.Member.Target, shape.Member.Traits, depth+1))
+		}
+		return items
+	case "map":
+		obj := data.NewObject()
+		if depth < MaxSynthesisDepth {
+			key := data.AsString(ast.synthesize(shape.Key.Target, shape.Key.Traits, depth+1))
+			if key == "" {
+				key = "key"
+			}
+			obj.Put(key, ast.synthesize(shape.Value.Target, shape.Value.Traits, depth+1))
+		}
+		return obj
+	case "structure":
+		obj := data.NewObject()
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			if depth >= MaxSynthesisDepth && (!mem.Traits.Has("smithy.api#required") || depth >= maxRequiredSynthesisDepth) {
+				continue
+			}
+			obj.Put(k, ast.synthesize(mem.Target, mem.Traits, depth+1))
+		}
+		return obj
+	case "union":
+		obj := data.NewObject()
+		if depth >= maxRequiredSynthesisDepth {
+			return obj
+		}
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			obj.Put(k, ast.synthesize(mem.Target, mem.Traits, depth+1))
+			break
+		}
+		return obj
+	}
+	return nil
+}
+
+func synthesizeNumber(n float64, integral bool, traits *data.Object) interface{} {
+	if r := traits.GetObject("smithy.api#range"); r != nil {
+		min := data.AsDecimal(r.Get("min"))
+		max := data.AsDecimal(r.Get("max"))
+		if min != nil && max != nil {
+			n = (min.AsFloat64() + max.AsFloat64()) / 2
+		} else if min != nil {
+			n = min.AsFloat64()
+		} else if max != nil && max.AsFloat64() < n {
+			n = max.AsFloat64()
+		}
+	}
+	if integral {
+		return int64(n)
+	}
+	return n
+}
+
+// synthesizeString returns the shortest string that matches the @pattern, if there is one, padded or truncated to the
+// @length. Padding is with "x", or else with the last character, as a pattern like "^[0-9]+$" requires, and if neither
+// still matches the pattern, the length is not honored.
+func synthesizeString(s string, traits *data.Object) string {
+	var re *regexp.Regexp
+	if pat := traits.GetString("smithy.api#pattern"); pat != "" {
+		if parsed, err := syntax.Parse(pat, syntax.Perl); err == nil {
+			var buf strings.Builder
+			synthesizeRegexp(parsed.Simplify(), &buf)
+			s = buf.String()
+		}
+		re, _ = regexp.Compile(pat)
+	}
+	l := traits.GetObject("smithy.api#length")
+	if l == nil {
+		return s
+	}
+	pads := []string{"x"}
+	if r, size := utf8.DecodeLastRuneInString(s); size > 0 && r != 'x' {
+		pads = append(pads, string(r))
+	}
+	for _, pad := range pads {
+		fitted := s
+		if l.Has("min") {
+			for min := l.GetInt("min"); len(fitted) < min; {
+				fitted = fitted + pad
+			}
+		}
+		if l.Has("max") {
+			if max := l.GetInt("max"); len(fitted) > max {
+				fitted = fitted[:max]
+			}
+		}
+		if re == nil || re.MatchString(fitted) {
+			return fitted
+		}
+	}
+	return s
+}
+
+// generate the shortest string the regular expression matches, preferring letters from character classes
+func synthesizeRegexp(re *syntax.Regexp, buf *strings.Builder) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			buf.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		if len(re.Rune) >= 2 {
+			buf.WriteRune(re.Rune[0])
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		buf.WriteRune('x')
+	case syntax.OpCapture:
+		synthesizeRegexp(re.Sub[0], buf)
+	case syntax.OpPlus:
+		synthesizeRegexp(re.Sub[0], buf)
+	case syntax.OpRepeat:
+		for i := 0; i < re.Min; i++ {
+			synthesizeRegexp(re.Sub[0], buf)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			synthesizeRegexp(sub, buf)
+		}
+	case syntax.OpAlternate:
+		synthesizeRegexp(re.Sub[0], buf)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"sort"
+	"strings"
+)
+
+// TopologicalShapeOrder returns the ids of the shapes of the model, other than the prelude shapes, ordered so that
+// each shape comes after the shapes it depends on (see Shape.Dependencies, and its mixins), for generators that must
+// define types before they are used. Otherwise the order of the model is kept. Recursive shapes cannot be ordered
+// that way, so they are also returned as cycles: each is a set of shapes that depend on each other, directly or
+// indirectly, in the order of the model, and the shapes of a cycle are adjacent in the result.
+func (ast *AST) TopologicalShapeOrder() ([]string, [][]string) {
+	t := &topoSorter{
+		ast:     ast,
+		index:   make(map[string]int, 0),
+		lowlink: make(map[string]int, 0),
+		onStack: make(map[string]bool, 0),
+		order:   make([]string, 0),
+	}
+	if ast.Shapes == nil {
+		return t.order, nil
+	}
+	t.position = make(map[string]int, 0)
+	for i, id := range ast.Shapes.Keys() {
+		t.position[id] = i
+	}
+	for _, id := range ast.Shapes.Keys() {
+		if !t.included(id) {
+			continue
+		}
+		if _, ok := t.index[id]; !ok {
+			t.visit(id)
+		}
+	}
+	return t.order, t.cycles
+}
+
+// topoSorter finds the strongly connected components of the dependency graph with Tarjan's algorithm, which
+// produces them with the dependencies of each before it.
+type topoSorter struct {
+	ast      *AST
+	position map[string]int
+	index    map[string]int
+	lowlink  map[string]int
+	onStack  map[string]bool
+	stack    []string
+	next     int
+	order    []string
+	cycles   [][]string
+}
+
+func (t *topoSorter) included(id string) bool {
+	return t.ast.GetShape(id) != nil && !strings.HasPrefix(id, "smithy.api#")
+}
+
+func (t *topoSorter) dependencies(id string) []string {
+	shape := t.ast.GetShape(id)
+	deps := shape.Dependencies()
+	for _, ref := range shape.Mixins {
+		deps = append(deps, ref.Target)
+	}
+	return deps
+}
+
+func (t *topoSorter) visit(id string) {
+	t.index[id] = t.next
+	t.lowlink[id] = t.next
+	t.next++
+	t.stack = append(t.stack, id)
+	t.onStack[id] = true
+	selfDependent := false
+	for _, dep := range t.dependencies(id) {
+		if !t.included(dep) {
+			continue
+		}
+		if dep == id {
+			selfDependent = true
+		}
+		if _, ok := t.index[dep]; !ok {
+			t.visit(dep)
+			if t.lowlink[dep] < t.lowlink[id] {
+				t.lowlink[id] = t.lowlink[dep]
+			}
+		} else if t.onStack[dep] && t.index[dep] < t.lowlink[id] {
+			t.lowlink[id] = t.index[dep]
+		}
+	}
+	if t.lowlink[id] != t.index[id] {
+		return
+	}
+	var component []string
+	for {
+		n := len(t.stack) - 1
+		member := t.stack[n]
+		t.stack = t.stack[:n]
+		t.onStack[member] = false
+		component = append(component, member)
+		if member == id {
+			break
+		}
+	}
+	//within a component there is no dependency order, so the order of the model is used
+	sort.Slice(component, func(i, j int) bool {
+		return t.position[component[i]] < t.position[component[j]]
+	})
+	t.order = append(t.order, component...)
+	if len(component) > 1 || selfDependent {
+		t.cycles = append(t.cycles, component)
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// ValidateTraits checks every application of a trait that is defined in the assembly (a shape with the @trait trait),
+// or is one of the AWS traits, against the shape that defines it. Unknown structure members, missing required members,
+// and values of the wrong type are rejected. Annotation values (i.e. "@foo" with no value) are coerced to the empty
+// value of the trait's type, and object values are normalized to *data.Object, so the traits are updated in place.
+func (ast *AST) ValidateTraits() error {
+	for _, ev := range ast.traitValueEvents() {
+		return fmt.Errorf("%s", ev.Message)
+	}
+	return nil
+}
+
+// traitValueEvents checks the values of the traits applied to every shape and member, returning an event for each
+// one that is bad.
+func (ast *AST) traitValueEvents() []*ValidationEvent {
+	v := &traitValueVisitor{ast: ast}
+	ast.Walk(v)
+	return v.events
+}
+
+type traitValueVisitor struct {
+	BaseShapeVisitor
+	ast    *AST
+	events []*ValidationEvent
+}
+
+func (v *traitValueVisitor) PreShape(id string, shape *Shape) error {
+	v.events = append(v.events, v.ast.validateTraitValues(id, shape.Traits)...)
+	return nil
+}
+
+func (v *traitValueVisitor) VisitMember(shapeId string, name string, member *Member) error {
+	v.events = append(v.events, v.ast.validateTraitValues(shapeId+"$"+name, member.Traits)...)
+	return nil
+}
+
+func (ast *AST) validateTraitValues(context string, traits *data.Object) []*ValidationEvent {
+	var events []*ValidationEvent
+	for _, tid := range traits.Keys() {
+		defs, def := ast.traitDefinition(tid)
+		if def == nil {
+			continue
+		}
+		v := traits.Get(tid)
+		if o, ok := v.(*data.Object); ok && o.Length() == 0 {
+			v = annotationValue(def)
+		}
+		v, err := defs.coerceNode("", def, v)
+		if err != nil {
+			events = append(events, NewValidationEvent(ast, "TraitValue", SeverityError, context, "Bad value for trait %s on %s: %v", tid, context, err))
+			continue
+		}
+		traits.Put(tid, v)
+	}
+	return events
+}
+
+// annotationValue returns the value of a trait that is applied without one.
+func annotationValue(def *Shape) interface{} {
+	switch def.Type {
+	case "list", "set":
+		return []interface{}{}
+	case "boolean":
+		return true
+	}
+	return data.NewObject()
+}
+
+// nodeShape returns the shape that a node value for the target must conform to. Prelude types are not in the assembly,
+// so a shape with just the type is synthesized for them.
+func (ast *AST) nodeShape(target string) *Shape {
+	if shape := ast.GetShape(target); shape != nil {
+		return shape
+	}
+	if strings.HasPrefix(target, "smithy.api#") {
+		name := StripNamespace(target)
+		if name == "Unit" {
+			return &Shape{Type: "structure"}
+		}
+		if IsPreludeType(name) || strings.HasPrefix(name, "Primitive") {
+			return &Shape{Type: Uncapitalize(strings.TrimPrefix(name, "Primitive"))}
+		}
+	}
+	return nil
+}
+
+func (ast *AST) coerceNode(path string, shape *Shape, v interface{}) (interface{}, error) {
+	switch shape.Type {
+	case "structure", "union":
+		obj, ok := nodeObject(v)
+		if !ok {
+			return nil, nodeError(path, "expected an object")
+		}
+		if shape.Type == "union" && obj.Length() != 1 {
+			return nil, nodeError(path, "expected exactly one member of the union")
+		}
+		members := ast.allMembers(shape)
+		for _, k := range obj.Keys() {
+			mem := members.Get(k)
+			if mem == nil {
+				return nil, nodeError(path, "unknown member %q", k)
+			}
+		}
+		for _, k := range members.Keys() {
+			mem := members.Get(k)
+			if !obj.Has(k) {
+				if mem.Traits.Has("smithy.api#required") && !mem.Traits.Has("smithy.api#default") {
+					return nil, nodeError(path, "missing required member %q", k)
+				}
+				continue
+			}
+			mv, err := ast.coerceMember(joinNodePath(path, k), mem, obj.Get(k))
+			if err != nil {
+				return nil, err
+			}
+			obj.Put(k, mv)
+		}
+		return obj, nil
+	case "map":
+		obj, ok := nodeObject(v)
+		if !ok {
+			return nil, nodeError(path, "expected an object")
+		}
+		for _, k := range obj.Keys() {
+			mv, err := ast.coerceMember(joinNodePath(path, k), shape.Value, obj.Get(k))
+			if err != nil {
+				return nil, err
+			}
+			obj.Put(k, mv)
+		}
+		return obj, nil
+	case "list", "set":
+		lst, ok := v.([]interface{})
+		if !ok {
+			return nil, nodeError(path, "expected an array")
+		}
+		for i, item := range lst {
+			iv, err := ast.coerceMember(fmt.Sprintf("%s[%d]", path, i), shape.Member, item)
+			if err != nil {
+				return nil, err
+			}
+			lst[i] = iv
+		}
+		return lst, nil
+	case "string", "enum":
+		s, ok := nodeString(v)
+		if !ok {
+			return nil, nodeError(path, "expected a string")
+		}
+		if !enumAllows(shape, s) {
+			return nil, nodeError(path, "%q is not one of the enum values", s)
+		}
+		return s, nil
+	case "blob":
+		if _, ok := nodeString(v); !ok {
+			return nil, nodeError(path, "expected a string")
+		}
+		return v, nil
+	case "timestamp":
+		if _, ok := nodeString(v); !ok && data.AsDecimal(v) == nil {
+			return nil, nodeError(path, "expected a string or a number")
+		}
+		return v, nil
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return nil, nodeError(path, "expected a boolean")
+		}
+		return v, nil
+	case "byte", "short", "integer", "long", "bigInteger", "intEnum":
+		n := nodeNumber(v)
+		if n == nil {
+			return nil, nodeError(path, "expected a number")
+		}
+		if f := n.AsFloat64(); f != math.Trunc(f) {
+			return nil, nodeError(path, "expected an integer, found %v", n)
+		}
+		return n, nil
+	case "float", "double", "bigDecimal":
+		n := nodeNumber(v)
+		if n == nil {
+			return nil, nodeError(path, "expected a number")
+		}
+		return n, nil
+	}
+	//documents, and anything else, accept any value
+	return v, nil
+}
+
+func (ast *AST) coerceMember(path string, mem *Member, v interface{}) (interface{}, error) {
+	target := ast.nodeShape(mem.Target)
+	if target == nil {
+		return nil, nodeError(path, "shape not defined: %s", mem.Target)
+	}
+	return ast.coerceNode(path, target, v)
+}
+
+// allMembers returns the members of the structure including those from its mixins.
+func (ast *AST) allMembers(shape *Shape) *Members {
+	result := NewMembers()
+	for _, ref := range shape.Mixins {
+		if mixin := ast.GetShape(ref.Target); mixin != nil {
+			mems := ast.allMembers(mixin)
+			for _, k := range mems.Keys() {
+				result.Put(k, mems.Get(k))
+			}
+		}
+	}
+	if shape.Members != nil {
+		for _, k := range shape.Members.Keys() {
+			result.Put(k, shape.Members.Get(k))
+		}
+	}
+	return result
+}
+
+func enumAllows(shape *Shape, s string) bool {
+	if shape.Type == "enum" {
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			val := k
+			if ev := mem.Traits.Get("smithy.api#enumValue"); ev != nil {
+				val = data.AsString(ev)
+			}
+			if val == s {
+				return true
+			}
+		}
+		return false
+	}
+	if items := shape.Traits.GetArray("smithy.api#enum"); items != nil {
+		for _, item := range items {
+			if data.AsString(data.AsMap(item)["value"]) == s {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func nodeObject(v interface{}) (*data.Object, bool) {
+	switch o := v.(type) {
+	case *data.Object:
+		return o, true
+	case map[string]interface{}:
+		//IDL object literals are unordered, so use the order they are emitted in as JSON
+		obj := data.NewObject()
+		for _, k := range sortedKeys(o) {
+			obj.Put(k, o[k])
+		}
+		return obj, true
+	}
+	return nil, false
+}
+
+func nodeString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case *string:
+		return *s, true
+	}
+	return "", false
+}
+
+func nodeNumber(v interface{}) *data.Decimal {
+	switch n := v.(type) {
+	case int:
+		return data.NewDecimal(float64(n))
+	case int32:
+		return data.NewDecimal(float64(n))
+	case int64:
+		return data.NewDecimal(float64(n))
+	}
+	return data.AsDecimal(v)
+}
+
+func nodeError(path string, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if path == "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("%s: %s", path, msg)
+}
+
+func joinNodePath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
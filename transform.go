@@ -0,0 +1,514 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/boynton/data"
+)
+
+// A Transform changes a model in place, i.e. to produce a trimmed view of it for a projection. The args are those of
+// the transform in the projection config, and may be nil.
+type Transform interface {
+	Transform(ast *AST, args *data.Object) error
+}
+
+// TransformFunc adapts a function to the Transform interface.
+type TransformFunc func(ast *AST, args *data.Object) error
+
+func (f TransformFunc) Transform(ast *AST, args *data.Object) error {
+	return f(ast, args)
+}
+
+var transforms = map[string]Transform{
+	"includeShapesByTag":      TransformFunc(includeShapesByTag),
+	"excludeShapesByTag":      TransformFunc(excludeShapesByTag),
+	"includeShapesBySelector": TransformFunc(includeShapesBySelector),
+	"removeTraits":            TransformFunc(removeTraitsTransform),
+	"renameNamespace":         TransformFunc(renameNamespace),
+	"flattenMixins":           TransformFunc(flattenMixins),
+	"removeUnusedShapes":      TransformFunc(removeUnusedShapes),
+	"upgradeToV2": TransformFunc(func(ast *AST, args *data.Object) error {
+		ast.UpgradeToV2()
+		return nil
+	}),
+	"downgradeToV1": TransformFunc(func(ast *AST, args *data.Object) error {
+		ast.DowngradeToV1()
+		return nil
+	}),
+}
+var transformsLock sync.Mutex
+
+// RegisterTransform makes the transform available to projections by name, replacing any existing transform with
+// the name.
+func RegisterTransform(name string, t Transform) {
+	transformsLock.Lock()
+	defer transformsLock.Unlock()
+	transforms[name] = t
+}
+
+// LookupTransform returns the transform with the name, or nil if there is none.
+func LookupTransform(name string) Transform {
+	transformsLock.Lock()
+	defer transformsLock.Unlock()
+	return transforms[name]
+}
+
+// ApplyTransforms runs the transforms of a projection on the model, in order, stopping at the first that fails. The
+// built in transforms are:
+//
+//	includeShapesByTag: keeps the shapes with any of the "tags", and the shapes they depend on (as Filter does)
+//	excludeShapesByTag: removes the shapes with any of the "tags" (as FilterExcludeTags does)
+//	includeShapesBySelector: keeps only the shapes matched by the "selector", or that have a member matched by it
+//	removeTraits: removes the "traits" (ids, names of prelude traits, or "ns#" for all the traits of a namespace)
+//	renameNamespace: moves the shapes to new namespaces, given as a "namespaces" object mapping old to new
+//	flattenMixins: resolves the mixins of every shape and removes the mixins (as FlattenMixins does)
+//	removeUnusedShapes: removes the shapes that UnreferencedShapes returns, keeping trait definitions (and the shapes
+//	they use) unless "keepTraitDefinitions" is false
+//	upgradeToV2, downgradeToV1: change the model to the semantics of the other IDL version (see UpgradeToV2)
+func (ast *AST) ApplyTransforms(configs []*TransformConfig) error {
+	if ast.Shapes == nil {
+		ast.Shapes = NewShapes()
+	}
+	defer ast.ResetIndex()
+	for _, tc := range configs {
+		t := LookupTransform(tc.Name)
+		if t == nil {
+			return fmt.Errorf("Unknown transform: %q", tc.Name)
+		}
+		ast.ResetIndex()
+		err := t.Transform(ast, tc.Args)
+		if err != nil {
+			return fmt.Errorf("Transform %s: %v", tc.Name, err)
+		}
+	}
+	return nil
+}
+
+func transformTags(args *data.Object) ([]string, error) {
+	tags := args.GetStringArray("tags")
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("A list of tags is required")
+	}
+	return tags, nil
+}
+
+func includeShapesByTag(ast *AST, args *data.Object) error {
+	tags, err := transformTags(args)
+	if err == nil {
+		ast.Filter(tags)
+	}
+	return err
+}
+
+func excludeShapesByTag(ast *AST, args *data.Object) error {
+	tags, err := transformTags(args)
+	if err == nil {
+		ast.FilterExcludeTags(tags)
+	}
+	return err
+}
+
+func includeShapesBySelector(ast *AST, args *data.Object) error {
+	expr := args.GetString("selector")
+	if expr == "" {
+		return fmt.Errorf("A selector is required")
+	}
+	matches, err := ast.Select(expr)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, 0)
+	for _, id := range matches {
+		keep[strings.SplitN(id, "$", 2)[0]] = true
+	}
+	var remove []string
+	for _, id := range ast.Shapes.Keys() {
+		if !keep[id] {
+			remove = append(remove, id)
+		}
+	}
+	ast.RemoveShapes(remove)
+	return nil
+}
+
+func removeUnusedShapes(ast *AST, args *data.Object) error {
+	keepTraits := !args.Has("keepTraitDefinitions") || args.GetBool("keepTraitDefinitions")
+	ast.RemoveShapes(ast.unreferencedShapes(keepTraits))
+	return nil
+}
+
+func removeTraitsTransform(ast *AST, args *data.Object) error {
+	names := args.GetStringArray("traits")
+	if len(names) == 0 {
+		return fmt.Errorf("A list of traits is required")
+	}
+	matches := func(tid string) bool {
+		for _, name := range names {
+			if !strings.Contains(name, "#") {
+				name = "smithy.api#" + name
+			}
+			if tid == name || (strings.HasSuffix(name, "#") && strings.HasPrefix(tid, name)) {
+				return true
+			}
+		}
+		return false
+	}
+	without := func(traits *data.Object) {
+		if traits == nil {
+			return
+		}
+		for _, k := range traits.Keys() {
+			if matches(k) {
+				ObjectDelete(traits, k)
+			}
+		}
+	}
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		without(shape.Traits)
+		for _, mid := range memberIds(id, shape) {
+			without(ast.getMember(mid).Traits)
+		}
+	}
+	return nil
+}
+
+func renameNamespace(ast *AST, args *data.Object) error {
+	namespaces := data.AsMap(args.Get("namespaces"))
+	if len(namespaces) == 0 {
+		return fmt.Errorf("A namespaces object is required")
+	}
+	rename := func(id string) string {
+		ns := ShapeID(id).Namespace()
+		if newNs, ok := namespaces[ns]; ok && ns != "" {
+			return data.AsString(newNs) + strings.TrimPrefix(id, ns)
+		}
+		return id
+	}
+	shapes := NewShapes()
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		renameReferences(shape, rename)
+		newId := rename(id)
+		if shapes.Get(newId) != nil {
+			return fmt.Errorf("Renaming %s conflicts with an existing shape", id)
+		}
+		shapes.Put(newId, shape)
+	}
+	ast.Shapes = shapes
+	return nil
+}
+
+// renameReferences changes the ids of the shapes and traits referred to by the shape.
+func renameReferences(shape *Shape, rename func(string) string) {
+	renameTraits := func(traits *data.Object) *data.Object {
+		if traits == nil {
+			return nil
+		}
+		result := data.NewObject()
+		for _, k := range traits.Keys() {
+			result.Put(rename(k), traits.Get(k))
+		}
+		return result
+	}
+	var refs []*ShapeRef
+	refs = append(refs, shape.Mixins...)
+	refs = append(refs, shape.Operations...)
+	refs = append(refs, shape.Resources...)
+	refs = append(refs, shape.Errors...)
+	refs = append(refs, shape.CollectionOperations...)
+	refs = append(refs, shape.Input, shape.Output, shape.Create, shape.Put, shape.Read, shape.Update, shape.Delete, shape.List)
+	for _, ref := range shape.Identifiers {
+		refs = append(refs, ref)
+	}
+	for _, ref := range shape.Properties {
+		refs = append(refs, ref)
+	}
+	for _, ref := range refs {
+		if ref != nil {
+			ref.Target = rename(ref.Target)
+		}
+	}
+	shape.Traits = renameTraits(shape.Traits)
+	var members []*Member
+	if shape.Members != nil {
+		for _, k := range shape.Members.Keys() {
+			members = append(members, shape.Members.Get(k))
+		}
+	}
+	members = append(members, shape.Member, shape.Key, shape.Value)
+	for _, mem := range members {
+		if mem != nil {
+			mem.Target = rename(mem.Target)
+			mem.Traits = renameTraits(mem.Traits)
+		}
+	}
+}
+
+func flattenMixins(ast *AST, args *data.Object) error {
+	ast.FlattenMixins()
+	return nil
+}
+
+// FlattenMixins resolves the mixins of every shape, and removes the mixin shapes, for consumers of the model that do
+// not understand mixins. As in Smithy, a shape gets the traits of its mixins, other than @mixin and the localTraits
+// of the mixin, with its own traits taking precedence. The members of its mixins come first, in the order of the
+// mixins, followed by its own, and a member it redeclares keeps its place but adds its own traits. The operations,
+// resources and errors of mixins are added to those of the shape, as are their identifiers and properties, and their
+// input, output and version are used if the shape has none. Mixins of mixins are resolved first.
+func (ast *AST) FlattenMixins() {
+	if ast.Shapes == nil {
+		return
+	}
+	flattened := make(map[string]bool, 0)
+	var mixins []string
+	for _, id := range ast.Shapes.Keys() {
+		ast.flattenShapeMixins(id, flattened)
+		if ast.GetShape(id).Traits.Has("smithy.api#mixin") {
+			mixins = append(mixins, id)
+		}
+	}
+	ast.RemoveShapes(mixins)
+}
+
+func (ast *AST) flattenShapeMixins(id string, flattened map[string]bool) {
+	shape := ast.GetShape(id)
+	if flattened[id] || shape == nil || len(shape.Mixins) == 0 {
+		return
+	}
+	flattened[id] = true
+	traits := ast.EffectiveTraits(id)
+	members := NewMembers()
+	for _, ref := range shape.Mixins {
+		ast.flattenShapeMixins(ref.Target, flattened)
+		mixin := ast.GetShape(ref.Target)
+		if mixin == nil {
+			continue
+		}
+		if mixin.Members != nil {
+			for _, k := range mixin.Members.Keys() {
+				members.Put(k, mixin.Members.Get(k).Clone())
+			}
+		}
+		shape.Operations = appendMissingRefs(shape.Operations, mixin.Operations)
+		shape.Resources = appendMissingRefs(shape.Resources, mixin.Resources)
+		shape.Errors = appendMissingRefs(shape.Errors, mixin.Errors)
+		shape.Identifiers = mergeRefMaps(shape.Identifiers, mixin.Identifiers)
+		shape.Properties = mergeRefMaps(shape.Properties, mixin.Properties)
+		if shape.Input == nil {
+			shape.Input = mixin.Input
+		}
+		if shape.Output == nil {
+			shape.Output = mixin.Output
+		}
+		if shape.Version == "" {
+			shape.Version = mixin.Version
+		}
+	}
+	if shape.Members != nil {
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			if inherited := members.Get(k); inherited != nil {
+				for _, tk := range mem.Traits.Keys() {
+					if inherited.Traits == nil {
+						inherited.Traits = data.NewObject()
+					}
+					inherited.Traits.Put(tk, mem.Traits.Get(tk))
+				}
+				if mem.Target != "" {
+					inherited.Target = mem.Target
+				}
+			} else {
+				members.Put(k, mem)
+			}
+		}
+	}
+	if traits.Length() > 0 {
+		shape.Traits = traits
+	}
+	if members.Length() > 0 {
+		shape.Members = members
+	}
+	shape.Mixins = nil
+}
+
+// isLocalTrait returns true if the trait id is one of the localTraits of a mixin, which may be relative ids.
+func isLocalTrait(id string, local []string) bool {
+	for _, lt := range local {
+		if lt == id || (!strings.Contains(lt, "#") && StripNamespace(id) == lt) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendMissingRefs(refs []*ShapeRef, more []*ShapeRef) []*ShapeRef {
+	for _, ref := range more {
+		found := false
+		for _, r := range refs {
+			if r.Target == ref.Target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			refs = append(refs, &ShapeRef{Target: ref.Target})
+		}
+	}
+	return refs
+}
+
+func mergeRefMaps(refs map[string]*ShapeRef, more map[string]*ShapeRef) map[string]*ShapeRef {
+	for k, ref := range more {
+		if refs == nil {
+			refs = make(map[string]*ShapeRef, 0)
+		}
+		if _, ok := refs[k]; !ok {
+			refs[k] = &ShapeRef{Target: ref.Target}
+		}
+	}
+	return refs
+}
+
+// shapesWithTags returns the ids of the shapes that have any of the tags.
+func (ast *AST) shapesWithTags(tags []string) []string {
+	var ids []string
+	if ast.Shapes != nil {
+		for _, id := range ast.Shapes.Keys() {
+			for _, t := range ast.GetShape(id).Traits.GetStringArray("smithy.api#tags") {
+				if containsString(tags, t) {
+					ids = append(ids, id)
+					break
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// RemoveShapes removes the shapes from the model, along with the references to them from the shapes that remain: the
+// members that target them, their bindings to services, resources and operations, and the application of any of
+// them that are traits. A list or map whose member, key or value targets a removed shape is itself removed.
+func (ast *AST) RemoveShapes(ids []string) {
+	if ast.Shapes == nil || len(ids) == 0 {
+		return
+	}
+	removed := make(map[string]bool, 0)
+	for _, id := range ids {
+		removed[id] = true
+	}
+	for more := true; more; {
+		more = false
+		for _, id := range ast.Shapes.Keys() {
+			shape := ast.GetShape(id)
+			if removed[id] {
+				continue
+			}
+			for _, mem := range []*Member{shape.Member, shape.Key, shape.Value} {
+				if mem != nil && removed[mem.Target] {
+					removed[id] = true
+					more = true
+				}
+			}
+		}
+	}
+	shapes := NewShapes()
+	for _, id := range ast.Shapes.Keys() {
+		if !removed[id] {
+			shape := ast.GetShape(id)
+			removeReferences(shape, removed)
+			shapes.Put(id, shape)
+		}
+	}
+	ast.Shapes = shapes
+	ast.ResetIndex()
+}
+
+func removeReferences(shape *Shape, removed map[string]bool) {
+	removeRef := func(ref *ShapeRef) *ShapeRef {
+		if ref != nil && removed[ref.Target] {
+			return nil
+		}
+		return ref
+	}
+	removeRefs := func(refs []*ShapeRef) []*ShapeRef {
+		var result []*ShapeRef
+		for _, ref := range refs {
+			if !removed[ref.Target] {
+				result = append(result, ref)
+			}
+		}
+		return result
+	}
+	shape.Traits = removeTraits(shape.Traits, removed)
+	if shape.Members != nil {
+		members := NewMembers()
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			if !removed[mem.Target] {
+				mem.Traits = removeTraits(mem.Traits, removed)
+				members.Put(k, mem)
+			}
+		}
+		shape.Members = members
+	}
+	for _, mem := range []*Member{shape.Member, shape.Key, shape.Value} {
+		if mem != nil {
+			mem.Traits = removeTraits(mem.Traits, removed)
+		}
+	}
+	shape.Mixins = removeRefs(shape.Mixins)
+	shape.Operations = removeRefs(shape.Operations)
+	shape.Resources = removeRefs(shape.Resources)
+	shape.Errors = removeRefs(shape.Errors)
+	shape.CollectionOperations = removeRefs(shape.CollectionOperations)
+	shape.Input = removeRef(shape.Input)
+	shape.Output = removeRef(shape.Output)
+	shape.Create = removeRef(shape.Create)
+	shape.Put = removeRef(shape.Put)
+	shape.Read = removeRef(shape.Read)
+	shape.Update = removeRef(shape.Update)
+	shape.Delete = removeRef(shape.Delete)
+	shape.List = removeRef(shape.List)
+	for k, ref := range shape.Identifiers {
+		if removed[ref.Target] {
+			delete(shape.Identifiers, k)
+		}
+	}
+	for k, ref := range shape.Properties {
+		if removed[ref.Target] {
+			delete(shape.Properties, k)
+		}
+	}
+}
+
+// removeTraits returns the traits without those whose definitions have been removed.
+func removeTraits(traits *data.Object, removed map[string]bool) *data.Object {
+	if traits == nil {
+		return nil
+	}
+	result := data.NewObject()
+	for _, k := range traits.Keys() {
+		if !removed[k] {
+			result.Put(k, traits.Get(k))
+		}
+	}
+	return result
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+type TypeScriptGenerator struct {
+	BaseGenerator
+}
+
+// Generate TypeScript type definitions, one file per namespace. The "dts" option produces ".d.ts" declaration
+// files instead of ".ts" files.
+func (gen *TypeScriptGenerator) Generate(ast *AST, config *data.Object) error {
+	err := gen.Configure(config)
+	if err != nil {
+		return err
+	}
+	suffix := ".ts"
+	if config.GetBool("dts") {
+		suffix = ".d.ts"
+	}
+	for _, ns := range ast.Namespaces() {
+		fname := gen.FileName(ns, suffix)
+		sep := fmt.Sprintf("\n// ===== File(%q)\n\n", fname)
+		s := gen.ToTypeScript(ast, ns)
+		err := gen.Emit(s, fname, sep)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type TypeScriptWriter struct {
+	buf       bytes.Buffer
+	writer    *bufio.Writer
+	namespace string
+	ast       *AST
+}
+
+func (gen *TypeScriptGenerator) ToTypeScript(ast *AST, ns string) string {
+	w := &TypeScriptWriter{
+		namespace: ns,
+		ast:       ast,
+	}
+	w.Begin()
+	w.Emit("// Generated from smithy source, namespace %s\n", ns)
+	nss, imports := externalShapeRefs(ast, ns)
+	for _, other := range nss {
+		names := imports[other]
+		w.Emit("import type { %s } from \"./%s\";\n", strings.Join(names, ", "), gen.FileName(other, ""))
+	}
+	for _, k := range ast.Shapes.Keys() {
+		if shapeIdNamespace(k) == ns {
+			w.EmitShape(StripNamespace(k), ast.GetShape(k))
+		}
+	}
+	return w.End()
+}
+
+func (w *TypeScriptWriter) Begin() {
+	w.buf.Reset()
+	w.writer = bufio.NewWriter(&w.buf)
+}
+
+func (w *TypeScriptWriter) Emit(format string, args ...interface{}) {
+	w.writer.WriteString(fmt.Sprintf(format, args...))
+}
+
+func (w *TypeScriptWriter) End() string {
+	w.writer.Flush()
+	return w.buf.String()
+}
+
+// externalShapeRefs returns the sorted namespaces other than the prelude that the data shapes of the namespace refer
+// to, and a map from each to the sorted names of the shapes referred to in it, which generators import.
+func externalShapeRefs(ast *AST, namespace string) ([]string, map[string][]string) {
+	refs := make(map[string]map[string]bool, 0)
+	note := func(target string) {
+		ns := shapeIdNamespace(target)
+		if ns == namespace || ns == "smithy.api" {
+			return
+		}
+		if refs[ns] == nil {
+			refs[ns] = make(map[string]bool, 0)
+		}
+		refs[ns][StripNamespace(target)] = true
+	}
+	for _, k := range ast.Shapes.Keys() {
+		if shapeIdNamespace(k) != namespace {
+			continue
+		}
+		shape := ast.GetShape(k)
+		switch shape.Type {
+		case "list", "set":
+			note(shape.Member.Target)
+		case "map":
+			note(shape.Value.Target)
+		case "structure", "union":
+			for _, n := range shape.Members.Keys() {
+				note(shape.Members.Get(n).Target)
+			}
+		}
+	}
+	var nss []string
+	for ns := range refs {
+		nss = append(nss, ns)
+	}
+	sort.Strings(nss)
+	result := make(map[string][]string, 0)
+	for _, ns := range nss {
+		var names []string
+		for n := range refs[ns] {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		result[ns] = names
+	}
+	return nss, result
+}
+
+func (w *TypeScriptWriter) EmitShape(name string, shape *Shape) {
+	switch shape.Type {
+	case "service", "resource", "operation":
+		return
+	}
+	w.Emit("\n")
+	w.EmitDocumentation(shape.Traits, "")
+	switch shape.Type {
+	case "structure":
+		w.EmitStructureShape(name, shape)
+	case "union":
+		w.EmitUnionShape(name, shape)
+	case "enum", "intEnum":
+		w.EmitEnumShape(name, shape)
+	case "list", "set":
+		w.Emit("export type %s = %s[];\n", name, w.typeRef(shape.Member.Target))
+	case "map":
+		w.Emit("export type %s = Record<string, %s>;\n", name, w.typeRef(shape.Value.Target))
+	case "string":
+		if items := shape.Traits.GetArray("smithy.api#enum"); items != nil {
+			var values []string
+			for _, item := range items {
+				values = append(values, fmt.Sprintf("%q", data.AsObject(item).GetString("value")))
+			}
+			w.Emit("export type %s = %s;\n", name, strings.Join(values, " | "))
+		} else {
+			w.Emit("export type %s = string;\n", name)
+		}
+	default:
+		w.Emit("export type %s = %s;\n", name, typeScriptSimpleType(shape.Type))
+	}
+}
+
+func (w *TypeScriptWriter) EmitDocumentation(traits *data.Object, indent string) {
+	doc := traits.GetString("smithy.api#documentation")
+	deprecated := traits.Has("smithy.api#deprecated")
+	if doc == "" && !deprecated {
+		return
+	}
+	w.Emit("%s/**\n", indent)
+	if doc != "" {
+		for _, line := range strings.Split(doc, "\n") {
+			w.Emit("%s%s\n", indent, TrimRightSpace(" * "+line))
+		}
+	}
+	if deprecated {
+		w.Emit("%s * @deprecated %s\n", indent, data.AsString(ObjectGetPath(traits, "smithy.api#deprecated.message")))
+	}
+	w.Emit("%s */\n", indent)
+}
+
+func (w *TypeScriptWriter) EmitStructureShape(name string, shape *Shape) {
+	w.Emit("export interface %s {\n", name)
+	for _, k := range shape.Members.Keys() {
+		mem := shape.Members.Get(k)
+		opt := "?"
+		if mem.Traits.Has("smithy.api#required") {
+			opt = ""
+		}
+		w.EmitDocumentation(mem.Traits, IndentAmount)
+		w.Emit("%s%s%s: %s;\n", IndentAmount, k, opt, w.typeRef(mem.Target))
+	}
+	w.Emit("}\n")
+}
+
+// Unions are serialized as an object with exactly one key set, so each variant declares the other keys as
+// "never" to make the union discriminated on key presence.
+func (w *TypeScriptWriter) EmitUnionShape(name string, shape *Shape) {
+	keys := shape.Members.Keys()
+	if len(keys) == 0 {
+		w.Emit("export type %s = never;\n", name)
+		return
+	}
+	w.Emit("export type %s =\n", name)
+	for i, k := range keys {
+		mem := shape.Members.Get(k)
+		fields := []string{fmt.Sprintf("%s: %s", k, w.typeRef(mem.Target))}
+		for _, other := range keys {
+			if other != k {
+				fields = append(fields, other+"?: never")
+			}
+		}
+		term := ""
+		if i == len(keys)-1 {
+			term = ";"
+		}
+		w.Emit("%s| { %s }%s\n", IndentAmount, strings.Join(fields, "; "), term)
+	}
+}
+
+func (w *TypeScriptWriter) EmitEnumShape(name string, shape *Shape) {
+	var values []string
+	for _, k := range shape.Members.Keys() {
+		v := shape.Members.Get(k).Traits.Get("smithy.api#enumValue")
+		if shape.Type == "intEnum" {
+			values = append(values, fmt.Sprint(data.AsInt(v)))
+		} else {
+			s := data.AsString(v)
+			if s == "" {
+				s = k
+			}
+			values = append(values, fmt.Sprintf("%q", s))
+		}
+	}
+	if len(values) == 0 {
+		values = append(values, "never")
+	}
+	w.Emit("export type %s = %s;\n", name, strings.Join(values, " | "))
+}
+
+func (w *TypeScriptWriter) typeRef(target string) string {
+	if shapeIdNamespace(target) == "smithy.api" {
+		switch StripNamespace(target) {
+		case "String", "Timestamp", "Blob":
+			return "string"
+		case "Boolean", "PrimitiveBoolean":
+			return "boolean"
+		case "Document":
+			return "unknown"
+		case "Unit":
+			return "Record<string, never>"
+		default:
+			return "number"
+		}
+	}
+	return StripNamespace(target)
+}
+
+func typeScriptSimpleType(shapeType string) string {
+	switch shapeType {
+	case "string", "timestamp", "blob":
+		return "string"
+	case "boolean":
+		return "boolean"
+	case "document":
+		return "unknown"
+	default:
+		return "number"
+	}
+}
@@ -17,8 +17,9 @@ package smithy
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 
 	"github.com/boynton/data"
@@ -35,41 +36,92 @@ func (ast *AST) NamespaceAndServiceVersion() (string, string, string) {
 		if strings.HasPrefix(k, "smithy.") || strings.HasPrefix(k, "aws.") {
 			continue
 		}
-		i := strings.Index(k, "#")
-		if i >= 0 {
-			namespace = k[:i]
-		}
+		id := ShapeID(k)
+		namespace = id.Namespace()
 		if v.Type == "service" {
 			version = v.Version
-			name = k[i+1:]
+			name = id.Name()
 			break
 		}
 	}
 	return namespace, name, version
 }
 
+// IdlOptions control how the IDL for a model is written.
+type IdlOptions struct {
+	//ShapeIds is how shape ids are written: "relative" (the default) writes them relative to the namespace, importing
+	//those from other namespaces with use statements where that is not ambiguous, "local" strips only the namespace
+	//being written, and "absolute" writes every id with its namespace.
+	ShapeIds string
+	//TextBlockDocs writes documentation as @documentation text blocks, preserving it exactly, rather than as wrapped
+	//"///" comments.
+	TextBlockDocs bool
+	//OmitMetadata leaves the metadata of the model out. It is not namespaced, so when a model is written as a file per
+	//namespace, not every file needs it.
+	OmitMetadata bool
+	//CST is the lossless parse of the source of the model, whose comments are written with the shapes and members they
+	//were attached to, for rewriting the file without losing them. The traits of a statement that had comments in their
+	//arguments are written as they were.
+	CST *CST
+}
+
 // Generate Smithy IDL to describe the Smithy model for a specified namespace
 func (ast *AST) IDL(ns string) string {
+	return ast.IDLWithOptions(ns, nil)
+}
+
+// IDLWithOptions generates the Smithy IDL for the namespace, as IDL does, with the options.
+func (ast *AST) IDLWithOptions(ns string, opts *IdlOptions) string {
+	var sb strings.Builder
+	ast.WriteIDL(&sb, ns, opts)
+	return sb.String()
+}
+
+// WriteIDL writes the Smithy IDL for the namespace to the writer as it is generated, rather than building it in memory
+// first, as IDLWithOptions does. The options may be nil.
+func (ast *AST) WriteIDL(out io.Writer, ns string, opts *IdlOptions) error {
+	if opts == nil {
+		opts = &IdlOptions{}
+	}
 	w := &IdlWriter{
 		ast:       ast,
 		namespace: ns,
 		version:   ast.AssemblyVersion(),
+		shapeIds:  opts.ShapeIds,
+		textBlock: opts.TextBlockDocs,
+		cst:       opts.CST,
 	}
 
-	w.Begin()
-	w.Emit("$version: \"%d\"\n", w.version)
+	w.Begin(out)
+	if w.cst != nil {
+		w.emitCommentLines(w.cst.Header, "")
+	}
+	w.Emit("$version: \"%d\"", w.version)
+	if w.cst != nil && w.cst.Version != nil {
+		w.Emit(" %s", w.cst.Version)
+	}
+	w.Emit("\n")
 	emitted := make(map[string]bool, 0)
 
-	if ast.Metadata.Length() > 0 {
+	if ast.Metadata.Length() > 0 && !opts.OmitMetadata {
 		w.Emit("\n")
 		for _, k := range ast.Metadata.Keys() {
-			v := ast.Metadata.Get(k)
-			w.Emit("metadata %s = %s", k, data.Pretty(v))
+			w.EmitMetadata(k, ast.Metadata.Get(k))
+		}
+	}
+	if w.cst != nil {
+		w.emitCommentLines(w.cst.Preamble, "")
+	}
+	if ns != "" {
+		//a file without a namespace statement, which the parser accepts, is written without one
+		w.Emit("\nnamespace %s", ns)
+		if w.cst != nil && w.cst.NamespaceComment != nil {
+			w.Emit(" %s", w.cst.NamespaceComment)
 		}
+		w.Emit("\n")
 	}
-	w.Emit("\nnamespace %s\n", ns)
 
-	imports := ast.ExternalRefs(ns)
+	imports := w.chooseUses(ast.ExternalRefs(ns))
 	if len(imports) > 0 {
 		w.Emit("\n")
 		for _, im := range imports {
@@ -79,22 +131,21 @@ func (ast *AST) IDL(ns string) string {
 
 	for _, nsk := range ast.Shapes.Keys() {
 		shape := ast.GetShape(nsk)
-		shapeAbsName := strings.Split(nsk, "#")
-		shapeNs := shapeAbsName[0]
-		shapeName := shapeAbsName[1]
-		if shapeNs == ns {
+		id := ShapeID(nsk)
+		if id.Namespace() == ns {
 			if shape.Type == "service" {
 				w.Emit("\n")
-				w.EmitServiceShape(shapeName, shape)
+				w.EmitServiceShape(id.Name(), shape)
+				emitted[id.Name()] = true
 				break
 			}
 		}
 	}
 	for _, nsk := range ast.Shapes.Keys() {
-		lst := strings.Split(nsk, "#")
-		if lst[0] == ns {
+		id := ShapeID(nsk)
+		if id.Namespace() == ns {
 			shape := ast.GetShape(nsk)
-			k := lst[1]
+			k := id.Name()
 			if shape.Type == "operation" {
 				w.Emit("\n")
 				w.EmitOperationShape(k, shape, emitted)
@@ -102,9 +153,9 @@ func (ast *AST) IDL(ns string) string {
 		}
 	}
 	for _, nsk := range ast.Shapes.Keys() {
-		lst := strings.Split(nsk, "#")
-		k := lst[1]
-		if lst[0] == ns {
+		id := ShapeID(nsk)
+		k := id.Name()
+		if id.Namespace() == ns {
 			if !emitted[k] {
 				w.EmitShape(k, ast.GetShape(nsk))
 			}
@@ -113,131 +164,270 @@ func (ast *AST) IDL(ns string) string {
 	for _, nsk := range ast.Shapes.Keys() {
 		shape := ast.GetShape(nsk)
 		if shape.Type == "operation" {
-			lst := strings.Split(nsk, "#")
-			if lst[0] == ns {
+			if ShapeID(nsk).Namespace() == ns {
 				if d := shape.Traits.Get("smithy.api#examples"); d != nil {
-					switch v := d.(type) {
-					case []map[string]interface{}:
-						w.EmitExamplesTrait(nsk, v)
-					}
+					w.EmitExamplesTrait(nsk, d)
 				}
 			}
 		}
 	}
+	for _, id := range ast.applied.Keys() {
+		if ShapeID(id).Namespace() == ns || w.cst != nil {
+			w.EmitApply(id, ast.applied.GetObject(id))
+		}
+	}
+	if w.cst != nil && len(w.cst.Trailer) > 0 {
+		w.Emit("\n")
+		w.emitCommentLines(w.cst.Trailer, "")
+	}
 	return w.End()
 }
 
+// ExternalRefs returns the ids of the shapes and traits in other namespaces that the shapes in the namespace refer to,
+// other than those in the prelude, sorted.
 func (ast *AST) ExternalRefs(ns string) []string {
-	match := ns + "#"
-	if ns == "" {
-		match = ""
-	}
 	refs := make(map[string]bool, 0)
+	note := func(id string) {
+		if sid := ShapeID(id); sid.Namespace() != ns && sid.Namespace() != "smithy.api" && strings.Contains(id, "#") {
+			refs[string(sid.Shape())] = true
+		}
+	}
 	for _, k := range ast.Shapes.Keys() {
-		lst := strings.Split(k, "#")
-		if ns == "" || lst[0] == ns {
-			v := ast.GetShape(k)
-			ast.noteExternalRefs(match, k, v, refs)
+		if shapeIdNamespace(k) != ns {
+			continue
+		}
+		shape := ast.GetShape(k)
+		for _, id := range shape.Dependencies() {
+			note(id)
+		}
+		for _, ref := range shape.Mixins {
+			note(ref.Target)
+		}
+		for _, mid := range memberIds(k, shape) {
+			member := ast.getMember(mid)
+			note(member.Target)
+			for _, tid := range member.Traits.Keys() {
+				note(tid)
+			}
 		}
 	}
 	var res []string
-	for k, _ := range refs {
+	for k := range refs {
 		res = append(res, k)
 	}
+	sort.Strings(res)
 	return res
 }
 
-func (ast *AST) noteExternalTraitRefs(match string, traits *data.Object, refs map[string]bool) {
-	if traits != nil {
-		for _, tk := range traits.Keys() {
-			if !strings.HasPrefix(tk, "smithy.api#") && (match != "" && !strings.HasPrefix(tk, match)) {
-				refs[tk] = true
-			}
-		}
-	}
-}
-
-func (ast *AST) noteExternalRefs(match string, name string, shape *Shape, refs map[string]bool) {
-	if name == "smithy.api#Document" {
-		//force an alias to this to get emitted.
-	} else if strings.HasPrefix(name, "smithy.api#") {
-		return
-	}
-	if _, ok := refs[name]; ok {
-		return
-	}
-	if match == "" || !strings.HasPrefix(name, match) {
-		refs[name] = true
-		if shape != nil {
-			ast.noteExternalTraitRefs(match, shape.Traits, refs)
-			switch shape.Type {
-			case "map":
-				ast.noteExternalRefs(match, shape.Key.Target, ast.GetShape(shape.Key.Target), refs)
-				ast.noteExternalTraitRefs(match, shape.Key.Traits, refs)
-				ast.noteExternalRefs(match, shape.Value.Target, ast.GetShape(shape.Value.Target), refs)
-				ast.noteExternalTraitRefs(match, shape.Value.Traits, refs)
-			case "list", "set":
-				ast.noteExternalRefs(match, shape.Member.Target, ast.GetShape(shape.Member.Target), refs)
-				ast.noteExternalTraitRefs(match, shape.Member.Traits, refs)
-			case "structure", "union":
-				if shape.Members != nil {
-					for _, k := range shape.Members.Keys() {
-						member := shape.Members.Get(k)
-						ast.noteExternalRefs(match, member.Target, ast.GetShape(member.Target), refs)
-						ast.noteExternalTraitRefs(match, member.Traits, refs)
-					}
-				}
-			}
-		}
-	}
-}
-
 type IdlWriter struct {
-	buf       bytes.Buffer
 	writer    *bufio.Writer
 	namespace string
 	name      string
 	version   int
 	ast       *AST
+	uses      map[string]string //the ids imported with use statements, by their names
+	shapeIds  string            //see IdlOptions
+	textBlock bool              //see IdlOptions
+	cst       *CST              //see IdlOptions
+	current   string            //the id of the shape being written, for its comments and those of its members
+	traitsOf  string            //the id of the statement whose traits are being written, for their source in the CST
 }
 
-func (w *IdlWriter) Begin() {
-	w.buf.Reset()
-	w.writer = bufio.NewWriter(&w.buf)
+func (w *IdlWriter) Begin(out io.Writer) {
+	w.writer = bufio.NewWriter(out)
 }
 
+// stripNamespace returns the id relative to the namespace being written, if it resolves to the same shape that way:
+// shapes in the namespace, shapes imported with use statements, and prelude shapes that are not shadowed by either.
+// Otherwise the absolute id is returned. The "local" and "absolute" ShapeIds options restrict that.
 func (w *IdlWriter) stripNamespace(id string) string {
-	n := strings.Index(id, "#")
-	if n < 0 {
+	sid := ShapeID(id)
+	ns := sid.Namespace()
+	if !strings.Contains(id, "#") || w.shapeIds == "absolute" {
 		return id
 	}
-	return id[n+1:]
-	/*
-		match := w.namespace + "#"
-		if strings.HasPrefix(id, match) {
-			return id[len(match):]
-		}
-		if strings.HasPrefix(id, "smithy.api") {
-			n := strings.Index(id, "#")
-			if n >= 0 {
-				return id[n+1:]
-			}
+	name := strings.TrimPrefix(id, ns+"#")
+	shapeName := sid.Name()
+	if w.shapeIds == "local" {
+		if ns == w.namespace {
+			return name
 		}
 		return id
-	*/
+	}
+	switch {
+	case ns == w.namespace:
+		return name
+	case w.uses[shapeName] == string(sid.Shape()):
+		return name
+	case ns == "smithy.api" && w.uses[shapeName] == "" && w.ast.GetShape(w.namespace+"#"+shapeName) == nil:
+		return name
+	}
+	return id
+}
+
+// chooseUses picks the external references to import with use statements: those whose names do not conflict with
+// a shape in the namespace, another reference, or a prelude shape, which the parser would resolve them to instead.
+// The use statements the namespace was parsed with come first, as they were, so that the IDL round-trips.
+func (w *IdlWriter) chooseUses(refs []string) []string {
+	count := make(map[string]int, 0)
+	for _, id := range refs {
+		count[ShapeID(id).Name()]++
+	}
+	w.uses = make(map[string]string, 0)
+	if w.shapeIds == "local" || w.shapeIds == "absolute" {
+		return nil
+	}
+	var uses []string
+	taken := make(map[string]bool, 0)
+	for _, id := range w.ast.Uses(w.namespace) {
+		name := ShapeID(id).Name()
+		if taken[name] || w.ast.GetShape(w.namespace+"#"+name) != nil {
+			continue
+		}
+		taken[name] = true
+		uses = append(uses, id)
+		if !IsPreludeType(name) && !IsPreludeTrait(name) {
+			w.uses[name] = id
+		}
+	}
+	for _, id := range refs {
+		name := ShapeID(id).Name()
+		if !taken[name] && count[name] == 1 && w.ast.GetShape(w.namespace+"#"+name) == nil && !IsPreludeType(name) && !IsPreludeTrait(name) {
+			w.uses[name] = id
+			uses = append(uses, id)
+		}
+	}
+	return uses
 }
 
 func (w *IdlWriter) Emit(format string, args ...interface{}) {
 	w.writer.WriteString(fmt.Sprintf(format, args...))
 }
 
+// emitComments writes the comments before the shape or member with the id in the source, if there is a CST.
+func (w *IdlWriter) emitComments(id string, indent string) {
+	if node := w.cst.Node(id); node != nil {
+		w.emitCommentLines(node.Comments, indent)
+	}
+}
+
+// emitClosingComments writes the comments after the last member of the shape being written.
+func (w *IdlWriter) emitClosingComments(indent string) {
+	if node := w.cst.Node(w.current); node != nil {
+		w.emitCommentLines(node.Closing, indent)
+	}
+}
+
+func (w *IdlWriter) emitCommentLines(comments []*Comment, indent string) {
+	for _, c := range comments {
+		w.Emit("%s%s\n", indent, c)
+		if c.BlankAfter {
+			w.Emit("\n")
+		}
+	}
+}
+
+// endLine ends the line of the member of the shape being written, with its trailing comment if it has one.
+func (w *IdlWriter) endLine(member string) {
+	w.endLineOf(w.current + "$" + member)
+}
+
+// endLineOf ends the line of the statement with the id, with its trailing comment if it has one.
+func (w *IdlWriter) endLineOf(id string) {
+	if node := w.cst.Node(id); node != nil && node.Trailing != nil {
+		w.Emit(" %s", node.Trailing)
+	}
+	w.Emit("\n")
+}
+
+// emitProperty writes the property of the service, resource or operation being written, with its comments. Its value
+// is written as it was in the source if it has comments in it.
+func (w *IdlWriter) emitProperty(name string, value string, comma string) {
+	id := w.current + "." + name
+	w.emitComments(id, IndentAmount)
+	if node := w.cst.Node(id); node != nil && node.Source != "" {
+		value = strings.ReplaceAll(node.Source, "\n", "\n"+IndentAmount)
+	} else {
+		value = name + ": " + value
+	}
+	w.Emit("%s%s%s", IndentAmount, value, comma)
+	w.endLineOf(id)
+}
+
+// emitTraitSource writes the trait of the statement whose traits are being written as it was in the source, if it had
+// comments in its arguments, and returns false if it did not.
+func (w *IdlWriter) emitTraitSource(k string, indent string) bool {
+	if node := w.cst.Node(w.traitsOf); node != nil {
+		if source, ok := node.Traits[k]; ok {
+			w.Emit("%s%s\n", indent, strings.ReplaceAll(source, "\n", "\n"+indent))
+			return true
+		}
+	}
+	return false
+}
+
+// traitComment returns the comment at the end of the line of the trait of the statement whose traits are being
+// written, if it had one.
+func (w *IdlWriter) traitComment(k string) *Comment {
+	if node := w.cst.Node(w.traitsOf); node != nil {
+		return node.TraitComments[k]
+	}
+	return nil
+}
+
+// emitWithComment writes what emit writes, with the comment at the end of its last line, if there is one, or on a line
+// of its own at the indent if emit writes nothing.
+func (w *IdlWriter) emitWithComment(c *Comment, indent string, emit func()) {
+	if c == nil {
+		emit()
+		return
+	}
+	var sb strings.Builder
+	out := w.writer
+	w.writer = bufio.NewWriter(&sb)
+	emit()
+	w.writer.Flush()
+	w.writer = out
+	if sb.Len() == 0 {
+		w.Emit("%s%s\n", indent, c)
+	} else {
+		w.Emit("%s %s\n", strings.TrimSuffix(sb.String(), "\n"), c)
+	}
+}
+
+// emitMemberTraits writes the traits of the member of the shape being written.
+func (w *IdlWriter) emitMemberTraits(member string, traits *data.Object, indent string) {
+	w.traitsOf = w.current + "$" + member
+	w.EmitTraits(traits, indent)
+	w.traitsOf = w.current
+}
+
+// beginShape starts the shape with the name in the namespace being written, with the comments before it.
+func (w *IdlWriter) beginShape(name string, indent string) {
+	w.current = w.namespace + "#" + name
+	w.traitsOf = w.current
+	w.emitComments(w.current, indent)
+	if source := w.ast.SourceAnnotation(w.current); source != "" {
+		w.Emit("%s// %s%s\n", indent, sourceAnnotationPrefix, source)
+	}
+}
+
+func (w *IdlWriter) EmitMetadata(key string, v interface{}) {
+	if !isIdentifier(key) {
+		key = fmt.Sprintf("%q", key)
+	}
+	w.emitComments("metadata "+key, "")
+	w.Emit("metadata %s = %s", key, strings.TrimSuffix(data.Pretty(v), "\n"))
+	w.endLineOf("metadata " + key)
+}
+
 func (w *IdlWriter) EmitShape(name string, shape *Shape) {
-	s := strings.ToLower(shape.Type)
 	w.Emit("\n")
-	switch s {
+	w.beginShape(name, "")
+	switch shape.Type {
 	case "boolean":
 		w.EmitBooleanShape(name, shape)
-	case "byte", "short", "integer", "long", "float", "double", "bigInteger", "bigdecimal":
+	case "byte", "short", "integer", "long", "float", "double", "bigInteger", "bigDecimal":
 		w.EmitNumericShape(shape.Type, name, shape)
 	case "blob":
 		w.EmitBlobShape(name, shape)
@@ -253,26 +443,93 @@ func (w *IdlWriter) EmitShape(name string, shape *Shape) {
 		w.EmitStructureShape(name, shape)
 	case "union":
 		w.EmitUnionShape(name, shape)
-	case "enum", "intenum":
-		w.EmitEnumShape(shape.Type, name, shape)
+	case "enum":
+		w.EmitEnumShape(name, shape)
+	case "intEnum":
+		w.EmitIntEnumShape(name, shape)
 	case "resource":
 		w.EmitResourceShape(name, shape)
 	case "operation", "service":
 		// already emitted
 		// w.EmitOperationShape(name, shape, emitted)
 	default:
-		panic("fix: shape " + name + " of type " + data.Pretty(shape))
+		//i.e. document, which is written as the other simple shapes are
+		w.EmitTraits(shape.Traits, "")
+		w.EmitSimpleShape(shape.Type, name, shape)
 	}
 }
 
 func (w *IdlWriter) EmitDocumentation(doc, indent string) {
 	if doc != "" {
-		s := FormatComment(indent, "/// ", doc, 100, false)
+		//doc comments are not wrapped, since the line breaks would be part of the documentation when it is parsed
+		s := FormatComment(indent, "/// ", doc, 0, false)
+		if w.textBlock || parsedDocComment(s) != doc {
+			w.Emit("%s@%s(%s)\n", indent, w.stripNamespace("smithy.api#documentation"), textBlock(doc, indent))
+			return
+		}
 		w.Emit(s)
-		//		w.Emit("%s@documentation(%q)\n", indent, doc)
 	}
 }
 
+// parsedDocComment returns the documentation that the doc comment lines have when parsed: each line is trimmed, and
+// so is the whole.
+func parsedDocComment(lines string) string {
+	var doc string
+	for _, line := range strings.Split(strings.TrimRight(lines, "\n"), "\n") {
+		line = TrimSpace(strings.TrimPrefix(TrimSpace(line), "///"))
+		if doc == "" {
+			doc = line
+		} else {
+			doc = doc + "\n" + line
+		}
+	}
+	return TrimSpace(doc)
+}
+
+// textBlock returns the text as a text block, indented one level more than the indent. Anything that would not
+// survive as is, such as trailing whitespace or runs of quotes, is escaped.
+func textBlock(text string, indent string) string {
+	escapeQuotes := strings.Contains(text, "\"\"") || strings.HasSuffix(text, "\"")
+	var sb strings.Builder
+	sb.WriteString("\"\"\"\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i == len(lines)-1 && line == "" {
+			//the text ends with a newline, so the closing delimiter goes on a line of its own
+			sb.WriteString(indent + IndentAmount)
+			break
+		}
+		if line != "" {
+			sb.WriteString(indent + IndentAmount)
+		}
+		trailing := len(strings.TrimRight(line, " \t"))
+		for j, ch := range line {
+			switch {
+			case ch == '\\':
+				sb.WriteString("\\\\")
+			case ch == '"' && escapeQuotes:
+				sb.WriteString("\\\"")
+			case ch == '\r':
+				sb.WriteString("\\r")
+			case j >= trailing:
+				fmt.Fprintf(&sb, "\\u%04x", ch)
+			default:
+				sb.WriteRune(ch)
+			}
+		}
+		if i < len(lines)-1 {
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString("\"\"\"")
+	return sb.String()
+}
+
+// traitName returns the name of the prelude trait as written, qualified when stripNamespace would qualify it.
+func (w *IdlWriter) traitName(name string) string {
+	return w.stripNamespace("smithy.api#" + name)
+}
+
 func (w *IdlWriter) EmitBooleanTrait(b bool, tname, indent string) {
 	if b {
 		w.Emit("%s@%s\n", indent, tname)
@@ -294,11 +551,11 @@ func (w *IdlWriter) EmitLengthTrait(v interface{}, indent string) {
 	min := data.Get(l, "min")
 	max := data.Get(l, "max")
 	if min != nil && max != nil {
-		w.Emit("@length(min: %d, max: %d)\n", data.AsInt(min), data.AsInt(max))
+		w.Emit("%s@%s(min: %d, max: %d)\n", indent, w.traitName("length"), data.AsInt(min), data.AsInt(max))
 	} else if max != nil {
-		w.Emit("@length(max: %d)\n", data.AsInt(max))
+		w.Emit("%s@%s(max: %d)\n", indent, w.traitName("length"), data.AsInt(max))
 	} else if min != nil {
-		w.Emit("@length(min: %d)\n", data.AsInt(min))
+		w.Emit("%s@%s(min: %d)\n", indent, w.traitName("length"), data.AsInt(min))
 	}
 }
 
@@ -307,14 +564,22 @@ func (w *IdlWriter) EmitRangeTrait(v interface{}, indent string) {
 	min := data.Get(l, "min")
 	max := data.Get(l, "max")
 	if min != nil && max != nil {
-		w.Emit("@range(min: %v, max: %v)\n", data.AsDecimal(min), data.AsDecimal(max))
+		w.Emit("%s@%s(min: %s, max: %s)\n", indent, w.traitName("range"), numberString(min), numberString(max))
 	} else if max != nil {
-		w.Emit("@range(max: %v)\n", data.AsDecimal(max))
+		w.Emit("%s@%s(max: %s)\n", indent, w.traitName("range"), numberString(max))
 	} else if min != nil {
-		w.Emit("@range(min: %v)\n", data.AsDecimal(min))
+		w.Emit("%s@%s(min: %s)\n", indent, w.traitName("range"), numberString(min))
 	}
 }
 
+// numberString formats a number with all of its digits, and without an exponent, as the IDL parser reads it.
+func numberString(v interface{}) string {
+	if d := data.AsDecimal(v); d != nil {
+		return d.String()
+	}
+	return fmt.Sprint(v)
+}
+
 func (w *IdlWriter) EmitTraitTrait(v interface{}) {
 	l := data.AsMap(v)
 	if l != nil {
@@ -330,83 +595,112 @@ func (w *IdlWriter) EmitTraitTrait(v interface{}) {
 				if s != "[" {
 					s = s + ", "
 				}
-				s = s + e
+				s = s + fmt.Sprintf("%q", e)
 			}
 			s = s + "]"
 			lst = append(lst, fmt.Sprintf("conflicts: %s", s))
 		}
 		structurallyExclusive := data.GetString(l, "structurallyExclusive")
 		if structurallyExclusive != "" {
-			lst = append(lst, fmt.Sprintf("selector: %q", structurallyExclusive))
+			lst = append(lst, fmt.Sprintf("structurallyExclusive: %q", structurallyExclusive))
 		}
 		if len(lst) > 0 {
-			w.Emit("@trait(%s)\n", strings.Join(lst, ", "))
+			w.Emit("@%s(%s)\n", w.traitName("trait"), strings.Join(lst, ", "))
 			return
 		}
 	}
-	w.Emit("@trait\n")
+	w.Emit("@%s\n", w.traitName("trait"))
 }
 
 func (w *IdlWriter) EmitTagsTrait(v interface{}, indent string) {
-	if sa, ok := v.([]string); ok {
-		w.Emit("@tags(%v)\n", listOfStrings("", "%q", sa))
+	if sa := data.AsStringArray(v); sa != nil {
+		w.Emit("%s@%s(%v)\n", indent, w.traitName("tags"), listOfStrings("", "%q", sa))
+	}
+}
+
+func (w *IdlWriter) EmitDefaultTrait(v interface{}, indent string) {
+	w.Emit("%s@%s(%s)\n", indent, w.traitName("default"), data.Json(v))
+}
+
+// EmitAuthTrait emits the auth schemes as absolute shape ids in strings, which is equivalent to the shape ids.
+func (w *IdlWriter) EmitAuthTrait(v interface{}, indent string) {
+	schemes := data.AsStringArray(v)
+	if len(schemes) == 0 {
+		w.Emit("%s@%s([])\n", indent, w.traitName("auth"))
+	} else {
+		w.Emit("%s@%s(%s)\n", indent, w.traitName("auth"), listOfStrings("", "%q", schemes))
+	}
+}
+
+func (w *IdlWriter) EmitRetryableTrait(v interface{}, indent string) {
+	if obj, ok := traitObject(v); ok && data.AsBool(obj.Get("throttling")) {
+		w.Emit("%s@%s(throttling: true)\n", indent, w.traitName("retryable"))
+	} else {
+		w.Emit("%s@%s\n", indent, w.traitName("retryable"))
 	}
 }
 
+// EmitExternalDocumentationTrait emits the links with their names quoted, since they are often not identifiers.
+func (w *IdlWriter) EmitExternalDocumentationTrait(v interface{}, indent string) {
+	links, ok := traitObject(v)
+	if !ok {
+		w.EmitCustomTrait("smithy.api#externalDocumentation", v, indent)
+		return
+	}
+	var lst []string
+	for _, name := range links.Keys() {
+		lst = append(lst, fmt.Sprintf("%q: %q", name, links.GetString(name)))
+	}
+	w.Emit("%s@%s(%s)\n", indent, w.traitName("externalDocumentation"), strings.Join(lst, ", "))
+}
+
+// traitObject returns the value of a trait as an object, if it is one.
+func traitObject(v interface{}) (*data.Object, bool) {
+	switch m := v.(type) {
+	case *data.Object:
+		return m, true
+	case map[string]interface{}:
+		return data.ObjectFromMap(m), true
+	}
+	return nil, false
+}
+
 func (w *IdlWriter) EmitDeprecatedTrait(v interface{}, indent string) {
 	dep := data.AsObject(v)
 	if dep != nil {
-		s := indent + "@deprecated"
-		hasMessage := false
+		s := indent + "@" + w.traitName("deprecated")
+		var args []string
 		if dep.Has("message") {
-			s = s + fmt.Sprintf("(message: %q", dep.GetString("message"))
-			hasMessage = true
+			args = append(args, fmt.Sprintf("message: %q", dep.GetString("message")))
 		}
 		if dep.Has("since") {
-			if hasMessage {
-				s = s + fmt.Sprintf(", since: %q)", dep.GetString("since"))
-			} else {
-				s = s + fmt.Sprintf("(since: %q)", dep.GetString("since"))
-			}
-		} else {
-			s = s + ")"
+			args = append(args, fmt.Sprintf("since: %q", dep.GetString("since")))
+		}
+		if len(args) > 0 {
+			s = s + "(" + strings.Join(args, ", ") + ")"
 		}
 		w.Emit(s + "\n")
 	}
 }
 
 func (w *IdlWriter) EmitHttpTrait(rv interface{}, indent string) {
-	var method, uri string
-	code := 0
-	switch v := rv.(type) {
-	case map[string]interface{}:
-		method = data.GetString(v, "method")
-		uri = data.GetString(v, "uri")
-		code = data.GetInt(v, "code")
-	case *data.Object:
-		method = data.AsString(v.Get("method"))
-		uri = data.AsString(v.Get("uri"))
-		code = data.AsInt(v.Get("code"))
-	default:
-		panic("What?!")
+	var http HttpTrait
+	if err := DecodeNode(rv, &http); err != nil {
+		//written as it is, as a trait that is not known to the writer would be
+		w.EmitCustomTrait("smithy.api#http", rv, indent)
+		return
 	}
-	s := fmt.Sprintf("method: %q, uri: %q", method, uri)
-	if code != 0 {
-		s = s + fmt.Sprintf(", code: %d", code)
+	s := fmt.Sprintf("method: %q, uri: %q", http.Method, http.Uri)
+	if http.Code != 0 {
+		s = s + fmt.Sprintf(", code: %d", http.Code)
 	}
-	w.Emit("@http(%s)\n", s)
+	w.Emit("@%s(%s)\n", w.traitName("http"), s)
 }
 
 func (w *IdlWriter) EmitHttpErrorTrait(rv interface{}, indent string) {
-	var status int
-	switch v := rv.(type) {
-	case int32:
-		status = int(v)
-	default:
-		//		fmt.Printf("http error arg, expected an int32, found %s with type %s\n", rv, Kind(rv))
-	}
+	status := data.AsInt(rv)
 	if status != 0 {
-		w.Emit("@httpError(%d)\n", status)
+		w.Emit("@%s(%d)\n", w.traitName("httpError"), status)
 	}
 }
 
@@ -453,13 +747,24 @@ func (w *IdlWriter) EmitBlobShape(name string, shape *Shape) {
 func (w *IdlWriter) EmitCollectionShape(shapeName, name string, shape *Shape) {
 	w.EmitTraits(shape.Traits, "")
 	w.Emit("%s %s%s {\n", shapeName, name, w.withMixins(shape.Mixins))
-	w.Emit("    member: %s\n", w.stripNamespace(shape.Member.Target))
+	w.emitComments(w.current+"$member", IndentAmount)
+	w.Emit("    member: %s", w.stripNamespace(shape.Member.Target))
+	w.endLine("member")
+	w.emitClosingComments(IndentAmount)
 	w.Emit("}\n")
 }
 
 func (w *IdlWriter) EmitMapShape(name string, shape *Shape) {
 	w.EmitTraits(shape.Traits, "")
-	w.Emit("map %s%s {\n    key: %s,\n    value: %s\n}\n", name, w.withMixins(shape.Mixins), w.stripNamespace(shape.Key.Target), w.stripNamespace(shape.Value.Target))
+	w.Emit("map %s%s {\n", name, w.withMixins(shape.Mixins))
+	w.emitComments(w.current+"$key", IndentAmount)
+	w.Emit("    key: %s%s", w.stripNamespace(shape.Key.Target), w.comma())
+	w.endLine("key")
+	w.emitComments(w.current+"$value", IndentAmount)
+	w.Emit("    value: %s", w.stripNamespace(shape.Value.Target))
+	w.endLine("value")
+	w.emitClosingComments(IndentAmount)
+	w.Emit("}\n")
 }
 
 func (w *IdlWriter) EmitUnionShape(name string, shape *Shape) {
@@ -468,46 +773,87 @@ func (w *IdlWriter) EmitUnionShape(name string, shape *Shape) {
 	count := shape.Members.Length()
 	for _, fname := range shape.Members.Keys() {
 		mem := shape.Members.Get(fname)
-		w.EmitTraits(mem.Traits, IndentAmount)
+		w.emitComments(w.current+"$"+fname, IndentAmount)
+		w.emitMemberTraits(fname, mem.Traits, IndentAmount)
 		w.Emit("%s%s: %s", IndentAmount, fname, w.stripNamespace(mem.Target))
 		count--
 		if count > 0 {
-			w.Emit(",\n")
-		} else {
-			w.Emit("\n")
+			w.Emit(w.comma())
 		}
+		w.endLine(fname)
 	}
+	w.emitClosingComments(IndentAmount)
 	w.Emit("}\n")
 }
 
-func (w *IdlWriter) EmitEnumShape(enumType string, name string, shape *Shape) {
+func (w *IdlWriter) EmitEnumShape(name string, shape *Shape) {
+	if items, ok := enumTraitValue(shape); ok {
+		//member names that are not identifiers can only be written with the IDL 1.0 @enum trait, which 2.0 still
+		//allows, and which is parsed back to the same enum shape
+		traits := withTrait(cloneObject(shape.Traits), "smithy.api#enum", items)
+		w.EmitStringShape(name, &Shape{Type: "string", Traits: traits, Mixins: shape.Mixins})
+		return
+	}
+	w.emitEnumMembers("enum", name, shape, func(fname string, val interface{}) string {
+		if sval := data.AsString(val); val != nil && sval != fname {
+			return fmt.Sprintf(" = %q", sval)
+		}
+		return ""
+	})
+}
+
+// enumTraitValue returns the value of the @enum trait equivalent to the enum shape, if the name of any of its members
+// is not an identifier, and the trait can express all of their traits.
+func enumTraitValue(shape *Shape) ([]interface{}, bool) {
+	identifiers := true
+	var items []interface{}
+	for _, k := range shape.Members.Keys() {
+		identifiers = identifiers && isIdentifier(k)
+		mem := shape.Members.Get(k)
+		item := data.NewObject()
+		if v := mem.Traits.Get("smithy.api#enumValue"); v != nil {
+			item.Put("value", v)
+			item.Put("name", k)
+		} else {
+			item.Put("value", k)
+		}
+		for _, tk := range mem.Traits.Keys() {
+			switch tk {
+			case "smithy.api#enumValue":
+			case "smithy.api#documentation", "smithy.api#tags", "smithy.api#deprecated":
+				item.Put(StripNamespace(tk), mem.Traits.Get(tk))
+			default:
+				return nil, false
+			}
+		}
+		items = append(items, item)
+	}
+	return items, !identifiers
+}
+
+// EmitIntEnumShape emits the intEnum, the members of which always have a value, i.e. "FOO = 1".
+func (w *IdlWriter) EmitIntEnumShape(name string, shape *Shape) {
+	w.emitEnumMembers("intEnum", name, shape, func(fname string, val interface{}) string {
+		return fmt.Sprintf(" = %d", data.AsInt(val))
+	})
+}
+
+func (w *IdlWriter) emitEnumMembers(enumType string, name string, shape *Shape, eqval func(string, interface{}) string) {
 	w.EmitTraits(shape.Traits, "")
-	w.Emit("enum %s%s {\n", name, w.withMixins(shape.Mixins))
+	w.Emit("%s %s%s {\n", enumType, name, w.withMixins(shape.Mixins))
 	count := shape.Members.Length()
 	for _, fname := range shape.Members.Keys() {
 		mem := shape.Members.Get(fname)
-		sval := fname
-		eqval := ""
-		if val := mem.Traits.Get("smithy.api#enumValue"); val != nil {
-			if enumType == "intEnum" {
-				dval := data.AsInt(val)
-				eqval = fmt.Sprintf(" = %d", dval)
-			} else {
-				sval = data.AsString(val)
-				if sval != fname {
-					eqval = fmt.Sprintf(" = %q", sval)
-				}
-			}
-		}
-		w.EmitTraits(mem.Traits, IndentAmount)
-		w.Emit("%s%s%s", IndentAmount, fname, eqval)
+		w.emitComments(w.current+"$"+fname, IndentAmount)
+		w.emitMemberTraits(fname, mem.Traits, IndentAmount)
+		w.Emit("%s%s%s", IndentAmount, fname, eqval(fname, mem.Traits.Get("smithy.api#enumValue")))
 		count--
 		if count > 0 {
-			w.Emit(",\n")
-		} else {
-			w.Emit("\n")
+			w.Emit(w.comma())
 		}
+		w.endLine(fname)
 	}
+	w.emitClosingComments(IndentAmount)
 	w.Emit("}\n")
 }
 
@@ -520,16 +866,42 @@ func (w *IdlWriter) EmitTraits(traits *data.Object, indent string) {
 		v := traits.Get(k)
 		switch k {
 		case "smithy.api#documentation":
-			w.EmitDocumentation(data.AsString(v), indent)
+			if c := w.traitComment(k); c != nil {
+				//a doc comment cannot end with a comment, so it goes above
+				w.Emit("%s%s\n", indent, c)
+			}
+			if !w.emitTraitSource(k, indent) {
+				w.EmitDocumentation(data.AsString(v), indent)
+			}
 		}
 	}
 	for _, k := range traits.Keys() {
-		v := traits.Get(k)
+		if k != "smithy.api#documentation" {
+			w.emitWithComment(w.traitComment(k), indent, func() {
+				w.emitTrait(k, traits.Get(k), indent)
+			})
+		}
+	}
+}
+
+// emitTrait writes the trait, other than documentation, as it was in the source if it had comments in its arguments.
+func (w *IdlWriter) emitTrait(k string, v interface{}, indent string) {
+	if !w.emitTraitSource(k, indent) {
 		switch k {
 		case "smithy.api#documentation", "smithy.api#examples", "smithy.api#enumValue":
 			//do nothing, handled elsewhere
-		case "smithy.api#sensitive", "smithy.api#required", "smithy.api#readonly", "smithy.api#idempotent":
+		case "smithy.api#sensitive", "smithy.api#required", "smithy.api#readonly", "smithy.api#idempotent", "smithy.api#notProperty":
 			w.EmitBooleanTrait(data.AsBool(v), w.stripNamespace(k), indent)
+		case "smithy.api#streaming", "smithy.api#sparse", "smithy.api#uniqueItems", "smithy.api#idempotencyToken", "smithy.api#internal":
+			w.EmitBooleanTrait(data.AsBool(v), w.stripNamespace(k), indent)
+		case "smithy.api#jsonName", "smithy.api#xmlName", "smithy.api#since":
+			w.EmitStringTrait(data.AsString(v), w.stripNamespace(k), indent)
+		case "smithy.api#auth":
+			w.EmitAuthTrait(v, indent)
+		case "smithy.api#retryable":
+			w.EmitRetryableTrait(v, indent)
+		case "smithy.api#externalDocumentation":
+			w.EmitExternalDocumentationTrait(v, indent)
 		case "smithy.api#httpLabel", "smithy.api#httpPayload":
 			w.EmitBooleanTrait(data.AsBool(v), w.stripNamespace(k), indent)
 		case "smithy.api#httpQuery", "smithy.api#httpHeader", "smithy.api#timestampFormat":
@@ -546,83 +918,211 @@ func (w *IdlWriter) EmitTraits(traits *data.Object, indent string) {
 			w.EmitRangeTrait(v, indent)
 		case "smithy.api#tags":
 			w.EmitTagsTrait(v, indent)
+		case "smithy.api#default":
+			w.EmitDefaultTrait(v, indent)
 		case "smithy.api#pattern", "smithy.api#error":
 			w.EmitStringTrait(data.AsString(v), w.stripNamespace(k), indent)
-		case "aws.protocols#restJson1":
-			w.Emit("%s@%s\n", indent, k) //FIXME for the non-default attributes
 		case "smithy.api#paginated":
 			w.EmitPaginatedTrait(v)
 		case "smithy.api#trait":
 			w.EmitTraitTrait(v)
+		case "smithy.test#httpRequestTests", "smithy.test#httpResponseTests":
+			w.EmitProtocolTestsTrait(k, v, indent)
 		default:
-			w.EmitCustomTrait(k, v, indent)
+			if strings.HasPrefix(k, "aws.") {
+				w.EmitAwsTrait(k, v, indent)
+			} else {
+				w.EmitCustomTrait(k, v, indent)
+			}
 		}
 	}
 }
 
 func (w *IdlWriter) EmitCustomTrait(k string, v interface{}, indent string) {
+	w.Emit("%s@%s%s\n", indent, w.stripNamespace(k), w.traitArgs(v, indent))
+}
+
+func (w *IdlWriter) EmitAwsTrait(k string, v interface{}, indent string) {
+	w.Emit("%s@%s%s\n", indent, w.stripNamespace(k), w.traitArgs(v, indent))
+}
+
+func (w *IdlWriter) traitArgs(v interface{}, indent string) string {
 	args := ""
+	if m, ok := v.(map[string]interface{}); ok {
+		v = data.ObjectFromMap(m)
+	}
 	if m, ok := v.(*data.Object); ok {
 		if m.Length() > 0 {
 			var lst []string
 			for _, ak := range m.Keys() {
 				av := m.Get(ak)
-				lst = append(lst, fmt.Sprintf("%s: %s", ak, data.Json(av)))
+				key := ak
+				if !isIdentifier(ak) {
+					key = fmt.Sprintf("%q", ak)
+				}
+				lst = append(lst, fmt.Sprintf("%s: %s", key, data.Json(av)))
 			}
-			args = "(\n    " + strings.Join(lst, ",\n    ") + ")"
+			args = "(\n" + indent + "    " + strings.Join(lst, ",\n"+indent+"    ") + ")"
 		}
+	} else if v != nil {
+		args = "(" + data.Json(v) + ")"
 	}
-	w.Emit("%s@%s%s\n", indent, w.stripNamespace(k), args)
+	return args
+}
+
+func (w *IdlWriter) EmitProtocolTestsTrait(k string, v interface{}, indent string) {
+	formatted := strings.TrimSuffix(data.Pretty(v), "\n")
+	w.Emit("%s@%s(%s)\n", indent, w.stripNamespace(k), strings.ReplaceAll(formatted, "\n", "\n"+indent))
 }
 
 func (w *IdlWriter) EmitPaginatedTrait(d interface{}) {
-	if m, ok := d.(map[string]interface{}); ok {
-		var args []string
-		for k, v := range m {
-			args = append(args, fmt.Sprintf("%s: %q", k, v))
-		}
-		if len(args) > 0 {
-			w.Emit("@paginated(" + strings.Join(args, ", ") + ")\n")
+	obj, ok := traitObject(d)
+	if !ok {
+		return
+	}
+	var args []string
+	for _, k := range obj.Keys() {
+		args = append(args, fmt.Sprintf("%s: %q", k, obj.GetString(k)))
+	}
+	if len(args) > 0 {
+		w.Emit("@%s(%s)\n", w.traitName("paginated"), strings.Join(args, ", "))
+	} else {
+		w.Emit("@%s\n", w.traitName("paginated"))
+	}
+}
+
+// EmitExamplesTrait applies the examples to the operation after the shapes, as a JSON node value, which is too long to
+// read well above the operation.
+// EmitApply emits the traits that are applied to the shape or member with the id, but not in the model yet, as an
+// apply statement for each trait, since the parser takes one trait per statement. Traits that EmitTraits writes in
+// another way, such as documentation as a doc comment, are written with their values as they are.
+func (w *IdlWriter) EmitApply(id string, traits *data.Object) {
+	target := w.stripNamespace(id)
+	w.traitsOf = "apply " + id
+	w.Emit("\n")
+	w.emitComments(w.traitsOf, "")
+	node := w.cst.Node(w.traitsOf)
+	for i, k := range traits.Keys() {
+		var trailing *Comment
+		if node != nil && i == traits.Length()-1 {
+			trailing = node.Trailing
 		}
+		w.Emit("apply %s ", target)
+		w.emitWithComment(trailing, "", func() {
+			switch k {
+			case "smithy.api#documentation", "smithy.api#examples", "smithy.api#enumValue":
+				if !w.emitTraitSource(k, "") {
+					w.EmitCustomTrait(k, traits.Get(k), "")
+				}
+			default:
+				w.emitTrait(k, traits.Get(k), "")
+			}
+		})
 	}
 }
 
 func (w *IdlWriter) EmitExamplesTrait(opname string, raw interface{}) {
-	switch dat := raw.(type) {
-	case []map[string]interface{}:
-		target := w.stripNamespace(opname)
-		formatted := data.Pretty(dat)
-		if strings.HasSuffix(formatted, "\n") {
-			formatted = formatted[:len(formatted)-1]
-		}
-		w.Emit("apply "+target+" @examples(%s)\n", formatted)
-	default:
-		panic("FIX ME!")
-	}
+	target := w.stripNamespace(opname)
+	formatted := strings.TrimSuffix(data.Pretty(raw), "\n")
+	w.Emit("\napply %s @%s(%s)\n", target, w.traitName("examples"), formatted)
 }
 
-func (w *IdlWriter) EmitStructureShape(name string, shape *Shape) {
-	comma := ""
+// comma returns the separator of the members and properties of a shape body, which version 2 does without.
+func (w *IdlWriter) comma() string {
 	if w.version < 2 {
-		comma = ","
+		return ","
+	}
+	return ""
+}
+
+// emptyBody returns true if the shape being written has nothing in its body, not even comments.
+func (w *IdlWriter) emptyBody(shape *Shape) bool {
+	if shape.Members.Length() > 0 {
+		return false
 	}
+	node := w.cst.Node(w.current)
+	return node == nil || len(node.Closing) == 0
+}
+
+func (w *IdlWriter) EmitStructureShape(name string, shape *Shape) {
+	comma := w.comma()
 	w.EmitTraits(shape.Traits, "")
+	if w.emptyBody(shape) {
+		w.Emit("structure %s%s {}\n", name, w.withMixins(shape.Mixins))
+		return
+	}
 	w.Emit("structure %s%s {\n", name, w.withMixins(shape.Mixins))
 	for i, k := range shape.Members.Keys() {
 		if i > 0 {
 			w.Emit("\n")
 		}
-		v := shape.Members.Get(k)
-		w.EmitTraits(v.Traits, IndentAmount)
-		w.Emit("%s%s: %s%s\n", IndentAmount, k, w.stripNamespace(v.Target), comma)
+		w.EmitMember(k, shape.Members.Get(k), IndentAmount, comma)
 	}
+	w.emitClosingComments(IndentAmount)
 	w.Emit("}\n")
 }
 
+// EmitMember emits a structure member. In version 2 the default value of the member is emitted with the assignment
+// syntax rather than as a @default trait.
+func (w *IdlWriter) EmitMember(name string, mem *Member, indent, comma string) {
+	traits := mem.Traits
+	assign := ""
+	if w.version >= 2 && traits.Has("smithy.api#default") {
+		assign = " = " + data.Json(traits.Get("smithy.api#default"))
+		traits = data.NewObject()
+		for _, k := range mem.Traits.Keys() {
+			if k != "smithy.api#default" {
+				traits.Put(k, mem.Traits.Get(k))
+			}
+		}
+	}
+	w.emitComments(w.current+"$"+name, indent)
+	w.emitMemberTraits(name, traits, indent)
+	w.Emit("%s%s: %s%s%s", indent, name, w.stripNamespace(mem.Target), assign, comma)
+	w.endLine(name)
+}
+
+// EmitInlineStructure emits an operation's input or output structure in place. Only structures named with the default
+// suffixes are inlined, since the $operationInputSuffix and $operationOutputSuffix control statements are not emitted.
+func (w *IdlWriter) EmitInlineStructure(label string, ioTrait string, shape *Shape) {
+	i2 := IndentAmount + IndentAmount
+	traits := data.NewObject()
+	for _, k := range shape.Traits.Keys() {
+		if k != ioTrait {
+			traits.Put(k, shape.Traits.Get(k))
+		}
+	}
+	body := "{\n"
+	if w.emptyBody(shape) {
+		body = "{}\n"
+	}
+	if traits.Length() > 0 {
+		w.Emit("%s%s :=\n", IndentAmount, label)
+		w.EmitTraits(traits, i2)
+		w.Emit("%s%s%s", i2, strings.TrimPrefix(w.withMixins(shape.Mixins)+" ", " "), body)
+	} else {
+		w.Emit("%s%s :=%s %s", IndentAmount, label, w.withMixins(shape.Mixins), body)
+	}
+	if body == "{}\n" {
+		return
+	}
+	for i, k := range shape.Members.Keys() {
+		if i > 0 {
+			w.Emit("\n")
+		}
+		w.EmitMember(k, shape.Members.Get(k), i2, "")
+	}
+	w.emitClosingComments(i2)
+	w.Emit("%s}\n", IndentAmount)
+}
+
 func (w *IdlWriter) listOfShapeRefs(label string, format string, lst []*ShapeRef, absolute bool) string {
 	s := ""
 	if len(lst) > 0 {
-		s = label + ": ["
+		if label != "" {
+			s = label + ": "
+		}
+		s = s + "["
 		for n, a := range lst {
 			if n > 0 {
 				s = s + ", "
@@ -657,130 +1157,111 @@ func listOfStrings(label string, format string, lst []string) string {
 }
 
 func (w *IdlWriter) EmitServiceShape(name string, shape *Shape) {
-	comma := ""
-	if w.version < 2 {
-		comma = ","
-	}
+	w.beginShape(name, "")
 	w.EmitTraits(shape.Traits, "")
 	w.Emit("service %s%s {\n", name, w.withMixins(shape.Mixins))
-	w.Emit("    version: %q%s\n", shape.Version, comma)
+	w.emitProperty("version", fmt.Sprintf("%q", shape.Version), w.comma())
 	if len(shape.Operations) > 0 {
-		w.Emit("    %s\n", w.listOfShapeRefs("operations", "%s", shape.Operations, false))
+		w.emitProperty("operations", w.listOfShapeRefs("", "%s", shape.Operations, false), "")
 	}
 	if len(shape.Resources) > 0 {
-		w.Emit("    %s\n", w.listOfShapeRefs("resources", "%s", shape.Resources, false))
+		w.emitProperty("resources", w.listOfShapeRefs("", "%s", shape.Resources, false), "")
 	}
+	w.emitClosingComments(IndentAmount)
 	w.Emit("}\n")
 }
 
 func (w *IdlWriter) EmitResourceShape(name string, shape *Shape) {
 	w.EmitTraits(shape.Traits, "")
 	w.Emit("resource %s%s {\n", name, w.withMixins(shape.Mixins))
-	if len(shape.Identifiers) > 0 {
-		w.Emit("    identifiers: {\n")
-		for k, v := range shape.Identifiers {
-			w.Emit("        %s: %s,\n", k, w.stripNamespace(v.Target))
-		}
-		w.Emit("    }\n")
-		if shape.Create != nil {
-			w.Emit("    create: %v\n", w.stripNamespace(shape.Create.Target))
-		}
-		if shape.Put != nil {
-			w.Emit("    put: %v\n", w.stripNamespace(shape.Put.Target))
-		}
-		if shape.Read != nil {
-			w.Emit("    read: %v\n", w.stripNamespace(shape.Read.Target))
-		}
-		if shape.Update != nil {
-			w.Emit("    update: %v\n", w.stripNamespace(shape.Update.Target))
-		}
-		if shape.Delete != nil {
-			w.Emit("    delete: %v\n", w.stripNamespace(shape.Delete.Target))
-		}
-		if shape.List != nil {
-			w.Emit("    list: %v\n", w.stripNamespace(shape.List.Target))
-		}
-		if len(shape.Operations) > 0 {
-			var tmp []*ShapeRef
-			for _, id := range shape.Operations {
-				tmp = append(tmp, &ShapeRef{Target: w.stripNamespace(id.Target)})
-			}
-			w.Emit("    %s\n", w.listOfShapeRefs("operations", "%s", tmp, true))
-		}
-		if len(shape.CollectionOperations) > 0 {
-			w.Emit("    %s\n", w.listOfShapeRefs("collectionOperations", "%s", shape.CollectionOperations, true))
+	w.emitNamedShapeRefs("identifiers", shape.Identifiers)
+	w.emitNamedShapeRefs("properties", shape.Properties)
+	for _, op := range []struct {
+		name string
+		ref  *ShapeRef
+	}{{"create", shape.Create}, {"put", shape.Put}, {"read", shape.Read}, {"update", shape.Update}, {"delete", shape.Delete}, {"list", shape.List}} {
+		if op.ref != nil {
+			w.emitProperty(op.name, w.stripNamespace(op.ref.Target), "")
 		}
 	}
+	if len(shape.Operations) > 0 {
+		w.emitProperty("operations", w.listOfShapeRefs("", "%s", shape.Operations, false), "")
+	}
+	if len(shape.CollectionOperations) > 0 {
+		w.emitProperty("collectionOperations", w.listOfShapeRefs("", "%s", shape.CollectionOperations, false), "")
+	}
+	if len(shape.Resources) > 0 {
+		w.emitProperty("resources", w.listOfShapeRefs("", "%s", shape.Resources, false), "")
+	}
+	w.emitClosingComments(IndentAmount)
 	w.Emit("}\n")
 }
 
+func (w *IdlWriter) emitNamedShapeRefs(label string, refs map[string]*ShapeRef) {
+	if len(refs) > 0 {
+		var sb strings.Builder
+		sb.WriteString("{\n")
+		for _, k := range sortedIdentifierNames(refs) {
+			fmt.Fprintf(&sb, "        %s: %s%s\n", k, w.stripNamespace(refs[k].Target), w.comma())
+		}
+		sb.WriteString("    }")
+		w.emitProperty(label, sb.String(), "")
+	}
+}
+
 func (w *IdlWriter) EmitOperationShape(name string, shape *Shape, emitted map[string]bool) {
 	var inputShape, outputShape *Shape
-	var inputName, outputName string
+	var inputName, outputName, inputRef, outputRef string
 	var inputEmitted, outputEmitted bool
 	if shape.Input != nil {
-		inputName = w.stripNamespace(shape.Input.Target)
+		inputName = ShapeID(shape.Input.Target).Name()
+		inputRef = w.stripNamespace(shape.Input.Target)
 		inputShape = w.ast.GetShape(shape.Input.Target)
 	}
 	if shape.Output != nil {
-		outputName = w.stripNamespace(shape.Output.Target)
+		outputName = ShapeID(shape.Output.Target).Name()
+		outputRef = w.stripNamespace(shape.Output.Target)
 		outputShape = w.ast.GetShape(shape.Output.Target)
 	}
+	w.beginShape(name, "")
 	w.EmitTraits(shape.Traits, "")
 	w.Emit("operation %s%s {\n", name, w.withMixins(shape.Mixins))
 	if w.version == 2 {
 		if inputShape != nil {
-			if b := inputShape.Traits.Get("smithy.api#input"); b != nil {
-				inputTraits := "" //?
-				inputMixins := w.withMixins(inputShape.Mixins)
-				w.Emit("%sinput := %s%s{\n", IndentAmount, inputTraits, inputMixins)
-				i2 := IndentAmount + IndentAmount
-				for i, k := range inputShape.Members.Keys() {
-					if i > 0 {
-						w.Emit("\n")
-					}
-					v := inputShape.Members.Get(k)
-					w.EmitTraits(v.Traits, i2)
-					w.Emit("%s%s: %s\n", i2, k, w.stripNamespace(v.Target))
-				}
-				w.Emit("%s}\n", IndentAmount)
+			if inputShape.Traits.Has("smithy.api#input") && inputName == name+"Input" {
+				w.beginShape(inputName, IndentAmount)
+				w.EmitInlineStructure("input", "smithy.api#input", inputShape)
+				w.current = w.namespace + "#" + name
 				inputEmitted = true
 			} else {
-				w.Emit("%sinput: %s,\n", IndentAmount, w.stripNamespace(inputName))
+				w.emitProperty("input", inputRef, "")
 			}
 		}
-		if outputShape != nil { //probably should require the @output trait before inlining.
-			if b := outputShape.Traits.Get("smithy.api#output"); b != nil {
-				w.Emit("%soutput := {\n", IndentAmount)
-				i2 := IndentAmount + IndentAmount
-				for i, k := range outputShape.Members.Keys() {
-					if i > 0 {
-						w.Emit("\n")
-					}
-					v := outputShape.Members.Get(k)
-					w.EmitTraits(v.Traits, i2)
-					w.Emit("%s%s: %s\n", i2, k, w.stripNamespace(v.Target))
-				}
-				w.Emit("%s}\n", IndentAmount)
+		if outputShape != nil {
+			if outputShape.Traits.Has("smithy.api#output") && outputName == name+"Output" {
+				w.beginShape(outputName, IndentAmount)
+				w.EmitInlineStructure("output", "smithy.api#output", outputShape)
+				w.current = w.namespace + "#" + name
 				outputEmitted = true
 			} else {
-				w.Emit("%soutput: %s,\n", IndentAmount, w.stripNamespace(outputName))
+				w.emitProperty("output", outputRef, "")
 			}
 		}
 		if len(shape.Errors) > 0 {
-			w.Emit("    %s\n", w.listOfShapeRefs("errors", "%s", shape.Errors, false))
+			w.emitProperty("errors", w.listOfShapeRefs("", "%s", shape.Errors, false), "")
 		}
 	} else {
 		if shape.Input != nil {
-			w.Emit("    input: %s,\n", inputName)
+			w.emitProperty("input", inputRef, ",")
 		}
 		if shape.Output != nil {
-			w.Emit("    output: %s,\n", outputName)
+			w.emitProperty("output", outputRef, ",")
 		}
 		if len(shape.Errors) > 0 {
-			w.Emit("    %s,\n", w.listOfShapeRefs("errors", "%s", shape.Errors, false))
+			w.emitProperty("errors", w.listOfShapeRefs("", "%s", shape.Errors, false), ",")
 		}
 	}
+	w.emitClosingComments(IndentAmount)
 	w.Emit("}\n")
 	emitted[name] = true
 	if inputShape != nil {
@@ -797,7 +1278,7 @@ func (w *IdlWriter) EmitOperationShape(name string, shape *Shape, emitted map[st
 	}
 }
 
-func (w *IdlWriter) End() string {
-	w.writer.Flush()
-	return w.buf.String()
+// End flushes the output, returning the first error there was in writing it.
+func (w *IdlWriter) End() error {
+	return w.writer.Flush()
 }
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"testing"
+)
+
+// the enum shapes that the IDL writer must write so that they parse back to the same model
+var enumRoundTrips = []struct {
+	name string
+	idl  string
+}{
+	{"enum", `$version: "2"
+namespace test
+enum Color {
+    RED
+    GREEN
+}
+`},
+	{"enum with values", `$version: "2"
+namespace test
+/// The suits
+enum Suit {
+    /// The first
+    CLUBS = "clubs"
+    @deprecated
+    DIAMONDS = "diamonds"
+}
+`},
+	{"enum from @enum trait", `$version: "1.0"
+namespace test
+@enum([{value: "a-b"}, {value: "c d", documentation: "spaced"}])
+string Dashed
+`},
+	{"intEnum", `$version: "2"
+namespace test
+intEnum Level {
+    LOW = 1
+    HIGH = 10
+}
+`},
+	{"intEnum with traits", `$version: "2"
+namespace test
+/// The priorities
+@tags(["a"])
+intEnum Priority {
+    /// Not urgent
+    LOW = 0
+    @deprecated
+    MEDIUM = 5
+    HIGH = -1
+}
+`},
+}
+
+func TestEnumRoundTrip(t *testing.T) {
+	for _, tc := range enumRoundTrips {
+		t.Run(tc.name, func(t *testing.T) {
+			ast, err := ParseString(tc.idl, "test.smithy")
+			if err != nil {
+				t.Fatalf("Cannot parse: %v", err)
+			}
+			idl := ast.IDL("test")
+			parsed, err := ParseString(idl, "test.smithy")
+			if err != nil {
+				t.Fatalf("Cannot parse the generated IDL: %v\n%s", err, idl)
+			}
+			if !ast.Equal(parsed) {
+				t.Errorf("The generated IDL does not parse to the same model:\n%s", idl)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boynton/data"
+)
+
+// the prelude types that have a "Primitive" form in IDL 1.0, which is not nullable
+var primitiveTypes = []string{"Boolean", "Byte", "Short", "Integer", "Long", "Float", "Double"}
+
+// zeroValue returns the default value that a non-nullable shape of the type has in IDL 1.0, or nil if it has none.
+func zeroValue(shapeType string) interface{} {
+	switch shapeType {
+	case "boolean":
+		return false
+	case "byte", "short", "integer", "long", "float", "double":
+		return 0
+	}
+	return nil
+}
+
+// WithVersion returns a copy of the model converted to the IDL version (1 or 2), with DowngradeToV1 or UpgradeToV2.
+// If the model is already that version, it is returned unchanged.
+func (ast *AST) WithVersion(version int) (*AST, error) {
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("Unsupported target version: %d", version)
+	}
+	if ast.AssemblyVersion() == version {
+		return ast, nil
+	}
+	converted := ast.Clone()
+	if version == 1 {
+		converted.DowngradeToV1()
+	} else {
+		converted.UpgradeToV2()
+	}
+	return converted, nil
+}
+
+// UpgradeToV2 changes an IDL 1.0 model in place to the 2.0 semantics: strings with the @enum trait become enum shapes,
+// and sets become lists with @uniqueItems. Members that target the Primitive prelude types target the boxed types
+// with a @default of zero (or false), as do members that target other shapes that are not @box, which get the
+// @default themselves. A member with @box that targets a shape with a default gets a @default of null. The @box trait
+// is removed.
+func (ast *AST) UpgradeToV2() {
+	if ast.Shapes == nil {
+		ast.Smithy = "2.0"
+		return
+	}
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		if shape.Type == "string" && shape.Traits.Has("smithy.api#enum") {
+			//a bad @enum trait is left for validation to report
+			if enum, err := enumShape(shape.Type, shape.Traits); err == nil {
+				ast.Shapes.Put(id, enum)
+			}
+		} else if shape.Type == "set" {
+			shape.Type = "list"
+			shape.Traits = withTrait(shape.Traits, "smithy.api#uniqueItems", data.NewObject())
+		} else if zero := zeroValue(shape.Type); zero != nil {
+			if !shape.Traits.Has("smithy.api#box") && !shape.Traits.Has("smithy.api#default") {
+				shape.Traits = withTrait(shape.Traits, "smithy.api#default", zero)
+			}
+		}
+	}
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		if shape.Type == "enum" || shape.Type == "intEnum" {
+			continue
+		}
+		for _, mid := range memberIds(id, shape) {
+			mem := ast.getMember(mid)
+			boxed := mem.Traits.Has("smithy.api#box")
+			name := strings.TrimPrefix(mem.Target, "smithy.api#Primitive")
+			if name != mem.Target && containsString(primitiveTypes, name) {
+				mem.Target = "smithy.api#" + name
+				if !mem.Traits.Has("smithy.api#default") {
+					mem.Traits = withTrait(mem.Traits, "smithy.api#default", zeroValue(Uncapitalize(name)))
+				}
+			} else if target := ast.GetShape(mem.Target); target != nil && target.Traits.Has("smithy.api#default") {
+				if !mem.Traits.Has("smithy.api#default") && shape.Type == "structure" {
+					if boxed {
+						mem.Traits = withDefaultTrait(mem.Traits, nil)
+					} else {
+						mem.Traits = withTrait(mem.Traits, "smithy.api#default", target.Traits.Get("smithy.api#default"))
+					}
+				}
+			}
+			mem.Traits = withoutTrait(mem.Traits, "smithy.api#box")
+		}
+		shape.Traits = withoutTrait(shape.Traits, "smithy.api#box")
+	}
+	ast.Smithy = "2.0"
+	ast.ResetIndex()
+}
+
+// DowngradeToV1 changes an IDL 2.0 model in place to the 1.0 semantics: mixins are flattened, enum shapes become
+// strings with the @enum trait, and intEnum shapes become integers (the values of which cannot be expressed in 1.0).
+// A member with a @default of zero (or false) that targets a boxed prelude type targets its Primitive type instead,
+// and one with a @default of null gets @box. Shapes that can have a zero value but have no @default get @box, and the
+// @default, @clientOptional and @addedDefault traits, which 1.0 does not have, are removed. Lists with @uniqueItems
+// become sets.
+func (ast *AST) DowngradeToV1() {
+	ast.FlattenMixins()
+	if ast.Shapes == nil {
+		ast.Smithy = "1.0"
+		return
+	}
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		switch shape.Type {
+		case "enum":
+			var items []interface{}
+			for _, k := range shape.Members.Keys() {
+				mem := shape.Members.Get(k)
+				item := data.NewObject()
+				value := k
+				if mem.Traits.Has("smithy.api#enumValue") {
+					value = data.AsString(mem.Traits.Get("smithy.api#enumValue"))
+				}
+				item.Put("value", value)
+				item.Put("name", k)
+				for _, tk := range []string{"documentation", "tags", "deprecated"} {
+					if v := mem.Traits.Get("smithy.api#" + tk); v != nil {
+						item.Put(tk, v)
+					}
+				}
+				items = append(items, item)
+			}
+			shape.Type = "string"
+			shape.Members = nil
+			shape.Traits = withTrait(shape.Traits, "smithy.api#enum", items)
+		case "intEnum":
+			shape.Type = "integer"
+			shape.Members = nil
+		case "list":
+			if shape.Traits.Has("smithy.api#uniqueItems") {
+				shape.Type = "set"
+				shape.Traits = withoutTrait(shape.Traits, "smithy.api#uniqueItems")
+			}
+		}
+	}
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		for _, mid := range memberIds(id, shape) {
+			mem := ast.getMember(mid)
+			if mem.Traits.Has("smithy.api#default") {
+				name := strings.TrimPrefix(mem.Target, "smithy.api#")
+				def := mem.Traits.Get("smithy.api#default")
+				if def == nil {
+					mem.Traits = withTrait(mem.Traits, "smithy.api#box", data.NewObject())
+				} else if containsString(primitiveTypes, name) && nodeEqual(def, zeroValue(Uncapitalize(name))) {
+					mem.Target = "smithy.api#Primitive" + name
+				}
+			}
+			mem.Traits = withoutV2Traits(mem.Traits)
+		}
+		if zeroValue(shape.Type) != nil && !shape.Traits.Has("smithy.api#default") {
+			shape.Traits = withTrait(shape.Traits, "smithy.api#box", data.NewObject())
+		}
+		shape.Traits = withoutV2Traits(shape.Traits)
+	}
+	ast.Smithy = "1.0"
+	ast.ResetIndex()
+}
+
+func withoutV2Traits(traits *data.Object) *data.Object {
+	for _, k := range []string{"smithy.api#default", "smithy.api#clientOptional", "smithy.api#addedDefault"} {
+		traits = withoutTrait(traits, k)
+	}
+	return traits
+}
+
+// withoutTrait returns the traits without the one with the id, or nil if there are none left.
+func withoutTrait(traits *data.Object, id string) *data.Object {
+	if !traits.Has(id) {
+		return traits
+	}
+	var result *data.Object
+	for _, k := range traits.Keys() {
+		if k != id {
+			if result == nil {
+				result = data.NewObject()
+			}
+			result.Put(k, traits.Get(k))
+		}
+	}
+	return result
+}
+
+// enumShape returns the enum shape equivalent to a string (or integer) shape with the IDL 1.0 @enum trait. The name
+// of each enum definition is the name of its member, or its value if it has none. The documentation, tags and
+// deprecation of each definition become traits of its member. An error is returned if a definition is not an object,
+// or does not have a value of the type of the shape.
+func enumShape(typeName string, traits *data.Object) (*Shape, error) {
+	var tr *data.Object
+	for _, k := range traits.Keys() {
+		if k != "smithy.api#enum" {
+			tr = withTrait(tr, k, traits.Get(k))
+		}
+	}
+	enumShapeName := "enum"
+	if typeName == "integer" {
+		enumShapeName = "intEnum"
+	}
+	shape := &Shape{
+		Type:   enumShapeName,
+		Traits: tr,
+	}
+	mems := NewMembers()
+	for i, e := range traits.GetArray("smithy.api#enum") {
+		var mtraits *data.Object
+		d, ok := traitObject(e)
+		if !ok {
+			return nil, fmt.Errorf("Bad @enum definition %d: not an object", i)
+		}
+		name := d.GetString("name") //optional
+		if enumShapeName == "intEnum" {
+			switch d.Get("value").(type) {
+			case *data.Decimal, float64, int, int32, int64:
+			default:
+				return nil, fmt.Errorf("Bad @enum definition %d: its value must be a number", i)
+			}
+			if name == "" {
+				return nil, fmt.Errorf("Bad @enum definition %d: the definitions of an integer must have names", i)
+			}
+			ivalue := d.GetInt("value")
+			mtraits = withTrait(mtraits, "smithy.api#enumValue", ivalue)
+		} else {
+			svalue := d.GetString("value")
+			if svalue == "" {
+				return nil, fmt.Errorf("Bad @enum definition %d: its value must be a non-empty string", i)
+			}
+			if name == "" {
+				name = svalue
+				svalue = ""
+			}
+			if svalue != "" {
+				mtraits = withTrait(mtraits, "smithy.api#enumValue", svalue)
+			}
+		}
+		for _, tk := range []string{"documentation", "tags", "deprecated"} {
+			if v := d.Get(tk); v != nil {
+				mtraits = withTrait(mtraits, "smithy.api#"+tk, v)
+			}
+		}
+		mems.Put(name, &Member{
+			Target: "smithy.api#Unit",
+			Traits: mtraits,
+		})
+	}
+	shape.Members = mems
+	return shape, nil
+}
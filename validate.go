@@ -0,0 +1,427 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/boynton/data"
+)
+
+// ValidationEvent is a problem found in a model by validation.
+type ValidationEvent struct {
+	Id                string //the name of the check that produced the event, i.e. "Target"
+	Severity          Severity
+	ShapeId           string //the shape or member the event is about, if any
+	Location          *SourceLocation
+	Message           string
+	SuppressionReason string //the reason given by the suppression, if the event has been suppressed
+}
+
+// NewValidationEvent returns an event about the shape or member with the id, located where it is defined in the model.
+func NewValidationEvent(ast *AST, checkId string, severity Severity, shapeId string, format string, args ...interface{}) *ValidationEvent {
+	return &ValidationEvent{
+		Id:       checkId,
+		Severity: severity,
+		ShapeId:  shapeId,
+		Location: ast.SourceLocation(shapeId),
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *ValidationEvent) String() string {
+	s := fmt.Sprintf("[%s] %s", e.Severity, e.Id)
+	if e.ShapeId != "" {
+		s = s + " " + e.ShapeId
+	}
+	if e.Location != nil {
+		s = s + " (" + e.Location.String() + ")"
+	}
+	s = s + ": " + e.Message
+	if e.SuppressionReason != "" {
+		s = s + " (" + e.SuppressionReason + ")"
+	}
+	return s
+}
+
+// failed returns true if the event should fail validation.
+func (e *ValidationEvent) failed() bool {
+	return e.Severity == SeverityError || e.Severity == SeverityDanger
+}
+
+// ValidationError is the error returned by Validate when there are ERROR or DANGER events.
+type ValidationError struct {
+	Events []*ValidationEvent
+}
+
+func (e *ValidationError) Error() string {
+	var lines []string
+	for _, ev := range e.Events {
+		if ev.failed() {
+			lines = append(lines, ev.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// A Validator checks a model, returning the problems it finds.
+type Validator interface {
+	Validate(ast *AST) []*ValidationEvent
+}
+
+// ValidatorFunc adapts a function to the Validator interface.
+type ValidatorFunc func(ast *AST) []*ValidationEvent
+
+func (f ValidatorFunc) Validate(ast *AST) []*ValidationEvent {
+	return f(ast)
+}
+
+var builtinValidators = []Validator{
+	ValidatorFunc(validateModel),
+	ValidatorFunc((*AST).traitValueEvents),
+	HttpBindingValidator,
+	ExamplesValidator,
+}
+
+var registeredValidators []Validator
+var validatorsLock sync.Mutex
+
+// RegisterValidator adds a validator that is run by Validate and ValidationEvents, in addition to the built in ones.
+func RegisterValidator(v Validator) {
+	validatorsLock.Lock()
+	defer validatorsLock.Unlock()
+	registeredValidators = append(registeredValidators, v)
+}
+
+// Validators returns the built in validators followed by the registered ones.
+func Validators() []Validator {
+	validatorsLock.Lock()
+	defer validatorsLock.Unlock()
+	result := append([]Validator{}, builtinValidators...)
+	return append(result, registeredValidators...)
+}
+
+// Validate checks the model, returning a *ValidationError if there are any ERROR or DANGER events that are not
+// suppressed. Events of lesser severity are added to the warnings of the AST.
+func (ast *AST) Validate() error {
+	events := ast.ValidationEvents()
+	failed := false
+	for _, ev := range events {
+		if ev.failed() {
+			failed = true
+		} else if ev.Severity != SeveritySuppressed {
+			ast.AddWarning(ev.Severity, ev.Location, ev.Id+": "+ev.Message)
+		}
+	}
+	if failed {
+		return &ValidationError{Events: events}
+	}
+	return nil
+}
+
+// ValidationEvents runs all of the validators on the model, returning the problems found. The built in validators
+// check for shape references that do not resolve, members that target the wrong kind of shape, invalid service,
+// resource and operation bindings, traits applied to shapes they cannot be applied to, trait values that do not
+// match their definitions, bad HTTP bindings, and examples that do not match their operations.
+//
+// Events matched by the "suppressions" metadata of the model have their severity changed to SUPPRESSED. As with
+// smithy-build, ERROR events cannot be suppressed.
+func (ast *AST) ValidationEvents() []*ValidationEvent {
+	var events []*ValidationEvent
+	for _, v := range Validators() {
+		events = append(events, v.Validate(ast)...)
+	}
+	return ast.applySuppressions(events)
+}
+
+// applySuppressions changes the severity of the events matched by the suppressions metadata, returning them followed
+// by any errors in the suppressions themselves.
+func (ast *AST) applySuppressions(events []*ValidationEvent) []*ValidationEvent {
+	suppressions, errs := ast.suppressions()
+	for _, ev := range events {
+		for _, sup := range suppressions {
+			if sup.matches(ev) {
+				ev.Severity = SeveritySuppressed
+				ev.SuppressionReason = sup.Reason
+				break
+			}
+		}
+	}
+	return append(events, errs...)
+}
+
+// Suppression is an entry in the "suppressions" metadata of a model, which suppresses the events with the id, or a
+// more specific id (i.e. "Foo" suppresses "Foo.Bar"), in the namespace. A namespace of "*" matches all events.
+type Suppression struct {
+	Id        string
+	Namespace string
+	Reason    string
+}
+
+func (s *Suppression) matches(ev *ValidationEvent) bool {
+	if ev.Severity == SeverityError || ev.Severity == SeveritySuppressed {
+		return false
+	}
+	if ev.Id != s.Id && !strings.HasPrefix(ev.Id, s.Id+".") {
+		return false
+	}
+	return s.Namespace == "*" || (ev.ShapeId != "" && shapeIdNamespace(ev.ShapeId) == s.Namespace)
+}
+
+func (ast *AST) suppressions() ([]*Suppression, []*ValidationEvent) {
+	if ast.Metadata == nil || !ast.Metadata.Has("suppressions") {
+		return nil, nil
+	}
+	var suppressions []*Suppression
+	var errs []*ValidationEvent
+	items := data.AsArray(ast.Metadata.Get("suppressions"))
+	if items == nil {
+		errs = append(errs, NewValidationEvent(ast, "Suppression", SeverityError, "", "The suppressions metadata must be an array"))
+	}
+	for i, item := range items {
+		m := data.AsMap(item)
+		sup := &Suppression{
+			Id:        data.AsString(m["id"]),
+			Namespace: data.AsString(m["namespace"]),
+			Reason:    data.AsString(m["reason"]),
+		}
+		if sup.Id == "" || sup.Namespace == "" {
+			errs = append(errs, NewValidationEvent(ast, "Suppression", SeverityError, "", "Suppression %d must have an id and a namespace", i))
+			continue
+		}
+		suppressions = append(suppressions, sup)
+	}
+	return suppressions, errs
+}
+
+func validateModel(ast *AST) []*ValidationEvent {
+	v := &modelValidator{ast: ast}
+	if ast.Shapes != nil {
+		for _, id := range ast.Shapes.Keys() {
+			v.checkShape(id, ast.GetShape(id))
+		}
+	}
+	for _, id := range ast.applied.Keys() {
+		v.event("Target", SeverityError, id, "Traits are applied to a shape or member that is not defined: %s", id)
+	}
+	return v.events
+}
+
+type modelValidator struct {
+	ast    *AST
+	events []*ValidationEvent
+}
+
+func (v *modelValidator) event(checkId string, severity Severity, shapeId string, format string, args ...interface{}) {
+	v.events = append(v.events, NewValidationEvent(v.ast, checkId, severity, shapeId, format, args...))
+}
+
+// shapeType returns the type of the shape with the id, or "" if it is not defined. Prelude shapes that are not
+// simple types are not checked, and have the type "prelude".
+func (v *modelValidator) shapeType(id string) string {
+	if shape := v.ast.nodeShape(id); shape != nil {
+		return shape.Type
+	}
+	if strings.HasPrefix(id, "smithy.api#") {
+		return "prelude"
+	}
+	return ""
+}
+
+// checkRef reports a reference whose target is not defined or is not one of the expected types.
+func (v *modelValidator) checkRef(shapeId string, what string, ref *ShapeRef, types ...string) {
+	if ref == nil {
+		return
+	}
+	t := v.shapeType(ref.Target)
+	if t == "" {
+		v.event("Target", SeverityError, shapeId, "%s targets a shape that is not defined: %s", what, ref.Target)
+	} else if len(types) > 0 && t != "prelude" && !containsString(types, t) {
+		v.event("Target", SeverityError, shapeId, "%s must target a shape of type %s, but %s has type %s", what, strings.Join(types, " or "), ref.Target, t)
+	}
+}
+
+func (v *modelValidator) checkRefs(shapeId string, what string, refs []*ShapeRef, types ...string) {
+	for _, ref := range refs {
+		v.checkRef(shapeId, what, ref, types...)
+	}
+}
+
+func (v *modelValidator) checkMember(shapeId string, mem *Member, types ...string) {
+	if mem == nil {
+		return
+	}
+	t := v.shapeType(mem.Target)
+	switch {
+	case t == "":
+		v.event("Target", SeverityError, shapeId, "Member targets a shape that is not defined: %s", mem.Target)
+	case t == "service" || t == "operation" || t == "resource":
+		v.event("Target", SeverityError, shapeId, "Member cannot target %s, which has type %s", mem.Target, t)
+	case len(types) > 0 && t != "prelude" && !containsString(types, t):
+		v.event("Target", SeverityError, shapeId, "Member must target a shape of type %s, but %s has type %s", strings.Join(types, " or "), mem.Target, t)
+	}
+	v.checkTraits(shapeId, "member", mem.Traits)
+}
+
+func (v *modelValidator) checkShape(id string, shape *Shape) {
+	v.checkRefs(id, "Mixin", shape.Mixins, shape.Type)
+	v.checkTraits(id, shape.Type, shape.Traits)
+	if shape.Traits.Has("smithy.api#enum") {
+		if _, err := enumShape(shape.Type, shape.Traits); err != nil {
+			v.event("Enum", SeverityError, id, "%v", err)
+		}
+	}
+	switch shape.Type {
+	case "structure", "union", "enum", "intEnum":
+		if shape.Members != nil {
+			for _, k := range shape.Members.Keys() {
+				if shape.Members.Get(k) == nil {
+					v.event("Target", SeverityError, id+"$"+k, "Member has no definition")
+					continue
+				}
+				v.checkMember(id+"$"+k, shape.Members.Get(k))
+			}
+		}
+		if shape.Type == "union" && shape.Members.Length() == 0 && !shape.Traits.Has("smithy.api#mixin") {
+			v.event("Union", SeverityError, id, "A union must have at least one member")
+		}
+	case "list", "set":
+		if shape.Member == nil {
+			v.event("Target", SeverityError, id, "A %s must have a member", shape.Type)
+		}
+		v.checkMember(id+"$member", shape.Member)
+	case "map":
+		if shape.Key == nil || shape.Value == nil {
+			v.event("Target", SeverityError, id, "A map must have a key and a value")
+		}
+		v.checkMember(id+"$key", shape.Key, "string", "enum")
+		v.checkMember(id+"$value", shape.Value)
+	case "service":
+		v.checkRefs(id, "Service operation", shape.Operations, "operation")
+		v.checkRefs(id, "Service resource", shape.Resources, "resource")
+	case "operation":
+		v.checkRef(id, "Operation input", shape.Input, "structure")
+		v.checkRef(id, "Operation output", shape.Output, "structure")
+		v.checkRefs(id, "Operation error", shape.Errors, "structure")
+		for _, ref := range shape.Errors {
+			if e := v.ast.GetShape(ref.Target); e != nil && e.Type == "structure" && !e.Traits.Has("smithy.api#error") {
+				v.event("Target", SeverityError, id, "Operation error %s does not have the @error trait", ref.Target)
+			}
+		}
+	case "resource":
+		for _, k := range sortedIdentifierNames(shape.Identifiers) {
+			v.checkRef(id, "Resource identifier "+k, shape.Identifiers[k], "string", "enum")
+		}
+		for _, k := range sortedIdentifierNames(shape.Properties) {
+			v.checkRef(id, "Resource property "+k, shape.Properties[k])
+		}
+		for _, ref := range []*ShapeRef{shape.Create, shape.Put, shape.Read, shape.Update, shape.Delete, shape.List} {
+			v.checkRef(id, "Resource lifecycle operation", ref, "operation")
+		}
+		v.checkRefs(id, "Resource operation", shape.Operations, "operation")
+		v.checkRefs(id, "Resource collection operation", shape.CollectionOperations, "operation")
+		v.checkRefs(id, "Resource resource", shape.Resources, "resource")
+		if shape.Read != nil {
+			if op := v.ast.GetShape(shape.Read.Target); op != nil && op.Type == "operation" && !op.Traits.Has("smithy.api#readonly") {
+				v.event("Resource", SeverityError, id, "The read operation %s must have the @readonly trait", shape.Read.Target)
+			}
+		}
+		if shape.Put != nil {
+			if op := v.ast.GetShape(shape.Put.Target); op != nil && op.Type == "operation" && !op.Traits.Has("smithy.api#idempotent") {
+				v.event("Resource", SeverityError, id, "The put operation %s must have the @idempotent trait", shape.Put.Target)
+			}
+		}
+	}
+}
+
+// traitTargets are the kinds of shapes the prelude traits can be applied to. Traits not listed are not checked.
+var traitTargets = map[string][]string{
+	"smithy.api#error":             {"structure"},
+	"smithy.api#httpError":         {"structure"},
+	"smithy.api#input":             {"structure"},
+	"smithy.api#output":            {"structure"},
+	"smithy.api#mixin":             {"structure", "union", "string", "blob", "boolean", "document", "list", "map", "timestamp", "byte", "short", "integer", "long", "float", "double", "bigInteger", "bigDecimal", "enum", "intEnum", "operation", "resource", "service"},
+	"smithy.api#http":              {"operation"},
+	"smithy.api#readonly":          {"operation"},
+	"smithy.api#idempotent":        {"operation"},
+	"smithy.api#paginated":         {"operation", "service"},
+	"smithy.api#examples":          {"operation"},
+	"smithy.api#required":          {"member"},
+	"smithy.api#httpLabel":         {"member"},
+	"smithy.api#httpQuery":         {"member"},
+	"smithy.api#httpQueryParams":   {"member"},
+	"smithy.api#httpHeader":        {"member"},
+	"smithy.api#httpPrefixHeaders": {"member"},
+	"smithy.api#httpPayload":       {"member"},
+	"smithy.api#httpResponseCode":  {"member"},
+	"smithy.api#enumValue":         {"member"},
+	"smithy.api#pattern":           {"string", "member"},
+	"smithy.api#length":            {"string", "blob", "list", "set", "map", "member"},
+	"smithy.api#range":             {"byte", "short", "integer", "long", "float", "double", "bigInteger", "bigDecimal", "intEnum", "member"},
+	"smithy.api#uniqueItems":       {"list", "member"},
+	"smithy.api#sparse":            {"list", "map"},
+}
+
+// CheckTraitsDefined returns a ValidationError if any trait applied in the model is neither a prelude trait, nor
+// defined with @trait in the model or the AWS trait definitions, i.e. a misspelled @requried. Validation only warns
+// of such traits, since they may be defined in a model that was not assembled with this one.
+func (ast *AST) CheckTraitsDefined() error {
+	var events []*ValidationEvent
+	check := func(id string, traits *data.Object) {
+		for _, tid := range traits.Keys() {
+			defined := true
+			switch shapeIdNamespace(tid) {
+			case "smithy.api":
+				defined = IsPreludeTrait(ShapeID(tid).Name())
+			case "smithy.test":
+			default:
+				defs, _ := ast.traitDefinition(tid)
+				defined = defs != nil
+			}
+			if !defined {
+				events = append(events, NewValidationEvent(ast, "UnknownTrait", SeverityError, id, "The trait %s is not defined", tid))
+			}
+		}
+	}
+	for _, id := range ast.Shapes.Keys() {
+		shape := ast.GetShape(id)
+		check(id, shape.Traits)
+		for _, mid := range memberIds(id, shape) {
+			check(mid, ast.getMember(mid).Traits)
+		}
+	}
+	if len(events) > 0 {
+		return &ValidationError{Events: events}
+	}
+	return nil
+}
+
+func (v *modelValidator) checkTraits(id string, shapeType string, traits *data.Object) {
+	for _, tid := range traits.Keys() {
+		if targets, ok := traitTargets[tid]; ok && !containsString(targets, shapeType) {
+			v.event("TraitTarget", SeverityError, id, "The @%s trait cannot be applied to a %s", StripNamespace(tid), shapeType)
+		} else if !strings.HasPrefix(tid, "smithy.api#") && !strings.HasPrefix(tid, "smithy.test#") {
+			if defs, _ := v.ast.traitDefinition(tid); defs == nil {
+				v.event("UnknownTrait", SeverityWarning, id, "The trait %s is not defined", tid)
+			}
+		}
+	}
+	if traits.Has("smithy.api#httpError") && !traits.Has("smithy.api#error") {
+		v.event("TraitTarget", SeverityError, id, "The @httpError trait requires the @error trait")
+	}
+}
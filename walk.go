@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"errors"
+)
+
+// SkipShape can be returned by the PreShape method of a ShapeVisitor to skip the rest of the shape, including its
+// members. PostShape is still called for it.
+var SkipShape = errors.New("skip this shape")
+
+// ShapeVisitor receives callbacks from Walk. Embed BaseShapeVisitor to implement only the methods that are needed.
+type ShapeVisitor interface {
+	PreShape(id string, shape *Shape) error
+	PostShape(id string, shape *Shape) error
+	VisitSimple(id string, shape *Shape) error //blob, boolean, document, string, timestamp, and the number types
+	VisitEnum(id string, shape *Shape) error   //enum and intEnum
+	VisitStructure(id string, shape *Shape) error
+	VisitUnion(id string, shape *Shape) error
+	VisitList(id string, shape *Shape) error //list and set
+	VisitMap(id string, shape *Shape) error
+	VisitService(id string, shape *Shape) error
+	VisitOperation(id string, shape *Shape) error
+	VisitResource(id string, shape *Shape) error
+	VisitMember(shapeId string, name string, member *Member) error
+}
+
+// BaseShapeVisitor implements every ShapeVisitor method to do nothing.
+type BaseShapeVisitor struct {
+}
+
+func (v *BaseShapeVisitor) PreShape(id string, shape *Shape) error                   { return nil }
+func (v *BaseShapeVisitor) PostShape(id string, shape *Shape) error                  { return nil }
+func (v *BaseShapeVisitor) VisitSimple(id string, shape *Shape) error                { return nil }
+func (v *BaseShapeVisitor) VisitEnum(id string, shape *Shape) error                  { return nil }
+func (v *BaseShapeVisitor) VisitStructure(id string, shape *Shape) error             { return nil }
+func (v *BaseShapeVisitor) VisitUnion(id string, shape *Shape) error                 { return nil }
+func (v *BaseShapeVisitor) VisitList(id string, shape *Shape) error                  { return nil }
+func (v *BaseShapeVisitor) VisitMap(id string, shape *Shape) error                   { return nil }
+func (v *BaseShapeVisitor) VisitService(id string, shape *Shape) error               { return nil }
+func (v *BaseShapeVisitor) VisitOperation(id string, shape *Shape) error             { return nil }
+func (v *BaseShapeVisitor) VisitResource(id string, shape *Shape) error              { return nil }
+func (v *BaseShapeVisitor) VisitMember(shapeId string, name string, m *Member) error { return nil }
+
+// Walk calls the visitor for every shape in the model, in order. For each shape, PreShape is called, then the Visit
+// method for its type, then VisitMember for each of its members (the "member" of a list, the "key" and "value" of a
+// map), then PostShape. Walk stops at the first error returned by the visitor, and returns it.
+func (ast *AST) Walk(visitor ShapeVisitor) error {
+	if ast.Shapes == nil {
+		return nil
+	}
+	for _, id := range ast.Shapes.Keys() {
+		err := ast.WalkShape(id, ast.GetShape(id), visitor)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkShape calls the visitor for the one shape, as Walk does.
+func (ast *AST) WalkShape(id string, shape *Shape, visitor ShapeVisitor) error {
+	err := visitor.PreShape(id, shape)
+	if err == SkipShape {
+		return visitor.PostShape(id, shape)
+	}
+	if err != nil {
+		return err
+	}
+	switch shape.Type {
+	case "enum", "intEnum":
+		err = visitor.VisitEnum(id, shape)
+	case "structure":
+		err = visitor.VisitStructure(id, shape)
+	case "union":
+		err = visitor.VisitUnion(id, shape)
+	case "list", "set":
+		err = visitor.VisitList(id, shape)
+	case "map":
+		err = visitor.VisitMap(id, shape)
+	case "service":
+		err = visitor.VisitService(id, shape)
+	case "operation":
+		err = visitor.VisitOperation(id, shape)
+	case "resource":
+		err = visitor.VisitResource(id, shape)
+	default:
+		err = visitor.VisitSimple(id, shape)
+	}
+	if err != nil {
+		return err
+	}
+	if shape.Members != nil {
+		for _, k := range shape.Members.Keys() {
+			mem := shape.Members.Get(k)
+			if mem == nil {
+				//a member without a definition, in a malformed model, which validation reports
+				continue
+			}
+			err = visitor.VisitMember(id, k, mem)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, mem := range []*Member{shape.Member, shape.Key, shape.Value} {
+		if mem != nil {
+			err = visitor.VisitMember(id, []string{"member", "key", "value"}[i], mem)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return visitor.PostShape(id, shape)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"fmt"
+)
+
+type Severity string
+
+const (
+	SeverityNote    Severity = "NOTE"
+	SeverityWarning Severity = "WARNING"
+	SeverityDanger  Severity = "DANGER"
+	SeverityError   Severity = "ERROR"
+
+	//SeveritySuppressed is the severity of a validation event that has been suppressed by the model
+	SeveritySuppressed Severity = "SUPPRESSED"
+)
+
+// Warning is a problem found in a model that does not prevent it from being used.
+type Warning struct {
+	Severity Severity
+	Location *SourceLocation //nil if not known
+	Message  string
+}
+
+func (w *Warning) String() string {
+	if w.Location != nil {
+		return fmt.Sprintf("[%s] %s: %s", w.Severity, w.Location, w.Message)
+	}
+	return fmt.Sprintf("[%s] %s", w.Severity, w.Message)
+}
+
+// Warnings returns the warnings collected while parsing and assembling the model.
+func (ast *AST) Warnings() []*Warning {
+	return ast.warnings
+}
+
+// AddWarning records a warning about the model.
+func (ast *AST) AddWarning(severity Severity, loc *SourceLocation, msg string) {
+	ast.warnings = append(ast.warnings, &Warning{Severity: severity, Location: loc, Message: msg})
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/boynton/data"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYaml decodes YAML (or JSON, which is a subset) into the same representation that JSON decoding produces,
+// except that objects are *data.Object, to preserve the order of their keys, and numbers are *data.Decimal, to keep
+// their digits.
+func decodeYaml(b []byte) (interface{}, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal(b, &node)
+	if err != nil {
+		return nil, err
+	}
+	return yamlNodeValue(&node)
+}
+
+// yamlNumberPattern matches the numbers of YAML and JSON. YAML reads a plain number as a string if it is out of the
+// range of a float64, i.e. 1e400, so yamlNodeValue uses this to read it as a number anyway.
+var yamlNumberPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+func yamlNodeValue(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlNodeValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeValue(node.Alias)
+	case yaml.MappingNode:
+		obj := data.NewObject()
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := yamlNodeValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			obj.Put(node.Content[i].Value, v)
+		}
+		return obj, nil
+	case yaml.SequenceNode:
+		lst := make([]interface{}, 0, len(node.Content))
+		for _, n := range node.Content {
+			v, err := yamlNodeValue(n)
+			if err != nil {
+				return nil, err
+			}
+			lst = append(lst, v)
+		}
+		return lst, nil
+	}
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err := node.Decode(&b)
+		return b, err
+	case "!!int", "!!float":
+		return data.ParseDecimal(node.Value)
+	case "!!str":
+		if node.Style == 0 && yamlNumberPattern.MatchString(node.Value) {
+			return data.ParseDecimal(node.Value)
+		}
+		return node.Value, nil
+	default:
+		return node.Value, nil
+	}
+}
+
+// hasTopLevelKey returns true if the YAML or JSON document is an object with one of the keys. JSON is scanned token
+// by token, skipping the values, and only the top level of the YAML node tree is examined, without decoding it.
+func hasTopLevelKey(b []byte, keys ...string) bool {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		return jsonHasTopLevelKey(b, keys)
+	}
+	var node yaml.Node
+	err := yaml.Unmarshal(b, &node)
+	if err != nil || len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return false
+	}
+	top := node.Content[0].Content
+	for i := 0; i+1 < len(top); i += 2 {
+		for _, key := range keys {
+			if top[i].Value == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonHasTopLevelKey(b []byte, keys []string) bool {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil || tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return false
+		}
+		for _, key := range keys {
+			if tok == key {
+				return true
+			}
+		}
+		var skip json.RawMessage
+		err = dec.Decode(&skip)
+		if err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// yamlToJson converts a YAML document to JSON, preserving the order of keys.
+func yamlToJson(b []byte) ([]byte, error) {
+	raw, err := decodeYaml(b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// ToYaml formats the value as YAML, by way of its JSON representation, so that the key order of ordered objects
+// like *data.Object and the AST's Shapes and Members is preserved.
+func ToYaml(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	raw, err := decodeYaml(b)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err = enc.Encode(yamlNode(raw))
+	if err != nil {
+		return "", err
+	}
+	err = enc.Close()
+	return buf.String(), err
+}
+
+func yamlNode(v interface{}) *yaml.Node {
+	switch o := v.(type) {
+	case *data.Object:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range o.Keys() {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, yamlNode(o.Get(k)))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range o {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case string:
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: o}
+		if strings.Contains(o, "\n") {
+			node.Style = yaml.LiteralStyle
+		} else if yamlNumberPattern.MatchString(o) {
+			node.Style = yaml.DoubleQuotedStyle //so that yamlNodeValue does not read it as a number
+		}
+		return node
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(o)}
+	case *data.Decimal:
+		tag := "!!float"
+		if o.IsInt() {
+			tag = "!!int"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: o.String()}
+	case float64:
+		tag := "!!float"
+		if o == float64(int64(o)) {
+			tag = "!!int"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: strconv.FormatFloat(o, 'f', -1, 64)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+}
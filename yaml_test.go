@@ -0,0 +1,63 @@
+/*
+Copyright 2021 Lee R. Boynton
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package smithy
+
+import (
+	"testing"
+)
+
+// trait values that the YAML AST must read back as they were written
+var yamlRoundTrips = []struct {
+	name  string
+	value string
+}{
+	{"integer", `12`},
+	{"decimal", `0.1`},
+	{"big integer", `12345678901234567890123`},
+	{"huge exponent", `1e400`},
+	{"huge negative exponent", `-2.5e+400`},
+	{"tiny exponent", `1.5e-400`},
+	{"number string", `"12"`},
+	{"exponent string", `"1e400"`},
+	{"multiline string", `"a\nb"`},
+	{"boolean string", `"true"`},
+	{"null string", `"null"`},
+	{"list", `[1e400, "1e400", true, null]`},
+	{"object", `{"min": 0, "max": 1e400}`},
+}
+
+func TestYamlRoundTrip(t *testing.T) {
+	for _, tc := range yamlRoundTrips {
+		t.Run(tc.name, func(t *testing.T) {
+			src := `{"smithy": "2", "shapes": {"test#T": {"type": "string", "traits": {"test#value": ` + tc.value + `}}}}`
+			ast, err := LoadASTBytes([]byte(src))
+			if err != nil {
+				t.Fatalf("Cannot load the AST: %v", err)
+			}
+			text, err := ToYaml(ast)
+			if err != nil {
+				t.Fatalf("Cannot write the YAML: %v", err)
+			}
+			parsed, err := loadAST("test.yaml", []byte(text))
+			if err != nil {
+				t.Fatalf("Cannot load the YAML: %v\n%s", err, text)
+			}
+			if !ast.Equal(parsed) {
+				t.Errorf("The YAML does not load as the same model:\n%s", text)
+			}
+		})
+	}
+}